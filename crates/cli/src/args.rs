@@ -0,0 +1,719 @@
+use clap::{Args, ValueEnum};
+use mccabre_core::cache::Cache;
+use mccabre_core::cloner::{CloneStrategy, Granularity, NormalizeMode};
+use mccabre_core::complexity::Severity;
+use mccabre_core::config::{self, Config};
+use mccabre_core::diff;
+use mccabre_core::loader::{FileLoader, SourceFile, download_module};
+use mccabre_core::policy::FailurePolicy;
+use mccabre_core::reporter::{MARKDOWN_BUDGET, SortOrder};
+use std::io;
+use std::path::PathBuf;
+use std::time::SystemTime;
+
+/// Report output format
+#[derive(ValueEnum, Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub enum OutputFormat {
+    /// Human-readable terminal output
+    #[default]
+    Text,
+    /// JSON report
+    Json,
+    /// SARIF 2.1.0 for code scanning integrations
+    Sarif,
+    /// Self-contained HTML page
+    Html,
+    /// GitHub Actions annotations, followed by the text report
+    Github,
+    /// JUnit XML for CI test-report integrations
+    Junit,
+    /// CSV tables for spreadsheets
+    Csv,
+    /// Markdown summary for pull request comments
+    Markdown,
+}
+
+impl From<config::OutputFormat> for OutputFormat {
+    fn from(format: config::OutputFormat) -> Self {
+        match format {
+            config::OutputFormat::Text => OutputFormat::Text,
+            config::OutputFormat::Json => OutputFormat::Json,
+            config::OutputFormat::Sarif => OutputFormat::Sarif,
+            config::OutputFormat::Html => OutputFormat::Html,
+            config::OutputFormat::Github => OutputFormat::Github,
+            config::OutputFormat::Junit => OutputFormat::Junit,
+            config::OutputFormat::Csv => OutputFormat::Csv,
+            config::OutputFormat::Markdown => OutputFormat::Markdown,
+        }
+    }
+}
+
+/// Arguments for `analyze`
+#[derive(Args, Debug, Clone)]
+pub struct AnalyzeArgs {
+    /// Files, directories, `dir/...` patterns, or Go import paths to analyze
+    #[arg(value_name = "PATH", default_value = ".")]
+    pub paths: Vec<PathBuf>,
+
+    #[command(flatten)]
+    pub output: OutputArgs,
+
+    #[command(flatten)]
+    pub input: InputArgs,
+
+    /// Complexity threshold for warnings
+    #[arg(long)]
+    pub threshold: Option<usize>,
+
+    /// Flag functions with block nesting deeper than N
+    #[arg(long, value_name = "N")]
+    pub max_nesting: Option<usize>,
+
+    /// Flag functions whose body spans more than N lines
+    #[arg(long, value_name = "N")]
+    pub max_func_lines: Option<usize>,
+
+    /// Flag functions whose body has more than N statements
+    #[arg(long, value_name = "N")]
+    pub max_func_stmts: Option<usize>,
+
+    /// Flag functions that take more than N parameters
+    #[arg(long, value_name = "N")]
+    pub max_params: Option<usize>,
+
+    /// Order functions in text and JSON output
+    #[arg(long, value_enum, default_value_t = SortBy::Complexity)]
+    pub sort: SortBy,
+
+    /// Only list functions with cyclomatic complexity of at least N; exit codes still see all
+    #[arg(long, value_name = "N", default_value_t = 1)]
+    pub min_complexity: usize,
+
+    /// List the decision points behind each function over a threshold, with their lines
+    #[arg(long)]
+    pub explain: bool,
+
+    #[command(flatten)]
+    pub clone_args: CloneArgs,
+
+    #[command(flatten)]
+    pub fail_args: FailArgs,
+
+    /// Path to config file
+    #[arg(short, long)]
+    pub config: Option<PathBuf>,
+
+    #[command(flatten)]
+    pub file_args: FileArgs,
+
+    /// Worker threads for reading and analyzing files (default: available CPUs)
+    #[arg(long, value_name = "N")]
+    pub jobs: Option<usize>,
+
+    #[command(flatten)]
+    pub cache_args: CacheArgs,
+
+    /// Disable syntax highlighting for clone code blocks
+    #[arg(long)]
+    pub no_highlight: bool,
+
+    /// Print a diff from each clone group's first instance to every other instance
+    #[arg(long)]
+    pub show_diff: bool,
+
+    /// Print per-package and repository totals instead of the full report
+    #[arg(long)]
+    pub summary: bool,
+}
+
+/// Arguments for `complexity`
+#[derive(Args, Debug, Clone)]
+pub struct ComplexityArgs {
+    /// Files, directories, `dir/...` patterns, or Go import paths to analyze
+    #[arg(value_name = "PATH", default_value = ".")]
+    pub paths: Vec<PathBuf>,
+
+    #[command(flatten)]
+    pub output: OutputArgs,
+
+    #[command(flatten)]
+    pub input: InputArgs,
+
+    /// Complexity threshold for warnings
+    #[arg(long)]
+    pub threshold: Option<usize>,
+
+    /// Flag functions with block nesting deeper than N
+    #[arg(long, value_name = "N")]
+    pub max_nesting: Option<usize>,
+
+    /// Flag functions whose body spans more than N lines
+    #[arg(long, value_name = "N")]
+    pub max_func_lines: Option<usize>,
+
+    /// Flag functions whose body has more than N statements
+    #[arg(long, value_name = "N")]
+    pub max_func_stmts: Option<usize>,
+
+    /// Flag functions that take more than N parameters
+    #[arg(long, value_name = "N")]
+    pub max_params: Option<usize>,
+
+    /// Order functions in text and JSON output
+    #[arg(long, value_enum, default_value_t = SortBy::Complexity)]
+    pub sort: SortBy,
+
+    /// Only list functions with cyclomatic complexity of at least N; exit codes still see all
+    #[arg(long, value_name = "N", default_value_t = 1)]
+    pub min_complexity: usize,
+
+    /// List the decision points behind each function over a threshold, with their lines
+    #[arg(long)]
+    pub explain: bool,
+
+    #[command(flatten)]
+    pub fail_args: FailArgs,
+
+    /// Path to config file
+    #[arg(short, long)]
+    pub config: Option<PathBuf>,
+
+    #[command(flatten)]
+    pub file_args: FileArgs,
+
+    /// Worker threads for reading and analyzing files (default: available CPUs)
+    #[arg(long, value_name = "N")]
+    pub jobs: Option<usize>,
+
+    #[command(flatten)]
+    pub cache_args: CacheArgs,
+}
+
+/// Arguments for `watch`
+#[derive(Args, Debug, Clone)]
+pub struct WatchArgs {
+    /// File, directory, or `dir/...` pattern to watch
+    #[arg(value_name = "PATH", default_value = ".")]
+    pub path: PathBuf,
+
+    /// Complexity threshold for warnings
+    #[arg(long)]
+    pub threshold: Option<usize>,
+
+    /// Flag functions with block nesting deeper than N
+    #[arg(long, value_name = "N")]
+    pub max_nesting: Option<usize>,
+
+    /// Flag functions whose body spans more than N lines
+    #[arg(long, value_name = "N")]
+    pub max_func_lines: Option<usize>,
+
+    /// Flag functions whose body has more than N statements
+    #[arg(long, value_name = "N")]
+    pub max_func_stmts: Option<usize>,
+
+    /// Flag functions that take more than N parameters
+    #[arg(long, value_name = "N")]
+    pub max_params: Option<usize>,
+
+    #[command(flatten)]
+    pub clone_args: CloneArgs,
+
+    /// Quiet period after a change before re-running, in milliseconds
+    #[arg(long, value_name = "MS", default_value_t = 200)]
+    pub debounce: u64,
+
+    /// Path to config file
+    #[arg(short, long)]
+    pub config: Option<PathBuf>,
+
+    #[command(flatten)]
+    pub file_args: FileArgs,
+
+    /// Worker threads for reading and analyzing files (default: available CPUs)
+    #[arg(long, value_name = "N")]
+    pub jobs: Option<usize>,
+
+    #[command(flatten)]
+    pub cache_args: CacheArgs,
+}
+
+/// Arguments for `clones`
+#[derive(Args, Debug, Clone)]
+pub struct ClonesArgs {
+    /// Files, directories, `dir/...` patterns, or Go import paths to analyze
+    #[arg(value_name = "PATH", default_value = ".")]
+    pub paths: Vec<PathBuf>,
+
+    #[command(flatten)]
+    pub output: OutputArgs,
+
+    #[command(flatten)]
+    pub input: InputArgs,
+
+    #[command(flatten)]
+    pub clone_args: CloneArgs,
+
+    #[command(flatten)]
+    pub fail_args: FailArgs,
+
+    /// Path to config file
+    #[arg(short, long)]
+    pub config: Option<PathBuf>,
+
+    #[command(flatten)]
+    pub file_args: FileArgs,
+
+    /// Worker threads for reading and analyzing files (default: available CPUs)
+    #[arg(long, value_name = "N")]
+    pub jobs: Option<usize>,
+
+    #[command(flatten)]
+    pub cache_args: CacheArgs,
+
+    /// Keep a clone index in FILE and re-tokenize only files changed since it was written
+    #[arg(long, value_name = "FILE")]
+    pub index: Option<PathBuf>,
+
+    /// Disable syntax highlighting for clone code blocks
+    #[arg(long)]
+    pub no_highlight: bool,
+
+    /// Print a diff from each clone group's first instance to every other instance
+    #[arg(long)]
+    pub show_diff: bool,
+}
+
+/// Arguments for `fingerprint`
+#[derive(Args, Debug, Clone)]
+pub struct FingerprintArgs {
+    /// Files, directories, `dir/...` patterns, or Go import paths to analyze
+    #[arg(value_name = "PATH", default_value = ".")]
+    pub paths: Vec<PathBuf>,
+
+    /// Output in JSON format
+    #[arg(short, long)]
+    pub json: bool,
+
+    #[command(flatten)]
+    pub clone_args: CloneArgs,
+
+    /// Path to config file
+    #[arg(short, long)]
+    pub config: Option<PathBuf>,
+
+    #[command(flatten)]
+    pub file_args: FileArgs,
+
+    /// Worker threads for reading and analyzing files (default: available CPUs)
+    #[arg(long, value_name = "N")]
+    pub jobs: Option<usize>,
+
+    #[command(flatten)]
+    pub cache_args: CacheArgs,
+}
+
+/// Output flags shared by the analysis commands
+#[derive(Args, Debug, Clone)]
+pub struct OutputArgs {
+    /// Output in JSON format (same as --format json)
+    #[arg(short, long)]
+    pub json: bool,
+
+    /// Output format (default: `output.format` from the config file, else text)
+    #[arg(long, value_enum)]
+    pub format: Option<OutputFormat>,
+
+    /// Suppress findings recorded in a baseline file (see `mccabre baseline`)
+    #[arg(long, value_name = "PATH")]
+    pub baseline: Option<PathBuf>,
+
+    /// With --format junit, leave out passing functions and files without failures
+    #[arg(long)]
+    pub junit_failures_only: bool,
+
+    /// With --format markdown, leave out clone groups past this many bytes of output
+    #[arg(long, value_name = "BYTES", default_value_t = MARKDOWN_BUDGET)]
+    pub markdown_budget: usize,
+
+    /// Write the report to a file instead of stdout (not with text or github output)
+    #[arg(short, long, value_name = "PATH")]
+    pub output: Option<PathBuf>,
+
+    /// Also write one `report.<ext>` file per --formats entry into DIR, from the same analysis
+    #[arg(long, value_name = "DIR", requires = "formats")]
+    pub output_dir: Option<PathBuf>,
+
+    /// Comma-separated file formats for --output-dir, e.g. json,sarif,html
+    #[arg(
+        long,
+        value_enum,
+        value_delimiter = ',',
+        value_name = "FORMATS",
+        requires = "output_dir"
+    )]
+    pub formats: Vec<OutputFormat>,
+
+    /// List only the N most complex functions and the N largest clone groups
+    #[arg(long, value_name = "N")]
+    pub top: Option<usize>,
+
+    /// How much of the text report to print (default: normal)
+    #[arg(long, value_enum, value_name = "LEVEL")]
+    pub verbosity: Option<Verbosity>,
+
+    /// Same as --verbosity quiet
+    #[arg(short, long, conflicts_with = "verbosity")]
+    pub quiet: bool,
+}
+
+impl OutputArgs {
+    /// Selected format: `--json`, then `--format`, then the config file
+    ///
+    /// Text output becomes GitHub annotations when running under GitHub Actions, unless
+    /// `--format text` was given explicitly.
+    pub fn format(&self, config: &Config) -> OutputFormat {
+        if self.json {
+            return OutputFormat::Json;
+        }
+        if let Some(format) = self.format {
+            return format;
+        }
+
+        match config.output.format.into() {
+            OutputFormat::Text if std::env::var("GITHUB_ACTIONS").is_ok_and(|v| v == "true") => OutputFormat::Github,
+            format => format,
+        }
+    }
+
+    /// Selected verbosity: `--quiet`, then `--verbosity`
+    pub fn verbosity(&self) -> Verbosity {
+        match self.quiet {
+            true => Verbosity::Quiet,
+            false => self.verbosity.unwrap_or_default(),
+        }
+    }
+}
+
+/// File selection flags shared by the analysis commands
+#[derive(Args, Debug, Clone)]
+pub struct FileArgs {
+    /// Disable gitignore awareness
+    #[arg(long)]
+    pub no_gitignore: bool,
+
+    /// Skip paths matching a glob (repeatable), e.g. --exclude '*.pb.go'
+    #[arg(long, value_name = "GLOB")]
+    pub exclude: Vec<String>,
+
+    /// Go build tags to satisfy (comma-separated), e.g. --tags integration,e2e
+    #[arg(long, value_delimiter = ',', value_name = "TAGS")]
+    pub tags: Vec<String>,
+
+    /// Skip cgo files, which `import "C"`, building as with CGO_ENABLED=0
+    #[arg(long)]
+    pub skip_cgo: bool,
+}
+
+/// Source input flags shared by the analysis commands
+#[derive(Args, Debug, Clone)]
+pub struct InputArgs {
+    /// Read source from stdin instead of PATH
+    #[arg(long, requires = "filename")]
+    pub stdin: bool,
+
+    /// Name reported for stdin input; its extension selects the language
+    #[arg(long, value_name = "NAME", requires = "stdin")]
+    pub filename: Option<PathBuf>,
+
+    /// Only report files changed since REV (as `git diff REV` lists them) and their clone partners
+    #[arg(long, value_name = "REV", conflicts_with = "stdin")]
+    pub diff: Option<String>,
+
+    /// Only report files modified after TIME (a date such as 2024-01-01, or 24h ago) and their clone partners
+    #[arg(long, value_name = "TIME", value_parser = parse_since, conflicts_with_all = ["stdin", "diff"])]
+    pub since: Option<SystemTime>,
+
+    /// Only report the function named NAME and the clones it takes part in
+    #[arg(long = "func", value_name = "NAME")]
+    pub function: Option<String>,
+
+    /// Download a Go module version with `go mod download` and analyze it instead of PATH
+    #[arg(long, value_name = "MODULE@VERSION", conflicts_with_all = ["stdin", "diff"])]
+    pub module: Option<String>,
+}
+
+impl InputArgs {
+    /// Load stdin as a single file when `--stdin` is set, the module's directory when `--module`
+    /// is, otherwise every target in `paths`
+    pub fn load(&self, loader: &FileLoader, paths: &[PathBuf]) -> mccabre_core::Result<Vec<SourceFile>> {
+        match (&self.filename, self.stdin, &self.module) {
+            (Some(filename), true, _) => Ok(vec![SourceFile::from_reader(filename, io::stdin().lock())?]),
+            (_, _, Some(module)) => loader.load_targets(&[download_module(module)?]),
+            _ => loader.load_targets(paths),
+        }
+    }
+}
+
+/// Cache flags shared by the analysis commands
+#[derive(Args, Debug, Clone)]
+pub struct CacheArgs {
+    /// Analyze every file from scratch, without reading or writing the cache
+    #[arg(long)]
+    pub no_cache: bool,
+
+    /// Delete all cached results before running
+    #[arg(long)]
+    pub clear_cache: bool,
+}
+
+impl CacheArgs {
+    /// Open the cache unless disabled; an unusable cache directory means running uncached
+    pub fn open(&self) -> mccabre_core::Result<Option<Cache>> {
+        let Some(root) = Cache::default_root() else {
+            return Ok(None);
+        };
+
+        if self.clear_cache {
+            Cache::clear(&root)?;
+        }
+        if self.no_cache {
+            return Ok(None);
+        }
+
+        Ok(Cache::open(&root).ok())
+    }
+}
+
+/// Clone detection flags shared by `analyze` and `clones`
+#[derive(Args, Debug, Clone)]
+pub struct CloneArgs {
+    /// Minimum tokens for clone detection (default: 30)
+    #[arg(long)]
+    pub min_tokens: Option<usize>,
+
+    /// Report only clone groups with at least N instances (default: 2)
+    #[arg(long, value_name = "N")]
+    pub min_instances: Option<usize>,
+
+    /// Clone matching mode: exact, renamed (identifiers/literals normalized), strings (string literals normalized)
+    #[arg(long, value_parser = parse_normalize_mode)]
+    pub normalize: Option<NormalizeMode>,
+
+    /// Maximum mismatched tokens tolerated inside a clone (gapped clones)
+    #[arg(long)]
+    pub max_gap: Option<usize>,
+
+    /// Clone matching strategy: token (token windows), ast (syntax subtrees)
+    #[arg(long, value_parser = parse_clone_strategy)]
+    pub strategy: Option<CloneStrategy>,
+
+    /// Minimum subtree size for --strategy ast (default: 40)
+    #[arg(long)]
+    pub min_nodes: Option<usize>,
+
+    /// Clone window units: token (may cut statements), statement (whole statements only)
+    #[arg(long, value_parser = parse_granularity)]
+    pub granularity: Option<Granularity>,
+
+    /// Minimum statements for --granularity statement (default: 5)
+    #[arg(long, value_name = "N")]
+    pub min_statements: Option<usize>,
+
+    /// Keep clone groups nested inside a larger group
+    #[arg(long)]
+    pub keep_overlaps: bool,
+
+    /// Leave *_test.go files out of clone detection (they are still measured)
+    #[arg(long)]
+    pub skip_tests: bool,
+
+    /// Also report clusters of structurally identical `if err != nil { ... }` blocks
+    #[arg(long)]
+    pub report_errcheck_clones: bool,
+
+    /// Also report top-level `const`, `var`, and `type` blocks copied between files
+    #[arg(long)]
+    pub report_decl_clones: bool,
+
+    /// Also report functions whose bodies match, exactly or up to renaming, whatever their signatures
+    #[arg(long)]
+    pub whole_function_clones: bool,
+
+    /// Also report function pairs whose bodies are at least this similar, e.g. 0.8
+    #[arg(long, value_name = "SCORE", value_parser = parse_similarity)]
+    pub similarity_threshold: Option<f64>,
+}
+
+/// Function ordering accepted by `--sort`
+#[derive(ValueEnum, Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub enum SortBy {
+    /// Highest cyclomatic complexity first
+    #[default]
+    Complexity,
+    /// Function name
+    Name,
+    /// File path, then line
+    File,
+}
+
+impl From<SortBy> for SortOrder {
+    fn from(sort: SortBy) -> Self {
+        match sort {
+            SortBy::Complexity => SortOrder::Complexity,
+            SortBy::Name => SortOrder::Name,
+            SortBy::File => SortOrder::File,
+        }
+    }
+}
+
+/// Text report detail accepted by `--verbosity`
+#[derive(ValueEnum, Debug, Clone, Copy, Default, PartialEq, Eq, PartialOrd, Ord)]
+pub enum Verbosity {
+    /// Only functions and findings over a threshold, clone locations, and the summary; no progress counter
+    Quiet,
+    /// Every file's metrics and functions
+    #[default]
+    Normal,
+    /// As normal, with each function's body length, statement count, and return count
+    Verbose,
+}
+
+/// Finding kinds accepted by `--fail-on`
+#[derive(ValueEnum, Debug, Clone, Copy, PartialEq, Eq)]
+pub enum FailOn {
+    /// Any clone group
+    Clone,
+    /// Any function above the complexity warning threshold
+    Complexity,
+    /// Any function nested deeper than the nesting threshold
+    Nesting,
+    /// Any function longer than `--max-func-lines` or `--max-func-stmts`
+    Length,
+    /// Any function taking more than `--max-params` parameters
+    Parameters,
+}
+
+/// Exit-code flags shared by the analysis commands
+#[derive(Args, Debug, Clone)]
+pub struct FailArgs {
+    /// Exit with code 1 if any function's cyclomatic complexity exceeds N
+    #[arg(long, value_name = "N")]
+    pub max_complexity: Option<usize>,
+
+    /// Exit with code 1 if more than N clone groups are found
+    #[arg(long, value_name = "N")]
+    pub max_clones: Option<usize>,
+
+    /// Exit with code 1 if more than PERCENT of all lines fall inside clones
+    #[arg(long, value_name = "PERCENT")]
+    pub max_duplication: Option<f64>,
+
+    /// Exit with code 1 if any function has more than N return statements
+    #[arg(long, value_name = "N")]
+    pub max_returns: Option<usize>,
+
+    /// Exit with code 1 on any finding of these kinds (comma-separated)
+    #[arg(long, value_enum, value_delimiter = ',', value_name = "KINDS")]
+    pub fail_on: Vec<FailOn>,
+
+    /// Exit with code 1 if any function is rated SEVERITY or above (low, moderate, high, very-high)
+    #[arg(long, value_name = "SEVERITY")]
+    pub fail_on_severity: Option<Severity>,
+
+    /// Exit with code 1 if any file could not be parsed (such files are otherwise skipped)
+    #[arg(long)]
+    pub fail_on_parse_error: bool,
+}
+
+impl FailArgs {
+    /// Resolve the flags into limits; explicit `--max-*` values win over `--fail-on`
+    ///
+    /// Limits taken from the config also follow its per-path overrides, while an explicit
+    /// `--max-complexity` applies to every file.
+    pub fn policy(&self, config: &Config) -> FailurePolicy {
+        let mut policy = FailurePolicy::new();
+        let mut overrides = config.complexity.overrides.clone();
+
+        if let Some(limit) = self.max_complexity {
+            policy = policy.with_max_complexity(limit);
+            for entry in &mut overrides {
+                entry.warning_threshold = None;
+            }
+        } else if self.fail_on.contains(&FailOn::Complexity) {
+            policy = policy.with_max_complexity(config.complexity.warning_threshold);
+        }
+
+        if let Some(limit) = self.max_clones {
+            policy = policy.with_max_clones(limit);
+        } else if self.fail_on.contains(&FailOn::Clone) {
+            policy = policy.with_max_clones(0);
+        }
+
+        if let Some(percent) = self.max_duplication {
+            policy = policy.with_max_duplication(percent);
+        }
+
+        if self.fail_on.contains(&FailOn::Nesting) {
+            policy = policy.with_max_nesting(config.complexity.max_nesting);
+        }
+
+        if self.fail_on.contains(&FailOn::Length) {
+            policy = policy.with_length_limits(config.complexity.length_limits());
+        }
+
+        if self.fail_on.contains(&FailOn::Parameters)
+            && let Some(limit) = config.complexity.max_parameters
+        {
+            policy = policy.with_max_parameters(limit);
+        }
+
+        if let Some(limit) = self.max_returns {
+            policy = policy.with_max_returns(limit);
+        }
+
+        if let Some(severity) = self.fail_on_severity {
+            policy = policy.with_min_severity(severity);
+        }
+
+        policy
+            .with_fail_on_parse_error(self.fail_on_parse_error)
+            .with_overrides(overrides)
+    }
+}
+
+fn parse_normalize_mode(value: &str) -> Result<NormalizeMode, String> {
+    match value.to_lowercase().as_str() {
+        "exact" => Ok(NormalizeMode::Exact),
+        "renamed" => Ok(NormalizeMode::Renamed),
+        "strings" => Ok(NormalizeMode::Strings),
+        _ => Err("use: exact, renamed, or strings".to_string()),
+    }
+}
+
+fn parse_since(value: &str) -> Result<SystemTime, String> {
+    diff::parse_since(value, SystemTime::now())
+}
+
+fn parse_similarity(value: &str) -> Result<f64, String> {
+    match value.parse::<f64>() {
+        Ok(score) if score > 0.0 && score <= 1.0 => Ok(score),
+        _ => Err("use a score above 0 and at most 1, e.g. 0.8".to_string()),
+    }
+}
+
+fn parse_clone_strategy(value: &str) -> Result<CloneStrategy, String> {
+    match value.to_lowercase().as_str() {
+        "token" => Ok(CloneStrategy::Token),
+        "ast" => Ok(CloneStrategy::Ast),
+        _ => Err("use: token or ast".to_string()),
+    }
+}
+
+fn parse_granularity(value: &str) -> Result<Granularity, String> {
+    match value.to_lowercase().as_str() {
+        "token" => Ok(Granularity::Token),
+        "statement" => Ok(Granularity::Statement),
+        _ => Err("use: token or statement".to_string()),
+    }
+}
@@ -0,0 +1,72 @@
+use owo_colors::{Style, Styled};
+use std::env;
+use std::io::{self, IsTerminal};
+use std::sync::atomic::{AtomicBool, Ordering};
+
+static ENABLED: AtomicBool = AtomicBool::new(true);
+
+/// Decide once whether to color output
+///
+/// Colors are used only when stdout is a terminal, `NO_COLOR` is unset or empty, and
+/// `--no-color` was not given.
+pub fn init(no_color: bool) {
+    let no_color_env = env::var_os("NO_COLOR").is_some_and(|value| !value.is_empty());
+    ENABLED.store(
+        !no_color && !no_color_env && io::stdout().is_terminal(),
+        Ordering::Relaxed,
+    );
+}
+
+pub fn enabled() -> bool {
+    ENABLED.load(Ordering::Relaxed)
+}
+
+fn paint(style: fn(Style) -> Style) -> Style {
+    if enabled() { style(Style::new()) } else { Style::new() }
+}
+
+/// The `owo_colors` methods the commands use, obeying [`init`]
+///
+/// When colors are off each method applies an empty style, which writes the text and nothing
+/// else, so plain output is exactly the colored layout without escape codes.
+pub trait Colorize {
+    fn red(&self) -> Styled<&Self> {
+        self.styled(paint(Style::red))
+    }
+
+    fn green(&self) -> Styled<&Self> {
+        self.styled(paint(Style::green))
+    }
+
+    fn yellow(&self) -> Styled<&Self> {
+        self.styled(paint(Style::yellow))
+    }
+
+    fn blue(&self) -> Styled<&Self> {
+        self.styled(paint(Style::blue))
+    }
+
+    fn magenta(&self) -> Styled<&Self> {
+        self.styled(paint(Style::magenta))
+    }
+
+    fn cyan(&self) -> Styled<&Self> {
+        self.styled(paint(Style::cyan))
+    }
+
+    fn bold(&self) -> Styled<&Self> {
+        self.styled(paint(Style::bold))
+    }
+
+    fn dimmed(&self) -> Styled<&Self> {
+        self.styled(paint(Style::dimmed))
+    }
+
+    fn styled(&self, style: Style) -> Styled<&Self>;
+}
+
+impl<T: ?Sized> Colorize for T {
+    fn styled(&self, style: Style) -> Styled<&Self> {
+        style.style(self)
+    }
+}
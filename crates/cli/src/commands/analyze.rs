@@ -1,67 +1,192 @@
+use crate::args::{AnalyzeArgs, CloneArgs, FileArgs, OutputFormat, Verbosity};
+use crate::color::{self, Colorize};
+use crate::commands::{
+    changed_files, check_output, emit, enforce, function_focus, progress, reporter, warn_config, warn_parse_errors,
+    write_artifacts, write_report,
+};
+use crate::logging;
 use anyhow::Result;
 use mccabre_core::{
-    Highlighter,
-    cloner::CloneDetector,
-    complexity::{CyclomaticMetrics, LocMetrics},
-    config::Config,
+    Analyzer, Highlighter,
+    baseline::Baseline,
+    cache::Cache,
+    cloner::{Clone, DiffLine, diff_instances},
+    complexity::{CyclomaticMetrics, FunctionComplexity},
+    config::{ComplexityConfig, Config},
     loader::{FileLoader, SourceFile},
-    reporter::{FileReport, Report},
+    parallel::{Progress, default_jobs},
+    reporter::{Aggregate, FileReport, Report, Rollup},
 };
-use owo_colors::OwoColorize;
 use std::collections::HashMap;
-use std::path::PathBuf;
-
-pub fn run(
-    path: PathBuf, json: bool, threshold: Option<usize>, min_tokens: Option<usize>, config_path: Option<PathBuf>,
-    respect_gitignore: bool, highlight: bool,
-) -> Result<()> {
-    let config = if let Some(config_path) = config_path {
-        Config::from_file(config_path)?
-    } else {
-        Config::load_default()?
-    };
+use std::path::{Path, PathBuf};
 
-    let config = config.merge_with_cli(threshold, min_tokens, Some(respect_gitignore));
-    let loader = FileLoader::new().with_gitignore(config.files.respect_gitignore);
-    let files = loader.load(&path)?;
+pub fn run(args: AnalyzeArgs) -> Result<()> {
+    let mut config = load_config(
+        args.config.as_deref(),
+        args.threshold,
+        &args.clone_args,
+        &args.file_args,
+    )?;
+    if let Some(max_nesting) = args.max_nesting {
+        config.complexity.max_nesting = max_nesting;
+    }
+    if let Some(limit) = args.max_func_lines {
+        config.complexity.max_function_lines = Some(limit);
+    }
+    if let Some(limit) = args.max_func_stmts {
+        config.complexity.max_function_statements = Some(limit);
+    }
+    if let Some(limit) = args.max_params {
+        config.complexity.max_parameters = Some(limit);
+    }
+    let format = args.output.format(&config);
+    check_output(&args.output, format)?;
+    let jobs = args.jobs.unwrap_or_else(default_jobs);
+    let loader = FileLoader::from_config(&config.files)?.with_jobs(jobs);
+    let files = args.input.load(&loader, &args.paths)?;
 
     if files.is_empty() {
         eprintln!("{}", "No supported files found".yellow());
         return Ok(());
     }
 
-    let mut file_reports = Vec::new();
-
-    for file in &files {
-        let loc = LocMetrics::calculate(&file.content, file.language)?;
-        let cyclomatic = CyclomaticMetrics::calculate(&file.content, file.language)?;
+    let changed = changed_files(&args.input, &files)?;
+    if changed
+        .as_ref()
+        .is_some_and(|c| !files.iter().any(|f| c.contains(&f.path)))
+    {
+        eprintln!("{}", "No changed source files".yellow());
+        return Ok(());
+    }
+    let focus = function_focus(&args.input, &files, jobs)?;
 
-        file_reports.push(FileReport { path: file.path.clone(), loc, cyclomatic });
+    let cache = args.cache_args.open()?;
+    let mut report = build_report(&files, &config, jobs, cache.as_ref(), progress(&args.output))?;
+    if let Some(changed) = &changed {
+        report = changed.filter(report);
+    }
+    if let Some(focus) = &focus {
+        report = focus.filter(report);
+    }
+    if let Some(baseline) = &args.output.baseline {
+        report = Baseline::from_file(baseline)?.filter(report);
     }
 
-    let clones = if config.clones.enabled {
-        let detector = CloneDetector::new(config.clones.min_tokens);
-        let files_for_clone_detection: Vec<_> = files
-            .iter()
-            .map(|f| (f.path.clone(), f.content.clone(), f.language))
-            .collect();
-        detector.detect_across_files(&files_for_clone_detection)?
-    } else {
-        Vec::new()
-    };
+    report.sort(args.sort.into());
+    warn_parse_errors(&report);
 
-    let report = Report::new(file_reports, clones);
+    if args.summary {
+        match format {
+            OutputFormat::Json => emit(&args.output, &report.to_aggregate_json(&config.complexity)?)?,
+            _ => print_aggregate(&report.aggregate(&config.complexity)),
+        }
+        write_artifacts(&args.output, &config, &files, args.sort.into(), &report)?;
+        enforce(&args.fail_args, &config, &report);
+        return Ok(());
+    }
 
-    if json {
-        println!("{}", report.to_json()?);
-    } else {
-        print_pretty_report(&report, &config, &files, highlight);
+    let mut shown = report.with_min_complexity(args.min_complexity);
+    if let Some(n) = args.output.top {
+        shown = shown.with_top(n);
+    }
+    if let Some(reporter) = reporter(format, &args.output, &config, &files, args.sort.into()) {
+        write_report(&args.output, reporter.as_ref(), &shown)?;
+    }
+    write_artifacts(&args.output, &config, &files, args.sort.into(), &shown)?;
+    if matches!(format, OutputFormat::Text | OutputFormat::Github) {
+        // Quiet output lists clone locations without their code
+        let verbosity = args.output.verbosity();
+        let highlight = !args.no_highlight && verbosity != Verbosity::Quiet;
+        print_pretty_report(
+            &shown,
+            &config,
+            &files,
+            highlight,
+            verbosity,
+            args.explain,
+            args.show_diff,
+        );
     }
 
+    enforce(&args.fail_args, &config, &report);
     Ok(())
 }
 
-fn print_pretty_report(report: &Report, config: &Config, files: &[SourceFile], highlight: bool) {
+/// Load the config file and apply command-line overrides, warning about settings that hide
+/// findings
+pub fn load_config(
+    config_path: Option<&Path>, threshold: Option<usize>, clone_args: &CloneArgs, file_args: &FileArgs,
+) -> Result<Config> {
+    let config = merge_config(config_path, threshold, clone_args, file_args)?;
+    warn_config(&config);
+    Ok(config)
+}
+
+/// Load the config file and apply command-line overrides
+pub fn merge_config(
+    config_path: Option<&Path>, threshold: Option<usize>, clone_args: &CloneArgs, file_args: &FileArgs,
+) -> Result<Config> {
+    let config = Config::load(config_path)?;
+
+    let mut config = config.merge_with_cli(threshold, clone_args.min_tokens, Some(!file_args.no_gitignore));
+    config.files.exclude.extend(file_args.exclude.iter().cloned());
+    config.files.tags.extend(file_args.tags.iter().cloned());
+    config.files.skip_cgo |= file_args.skip_cgo;
+    if let Some(min_instances) = clone_args.min_instances {
+        config.clones.min_instances = min_instances;
+    }
+    if let Some(mode) = clone_args.normalize {
+        config.clones.normalize = mode;
+    }
+    if let Some(max_gap) = clone_args.max_gap {
+        config.clones.max_gap = max_gap;
+    }
+    if let Some(strategy) = clone_args.strategy {
+        config.clones.strategy = strategy;
+    }
+    if let Some(min_nodes) = clone_args.min_nodes {
+        config.clones.min_nodes = min_nodes;
+    }
+    if let Some(granularity) = clone_args.granularity {
+        config.clones.granularity = granularity;
+    }
+    if let Some(min_statements) = clone_args.min_statements {
+        config.clones.min_statements = min_statements;
+    }
+    config.clones.keep_overlaps |= clone_args.keep_overlaps;
+    config.clones.skip_tests |= clone_args.skip_tests;
+    config.clones.report_errcheck |= clone_args.report_errcheck_clones;
+    config.clones.report_declarations |= clone_args.report_decl_clones;
+    config.clones.report_function_clones |= clone_args.whole_function_clones;
+    if let Some(threshold) = clone_args.similarity_threshold {
+        config.clones.similarity_threshold = Some(threshold);
+    }
+
+    Ok(config)
+}
+
+/// Compute complexity for every file and detect clones across them, minus ignored findings
+pub fn build_report(
+    files: &[SourceFile], config: &Config, jobs: usize, cache: Option<&Cache>, progress: Option<Progress>,
+) -> Result<Report> {
+    let mut analyzer = Analyzer::new(config.clone()).with_jobs(jobs);
+    if let Some(cache) = cache {
+        analyzer = analyzer.with_cache(cache.clone());
+    }
+    if let Some(progress) = progress {
+        analyzer = analyzer.with_progress(progress);
+    }
+    if let Some(logger) = logging::logger() {
+        analyzer = analyzer.with_logger(logger);
+    }
+
+    Ok(analyzer.analyze(files)?)
+}
+
+fn print_pretty_report(
+    report: &Report, config: &Config, files: &[SourceFile], highlight: bool, verbosity: Verbosity, explain: bool,
+    show_diff: bool,
+) {
     println!("{}", "=".repeat(80).cyan());
     println!("{}", "MCCABRE CODE ANALYSIS REPORT".cyan().bold());
     println!("{}", "=".repeat(80).cyan());
@@ -88,49 +213,61 @@ fn print_pretty_report(report: &Report, config: &Config, files: &[SourceFile], h
         report.summary.high_complexity_files.bold()
     );
     println!("Clone groups detected:       {}", report.summary.total_clones.bold());
+    println!(
+        "Duplicated lines:            {} ({:.1}%)",
+        report.summary.duplicated_lines.bold(),
+        report.summary.duplication_ratio * 100.0
+    );
+    print_suppressed(report);
     println!();
 
-    if !report.files.is_empty() {
+    let file_map: HashMap<_, _> = files.iter().map(|f| (&f.path, f)).collect();
+    let listed = listed_files(report, config, verbosity);
+    if !listed.is_empty() {
         println!("{}", "FILE METRICS".green().bold());
         println!("{}", "-".repeat(80).cyan());
 
-        for file in &report.files {
+        let duplication = report.duplication();
+        for (file, functions) in listed {
             println!("{} {}", "FILE:".blue().bold(), file.path.display().bold());
 
+            let thresholds = config.complexity.for_path(&file.path);
+            let source = file_map.get(&file.path).copied().filter(|_| explain);
+            if verbosity == Verbosity::Quiet {
+                print_functions(&functions, &thresholds, verbosity, source);
+                print_findings(file);
+                continue;
+            }
             let complexity_value = file.cyclomatic.file_complexity;
             let complexity_text = format!("Cyclomatic Complexity:   {complexity_value}");
 
-            if complexity_value > config.complexity.error_threshold {
+            if complexity_value > thresholds.error_threshold {
                 println!("    {}", complexity_text.red().bold());
-            } else if complexity_value > config.complexity.warning_threshold {
+            } else if complexity_value > thresholds.warning_threshold {
                 println!("    {}", complexity_text.yellow());
             } else {
                 println!("    {}", complexity_text.green());
             }
+            println!("    Maintainability index:   {:.1}", file.maintainability_index);
             println!("    Physical LOC:            {}", file.loc.physical);
             println!("    Logical LOC:             {}", file.loc.logical);
+            println!("    Statements:              {}", file.loc.statements);
             println!("    Comment lines:           {}", file.loc.comments);
+            println!(
+                "    Comment density:         {:.1}%",
+                file.loc.comment_density() * 100.0
+            );
             println!("    Blank lines:             {}", file.loc.blank);
+            let duplicated = duplication[file.path.as_path()];
+            println!(
+                "    Duplicated lines:        {} ({:.1}%)",
+                duplicated.lines,
+                duplicated.ratio * 100.0
+            );
             println!();
 
-            if !file.cyclomatic.functions.is_empty() {
-                println!("    {}:", "Functions".magenta());
-                for func in &file.cyclomatic.functions {
-                    let func_text = format!(
-                        "      - {} (line {}): complexity {}",
-                        func.name, func.line, func.complexity
-                    );
-
-                    if func.complexity > config.complexity.error_threshold {
-                        println!("{}", func_text.red());
-                    } else if func.complexity > config.complexity.warning_threshold {
-                        println!("{}", func_text.yellow());
-                    } else {
-                        println!("{func_text}");
-                    }
-                }
-                println!();
-            }
+            print_functions(&functions, &thresholds, verbosity, source);
+            print_findings(file);
         }
     }
 
@@ -138,7 +275,6 @@ fn print_pretty_report(report: &Report, config: &Config, files: &[SourceFile], h
         println!("{}", "DETECTED CLONES".green().bold());
         println!("{}", "-".repeat(80).cyan());
 
-        let file_map: HashMap<_, _> = files.iter().map(|f| (&f.path, f)).collect();
         let highlighter = if highlight { Some(Highlighter::new()) } else { None };
 
         for clone in &report.clones {
@@ -148,16 +284,19 @@ fn print_pretty_report(report: &Report, config: &Config, files: &[SourceFile], h
                 format!("#{}", clone.id).yellow().bold(),
                 "(length:".dimmed(),
                 format!("{} tokens", clone.length).bold(),
-                format!("{} occurrences)", clone.locations.len()).bold(),
-                "".dimmed()
+                format!("{} instances)", clone.locations.len()).bold(),
+                clone.group_id.dimmed()
             );
 
             for loc in &clone.locations {
+                let gap_note =
+                    if loc.gap_tokens > 0 { format!(" ({} tokens differ)", loc.gap_tokens) } else { String::new() };
                 println!(
-                    "  {} {}:{}",
+                    "  {} {}:{}{}",
                     "-".dimmed(),
                     loc.file.display(),
-                    format!("{}-{}", loc.start_line, loc.end_line).dimmed()
+                    format!("{}-{}", loc.start_line, loc.end_line).dimmed(),
+                    gap_note.dimmed()
                 );
 
                 if highlight && let Some(source_file) = file_map.get(&loc.file) {
@@ -165,7 +304,8 @@ fn print_pretty_report(report: &Report, config: &Config, files: &[SourceFile], h
 
                     if let Some(ref hl) = highlighter {
                         let ext = source_file.path.extension().and_then(|e| e.to_str()).unwrap_or("txt");
-                        let highlighted = hl.highlight(&code_block, ext);
+                        let highlighted =
+                            if color::enabled() { hl.highlight(&code_block, ext) } else { code_block.clone() };
 
                         println!("{}", "    ┌─────".dimmed());
                         for line in highlighted.lines() {
@@ -175,13 +315,117 @@ fn print_pretty_report(report: &Report, config: &Config, files: &[SourceFile], h
                     }
                 }
             }
+            if show_diff {
+                print_instance_diffs(clone, &file_map);
+            }
             println!();
         }
     }
+    print_errcheck_clusters(report);
+    print_duplicate_declarations(report);
+    print_function_clones(report);
+    print_similar_functions(report);
 
     println!("{}", "=".repeat(80).cyan());
 }
 
+/// Print a unified diff from a clone group's first instance to each of the others
+///
+/// Instances whose lines match the first's, indentation aside, print nothing.
+pub fn print_instance_diffs(clone: &Clone, file_map: &HashMap<&PathBuf, &SourceFile>) {
+    let Some((first, others)) = clone.locations.split_first() else {
+        return;
+    };
+    let Some(old) = file_map.get(&first.file) else {
+        return;
+    };
+
+    for loc in others {
+        let Some(new) = file_map.get(&loc.file) else {
+            continue;
+        };
+        let hunks = diff_instances(first, &old.content, loc, &new.content);
+        if hunks.is_empty() {
+            continue;
+        }
+
+        println!(
+            "{}",
+            format!(
+                "    --- {}:{}-{}",
+                first.file.display(),
+                first.start_line,
+                first.end_line
+            )
+            .dimmed()
+        );
+        println!(
+            "{}",
+            format!("    +++ {}:{}-{}", loc.file.display(), loc.start_line, loc.end_line).dimmed()
+        );
+        for hunk in &hunks {
+            println!("    {}", hunk.header().cyan());
+            for line in &hunk.lines {
+                match line {
+                    DiffLine::Context(text) => println!("     {text}"),
+                    DiffLine::Removed(text) => println!("    {}", format!("-{text}").red()),
+                    DiffLine::Added(text) => println!("    {}", format!("+{text}").green()),
+                }
+            }
+        }
+    }
+}
+
+/// Per-package table for `--summary`, with a total row
+fn print_aggregate(aggregate: &Aggregate) {
+    println!("{}", "=".repeat(80).cyan());
+    println!("{}", "SUMMARY BY PACKAGE".cyan().bold());
+    println!("{}\n", "=".repeat(80).cyan());
+
+    println!(
+        "{}",
+        format!(
+            "{:<28} {:>6} {:>6} {:>7} {:>7} {:>5} {:>7} {:>9}",
+            "PACKAGE", "FILES", "FUNCS", "AVG CC", "MAX CC", "OVER", "CLONES", "DUP LINES"
+        )
+        .bold()
+    );
+    println!("{}", "-".repeat(80).cyan());
+    for package in &aggregate.packages {
+        // Name the package only where a directory holds several, as with `foo` and `foo_test`
+        let shared = aggregate
+            .packages
+            .iter()
+            .filter(|p| p.package == package.package)
+            .count()
+            > 1;
+        let label = match &package.name {
+            Some(name) if shared => format!("{} ({name})", package.package.display()),
+            _ => package.package.display().to_string(),
+        };
+        println!("{}", rollup_row(&label, &package.rollup));
+    }
+    println!("{}", "-".repeat(80).cyan());
+    println!("{}", rollup_row("TOTAL", &aggregate.total).bold());
+
+    println!("{}", "=".repeat(80).cyan());
+}
+
+/// One row of the `--summary` table
+fn rollup_row(label: &str, rollup: &Rollup) -> String {
+    format!(
+        "{:<28} {:>6} {:>6} {:>7.2} {:>7} {:>5} {:>7} {:>9}",
+        label,
+        rollup.files,
+        rollup.functions,
+        rollup.avg_complexity,
+        rollup.max_complexity,
+        rollup.functions_over_threshold,
+        rollup.clone_groups,
+        rollup.duplicated_lines
+    )
+}
+
 /// Extract lines from source code by line numbers (1-indexed)
 fn extract_lines(source: &str, start_line: usize, end_line: usize) -> String {
     source
@@ -195,3 +439,227 @@ fn extract_lines(source: &str, start_line: usize, end_line: usize) -> String {
         .collect::<Vec<_>>()
         .join("\n")
 }
+
+/// Files to list in a text report at `verbosity`, each with the functions to show
+///
+/// Quiet output keeps only the functions over a complexity or nesting threshold, and only files
+/// with such a function or a rule finding.
+pub fn listed_files<'a>(
+    report: &'a Report, config: &Config, verbosity: Verbosity,
+) -> Vec<(&'a FileReport, Vec<&'a FunctionComplexity>)> {
+    report
+        .files
+        .iter()
+        .filter_map(|file| {
+            let thresholds = config.complexity.for_path(&file.path);
+            let functions: Vec<&FunctionComplexity> = file
+                .cyclomatic
+                .functions
+                .iter()
+                .filter(|func| verbosity != Verbosity::Quiet || over_threshold(func, &thresholds))
+                .collect();
+            let clean = functions.is_empty() && file.findings.is_empty();
+            (verbosity != Verbosity::Quiet || !clean).then_some((file, functions))
+        })
+        .collect()
+}
+
+fn over_threshold(func: &FunctionComplexity, thresholds: &ComplexityConfig) -> bool {
+    func.cyclomatic > thresholds.warning_threshold || func.max_nesting > thresholds.max_nesting
+}
+
+/// List a file's functions, red above the error threshold and yellow above the others
+///
+/// With the file's `source`, each function over a threshold is followed by the decision points
+/// that make up its cyclomatic complexity.
+pub fn print_functions(
+    functions: &[&FunctionComplexity], thresholds: &ComplexityConfig, verbosity: Verbosity, source: Option<&SourceFile>,
+) {
+    if functions.is_empty() {
+        return;
+    }
+    let explained = source.and_then(|file| CyclomaticMetrics::explain(&file.content, file.language).ok());
+
+    println!("    {}:", "Functions".magenta());
+    for func in functions {
+        let mut func_text = format!(
+            "      - {} (line {}): cyclomatic {} ({}), cognitive {}, nesting {}",
+            func.name, func.line, func.cyclomatic, func.severity, func.cognitive, func.max_nesting
+        );
+        if verbosity == Verbosity::Verbose {
+            func_text.push_str(&format!(
+                ", {} lines, {} statements, {} returns",
+                func.lines, func.statements, func.returns
+            ));
+        }
+
+        if func.cyclomatic > thresholds.error_threshold {
+            println!("{}", func_text.red());
+        } else if over_threshold(func, thresholds) {
+            println!("{}", func_text.yellow());
+        } else {
+            println!("{func_text}");
+        }
+
+        let points = explained.as_ref().and_then(|e| e.get(&(func.line, func.column)));
+        if let Some(points) = points.filter(|_| over_threshold(func, thresholds)) {
+            for point in points {
+                println!("          {} at line {}: +1", point.construct, point.line);
+            }
+        }
+    }
+    println!();
+}
+
+/// List a file's rule findings under its metrics
+pub fn print_findings(file: &FileReport) {
+    if file.findings.is_empty() {
+        return;
+    }
+
+    println!("    {}:", "Findings".magenta());
+    for finding in &file.findings {
+        println!(
+            "      - {} (line {}, {}): {}",
+            finding.rule.yellow(),
+            finding.line,
+            finding.function,
+            finding.message
+        );
+        if let Some(suggestion) = &finding.suggestion {
+            println!("        {}", suggestion.dimmed());
+        }
+    }
+    println!();
+}
+
+/// Summary line for findings dropped by `//mccabre:ignore`, shown only when there are some
+pub fn print_suppressed(report: &Report) {
+    let suppressed = report.suppressed;
+    if suppressed.total() > 0 {
+        println!(
+            "Suppressed findings:         {} ({} complexity, {} rule, {} clone)",
+            suppressed.total().dimmed(),
+            suppressed.complexity,
+            suppressed.findings,
+            suppressed.clones
+        );
+    }
+}
+
+/// List function pairs above `--similarity-threshold`, shown only when there are some
+pub fn print_similar_functions(report: &Report) {
+    if report.similar_functions.is_empty() {
+        return;
+    }
+
+    println!("{}", "SIMILAR FUNCTIONS".green().bold());
+    println!("{}", "-".repeat(80).cyan());
+    for pair in &report.similar_functions {
+        println!(
+            "{} {} {}",
+            "Pair".yellow(),
+            format!("#{}", pair.id).yellow().bold(),
+            format!("({:.0}% similar)", pair.score * 100.0).bold()
+        );
+        for function in &pair.functions {
+            println!(
+                "  {} {} {}:{}",
+                "-".dimmed(),
+                function.name,
+                function.location.file.display(),
+                format!("{}-{}", function.location.start_line, function.location.end_line).dimmed()
+            );
+        }
+        println!();
+    }
+}
+
+/// List copied declaration blocks, shown only when `--report-decl-clones` found some
+pub fn print_duplicate_declarations(report: &Report) {
+    if report.duplicate_declarations.is_empty() {
+        return;
+    }
+
+    println!("{}", "DUPLICATED DECLARATIONS".green().bold());
+    println!("{}", "-".repeat(80).cyan());
+    for duplicate in &report.duplicate_declarations {
+        println!(
+            "{} {} {}",
+            format!("{} block", duplicate.keyword).yellow(),
+            format!("#{}", duplicate.id).yellow().bold(),
+            format!("({} specs, {} instances)", duplicate.specs, duplicate.instances.len()).bold()
+        );
+        for loc in &duplicate.instances {
+            println!(
+                "  {} {}:{}",
+                "-".dimmed(),
+                loc.file.display(),
+                format!("{}-{}", loc.start_line, loc.end_line).dimmed()
+            );
+        }
+        println!();
+    }
+}
+
+/// List functions with matching bodies, shown only when `--whole-function-clones` found some
+pub fn print_function_clones(report: &Report) {
+    if report.function_clones.is_empty() {
+        return;
+    }
+
+    println!("{}", "DUPLICATED FUNCTION BODIES".green().bold());
+    println!("{}", "-".repeat(80).cyan());
+    for clone in &report.function_clones {
+        println!(
+            "{} {} {}",
+            "Body".yellow(),
+            format!("#{}", clone.id).yellow().bold(),
+            format!(
+                "({}, {} tokens, {} functions)",
+                if clone.exact { "exact" } else { "renamed" },
+                clone.tokens,
+                clone.functions.len()
+            )
+            .bold()
+        );
+        for function in &clone.functions {
+            println!(
+                "  {} {} {}:{}",
+                "-".dimmed(),
+                function.name,
+                function.location.file.display(),
+                format!("{}-{}", function.location.start_line, function.location.end_line).dimmed()
+            );
+        }
+        println!();
+    }
+}
+
+/// List repeated error-handling blocks, shown only when `--report-errcheck-clones` found some
+pub fn print_errcheck_clusters(report: &Report) {
+    if report.errcheck_clusters.is_empty() {
+        return;
+    }
+
+    println!("{}", "ERROR-HANDLING CLUSTERS".green().bold());
+    println!("{}", "-".repeat(80).cyan());
+    for cluster in &report.errcheck_clusters {
+        println!(
+            "{} {} {}",
+            "Cluster".yellow(),
+            format!("#{}", cluster.id).yellow().bold(),
+            format!("({} instances)", cluster.instances.len()).bold()
+        );
+        println!("  {}", cluster.shape.dimmed());
+        for loc in &cluster.instances {
+            println!(
+                "  {} {}:{}",
+                "-".dimmed(),
+                loc.file.display(),
+                format!("{}-{}", loc.start_line, loc.end_line).dimmed()
+            );
+        }
+        println!();
+    }
+}
@@ -0,0 +1,20 @@
+use crate::args::{CacheArgs, CloneArgs, FileArgs};
+use crate::commands::analyze::{build_report, load_config};
+use anyhow::Result;
+use mccabre_core::{baseline::Baseline, loader::FileLoader, parallel::default_jobs};
+use std::path::PathBuf;
+
+pub fn run(
+    path: PathBuf, threshold: Option<usize>, clone_args: CloneArgs, config_path: Option<PathBuf>, file_args: FileArgs,
+    jobs: Option<usize>, cache_args: CacheArgs,
+) -> Result<()> {
+    let config = load_config(config_path.as_deref(), threshold, &clone_args, &file_args)?;
+    let jobs = jobs.unwrap_or_else(default_jobs);
+    let loader = FileLoader::from_config(&config.files)?.with_jobs(jobs);
+    let files = loader.load_targets(&[path])?;
+
+    let report = build_report(&files, &config, jobs, cache_args.open()?.as_ref(), None)?;
+    println!("{}", Baseline::from_report(&report, &config.complexity).to_json()?);
+
+    Ok(())
+}
@@ -1,66 +1,196 @@
+use crate::args::{ClonesArgs, OutputFormat, Verbosity};
+use crate::color::{self, Colorize};
+use crate::commands::{
+    analyze::{
+        load_config, print_duplicate_declarations, print_errcheck_clusters, print_function_clones,
+        print_instance_diffs, print_similar_functions, print_suppressed,
+    },
+    changed_files, check_output, enforce, function_focus, progress, reporter, warn_parse_errors, write_artifacts,
+    write_report,
+};
+use crate::logging;
 use anyhow::Result;
 use mccabre_core::{
-    Highlighter,
-    cloner::CloneDetector,
+    Highlighter, MccabreError,
+    analyzer::detect_duplication,
+    baseline::Baseline,
+    cache::Cache,
+    cloner::{CloneDetector, CloneIndex},
     config::Config,
-    loader::{FileLoader, SourceFile},
-    reporter::Report,
+    loader::{FileLoader, SourceFile, is_test_file},
+    parallel::{Progress, default_jobs},
+    position::Positions,
+    reporter::{Report, SortOrder},
+    suppress::Suppressions,
+    syntax::partition,
 };
-use owo_colors::OwoColorize;
 use std::collections::HashMap;
-use std::path::PathBuf;
-
-pub fn run(
-    path: PathBuf, json: bool, min_tokens: Option<usize>, config_path: Option<PathBuf>, respect_gitignore: bool,
-    highlight: bool,
-) -> Result<()> {
-    let config = if let Some(config_path) = config_path {
-        Config::from_file(config_path)?
-    } else {
-        Config::load_default()?
-    };
+use std::path::Path;
 
-    let config = config.merge_with_cli(None, min_tokens, Some(respect_gitignore));
-    let loader = FileLoader::new().with_gitignore(config.files.respect_gitignore);
-    let files = loader.load(&path)?;
+pub fn run(args: ClonesArgs) -> Result<()> {
+    let config = load_config(args.config.as_deref(), None, &args.clone_args, &args.file_args)?;
+    let format = args.output.format(&config);
+    check_output(&args.output, format)?;
+    let jobs = args.jobs.unwrap_or_else(default_jobs);
+    let loader = FileLoader::from_config(&config.files)?.with_jobs(jobs);
+    let files = args.input.load(&loader, &args.paths)?;
 
     if files.is_empty() {
         eprintln!("{}", "No supported files found".yellow());
         return Ok(());
     }
 
-    let detector = CloneDetector::new(config.clones.min_tokens);
-    let files_for_clone_detection: Vec<_> = files
+    let changed = changed_files(&args.input, &files)?;
+    if changed
+        .as_ref()
+        .is_some_and(|c| !files.iter().any(|f| c.contains(&f.path)))
+    {
+        eprintln!("{}", "No changed source files".yellow());
+        return Ok(());
+    }
+    let focus = function_focus(&args.input, &files, jobs)?;
+
+    let mut report = clone_report(
+        &files,
+        &config,
+        jobs,
+        args.cache_args.open()?,
+        progress(&args.output),
+        args.index.as_deref(),
+    )?;
+    report = Positions::from_files(&files)?.resolve(report);
+    if let Some(changed) = &changed {
+        report = changed.filter(report);
+    }
+    if let Some(focus) = &focus {
+        report = focus.filter(report);
+    }
+    if let Some(baseline) = &args.output.baseline {
+        report = Baseline::from_file(baseline)?.filter(report);
+    }
+    warn_parse_errors(&report);
+    let measured: Vec<&SourceFile> = files
         .iter()
-        .map(|f| (f.path.clone(), f.content.clone(), f.language))
+        .filter(|f| changed.as_ref().is_none_or(|c| c.contains(&f.path)))
+        .filter(|f| focus.as_ref().is_none_or(|focus| focus.file == f.path))
+        .filter(|f| !report.parse_errors.iter().any(|e| e.file == f.path))
         .collect();
-    let clones = detector.detect_across_files(&files_for_clone_detection)?;
-
-    let report = Report::new(Vec::new(), clones);
+    report.count_lines(measured);
 
-    if json {
-        println!("{}", report.to_json()?);
-    } else {
-        print_clones_report(&report, &files, highlight);
+    let top = args.output.top.map(|n| report.with_top(n));
+    let shown = top.as_ref().unwrap_or(&report);
+    if let Some(reporter) = reporter(format, &args.output, &config, &files, SortOrder::File) {
+        write_report(&args.output, reporter.as_ref(), shown)?;
+    }
+    write_artifacts(&args.output, &config, &files, SortOrder::File, shown)?;
+    if matches!(format, OutputFormat::Text | OutputFormat::Github) {
+        // Quiet output lists clone locations without their code
+        let highlight = !args.no_highlight && args.output.verbosity() != Verbosity::Quiet;
+        print_clones_report(shown, &files, highlight, args.show_diff);
     }
 
+    enforce(&args.fail_args, &config, &report);
     Ok(())
 }
 
-fn print_clones_report(report: &Report, files: &[SourceFile], highlight: bool) {
+/// Detect clones in parsable files, dropping nested groups unless `keep_overlaps` is set and
+/// applying `//mccabre:ignore clone` directives; error-handling clusters and similar function
+/// pairs are added when enabled
+///
+/// With an `index` path the index there is loaded (or started), updated, and written back.
+pub fn clone_report(
+    files: &[SourceFile], config: &Config, jobs: usize, cache: Option<Cache>, progress: Option<Progress>,
+    index: Option<&Path>,
+) -> Result<Report> {
+    let mut detector = CloneDetector::new(config.clones.min_tokens)
+        .with_normalize_mode(config.clones.normalize)
+        .with_max_gap(config.clones.max_gap)
+        .with_strategy(config.clones.strategy)
+        .with_min_nodes(config.clones.min_nodes)
+        .with_granularity(config.clones.granularity)
+        .with_min_statements(config.clones.min_statements)
+        .with_min_instances(config.clones.min_instances)
+        .with_jobs(jobs);
+    if let Some(cache) = cache {
+        detector = detector.with_cache(cache);
+    }
+    if let Some(progress) = progress {
+        detector = detector.with_progress(progress);
+    }
+    let logger = logging::logger();
+    if let Some(logger) = &logger {
+        detector = detector.with_logger(logger.clone());
+    }
+    let (valid, parse_errors) = partition(files, jobs);
+    let files_for_clone_detection: Vec<_> = valid
+        .iter()
+        .filter(|f| !(config.clones.skip_tests && is_test_file(&f.path)))
+        .map(|f| (f.path.clone(), f.content.clone(), f.language))
+        .collect();
+    let clones = match index {
+        Some(path) => {
+            let mut index = load_index(path)?;
+            let clones = detector.detect_with_index(&mut index, &files_for_clone_detection)?;
+            index.save(path)?;
+            clones
+        }
+        None => detector.detect_across_files(&files_for_clone_detection)?,
+    };
+
+    let mut report = Report::new(Vec::new(), clones);
+    report.parse_errors = parse_errors;
+    detect_duplication(&mut report, &valid, &config.clones, jobs)?;
+    if !config.clones.keep_overlaps {
+        let dropped = report.dedupe_overlapping();
+        if let Some(logger) = &logger {
+            logger.debug(
+                "overlap dedup",
+                &[("kept", &report.clones.len()), ("dropped", &dropped)],
+            );
+        }
+    }
+    let report = Suppressions::from_files(&valid)?.filter(report);
+    if let Some(logger) = &logger {
+        logger.debug("suppressions", &[("clones_dropped", &report.suppressed.clones)]);
+    }
+    Ok(report)
+}
+
+/// The index at `path`, or an empty one when there is none yet or it cannot be read back
+fn load_index(path: &Path) -> Result<CloneIndex> {
+    if !path.exists() {
+        return Ok(CloneIndex::new());
+    }
+    match CloneIndex::load(path) {
+        Ok(index) => Ok(index),
+        Err(err @ MccabreError::InvalidIndex { .. }) => {
+            eprintln!("{}", format!("{err}; rebuilding it").yellow());
+            Ok(CloneIndex::new())
+        }
+        Err(err) => Err(err.into()),
+    }
+}
+
+fn print_clones_report(report: &Report, files: &[SourceFile], highlight: bool, show_diff: bool) {
     println!("{}", "=".repeat(80).cyan());
     println!("{}", "CLONE DETECTION REPORT".cyan().bold());
     println!("{}\n", "=".repeat(80).cyan());
 
     if report.clones.is_empty() {
         println!("{}", "No clones detected!".green().bold());
+        print_suppressed(report);
     } else {
-        println!(
+        print!(
             "{} {} {}",
             "Found".green().bold(),
-            report.clones.len().to_string().yellow().bold(),
+            report.summary.total_clones.to_string().yellow().bold(),
             "clone groups".green().bold()
         );
+        if report.clones.len() < report.summary.total_clones {
+            print!(" (showing the largest {})", report.clones.len());
+        }
+        println!();
+        print_suppressed(report);
         println!();
 
         let file_map: HashMap<_, _> = files.iter().map(|f| (&f.path, f)).collect();
@@ -73,16 +203,19 @@ fn print_clones_report(report: &Report, files: &[SourceFile], highlight: bool) {
                 format!("#{}", clone.id).yellow().bold(),
                 "(length:".dimmed(),
                 format!("{} tokens", clone.length).bold(),
-                format!("{} occurrences)", clone.locations.len()).bold(),
-                "".dimmed()
+                format!("{} instances)", clone.locations.len()).bold(),
+                clone.group_id.dimmed()
             );
 
             for loc in &clone.locations {
+                let gap_note =
+                    if loc.gap_tokens > 0 { format!(" ({} tokens differ)", loc.gap_tokens) } else { String::new() };
                 println!(
-                    "  {} {}:{}",
+                    "  {} {}:{}{}",
                     "-".dimmed(),
                     loc.file.display(),
-                    format!("{}-{}", loc.start_line, loc.end_line).dimmed()
+                    format!("{}-{}", loc.start_line, loc.end_line).dimmed(),
+                    gap_note.dimmed()
                 );
 
                 if highlight && let Some(source_file) = file_map.get(&loc.file) {
@@ -90,7 +223,8 @@ fn print_clones_report(report: &Report, files: &[SourceFile], highlight: bool) {
 
                     if let Some(ref hl) = highlighter {
                         let ext = source_file.path.extension().and_then(|e| e.to_str()).unwrap_or("txt");
-                        let highlighted = hl.highlight(&code_block, ext);
+                        let highlighted =
+                            if color::enabled() { hl.highlight(&code_block, ext) } else { code_block.clone() };
 
                         println!("{}", "    ┌─────".dimmed());
                         for line in highlighted.lines() {
@@ -100,9 +234,16 @@ fn print_clones_report(report: &Report, files: &[SourceFile], highlight: bool) {
                     }
                 }
             }
+            if show_diff {
+                print_instance_diffs(clone, &file_map);
+            }
             println!();
         }
     }
+    print_errcheck_clusters(report);
+    print_duplicate_declarations(report);
+    print_function_clones(report);
+    print_similar_functions(report);
 
     println!("{}", "=".repeat(80).cyan());
 }
@@ -0,0 +1,104 @@
+use crate::color::Colorize;
+use anyhow::Result;
+use mccabre_core::compare::{CloneChange, Comparison, FunctionChange};
+use std::path::Path;
+
+pub fn run(old: &Path, new: &Path, tolerance: f64, json: bool) -> Result<()> {
+    let comparison = Comparison::from_files(old, new)?;
+    let regressions = comparison.regressions(tolerance);
+
+    if json {
+        println!("{}", comparison.to_json()?);
+    } else {
+        print_comparison(&comparison);
+    }
+
+    if !regressions.is_empty() {
+        let summary: Vec<String> = regressions.iter().map(|r| r.to_string()).collect();
+        eprintln!("{} {}", "Failed:".red().bold(), summary.join("; "));
+        std::process::exit(1);
+    }
+    Ok(())
+}
+
+fn print_comparison(comparison: &Comparison) {
+    let (old, new) = (comparison.old_totals, comparison.new_totals);
+
+    println!();
+    println!("{}", "COMPARISON".green().bold());
+    println!("{}", "=".repeat(80).cyan());
+    println!();
+    println!(
+        "Total complexity:      {} -> {} (+{} -{})",
+        old.complexity,
+        new.complexity,
+        comparison.complexity_added(),
+        comparison.complexity_removed()
+    );
+    println!(
+        "Clone groups:          {} -> {} (+{} -{})",
+        old.clone_groups,
+        new.clone_groups,
+        comparison.clones_introduced.len(),
+        comparison.clones_resolved.len()
+    );
+    println!(
+        "Duplication:           {:.1}% -> {:.1}%",
+        old.duplication_ratio * 100.0,
+        new.duplication_ratio * 100.0
+    );
+
+    if !comparison.functions.is_empty() {
+        println!();
+        println!("{}", "FUNCTIONS".green().bold());
+        println!("{}", "-".repeat(80).cyan());
+        println!(
+            "{:<32} {:<30} {:>5} {:>5} {:>5}",
+            "FUNCTION", "FILE", "OLD", "NEW", "DELTA"
+        );
+        for change in &comparison.functions {
+            print_function(change);
+        }
+    }
+
+    print_clones("INTRODUCED CLONES", &comparison.clones_introduced);
+    print_clones("RESOLVED CLONES", &comparison.clones_resolved);
+    println!();
+}
+
+fn print_function(change: &FunctionChange) {
+    let score = |value: Option<usize>| value.map_or_else(|| "-".to_string(), |n| n.to_string());
+    let delta = change.delta();
+    let text = if delta > 0 { format!("+{delta}") } else { delta.to_string() };
+    let delta = if delta > 0 { text.red() } else { text.green() };
+
+    println!(
+        "{:<32} {:<30} {:>5} {:>5} {:>5}",
+        change.function,
+        change.file.display().to_string(),
+        score(change.old),
+        score(change.new),
+        delta
+    );
+}
+
+fn print_clones(title: &str, clones: &[CloneChange]) {
+    if clones.is_empty() {
+        return;
+    }
+
+    println!();
+    println!("{}", title.green().bold());
+    println!("{}", "-".repeat(80).cyan());
+    for clone in clones {
+        println!(
+            "{}  {:>4} tokens  {:>2} instances  {}:{}-{}",
+            clone.group_id,
+            clone.token_count,
+            clone.instances,
+            clone.file.display(),
+            clone.start_line,
+            clone.end_line
+        );
+    }
+}
@@ -1,52 +1,113 @@
+use crate::args::{ComplexityArgs, OutputFormat, Verbosity};
+use crate::color::Colorize;
+use crate::commands::{
+    analyze::{listed_files, print_findings, print_functions, print_suppressed},
+    changed_files, check_clone_limits, check_output, enforce, function_focus, progress, reporter, warn_config,
+    warn_parse_errors, write_artifacts, write_report,
+};
 use anyhow::Result;
 use mccabre_core::{
-    complexity::{CyclomaticMetrics, LocMetrics},
+    baseline::Baseline,
     config::Config,
-    loader::FileLoader,
+    loader::{FileLoader, SourceFile},
+    parallel::default_jobs,
+    position::Positions,
     reporter::{FileReport, Report},
+    suppress::Suppressions,
+    syntax::partition,
 };
-use owo_colors::OwoColorize;
-use std::path::PathBuf;
-
-pub fn run(
-    path: PathBuf, json: bool, threshold: Option<usize>, config_path: Option<PathBuf>, respect_gitignore: bool,
-) -> Result<()> {
-    let config = if let Some(config_path) = config_path {
-        Config::from_file(config_path)?
-    } else {
-        Config::load_default()?
-    };
-
-    let config = config.merge_with_cli(threshold, None, Some(respect_gitignore));
-    let loader = FileLoader::new().with_gitignore(config.files.respect_gitignore);
-    let files = loader.load(&path)?;
+
+pub fn run(args: ComplexityArgs) -> Result<()> {
+    let config = Config::load(args.config.as_deref())?;
+
+    let mut config = config.merge_with_cli(args.threshold, None, Some(!args.file_args.no_gitignore));
+    config.files.exclude.extend(args.file_args.exclude);
+    config.files.tags.extend(args.file_args.tags);
+    config.files.skip_cgo |= args.file_args.skip_cgo;
+    if let Some(max_nesting) = args.max_nesting {
+        config.complexity.max_nesting = max_nesting;
+    }
+    if let Some(limit) = args.max_func_lines {
+        config.complexity.max_function_lines = Some(limit);
+    }
+    if let Some(limit) = args.max_func_stmts {
+        config.complexity.max_function_statements = Some(limit);
+    }
+    if let Some(limit) = args.max_params {
+        config.complexity.max_parameters = Some(limit);
+    }
+    warn_config(&config);
+    let format = args.output.format(&config);
+    check_output(&args.output, format)?;
+    check_clone_limits(&args.fail_args)?;
+    let jobs = args.jobs.unwrap_or_else(default_jobs);
+    let loader = FileLoader::from_config(&config.files)?.with_jobs(jobs);
+    let mut files = args.input.load(&loader, &args.paths)?;
 
     if files.is_empty() {
         eprintln!("{}", "No supported files found".yellow());
         return Ok(());
     }
 
-    let mut file_reports = Vec::new();
-
-    for file in &files {
-        let loc = LocMetrics::calculate(&file.content, file.language)?;
-        let cyclomatic = CyclomaticMetrics::calculate(&file.content, file.language)?;
+    let changed = changed_files(&args.input, &files)?;
+    if changed
+        .as_ref()
+        .is_some_and(|c| !files.iter().any(|f| c.contains(&f.path)))
+    {
+        eprintln!("{}", "No changed source files".yellow());
+        return Ok(());
+    }
+    if let Some(changed) = &changed {
+        files.retain(|f| changed.contains(&f.path));
+    }
+    let focus = function_focus(&args.input, &files, jobs)?;
+    if let Some(focus) = &focus {
+        files.retain(|f| f.path == focus.file);
+    }
 
-        file_reports.push(FileReport { path: file.path.clone(), loc, cyclomatic });
+    let (valid, parse_errors) = partition(&files, jobs);
+    let file_reports = FileReport::from_files(
+        &valid,
+        jobs,
+        args.cache_args.open()?.as_ref(),
+        progress(&args.output).as_ref(),
+    )?;
+    let mut report = Report::new(file_reports, Vec::new());
+    report.parse_errors = parse_errors;
+    report.check_function_length(&config.complexity.length_limits());
+    report.check_parameters(config.complexity.max_parameters);
+    let mut report = Suppressions::from_files(&valid)?.filter(report);
+    report.classify(&config.complexity.severity_bands);
+    let mut report = Positions::from_files(&valid)?.resolve(report);
+    if let Some(focus) = &focus {
+        report = focus.filter(report);
+    }
+    if let Some(baseline) = &args.output.baseline {
+        report = Baseline::from_file(baseline)?.filter(report);
     }
 
-    let report = Report::new(file_reports, Vec::new());
+    report.sort(args.sort.into());
+    warn_parse_errors(&report);
 
-    if json {
-        println!("{}", report.to_json()?);
-    } else {
-        print_complexity_report(&report, &config);
+    let mut shown = report.with_min_complexity(args.min_complexity);
+    if let Some(n) = args.output.top {
+        shown = shown.with_top(n);
+    }
+    if let Some(reporter) = reporter(format, &args.output, &config, &files, args.sort.into()) {
+        write_report(&args.output, reporter.as_ref(), &shown)?;
+    }
+    write_artifacts(&args.output, &config, &files, args.sort.into(), &shown)?;
+    if matches!(format, OutputFormat::Text | OutputFormat::Github) {
+        print_complexity_report(&shown, &config, &files, args.output.verbosity(), args.explain);
     }
 
+    enforce(&args.fail_args, &config, &report);
     Ok(())
 }
 
-fn print_complexity_report(report: &Report, config: &Config) {
+fn print_complexity_report(
+    report: &Report, config: &Config, files: &[SourceFile], verbosity: Verbosity, explain: bool,
+) {
     println!("{}", "=".repeat(80).cyan());
     println!("{}", "COMPLEXITY ANALYSIS".cyan().bold());
     println!("{}\n", "=".repeat(80).cyan());
@@ -68,49 +129,51 @@ fn print_complexity_report(report: &Report, config: &Config) {
     );
     println!("Maximum complexity:          {}", report.summary.max_complexity.bold());
     println!(
-        "High complexity files:       {}\n",
+        "High complexity files:       {}",
         report.summary.high_complexity_files.bold()
     );
+    print_suppressed(report);
+    println!();
 
-    println!("{}", "FILE METRICS".green().bold());
-    println!("{}", "-".repeat(80).cyan());
+    let listed = listed_files(report, config, verbosity);
+    if !listed.is_empty() {
+        println!("{}", "FILE METRICS".green().bold());
+        println!("{}", "-".repeat(80).cyan());
+    }
 
-    for file in &report.files {
+    for (file, functions) in listed {
         println!("{} {}", "FILE:".blue().bold(), file.path.display().bold());
 
+        let thresholds = config.complexity.for_path(&file.path);
+        let source = files.iter().find(|f| explain && f.path == file.path);
+        if verbosity == Verbosity::Quiet {
+            print_functions(&functions, &thresholds, verbosity, source);
+            print_findings(file);
+            continue;
+        }
         let complexity_value = file.cyclomatic.file_complexity;
         let complexity_text = format!("Cyclomatic Complexity:   {complexity_value}");
 
-        if complexity_value > config.complexity.error_threshold {
+        if complexity_value > thresholds.error_threshold {
             println!("    {}", complexity_text.red().bold());
-        } else if complexity_value > config.complexity.warning_threshold {
+        } else if complexity_value > thresholds.warning_threshold {
             println!("    {}", complexity_text.yellow());
         } else {
             println!("    {}", complexity_text.green());
         }
+        println!("    Maintainability index:   {:.1}", file.maintainability_index);
         println!("    Physical LOC:            {}", file.loc.physical);
         println!("    Logical LOC:             {}", file.loc.logical);
+        println!("    Statements:              {}", file.loc.statements);
         println!("    Comment lines:           {}", file.loc.comments);
+        println!(
+            "    Comment density:         {:.1}%",
+            file.loc.comment_density() * 100.0
+        );
         println!("    Blank lines:             {}\n", file.loc.blank);
 
-        if !file.cyclomatic.functions.is_empty() {
-            println!("    {}:", "Functions".magenta());
-            for func in &file.cyclomatic.functions {
-                let func_text = format!(
-                    "      - {} (line {}): complexity {}",
-                    func.name, func.line, func.complexity
-                );
-
-                if func.complexity > config.complexity.error_threshold {
-                    println!("{}", func_text.red());
-                } else if func.complexity > config.complexity.warning_threshold {
-                    println!("{}", func_text.yellow());
-                } else {
-                    println!("{func_text}");
-                }
-            }
-            println!();
-        }
+        print_functions(&functions, &thresholds, verbosity, source);
+        print_findings(file);
     }
 
     println!("{}", "=".repeat(80).cyan());
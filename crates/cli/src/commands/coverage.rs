@@ -1,7 +1,7 @@
+use crate::color::Colorize;
 use anyhow::Result;
 use mccabre_core::coverage::{FileCoverage, parse_coverage_from_file};
 use mccabre_core::reporter::{coverage_jsonl::JsonlReporter, coverage_term::report_coverage};
-use owo_colors::OwoColorize;
 use std::fs;
 use std::path::{Path, PathBuf};
 
@@ -0,0 +1,179 @@
+use crate::args::{CloneArgs, FileArgs};
+use crate::color::Colorize;
+use crate::commands::{analyze::merge_config, dump_config::print_config};
+use anyhow::Result;
+use mccabre_core::{
+    config::{Config, DEFAULT_CONFIG_FILES, FileConfig},
+    constraint::BuildContext,
+    loader::{FileLoader, go_env, is_test_file, resolve_target},
+    tokenizer::Language,
+};
+use std::path::{Path, PathBuf};
+
+pub fn run(
+    path: PathBuf, threshold: Option<usize>, config_path: Option<PathBuf>, clone_args: CloneArgs, file_args: FileArgs,
+) -> Result<()> {
+    let config = merge_config(config_path.as_deref(), threshold, &clone_args, &file_args)?;
+    let mut problems = Vec::new();
+
+    println!();
+    println!("{}", "DOCTOR".green().bold());
+    println!("{}", "=".repeat(80).cyan());
+    println!();
+
+    println!("{}", "Config File:".yellow().bold());
+    match config_path.or_else(|| DEFAULT_CONFIG_FILES.iter().map(PathBuf::from).find(|p| p.exists())) {
+        Some(path) => println!("  {}", path.display()),
+        None => println!("  none found, using defaults"),
+    }
+    println!();
+
+    check_target(&path, &config, &mut problems);
+    check_toolchain(&config.files, &mut problems);
+
+    print_config(&config);
+
+    problems.extend(config.warnings());
+
+    println!("{}", "Checks:".yellow().bold());
+    if problems.is_empty() {
+        println!("  {}", "No problems found".green());
+    }
+    for problem in &problems {
+        println!("  {} {problem}", "Warning:".yellow().bold());
+    }
+
+    println!();
+    println!("{}", "=".repeat(80).cyan());
+    println!();
+
+    Ok(())
+}
+
+/// Count the files the target selects, by language, and the files each filter leaves out
+fn check_target(path: &Path, config: &Config, problems: &mut Vec<String>) {
+    let files = &config.files;
+    println!("{} {}", "Target:".yellow().bold(), path.display());
+
+    let loaded = match FileLoader::from_config(files).and_then(|loader| loader.load_targets(&[path])) {
+        Ok(loaded) => loaded,
+        Err(e) => {
+            println!("  {}", e.to_string().red());
+            problems.push(format!("{} cannot be analyzed: {e}", path.display()));
+            println!();
+            return;
+        }
+    };
+
+    let mut languages: Vec<(Language, usize)> = Vec::new();
+    for file in &loaded {
+        match languages.iter_mut().find(|(language, _)| *language == file.language) {
+            Some((_, count)) => *count += 1,
+            None => languages.push((file.language, 1)),
+        }
+    }
+    println!("  Supported files:       {}", loaded.len());
+    for (language, count) in &languages {
+        println!("    {:<20} {count}", format!("{language:?}:"));
+    }
+
+    let go: Vec<&Path> = loaded
+        .iter()
+        .filter(|file| file.language == Language::Go)
+        .map(|file| file.path.as_path())
+        .collect();
+    let tests = go.iter().filter(|path| is_test_file(path)).count();
+    println!("  Go test files:         {tests}");
+
+    let relaxed = [
+        (
+            "Skipped by .gitignore:",
+            files.respect_gitignore,
+            FileConfig { respect_gitignore: false, ..files.clone() },
+        ),
+        (
+            "Skipped as generated:",
+            files.skip_generated,
+            FileConfig { skip_generated: false, ..files.clone() },
+        ),
+        (
+            "Skipped under vendor/:",
+            files.skip_vendor,
+            FileConfig { skip_vendor: false, ..files.clone() },
+        ),
+        (
+            "Skipped by exclude:",
+            !files.exclude.is_empty(),
+            FileConfig { exclude: Vec::new(), ..files.clone() },
+        ),
+    ];
+    for (label, enabled, relaxed) in relaxed {
+        if enabled {
+            let skipped = count_files(path, &relaxed).saturating_sub(loaded.len());
+            println!("  {label:<22} {skipped}");
+        }
+    }
+    println!();
+
+    if go.is_empty() {
+        problems.push(format!("{} has no Go files", path.display()));
+    } else if tests == go.len() && config.clones.skip_tests {
+        problems.push("every Go file is a _test.go file, and clones.skip_tests leaves them all out".to_string());
+    }
+}
+
+/// Files a target selects under other `[files]` settings, or 0 when it cannot be loaded
+fn count_files(path: &Path, files: &FileConfig) -> usize {
+    FileLoader::from_config(files)
+        .and_then(|loader| loader.load(resolve_target(path)?))
+        .map_or(0, |loaded| loaded.len())
+}
+
+/// Report the installed Go toolchain and the build context mccabre evaluates constraints with
+fn check_toolchain(files: &FileConfig, problems: &mut Vec<String>) {
+    let mut build = BuildContext::new().with_tags(&files.tags);
+    if files.skip_cgo {
+        build = build.with_cgo(false);
+    }
+
+    println!("{}", "Go Toolchain:".yellow().bold());
+    let env = match go_env() {
+        Ok(env) => {
+            println!("  Version:               {}", env.version);
+            Some(env)
+        }
+        Err(e) => {
+            println!("  {}", e.to_string().red());
+            problems.push("go is not installed: Go import paths and module@version targets cannot be resolved".into());
+            None
+        }
+    };
+
+    println!("  GOOS/GOARCH:           {}/{}", build.goos(), build.goarch());
+    println!("  cgo:                   {}", build.cgo());
+    let tags: Vec<&str> = build.tags().collect();
+    println!(
+        "  Extra tags:            {}",
+        if tags.is_empty() { "none".to_string() } else { tags.join(",") }
+    );
+    println!();
+
+    if let Some(env) = env {
+        if (env.goos.as_str(), env.goarch.as_str()) != (build.goos(), build.goarch()) {
+            problems.push(format!(
+                "go env targets {}/{} but files are selected for {}/{}: set GOOS and GOARCH to match",
+                env.goos,
+                env.goarch,
+                build.goos(),
+                build.goarch()
+            ));
+        }
+        if env.cgo != build.cgo() && !files.skip_cgo {
+            problems.push(format!(
+                "go env has CGO_ENABLED={} but cgo files are {}: set CGO_ENABLED or use --skip-cgo",
+                u8::from(env.cgo),
+                if build.cgo() { "analyzed" } else { "skipped" }
+            ));
+        }
+    }
+}
@@ -1,6 +1,6 @@
+use crate::color::Colorize;
 use anyhow::Result;
 use mccabre_core::config::Config;
-use owo_colors::OwoColorize;
 use std::path::PathBuf;
 
 pub fn run(config_path: Option<PathBuf>, output_path: Option<PathBuf>) -> Result<()> {
@@ -17,31 +17,94 @@ pub fn run(config_path: Option<PathBuf>, output_path: Option<PathBuf>) -> Result
     println!("{}", "=".repeat(80).cyan());
     println!();
 
+    print_config(&config);
+
+    println!("{}", "=".repeat(80).cyan());
+    println!();
+
+    if let Some(output) = output_path {
+        let save_path = if output.is_dir() { output.join("mccabre.toml") } else { output };
+
+        config.save(&save_path)?;
+        println!("{} {}", "Configuration saved to:".green().bold(), save_path.display());
+    } else {
+        println!("{}", "To save this configuration, use --output <path>.".dimmed());
+    }
+
+    Ok(())
+}
+
+/// Print every setting of `config`, grouped by section
+pub fn print_config(config: &Config) {
     println!("{}", "Complexity Settings:".yellow().bold());
     println!("  Warning threshold:     {}", config.complexity.warning_threshold);
     println!("  Error threshold:       {}", config.complexity.error_threshold);
+    println!("  Max nesting:           {}", config.complexity.max_nesting);
+    let limit = |limit: Option<usize>| limit.map_or_else(|| "unlimited".to_string(), |n| n.to_string());
+    println!(
+        "  Max function lines:    {}",
+        limit(config.complexity.max_function_lines)
+    );
+    println!(
+        "  Max statements:        {}",
+        limit(config.complexity.max_function_statements)
+    );
+    println!("  Max parameters:        {}", limit(config.complexity.max_parameters));
+    let bands = &config.complexity.severity_bands;
+    println!(
+        "  Severity bands:        moderate {}, high {}, very high {}",
+        bands.moderate, bands.high, bands.very_high
+    );
+    for entry in &config.complexity.overrides {
+        let value = |value: Option<usize>| value.map_or_else(|| "-".to_string(), |n| n.to_string());
+        println!(
+            "  Override {}: warning {}, error {}, nesting {}",
+            entry.path,
+            value(entry.warning_threshold),
+            value(entry.error_threshold),
+            value(entry.max_nesting)
+        );
+    }
     println!();
 
     println!("{}", "Clone Detection Settings:".yellow().bold());
     println!("  Enabled:               {}", config.clones.enabled);
     println!("  Minimum tokens:        {}", config.clones.min_tokens);
+    println!("  Minimum instances:     {}", config.clones.min_instances);
+    println!("  Normalize:             {}", config.clones.normalize);
+    println!("  Maximum gap:           {}", config.clones.max_gap);
+    println!("  Strategy:              {}", config.clones.strategy);
+    println!("  Minimum nodes:         {}", config.clones.min_nodes);
+    println!("  Granularity:           {}", config.clones.granularity);
+    println!("  Minimum statements:    {}", config.clones.min_statements);
+    println!("  Keep overlaps:         {}", config.clones.keep_overlaps);
+    println!("  Skip tests:            {}", config.clones.skip_tests);
+    println!("  Errcheck clusters:     {}", config.clones.report_errcheck);
+    println!("  Declaration blocks:    {}", config.clones.report_declarations);
+    println!("  Function bodies:       {}", config.clones.report_function_clones);
+    println!(
+        "  Similarity threshold:  {}",
+        config
+            .clones
+            .similarity_threshold
+            .map_or_else(|| "off".to_string(), |t| t.to_string())
+    );
     println!();
 
     println!("{}", "File Settings:".yellow().bold());
     println!("  Respect .gitignore:    {}", config.files.respect_gitignore);
+    println!("  Skip generated files:  {}", config.files.skip_generated);
+    println!("  Skip vendor/:          {}", config.files.skip_vendor);
+    println!("  Skip cgo files:        {}", config.files.skip_cgo);
+    if !config.files.exclude.is_empty() {
+        println!("  Exclude:               {}", config.files.exclude.join(", "));
+    }
+    if !config.files.tags.is_empty() {
+        println!("  Build tags:            {}", config.files.tags.join(", "));
+    }
     println!();
 
-    println!("{}", "=".repeat(80).cyan());
+    println!("{}", "Output Settings:".yellow().bold());
+    println!("  Format:                {}", config.output.format);
     println!();
-
-    if let Some(output) = output_path {
-        let save_path = if output.is_dir() { output.join("mccabre.toml") } else { output };
-
-        config.save(&save_path)?;
-        println!("{} {}", "Configuration saved to:".green().bold(), save_path.display());
-    } else {
-        println!("{}", "To save this configuration, use --output <path>.".dimmed());
-    }
-
-    Ok(())
 }
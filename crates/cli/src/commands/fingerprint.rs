@@ -0,0 +1,59 @@
+use crate::args::FingerprintArgs;
+use crate::color::Colorize;
+use crate::commands::{analyze::load_config, clones::clone_report, warn_parse_errors};
+use anyhow::Result;
+use mccabre_core::{
+    cloner::{CloneStrategy, NormalizeMode, fingerprint_clones},
+    loader::FileLoader,
+    parallel::default_jobs,
+    position::Positions,
+};
+
+pub fn run(args: FingerprintArgs) -> Result<()> {
+    let config = load_config(args.config.as_deref(), None, &args.clone_args, &args.file_args)?;
+    let jobs = args.jobs.unwrap_or_else(default_jobs);
+    let loader = FileLoader::from_config(&config.files)?.with_jobs(jobs);
+    let files = loader.load_targets(&args.paths)?;
+
+    if files.is_empty() {
+        eprintln!("{}", "No supported files found".yellow());
+        return Ok(());
+    }
+
+    let report = clone_report(&files, &config, jobs, args.cache_args.open()?, None, None)?;
+    warn_parse_errors(&report);
+    // The AST strategy always matches renamed copies, so hash them the same way
+    let mode = match config.clones.strategy {
+        CloneStrategy::Ast => NormalizeMode::Renamed,
+        CloneStrategy::Token => config.clones.normalize,
+    };
+    let mut fingerprints = fingerprint_clones(&report.clones, &files, mode)?;
+    let positions = Positions::from_files(&files)?;
+    for fp in &mut fingerprints {
+        let (start, end) = (
+            positions.position(&fp.file, fp.start_line),
+            positions.position(&fp.file, fp.end_line),
+        );
+        fp.end_line = if end.file == start.file { end.line } else { start.line };
+        fp.start_line = start.line;
+        fp.file = start.file;
+    }
+
+    if args.json {
+        println!("{}", serde_json::to_string_pretty(&fingerprints)?);
+        return Ok(());
+    }
+
+    for fp in &fingerprints {
+        println!(
+            "{}  {:>4} tokens  {:>2} instances  {}:{}-{}",
+            fp.fingerprint,
+            fp.token_count,
+            fp.instances,
+            fp.file.display(),
+            fp.start_line,
+            fp.end_line
+        );
+    }
+    Ok(())
+}
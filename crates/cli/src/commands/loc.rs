@@ -1,24 +1,24 @@
+use crate::args::FileArgs;
+use crate::color::Colorize;
 use anyhow::Result;
 use mccabre_core::{
     complexity::loc::{FileLocReport, LocMetrics, LocReport, RankBy},
     config::Config,
     loader::FileLoader,
 };
-use owo_colors::OwoColorize;
 use std::path::PathBuf;
 
 pub fn run(
-    path: PathBuf, json: bool, rank_by: RankBy, rank_dirs: bool, config_path: Option<PathBuf>, respect_gitignore: bool,
+    path: PathBuf, json: bool, rank_by: RankBy, rank_dirs: bool, config_path: Option<PathBuf>, file_args: FileArgs,
 ) -> Result<()> {
-    let config = if let Some(config_path) = config_path {
-        Config::from_file(config_path)?
-    } else {
-        Config::load_default()?
-    };
-
-    let config = config.merge_with_cli(None, None, Some(respect_gitignore));
-    let loader = FileLoader::new().with_gitignore(config.files.respect_gitignore);
-    let files = loader.load(&path)?;
+    let config = Config::load(config_path.as_deref())?;
+
+    let mut config = config.merge_with_cli(None, None, Some(!file_args.no_gitignore));
+    config.files.exclude.extend(file_args.exclude);
+    config.files.tags.extend(file_args.tags);
+    config.files.skip_cgo |= file_args.skip_cgo;
+    let loader = FileLoader::from_config(&config.files)?;
+    let files = loader.load_targets(&[path])?;
 
     if files.is_empty() {
         eprintln!("{}", "No supported files found".yellow());
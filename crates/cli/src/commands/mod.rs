@@ -1,6 +1,276 @@
 pub mod analyze;
+pub mod baseline;
 pub mod clones;
+pub mod compare;
 pub mod complexity;
 pub mod coverage;
+pub mod doctor;
 pub mod dump_config;
+pub mod fingerprint;
 pub mod loc;
+pub mod rules;
+pub mod watch;
+
+use crate::args::{FailArgs, FailOn, InputArgs, OutputArgs, OutputFormat, Verbosity};
+use crate::color::Colorize;
+use anyhow::{Context, Result, bail};
+use mccabre_core::{
+    cloner::{CloneStrategy, Granularity},
+    config::{CloneConfig, Config},
+    diff::ChangedFiles,
+    focus::FunctionFocus,
+    loader::SourceFile,
+    parallel::Progress,
+    reporter::{
+        CsvReporter, GithubReporter, HtmlReporter, JsonReporter, JunitReporter, MarkdownReporter, Report, Reporter,
+        SarifReporter, SortOrder,
+    },
+};
+use std::fs::{self, File};
+use std::io::{self, BufWriter, IsTerminal, Write};
+use std::path::Path;
+use std::sync::{Mutex, PoisonError};
+
+/// Files between redraws of the progress counter
+const PROGRESS_EVERY: usize = 25;
+
+/// Exit with code 1 when the report exceeds a failure limit, naming the limits that tripped
+///
+/// With `--max-duplication`, the duplication percentage is printed first along with the clone
+/// settings it was measured with, whether or not it is over the limit.
+pub fn enforce(fail_args: &FailArgs, config: &Config, report: &Report) {
+    if let Some(limit) = fail_args.max_duplication {
+        let summary = &report.summary;
+        eprintln!(
+            "{} {:.2}% ({} of {} lines, limit {limit}%) with {}",
+            "Duplication:".bold(),
+            summary.duplication_ratio * 100.0,
+            summary.duplicated_lines,
+            summary.total_physical_loc,
+            clone_settings(&config.clones)
+        );
+    }
+
+    let violations = fail_args.policy(config).check(report);
+    if violations.is_empty() {
+        return;
+    }
+
+    let summary: Vec<String> = violations.iter().map(|v| v.to_string()).collect();
+    eprintln!("{} {}", "Failed:".red().bold(), summary.join("; "));
+    std::process::exit(1);
+}
+
+/// The clone settings that decide how many lines count as duplicated
+fn clone_settings(clones: &CloneConfig) -> String {
+    let mut settings = if !clones.enabled {
+        "clone detection disabled".to_string()
+    } else if clones.strategy == CloneStrategy::Ast {
+        format!("strategy ast, min nodes {}", clones.min_nodes)
+    } else if clones.granularity == Granularity::Statement {
+        format!("granularity statement, min statements {}", clones.min_statements)
+    } else if clones.max_gap > 0 {
+        format!("min tokens {}, max gap {}", clones.min_tokens, clones.max_gap)
+    } else {
+        format!("min tokens {}", clones.min_tokens)
+    };
+    if clones.enabled {
+        settings.push_str(&format!(
+            ", normalize {}, min instances {}",
+            clones.normalize, clones.min_instances
+        ));
+    }
+    if clones.skip_tests {
+        settings.push_str(", tests skipped");
+    }
+    settings
+}
+
+/// Name each file skipped because it could not be parsed, on stderr
+pub fn warn_parse_errors(report: &Report) {
+    for error in &report.parse_errors {
+        eprintln!("{} {error}", "Skipped:".yellow().bold());
+    }
+}
+
+/// Name each setting that hides findings or flags everything, on stderr
+pub fn warn_config(config: &Config) {
+    let warnings = config.warnings();
+    for warning in &warnings {
+        eprintln!("{} {warning}", "Warning:".yellow().bold());
+    }
+    if !warnings.is_empty() {
+        eprintln!("{}", "Run `mccabre doctor` to check the setup".dimmed());
+    }
+}
+
+/// Files changed since the `--diff` revision or modified after `--since`, or `None` to report
+/// on every file
+///
+/// Outside a git work tree `--diff` warns and falls back to a full analysis.
+pub fn changed_files(input: &InputArgs, files: &[SourceFile]) -> Result<Option<ChangedFiles>> {
+    if let Some(time) = input.since {
+        return Ok(Some(ChangedFiles::modified_since(files, time)));
+    }
+    let Some(rev) = &input.diff else {
+        return Ok(None);
+    };
+
+    let changed = ChangedFiles::since(rev, Path::new("."))?;
+    if changed.is_none() {
+        eprintln!("{}", "Not a git repository; analyzing all files".yellow());
+    }
+    Ok(changed)
+}
+
+/// Locate the `--func` target, failing when no file or more than one file defines it
+pub fn function_focus(input: &InputArgs, files: &[SourceFile], jobs: usize) -> Result<Option<FunctionFocus>> {
+    match &input.function {
+        Some(name) => Ok(Some(FunctionFocus::find(files, name, jobs)?)),
+        None => Ok(None),
+    }
+}
+
+/// Reject clone limits on a command that does not detect clones, where they could never trip
+pub fn check_clone_limits(fail_args: &FailArgs) -> Result<()> {
+    if fail_args.max_clones.is_some()
+        || fail_args.max_duplication.is_some()
+        || fail_args.fail_on.contains(&FailOn::Clone)
+    {
+        bail!(
+            "`complexity` does not detect clones: use `analyze` or `clones` for --max-clones, --max-duplication, or --fail-on clone"
+        );
+    }
+    Ok(())
+}
+
+/// Reject `--output` with a format that is printed to the terminal
+pub fn check_output(output: &OutputArgs, format: OutputFormat) -> Result<()> {
+    if output.output.is_some() && matches!(format, OutputFormat::Text | OutputFormat::Github) {
+        bail!("--output needs a file format: json, sarif, html, junit, csv, or markdown");
+    }
+    if output.formats.iter().any(|&f| extension(f).is_none()) {
+        bail!("--formats needs file formats: json, sarif, html, junit, csv, or markdown");
+    }
+    Ok(())
+}
+
+/// Write a rendered report to `--output`, or print it to stdout
+pub fn emit(output: &OutputArgs, content: &str) -> Result<()> {
+    let mut content = content.to_string();
+    if !content.ends_with('\n') {
+        content.push('\n');
+    }
+
+    match &output.output {
+        Some(path) => fs::write(path, content).with_context(|| format!("Failed to write {}", path.display())),
+        None => {
+            print!("{content}");
+            Ok(())
+        }
+    }
+}
+
+/// The reporter for `format`, or `None` for the colored terminal report
+///
+/// GitHub annotations are printed ahead of the terminal report, so callers print that too.
+/// JSON lists functions in `order`.
+pub fn reporter<'a>(
+    format: OutputFormat, output: &OutputArgs, config: &Config, files: &'a [SourceFile], order: SortOrder,
+) -> Option<Box<dyn Reporter + 'a>> {
+    let thresholds = config.complexity.clone();
+    Some(match format {
+        OutputFormat::Text => return None,
+        OutputFormat::Json => Box::new(JsonReporter::new(order)),
+        OutputFormat::Sarif => Box::new(SarifReporter::new(thresholds)),
+        OutputFormat::Html => Box::new(HtmlReporter::new(files, thresholds)),
+        OutputFormat::Github => Box::new(GithubReporter::new(thresholds)),
+        OutputFormat::Junit => Box::new(JunitReporter::new(thresholds, !output.junit_failures_only)),
+        OutputFormat::Csv => Box::new(CsvReporter),
+        OutputFormat::Markdown => {
+            Box::new(MarkdownReporter::new(files, thresholds).with_budget(output.markdown_budget))
+        }
+    })
+}
+
+/// Render a report to `--output`, or to stdout
+pub fn write_report(output: &OutputArgs, reporter: &dyn Reporter, report: &Report) -> Result<()> {
+    match &output.output {
+        Some(path) => {
+            let file = File::create(path).with_context(|| format!("Failed to write {}", path.display()))?;
+            let mut writer = BufWriter::new(file);
+            reporter.report(&mut writer, report)?;
+            writer.flush()?;
+        }
+        None => reporter.report(&mut io::stdout().lock(), report)?,
+    }
+    Ok(())
+}
+
+/// Write every `--formats` report into `--output-dir`, rendering each from the one `report`
+///
+/// Each format is written to `report.<ext>`, so a CI job gets all its artifacts from a single
+/// analysis instead of running mccabre once per format.
+pub fn write_artifacts(
+    output: &OutputArgs, config: &Config, files: &[SourceFile], order: SortOrder, report: &Report,
+) -> Result<()> {
+    let Some(dir) = &output.output_dir else {
+        return Ok(());
+    };
+    fs::create_dir_all(dir).with_context(|| format!("Failed to create {}", dir.display()))?;
+
+    for &format in &output.formats {
+        let (Some(ext), Some(reporter)) = (extension(format), reporter(format, output, config, files, order)) else {
+            continue;
+        };
+        let path = dir.join(format!("report.{ext}"));
+        let file = File::create(&path).with_context(|| format!("Failed to write {}", path.display()))?;
+        let mut writer = BufWriter::new(file);
+        reporter.report(&mut writer, report)?;
+        writer.flush()?;
+        if output.verbosity() != Verbosity::Quiet {
+            eprintln!("{} {}", "Wrote".dimmed(), path.display());
+        }
+    }
+    Ok(())
+}
+
+/// File extension for a format written by `--output-dir`, or `None` for terminal formats
+fn extension(format: OutputFormat) -> Option<&'static str> {
+    match format {
+        OutputFormat::Text | OutputFormat::Github => None,
+        OutputFormat::Json => Some("json"),
+        OutputFormat::Sarif => Some("sarif"),
+        OutputFormat::Html => Some("html"),
+        OutputFormat::Junit => Some("xml"),
+        OutputFormat::Csv => Some("csv"),
+        OutputFormat::Markdown => Some("md"),
+    }
+}
+
+/// A `files done / total` counter on stderr, unless the verbosity is quiet or stderr is not a terminal
+///
+/// The counter is redrawn in place and erased when a phase finishes, before anything is printed to
+/// stdout. Workers finish out of order, so a count lower than one already drawn is skipped.
+pub fn progress(output: &OutputArgs) -> Option<Progress> {
+    if output.verbosity() == Verbosity::Quiet || !io::stderr().is_terminal() {
+        return None;
+    }
+
+    let drawn = Mutex::new(0);
+    Some(Progress::new(move |phase, done, total| {
+        let mut drawn = drawn.lock().unwrap_or_else(PoisonError::into_inner);
+        if done == 0 {
+            *drawn = 0;
+        } else if done <= *drawn || (done < total && done % PROGRESS_EVERY != 0) {
+            return;
+        }
+
+        *drawn = done;
+        if done == total {
+            eprint!("\r\x1b[2K");
+        } else {
+            eprint!("\r{phase} {done}/{total} files");
+        }
+    }))
+}
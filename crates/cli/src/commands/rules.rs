@@ -0,0 +1,36 @@
+use crate::color::Colorize;
+use anyhow::Result;
+use mccabre_core::rules::catalog;
+
+pub fn run(json: bool) -> Result<()> {
+    let rules = catalog();
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&rules)?);
+        return Ok(());
+    }
+
+    println!();
+    println!("{}", "RULES".green().bold());
+    println!("{}", "=".repeat(80).cyan());
+    println!();
+
+    for rule in &rules {
+        println!("{}", rule.id.yellow().bold());
+        println!("  {}", rule.description);
+        if !rule.config_keys.is_empty() {
+            let default = rule
+                .default_threshold
+                .map_or_else(|| "off".to_string(), |n| n.to_string());
+            println!(
+                "  Config:                {} (default: {default})",
+                rule.config_keys.join(", ")
+            );
+        }
+        let formats: Vec<String> = rule.formats.iter().map(|format| format.to_string()).collect();
+        println!("  Formats:               {}", formats.join(", "));
+        println!();
+    }
+
+    Ok(())
+}
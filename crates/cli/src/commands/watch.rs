@@ -0,0 +1,116 @@
+use crate::args::WatchArgs;
+use crate::color::Colorize;
+use crate::commands::analyze::{build_report, load_config, print_suppressed};
+use anyhow::Result;
+use mccabre_core::{
+    cache::Cache,
+    config::Config,
+    loader::{FileLoader, resolve_target},
+    parallel::default_jobs,
+    reporter::{Report, SortOrder},
+    watch::Watcher,
+};
+use std::path::{Path, PathBuf};
+use std::time::Duration;
+
+pub fn run(args: WatchArgs) -> Result<()> {
+    let mut config = load_config(
+        args.config.as_deref(),
+        args.threshold,
+        &args.clone_args,
+        &args.file_args,
+    )?;
+    if let Some(max_nesting) = args.max_nesting {
+        config.complexity.max_nesting = max_nesting;
+    }
+    if let Some(limit) = args.max_func_lines {
+        config.complexity.max_function_lines = Some(limit);
+    }
+    if let Some(limit) = args.max_func_stmts {
+        config.complexity.max_function_statements = Some(limit);
+    }
+    if let Some(limit) = args.max_params {
+        config.complexity.max_parameters = Some(limit);
+    }
+    let jobs = args.jobs.unwrap_or_else(default_jobs);
+    let cache = args.cache_args.open()?;
+
+    let path = resolve_target(&args.path)?;
+
+    let mut watcher = Watcher::new(FileLoader::from_config(&config.files)?, &path)?
+        .with_debounce(Duration::from_millis(args.debounce));
+
+    analyze(&path, &config, jobs, cache.as_ref(), &[]);
+    loop {
+        let changed = watcher.wait()?;
+        analyze(&path, &config, jobs, cache.as_ref(), &changed);
+    }
+}
+
+/// Analyze the tree and print a summary; errors are printed so a bad save does not end the watch
+///
+/// With the cache, only changed files are tokenized and measured again.
+fn analyze(path: &Path, config: &Config, jobs: usize, cache: Option<&Cache>, changed: &[PathBuf]) {
+    let result = FileLoader::from_config(&config.files)
+        .map(|loader| loader.with_jobs(jobs))
+        .and_then(|loader| loader.load(path))
+        .map_err(anyhow::Error::from)
+        .and_then(|files| build_report(&files, config, jobs, cache, None));
+
+    println!("{}", "-".repeat(80).cyan());
+    if !changed.is_empty() {
+        let names: Vec<String> = changed.iter().map(|p| p.display().to_string()).collect();
+        println!("{} {}", "Changed:".blue().bold(), names.join(", "));
+    }
+
+    match result {
+        Ok(report) => print_summary(&report, config),
+        Err(e) => eprintln!("{} {e}", "Error:".red().bold()),
+    }
+    println!("{}", "Watching for changes (Ctrl-C to stop)".dimmed());
+}
+
+fn print_summary(report: &Report, config: &Config) {
+    println!("Total files analyzed:        {}", report.summary.total_files.bold());
+    println!(
+        "Average complexity:          {}",
+        format!("{:.2}", report.summary.avg_complexity).bold()
+    );
+    println!("Maximum complexity:          {}", report.summary.max_complexity.bold());
+    println!("Clone groups detected:       {}", report.summary.total_clones.bold());
+    println!(
+        "Duplicated lines:            {} ({:.1}%)",
+        report.summary.duplicated_lines.bold(),
+        report.summary.duplication_ratio * 100.0
+    );
+    print_suppressed(report);
+
+    let mut over: Vec<_> = report
+        .files
+        .iter()
+        .flat_map(|file| {
+            let thresholds = config.complexity.for_path(&file.path);
+            file.cyclomatic
+                .functions
+                .iter()
+                .filter(move |func| func.cyclomatic > thresholds.warning_threshold)
+                .map(move |func| (file, func, thresholds.error_threshold))
+        })
+        .collect();
+    over.sort_by(|a, b| SortOrder::Complexity.compare((&a.0.path, a.1), (&b.0.path, b.1)));
+
+    for (file, func, error_threshold) in over {
+        let text = format!(
+            "  {}:{} {} (cyclomatic {})",
+            file.path.display(),
+            func.line,
+            func.name,
+            func.cyclomatic
+        );
+        if func.cyclomatic > error_threshold {
+            println!("{}", text.red());
+        } else {
+            println!("{}", text.yellow());
+        }
+    }
+}
@@ -0,0 +1,16 @@
+use mccabre_core::logging::Logger;
+use std::sync::atomic::{AtomicBool, Ordering};
+
+static ENABLED: AtomicBool = AtomicBool::new(false);
+
+/// Decide once whether `--debug` records are written
+pub fn init(debug: bool) {
+    ENABLED.store(debug, Ordering::Relaxed);
+}
+
+/// A logger writing each record to stderr as a `level=DEBUG` line, or `None` without `--debug`
+pub fn logger() -> Option<Logger> {
+    ENABLED
+        .load(Ordering::Relaxed)
+        .then(|| Logger::new(|record| eprintln!("level=DEBUG {record}")))
+}
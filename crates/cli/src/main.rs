@@ -1,6 +1,10 @@
+mod args;
+mod color;
 mod commands;
+mod logging;
 
 use anyhow::Result;
+use args::{AnalyzeArgs, CacheArgs, CloneArgs, ClonesArgs, ComplexityArgs, FileArgs, FingerprintArgs, WatchArgs};
 use clap::{Parser, Subcommand};
 use mccabre_core::complexity::loc::RankBy;
 use std::path::PathBuf;
@@ -12,105 +16,122 @@ use std::path::PathBuf;
 struct Cli {
     #[command(subcommand)]
     command: Commands,
+
+    /// Never color output (colors are also off when stdout is not a terminal or NO_COLOR is set)
+    #[arg(long, global = true)]
+    no_color: bool,
+
+    /// Log token counts, index sizes, and what each detection stage filtered to stderr
+    #[arg(long, global = true)]
+    debug: bool,
 }
 
 #[derive(Subcommand)]
 enum Commands {
     /// Run full analysis (complexity + clones + LOC)
-    Analyze {
-        /// Path to file or directory to analyze
+    Analyze(AnalyzeArgs),
+
+    /// Analyze cyclomatic complexity and LOC only
+    Complexity(ComplexityArgs),
+
+    /// Detect code clones only
+    Clones(ClonesArgs),
+
+    /// Print a position-independent content hash for each clone group
+    Fingerprint(FingerprintArgs),
+
+    /// Re-run analysis whenever source files change
+    Watch(WatchArgs),
+
+    /// Record current findings so later runs report only new ones
+    Baseline {
+        /// File, directory, `dir/...` pattern, or Go import path to analyze
         #[arg(value_name = "PATH", default_value = ".")]
         path: PathBuf,
 
-        /// Output in JSON format
-        #[arg(short, long)]
-        json: bool,
-
-        /// Complexity threshold for warnings
+        /// Complexity threshold for recorded functions
         #[arg(long)]
         threshold: Option<usize>,
 
-        /// Minimum tokens for clone detection
-        #[arg(long, default_value = "30")]
-        min_tokens: usize,
+        #[command(flatten)]
+        clone_args: CloneArgs,
 
         /// Path to config file
         #[arg(short, long)]
         config: Option<PathBuf>,
 
-        /// Disable gitignore awareness
-        #[arg(long)]
-        no_gitignore: bool,
+        #[command(flatten)]
+        file_args: FileArgs,
 
-        /// Disable syntax highlighting for clone code blocks
-        #[arg(long)]
-        no_highlight: bool,
+        /// Worker threads for reading and analyzing files (default: available CPUs)
+        #[arg(long, value_name = "N")]
+        jobs: Option<usize>,
+
+        #[command(flatten)]
+        cache_args: CacheArgs,
     },
 
-    /// Analyze cyclomatic complexity and LOC only
-    Complexity {
-        /// Path to file or directory to analyze
-        #[arg(value_name = "PATH", default_value = ".")]
-        path: PathBuf,
+    /// Show what changed between two JSON reports and fail when the newer one is worse
+    Compare {
+        /// Older report, written by `--format json`
+        #[arg(value_name = "OLD")]
+        old: PathBuf,
+
+        /// Newer report, written by `--format json`
+        #[arg(value_name = "NEW")]
+        new: PathBuf,
+
+        /// Percent a total may grow before the comparison fails
+        #[arg(long, value_name = "PERCENT", default_value_t = 0.0)]
+        tolerance: f64,
 
         /// Output in JSON format
         #[arg(short, long)]
         json: bool,
+    },
 
-        /// Complexity threshold for warnings
-        #[arg(long)]
-        threshold: Option<usize>,
-
-        /// Path to config file
+    /// Display current configuration
+    DumpConfig {
+        /// Path to config file (if not specified, shows defaults)
         #[arg(short, long)]
         config: Option<PathBuf>,
 
-        /// Disable gitignore awareness
-        #[arg(long)]
-        no_gitignore: bool,
+        /// Save configuration to file (file path or directory)
+        #[arg(short = 'o', long)]
+        output: Option<PathBuf>,
     },
 
-    /// Detect code clones only
-    Clones {
-        /// Path to file or directory to analyze
+    /// Check the target, the Go toolchain, and the effective config for common problems
+    Doctor {
+        /// File, directory, `dir/...` pattern, or Go import path to check
         #[arg(value_name = "PATH", default_value = ".")]
         path: PathBuf,
 
-        /// Output in JSON format
-        #[arg(short, long)]
-        json: bool,
-
-        /// Minimum tokens for clone detection
-        #[arg(long, default_value = "30")]
-        min_tokens: usize,
+        /// Complexity threshold, as for `analyze`
+        #[arg(long)]
+        threshold: Option<usize>,
 
         /// Path to config file
         #[arg(short, long)]
         config: Option<PathBuf>,
 
-        /// Disable gitignore awareness
-        #[arg(long)]
-        no_gitignore: bool,
+        #[command(flatten)]
+        clone_args: CloneArgs,
 
-        /// Disable syntax highlighting for clone code blocks
-        #[arg(long)]
-        no_highlight: bool,
+        #[command(flatten)]
+        file_args: FileArgs,
     },
 
-    /// Display current configuration
-    DumpConfig {
-        /// Path to config file (if not specified, shows defaults)
+    /// List every rule with its id, threshold setting, and the formats it appears in
+    Rules {
+        /// Output in JSON format
         #[arg(short, long)]
-        config: Option<PathBuf>,
-
-        /// Save configuration to file (file path or directory)
-        #[arg(short = 'o', long)]
-        output: Option<PathBuf>,
+        json: bool,
     },
 
     /// Analyze lines of code with ranking
     Loc {
-        /// Path to file or directory to analyze
+        /// File, directory, `dir/...` pattern, or Go import path to analyze
         #[arg(value_name = "PATH", default_value = ".")]
         path: PathBuf,
 
@@ -130,9 +151,8 @@ enum Commands {
         #[arg(short, long)]
         config: Option<PathBuf>,
 
-        /// Disable gitignore awareness
-        #[arg(long)]
-        no_gitignore: bool,
+        #[command(flatten)]
+        file_args: FileArgs,
     },
 
     /// Analyze code coverage from LCOV data
@@ -181,27 +201,25 @@ enum CoverageSubcommand {
 
 fn main() -> Result<()> {
     let cli = Cli::parse();
+    color::init(cli.no_color);
+    logging::init(cli.debug);
 
     match cli.command {
-        Commands::Analyze { path, json, threshold, min_tokens, config, no_gitignore, no_highlight } => {
-            commands::analyze::run(
-                path,
-                json,
-                threshold,
-                Some(min_tokens),
-                config,
-                !no_gitignore,
-                !no_highlight,
-            )
-        }
-        Commands::Complexity { path, json, threshold, config, no_gitignore } => {
-            commands::complexity::run(path, json, threshold, config, !no_gitignore)
-        }
-        Commands::Clones { path, json, min_tokens, config, no_gitignore, no_highlight } => {
-            commands::clones::run(path, json, Some(min_tokens), config, !no_gitignore, !no_highlight)
+        Commands::Analyze(args) => commands::analyze::run(args),
+        Commands::Complexity(args) => commands::complexity::run(args),
+        Commands::Clones(args) => commands::clones::run(args),
+        Commands::Fingerprint(args) => commands::fingerprint::run(args),
+        Commands::Watch(args) => commands::watch::run(args),
+        Commands::Baseline { path, threshold, clone_args, config, file_args, jobs, cache_args } => {
+            commands::baseline::run(path, threshold, clone_args, config, file_args, jobs, cache_args)
         }
+        Commands::Compare { old, new, tolerance, json } => commands::compare::run(&old, &new, tolerance, json),
         Commands::DumpConfig { config, output } => commands::dump_config::run(config, output),
-        Commands::Loc { path, json, rank_by, rank_dirs, config, no_gitignore } => {
+        Commands::Doctor { path, threshold, config, clone_args, file_args } => {
+            commands::doctor::run(path, threshold, config, clone_args, file_args)
+        }
+        Commands::Rules { json } => commands::rules::run(json),
+        Commands::Loc { path, json, rank_by, rank_dirs, config, file_args } => {
             let rank_by = match rank_by.to_lowercase().as_str() {
                 "logical" => RankBy::Logical,
                 "physical" => RankBy::Physical,
@@ -213,7 +231,7 @@ fn main() -> Result<()> {
                 }
             };
 
-            commands::loc::run(path, json, rank_by, rank_dirs, config, !no_gitignore)
+            commands::loc::run(path, json, rank_by, rank_dirs, config, file_args)
         }
         Commands::Coverage { subcommand } => match subcommand {
             CoverageSubcommand::Report { from, jsonl, repo_root } => commands::coverage::run(from, jsonl, repo_root),
@@ -0,0 +1,65 @@
+//! Clone detection time on large synthetic inputs, doubling in size
+//!
+//! Run with `cargo bench -p mccabre-core --bench clone_detection`. Each input is timed at four
+//! sizes; near-linear detection keeps the time per byte roughly constant as the input grows.
+
+use mccabre_core::cloner::CloneDetector;
+use mccabre_core::tokenizer::Language;
+use std::hint::black_box;
+use std::path::PathBuf;
+use std::time::{Duration, Instant};
+
+/// A generated byte table: one long run of the same two tokens
+fn byte_table(rows: usize) -> String {
+    let row = format!("\t{},\n", vec!["0x00"; 16].join(", "));
+    format!("package blob\n\nvar data = []byte{{\n{}}}\n", row.repeat(rows))
+}
+
+/// A huge generated function repeating a handful of statement shapes
+fn generated_function(statements: usize) -> String {
+    let mut source = String::from("package gen\n\nfunc register(r *Registry) {\n");
+    for i in 0..statements {
+        match i % 4 {
+            0 => source.push_str(&format!("\tr.Set(\"key{}\", {i})\n", i % 50)),
+            1 => source.push_str(&format!(
+                "\tif v := r.Get({}); v != nil {{\n\t\tr.Put(v, {i})\n\t}}\n",
+                i % 7
+            )),
+            2 => source.push_str(&format!("\tr.Emit(Op{}, {i}, {})\n", i % 13, i + 1)),
+            _ => source.push_str("\tfor j := 0; j < 4; j++ {\n\t\tr.Step(j)\n\t}\n"),
+        }
+    }
+    source.push_str("}\n");
+    source
+}
+
+fn time(source: &str) -> Duration {
+    let detector = CloneDetector::new(30).with_jobs(1);
+    let started = Instant::now();
+    let clones = detector
+        .detect_in_file(source, Language::Go, PathBuf::from("bench.go"))
+        .unwrap();
+    black_box(clones);
+    started.elapsed()
+}
+
+fn main() {
+    let inputs = [
+        ("byte table", byte_table as fn(usize) -> String),
+        ("generated function", generated_function),
+    ];
+
+    for (name, generate) in inputs {
+        println!("{name}");
+        for scale in [1, 2, 4, 8] {
+            let source = generate(10_000 * scale);
+            let elapsed = time(&source);
+            println!(
+                "  {:>9} bytes  {:>10.2?}  {:>7.1} ns/byte",
+                source.len(),
+                elapsed,
+                elapsed.as_nanos() as f64 / source.len() as f64
+            );
+        }
+    }
+}
@@ -0,0 +1,327 @@
+use crate::Result;
+use crate::cache::Cache;
+use crate::cloner::{
+    CloneDetector, MIN_CLUSTER_SIZE, MIN_DECLARATION_SPECS, detect_duplicate_declarations, detect_errcheck_clusters,
+    detect_function_clones, detect_similar_functions,
+};
+use crate::config::{CloneConfig, Config};
+use crate::loader::{SourceFile, is_test_file};
+use crate::logging::{Logger, debug};
+use crate::parallel::{Progress, default_jobs};
+use crate::position::Positions;
+use crate::reporter::{FileReport, Report};
+use crate::suppress::Suppressions;
+use crate::syntax::partition;
+
+/// Full analysis of sources that are already in memory
+///
+/// Computes per-file metrics, detects clones across all files, applies `//mccabre:ignore`
+/// directives, and resolves positions through `//line` directives. Nothing is read from disk,
+/// so callers can feed buffers from an editor, a VCS, or a test fixture and get the same
+/// [`Report`] the CLI prints.
+///
+/// ```
+/// use mccabre_core::analyzer::Analyzer;
+/// use mccabre_core::config::Config;
+/// use mccabre_core::loader::SourceFile;
+///
+/// let file = SourceFile::new("src/lib.rs", "fn add(a: i32, b: i32) -> i32 { a + b }").unwrap();
+/// let report = Analyzer::new(Config::default()).analyze(&[file]).unwrap();
+///
+/// assert_eq!(report.files[0].cyclomatic.functions[0].name, "add");
+/// ```
+pub struct Analyzer {
+    config: Config,
+    jobs: usize,
+    cache: Option<Cache>,
+    progress: Option<Progress>,
+    logger: Option<Logger>,
+}
+
+impl Analyzer {
+    pub fn new(config: Config) -> Self {
+        Self { config, jobs: default_jobs(), cache: None, progress: None, logger: None }
+    }
+
+    /// Set the number of worker threads (at least 1)
+    pub fn with_jobs(mut self, jobs: usize) -> Self {
+        self.jobs = jobs.max(1);
+        self
+    }
+
+    /// Serve unchanged files from an on-disk cache
+    pub fn with_cache(mut self, cache: Cache) -> Self {
+        self.cache = Some(cache);
+        self
+    }
+
+    /// Report files as they are analyzed and tokenized for clone detection
+    pub fn with_progress(mut self, progress: Progress) -> Self {
+        self.progress = Some(progress);
+        self
+    }
+
+    /// Log what clone detection found and how many findings each filtering stage removed
+    pub fn with_logger(mut self, logger: Logger) -> Self {
+        self.logger = Some(logger);
+        self
+    }
+
+    pub fn config(&self) -> &Config {
+        &self.config
+    }
+
+    /// Analyze the given files
+    ///
+    /// Files that fail [`ParseError::check`](crate::syntax::ParseError::check) are left out and
+    /// listed in `parse_errors`.
+    pub fn analyze(&self, files: &[SourceFile]) -> Result<Report> {
+        let (files, parse_errors) = partition(files, self.jobs);
+        let files = files.as_ref();
+        let file_reports = FileReport::from_files(files, self.jobs, self.cache.as_ref(), self.progress.as_ref())?;
+
+        let clones = if self.config.clones.enabled {
+            let mut detector = CloneDetector::new(self.config.clones.min_tokens)
+                .with_normalize_mode(self.config.clones.normalize)
+                .with_max_gap(self.config.clones.max_gap)
+                .with_strategy(self.config.clones.strategy)
+                .with_min_nodes(self.config.clones.min_nodes)
+                .with_granularity(self.config.clones.granularity)
+                .with_min_statements(self.config.clones.min_statements)
+                .with_min_instances(self.config.clones.min_instances)
+                .with_jobs(self.jobs);
+            if let Some(cache) = &self.cache {
+                detector = detector.with_cache(cache.clone());
+            }
+            if let Some(progress) = &self.progress {
+                detector = detector.with_progress(progress.clone());
+            }
+            if let Some(logger) = &self.logger {
+                detector = detector.with_logger(logger.clone());
+            }
+            let sources: Vec<_> = files
+                .iter()
+                .filter(|f| !(self.config.clones.skip_tests && is_test_file(&f.path)))
+                .map(|f| (f.path.clone(), f.content.clone(), f.language))
+                .collect();
+            detector.detect_across_files(&sources)?
+        } else {
+            Vec::new()
+        };
+
+        let mut report = Report::new(file_reports, clones);
+        report.parse_errors = parse_errors;
+        detect_duplication(&mut report, files, &self.config.clones, self.jobs)?;
+        report.classify(&self.config.complexity.severity_bands);
+        if !self.config.clones.keep_overlaps {
+            let dropped = report.dedupe_overlapping();
+            debug(
+                self.logger.as_ref(),
+                "overlap dedup",
+                &[("kept", &report.clones.len()), ("dropped", &dropped)],
+            );
+        }
+
+        report.check_function_length(&self.config.complexity.length_limits());
+        report.check_parameters(self.config.complexity.max_parameters);
+        let report = Suppressions::from_files(files)?.filter(report);
+        let suppressed = &report.suppressed;
+        debug(
+            self.logger.as_ref(),
+            "suppressions",
+            &[
+                ("clones_dropped", &suppressed.clones),
+                ("functions_dropped", &suppressed.complexity),
+                ("findings_dropped", &suppressed.findings),
+            ],
+        );
+        Ok(Positions::from_files(files)?.resolve(report))
+    }
+}
+
+/// Fill in the duplication reports `config` asks for beyond clone groups: error-handling
+/// clusters, similar function pairs, duplicate declarations, and duplicated function bodies
+///
+/// Test files are left out when `skip_tests` is set, as they are for clone detection.
+pub fn detect_duplication(report: &mut Report, files: &[SourceFile], config: &CloneConfig, jobs: usize) -> Result<()> {
+    if !(config.report_errcheck
+        || config.similarity_threshold.is_some()
+        || config.report_declarations
+        || config.report_function_clones)
+    {
+        return Ok(());
+    }
+
+    let sources: Vec<_> = files
+        .iter()
+        .filter(|f| !(config.skip_tests && is_test_file(&f.path)))
+        .cloned()
+        .collect();
+    if config.report_errcheck {
+        report.errcheck_clusters = detect_errcheck_clusters(&sources, MIN_CLUSTER_SIZE, jobs)?;
+    }
+    if let Some(threshold) = config.similarity_threshold {
+        report.similar_functions =
+            detect_similar_functions(&sources, threshold, config.min_tokens, config.normalize, jobs)?;
+    }
+    if config.report_declarations {
+        report.duplicate_declarations = detect_duplicate_declarations(&sources, MIN_DECLARATION_SPECS, jobs)?;
+    }
+    if config.report_function_clones {
+        report.function_clones = detect_function_clones(&sources, config.min_tokens, jobs)?;
+    }
+
+    Ok(())
+}
+
+/// Analyze in-memory sources with a configuration, using the default worker count
+pub fn analyze_sources(files: &[SourceFile], config: &Config) -> Result<Report> {
+    Analyzer::new(config.clone()).analyze(files)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::reporter::JsonReport;
+    use std::path::PathBuf;
+
+    const HANDLER: &str = r#"
+func handle(items []int) int {
+	total := 0
+	for _, item := range items {
+		if item > 0 && item < 100 {
+			total += item * 2
+		} else {
+			total -= item
+		}
+	}
+	return total
+}
+"#;
+
+    fn sources() -> Vec<SourceFile> {
+        vec![
+            SourceFile::new("pkg/a.go", format!("package pkg\n{HANDLER}")).unwrap(),
+            SourceFile::new("pkg/b.go", format!("package pkg\n\n{HANDLER}")).unwrap(),
+        ]
+    }
+
+    #[test]
+    fn test_analyze_in_memory_sources() {
+        let mut config = Config::default();
+        config.clones.min_tokens = 20;
+
+        let report = analyze_sources(&sources(), &config).unwrap();
+
+        assert_eq!(report.summary.total_files, 2);
+        assert_eq!(report.files[0].cyclomatic.functions[0].name, "handle");
+        assert_eq!(report.files[0].cyclomatic.functions[0].cyclomatic, 4);
+        assert_eq!(report.clones.len(), 1);
+        assert_eq!(report.clones[0].locations.len(), 2);
+
+        let [a, b] = &report.clones[0].locations[..] else { unreachable!() };
+        assert_eq!((a.file.to_str(), b.file.to_str()), (Some("pkg/a.go"), Some("pkg/b.go")));
+        assert_eq!(b.end_line, a.end_line + 1);
+    }
+
+    #[test]
+    fn test_clone_detection_can_be_disabled() {
+        let mut config = Config::default();
+        config.clones.min_tokens = 20;
+        config.clones.enabled = false;
+
+        let report = Analyzer::new(config).with_jobs(1).analyze(&sources()).unwrap();
+
+        assert!(report.clones.is_empty());
+        assert_eq!(report.files.len(), 2);
+    }
+
+    #[test]
+    fn test_skip_tests_keeps_test_files_measured() {
+        let mut config = Config::default();
+        config.clones.min_tokens = 20;
+        config.clones.skip_tests = true;
+
+        let mut files = sources();
+        files.push(SourceFile::new("pkg/a_test.go", format!("package pkg\n{HANDLER}")).unwrap());
+        let report = Analyzer::new(config).with_jobs(1).analyze(&files).unwrap();
+
+        assert_eq!(report.files.len(), 3);
+        assert_eq!(report.files[2].cyclomatic.functions[0].cyclomatic, 4);
+        assert_eq!(report.clones.len(), 1);
+        assert!(
+            report.clones[0]
+                .locations
+                .iter()
+                .all(|l| !l.file.ends_with("a_test.go"))
+        );
+    }
+
+    #[test]
+    fn test_example_functions_are_analyzed_like_any_other() {
+        use crate::loader::FileLoader;
+
+        let dir = std::path::Path::new(env!("CARGO_MANIFEST_DIR")).join("testdata/examples");
+        let files = FileLoader::new().load(&dir).unwrap();
+        let mut config = Config::default();
+        config.clones.min_tokens = 20;
+        let report = Analyzer::new(config).with_jobs(1).analyze(&files).unwrap();
+
+        let function = |name: &str| {
+            let file = report
+                .files
+                .iter()
+                .find(|f| f.cyclomatic.functions.iter().any(|func| func.name == name));
+            file.and_then(|f| f.cyclomatic.functions.iter().find(|func| func.name == name))
+                .unwrap()
+        };
+        let (example, print) = (function("ExamplePrint"), function("Print"));
+        assert_eq!(example.cyclomatic, 6);
+        assert_eq!(
+            (example.cyclomatic, example.cognitive),
+            (print.cyclomatic, print.cognitive)
+        );
+        assert_eq!(example.max_nesting, print.max_nesting);
+
+        // The loop copied from Print into the example is one clone group across both files
+        assert_eq!(report.clones.len(), 1);
+        let mut names: Vec<_> = report.clones[0]
+            .locations
+            .iter()
+            .filter_map(|l| l.file.file_name())
+            .collect();
+        names.sort();
+        assert_eq!(names, ["tally.go", "tally_example_test.go"]);
+    }
+
+    #[test]
+    fn test_unparsable_file_is_reported_not_fatal() {
+        let mut config = Config::default();
+        config.clones.min_tokens = 20;
+
+        let mut files = sources();
+        files.insert(
+            1,
+            SourceFile::new("pkg/broken.go", "package pkg\n\nfunc broken() {\n").unwrap(),
+        );
+        let report = Analyzer::new(config).with_jobs(2).analyze(&files).unwrap();
+
+        assert_eq!(report.files.len(), 2);
+        assert_eq!(report.clones.len(), 1);
+        assert_eq!(report.parse_errors.len(), 1);
+        assert_eq!(report.parse_errors[0].file, PathBuf::from("pkg/broken.go"));
+        assert_eq!((report.parse_errors[0].line, report.parse_errors[0].column), (3, 15));
+    }
+
+    #[test]
+    fn test_matches_serialized_report() {
+        let config = Config::default();
+        let serial = Analyzer::new(config.clone()).with_jobs(1).analyze(&sources()).unwrap();
+        let parallel = Analyzer::new(config).with_jobs(4).analyze(&sources()).unwrap();
+
+        assert_eq!(
+            serde_json::to_value(JsonReport::from_report(&serial)).unwrap(),
+            serde_json::to_value(JsonReport::from_report(&parallel)).unwrap()
+        );
+    }
+}
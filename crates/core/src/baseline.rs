@@ -0,0 +1,355 @@
+use crate::config::ComplexityConfig;
+use crate::reporter::Report;
+use crate::{MccabreError, Result};
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use std::fs;
+use std::path::{Component, Path, PathBuf};
+
+/// Version of the baseline file layout
+pub const BASELINE_VERSION: &str = "2";
+
+/// Snapshot of existing findings, used to report only new ones
+///
+/// Clones are keyed on their group id and token count and functions on file path and name, so
+/// line shifts from unrelated edits do not turn old findings into new ones.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Baseline {
+    pub version: String,
+    pub clones: Vec<BaselineClone>,
+    pub complexity: Vec<BaselineFunction>,
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct BaselineClone {
+    /// Content hash of the clone's normalized tokens, as in [`Clone::group_id`](crate::cloner::Clone::group_id)
+    pub group_id: String,
+    pub token_count: usize,
+    pub instances: usize,
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct BaselineFunction {
+    pub file: PathBuf,
+    pub function: String,
+    pub cyclomatic: usize,
+}
+
+impl Baseline {
+    /// Capture clones and functions above the warning threshold
+    pub fn from_report(report: &Report, thresholds: &ComplexityConfig) -> Self {
+        let mut clones: Vec<BaselineClone> = report
+            .clones
+            .iter()
+            .map(|clone| BaselineClone {
+                group_id: clone.group_id.clone(),
+                token_count: clone.length,
+                instances: clone.locations.len(),
+            })
+            .collect();
+        clones.sort_by(|a, b| (&a.group_id, a.token_count).cmp(&(&b.group_id, b.token_count)));
+
+        let mut complexity: Vec<BaselineFunction> = report
+            .files
+            .iter()
+            .flat_map(|file| {
+                let limit = thresholds.for_path(&file.path).warning_threshold;
+                file.cyclomatic
+                    .functions
+                    .iter()
+                    .filter(move |func| func.cyclomatic > limit)
+                    .map(|func| BaselineFunction {
+                        file: normalize_path(&file.path),
+                        function: func.name.clone(),
+                        cyclomatic: func.cyclomatic,
+                    })
+            })
+            .collect();
+        complexity.sort_by(|a, b| (&a.file, &a.function).cmp(&(&b.file, &b.function)));
+
+        Self { version: BASELINE_VERSION.to_string(), clones, complexity }
+    }
+
+    /// Load a baseline written by [`Baseline::to_json`]
+    pub fn from_file<P: AsRef<Path>>(path: P) -> Result<Self> {
+        let path = path.as_ref();
+        let content =
+            fs::read_to_string(path).map_err(|e| MccabreError::FileRead { path: path.to_path_buf(), source: e })?;
+        let baseline: Self = serde_json::from_str(&content)
+            .map_err(|e| MccabreError::InvalidBaseline { path: path.to_path_buf(), message: e.to_string() })?;
+
+        if baseline.version != BASELINE_VERSION {
+            return Err(MccabreError::InvalidBaseline {
+                path: path.to_path_buf(),
+                message: format!(
+                    "unsupported version {} (expected {BASELINE_VERSION}); record it again with `mccabre baseline`",
+                    baseline.version
+                ),
+            });
+        }
+
+        Ok(baseline)
+    }
+
+    pub fn to_json(&self) -> serde_json::Result<String> {
+        serde_json::to_string_pretty(self)
+    }
+
+    /// Remove findings already recorded in the baseline
+    ///
+    /// A clone stays suppressed while a group with the same content and length has no more
+    /// instances than when the baseline was taken.
+    /// A function stays suppressed while its cyclomatic complexity does not grow.
+    pub fn filter(&self, report: Report) -> Report {
+        let mut known_clones: HashMap<(&str, usize), usize> = HashMap::new();
+        for clone in &self.clones {
+            let instances = known_clones
+                .entry((clone.group_id.as_str(), clone.token_count))
+                .or_default();
+            *instances = (*instances).max(clone.instances);
+        }
+
+        let mut known_functions: HashMap<&Path, HashMap<&str, Vec<usize>>> = HashMap::new();
+        for func in &self.complexity {
+            known_functions
+                .entry(func.file.as_path())
+                .or_default()
+                .entry(func.function.as_str())
+                .or_default()
+                .push(func.cyclomatic);
+        }
+
+        let clones = report
+            .clones
+            .into_iter()
+            .filter(|clone| {
+                known_clones
+                    .get(&(clone.group_id.as_str(), clone.length))
+                    .is_none_or(|&instances| clone.locations.len() > instances)
+            })
+            .collect();
+
+        let files = report
+            .files
+            .into_iter()
+            .map(|mut file| {
+                let Some(known) = known_functions.get_mut(normalize_path(&file.path).as_path()) else {
+                    return file;
+                };
+
+                file.cyclomatic.functions.retain(|func| {
+                    let Some(recorded) = known.get_mut(func.name.as_str()) else {
+                        return true;
+                    };
+
+                    // Pair with the smallest recorded score that still covers this function
+                    match recorded
+                        .iter()
+                        .enumerate()
+                        .filter(|(_, c)| **c >= func.cyclomatic)
+                        .min_by_key(|(_, c)| **c)
+                    {
+                        Some((idx, _)) => {
+                            recorded.swap_remove(idx);
+                            false
+                        }
+                        None => true,
+                    }
+                });
+                file
+            })
+            .collect();
+
+        let mut filtered = Report::new(files, clones);
+        filtered.suppressed = report.suppressed;
+        filtered.parse_errors = report.parse_errors;
+        filtered.errcheck_clusters = report.errcheck_clusters;
+        filtered.similar_functions = report.similar_functions;
+        filtered.duplicate_declarations = report.duplicate_declarations;
+        filtered.function_clones = report.function_clones;
+        filtered
+    }
+}
+
+/// Drop `.` components so `./src/a.rs` and `src/a.rs` compare equal
+pub(crate) fn normalize_path(path: &Path) -> PathBuf {
+    path.components().filter(|c| !matches!(c, Component::CurDir)).collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::{Clone, CloneLocation};
+    use crate::complexity::{CyclomaticMetrics, FunctionComplexity, HalsteadMetrics, LocMetrics};
+    use crate::reporter::FileReport;
+    use tempfile::TempDir;
+
+    fn function(name: &str, cyclomatic: usize, line: usize) -> FunctionComplexity {
+        FunctionComplexity {
+            name: name.to_string(),
+            cyclomatic,
+            cognitive: 0,
+            max_nesting: 0,
+            line,
+            halstead: HalsteadMetrics::default(),
+            ..Default::default()
+        }
+    }
+
+    fn file(path: &str, functions: Vec<FunctionComplexity>) -> FileReport {
+        FileReport {
+            path: PathBuf::from(path),
+            loc: LocMetrics { physical: 10, logical: 8, comments: 1, blank: 1, statements: 8 },
+            cyclomatic: CyclomaticMetrics { file_complexity: 20, functions },
+            maintainability_index: 50.0,
+            findings: Vec::new(),
+            package: None,
+        }
+    }
+
+    fn clone(group_id: &str, length: usize, locations: &[(&str, usize)]) -> Clone {
+        Clone {
+            id: 1,
+            length,
+            locations: locations
+                .iter()
+                .map(|(file, start)| CloneLocation {
+                    file: PathBuf::from(file),
+                    start_line: *start,
+                    end_line: start + 5,
+                    gap_tokens: 0,
+                    ..Default::default()
+                })
+                .collect(),
+            hash: 0,
+            group_id: group_id.to_string(),
+        }
+    }
+
+    fn legacy_report() -> Report {
+        Report::new(
+            vec![file(
+                "./src/a.rs",
+                vec![function("tangled", 15, 3), function("small", 2, 40)],
+            )],
+            vec![clone("abc", 30, &[("src/a.rs", 10), ("src/b.rs", 20)])],
+        )
+    }
+
+    #[test]
+    fn test_baseline_records_violations_only() {
+        let baseline = Baseline::from_report(&legacy_report(), &ComplexityConfig::default());
+
+        assert_eq!(baseline.version, BASELINE_VERSION);
+        assert_eq!(baseline.clones.len(), 1);
+        assert_eq!(baseline.clones[0].group_id, "abc");
+        assert_eq!(baseline.complexity.len(), 1);
+        assert_eq!(baseline.complexity[0].file, PathBuf::from("src/a.rs"));
+        assert_eq!(baseline.complexity[0].function, "tangled");
+    }
+
+    #[test]
+    fn test_filter_ignores_line_shifts() {
+        let baseline = Baseline::from_report(&legacy_report(), &ComplexityConfig::default());
+
+        let shifted = Report::new(
+            vec![file(
+                "src/a.rs",
+                vec![function("tangled", 15, 30), function("small", 2, 60)],
+            )],
+            vec![clone("abc", 30, &[("src/a.rs", 50), ("src/b.rs", 70)])],
+        );
+        let filtered = baseline.filter(shifted);
+
+        assert!(filtered.clones.is_empty());
+        assert_eq!(filtered.summary.total_clones, 0);
+        let names: Vec<&str> = filtered.files[0]
+            .cyclomatic
+            .functions
+            .iter()
+            .map(|f| f.name.as_str())
+            .collect();
+        assert_eq!(names, vec!["small"]);
+    }
+
+    #[test]
+    fn test_filter_reports_new_and_worse_findings() {
+        let baseline = Baseline::from_report(&legacy_report(), &ComplexityConfig::default());
+
+        let changed = Report::new(
+            vec![file(
+                "src/a.rs",
+                vec![function("tangled", 18, 3), function("fresh", 12, 50)],
+            )],
+            vec![
+                clone("abc", 30, &[("src/a.rs", 10), ("src/b.rs", 20), ("src/c.rs", 5)]),
+                clone("abc", 45, &[("src/a.rs", 10), ("src/b.rs", 20)]),
+                clone("def", 30, &[("src/c.rs", 1), ("src/d.rs", 1)]),
+            ],
+        );
+        let filtered = baseline.filter(changed);
+
+        assert_eq!(filtered.clones.len(), 3);
+        assert_eq!(filtered.files[0].cyclomatic.functions.len(), 2);
+    }
+
+    #[test]
+    fn test_filter_keeps_methods_apart_from_plain_functions() {
+        let baseline = Baseline {
+            version: BASELINE_VERSION.to_string(),
+            clones: Vec::new(),
+            complexity: vec![BaselineFunction {
+                file: PathBuf::from("api.go"),
+                function: "Handle".to_string(),
+                cyclomatic: 15,
+            }],
+        };
+
+        let report = Report::new(
+            vec![file(
+                "api.go",
+                vec![function("(*Server).Handle", 15, 3), function("Handle", 15, 40)],
+            )],
+            Vec::new(),
+        );
+        let filtered = baseline.filter(report);
+        let names: Vec<&str> = filtered.files[0]
+            .cyclomatic
+            .functions
+            .iter()
+            .map(|f| f.name.as_str())
+            .collect();
+        assert_eq!(names, vec!["(*Server).Handle"]);
+    }
+
+    #[test]
+    fn test_round_trip() {
+        let dir = TempDir::new().unwrap();
+        let path = dir.path().join(".mccabre-baseline.json");
+        let baseline = Baseline::from_report(&legacy_report(), &ComplexityConfig::default());
+        fs::write(&path, baseline.to_json().unwrap()).unwrap();
+
+        assert_eq!(Baseline::from_file(&path).unwrap(), baseline);
+    }
+
+    #[test]
+    fn test_invalid_baseline() {
+        let dir = TempDir::new().unwrap();
+        let path = dir.path().join("baseline.json");
+
+        fs::write(&path, "not json").unwrap();
+        assert!(matches!(
+            Baseline::from_file(&path),
+            Err(MccabreError::InvalidBaseline { .. })
+        ));
+
+        fs::write(&path, r#"{"version": "99", "clones": [], "complexity": []}"#).unwrap();
+        assert!(matches!(
+            Baseline::from_file(&path),
+            Err(MccabreError::InvalidBaseline { .. })
+        ));
+    }
+}
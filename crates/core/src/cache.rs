@@ -0,0 +1,223 @@
+use crate::loader::SourceFile;
+use crate::reporter::FileReport;
+use crate::tokenizer::{Language, NormalizeMode, Token};
+use crate::{MccabreError, Result};
+use serde::Serialize;
+use serde::de::DeserializeOwned;
+use sha2::{Digest, Sha256};
+use std::env;
+use std::fs;
+use std::path::{Path, PathBuf};
+
+/// Version of everything stored in the cache and the clone index
+///
+/// Bump it whenever a cached struct ([`FileReport`], [`Token`], or the metrics they hold) gains,
+/// loses, or changes a field, or the tokenizer or function naming changes what they contain.
+/// Old entries are then discarded instead of being read with new fields left at their defaults.
+pub const CACHE_SCHEMA: u32 = 1;
+
+/// Directory name and index version: entries written by other tool versions or schemas are discarded
+pub(crate) fn cache_version() -> String {
+    format!("{}+schema.{CACHE_SCHEMA}", env!("CARGO_PKG_VERSION"))
+}
+
+/// On-disk cache of per-file analysis results
+///
+/// Entries live under `<root>/<tool version>+schema.<N>/` and are keyed by a SHA-256 of the
+/// schema, file path, language, and content, so an edited file misses while unchanged files are served from disk.
+/// The cache is best effort: unreadable entries count as misses and failed writes are ignored.
+#[derive(Debug, Clone)]
+pub struct Cache {
+    dir: PathBuf,
+}
+
+impl Cache {
+    /// Default cache root: `$XDG_CACHE_HOME/mccabre`, falling back to `~/.cache/mccabre`
+    pub fn default_root() -> Option<PathBuf> {
+        let base = env::var_os("XDG_CACHE_HOME")
+            .filter(|dir| !dir.is_empty())
+            .map(PathBuf::from)
+            .or_else(|| env::var_os("HOME").map(|home| PathBuf::from(home).join(".cache")))?;
+        Some(base.join("mccabre"))
+    }
+
+    /// Open the cache under `root`, removing entries left by other tool versions or schemas
+    pub fn open<P: AsRef<Path>>(root: P) -> Result<Self> {
+        let root = root.as_ref();
+        let version = cache_version();
+        let dir = root.join(&version);
+        fs::create_dir_all(&dir).map_err(|e| MccabreError::FileRead { path: dir.clone(), source: e })?;
+
+        for entry in fs::read_dir(root)?.flatten() {
+            if entry.file_name() != version.as_str() && entry.path().is_dir() {
+                let _ = fs::remove_dir_all(entry.path());
+            }
+        }
+
+        Ok(Self { dir })
+    }
+
+    /// Delete everything under the cache root
+    pub fn clear<P: AsRef<Path>>(root: P) -> Result<()> {
+        match fs::remove_dir_all(root.as_ref()) {
+            Err(e) if e.kind() != std::io::ErrorKind::NotFound => Err(e.into()),
+            _ => Ok(()),
+        }
+    }
+
+    /// Directory holding entries for the running version
+    pub fn dir(&self) -> &Path {
+        &self.dir
+    }
+
+    /// Per-file metrics, computed and stored on a miss
+    pub fn file_report(&self, file: &SourceFile) -> Result<FileReport> {
+        self.get_or_insert("report", &file.path, &file.content, file.language, || {
+            FileReport::from_source(file.path.clone(), &file.content, file.language)
+        })
+    }
+
+    /// Token stream for a file under a normalization mode, computed and stored on a miss
+    pub fn tokens<F>(
+        &self, path: &Path, source: &str, language: Language, mode: NormalizeMode, compute: F,
+    ) -> Result<Vec<Token>>
+    where
+        F: FnOnce() -> Result<Vec<Token>>,
+    {
+        self.get_or_insert(&format!("tokens-{mode}"), path, source, language, compute)
+    }
+
+    fn get_or_insert<T, F>(&self, kind: &str, path: &Path, source: &str, language: Language, compute: F) -> Result<T>
+    where
+        T: Serialize + DeserializeOwned,
+        F: FnOnce() -> Result<T>,
+    {
+        let entry = self
+            .dir
+            .join(format!("{}.{kind}.json", entry_key(path, source, language)));
+
+        if let Some(value) = fs::read(&entry)
+            .ok()
+            .and_then(|bytes| serde_json::from_slice(&bytes).ok())
+        {
+            return Ok(value);
+        }
+
+        let value = compute()?;
+        if let Ok(bytes) = serde_json::to_vec(&value) {
+            // Write then rename so concurrent runs never read a partial entry
+            let partial = entry.with_extension(format!("json.{}", std::process::id()));
+            if fs::write(&partial, bytes).is_ok() && fs::rename(&partial, &entry).is_err() {
+                let _ = fs::remove_file(&partial);
+            }
+        }
+
+        Ok(value)
+    }
+}
+
+fn entry_key(path: &Path, source: &str, language: Language) -> String {
+    let mut hasher = Sha256::new();
+    hasher.update(CACHE_SCHEMA.to_le_bytes());
+    hasher.update(path.to_string_lossy().as_bytes());
+    hasher.update([0]);
+    hasher.update(format!("{language:?}").as_bytes());
+    hasher.update([0]);
+    hasher.update(source.as_bytes());
+
+    hasher.finalize().iter().map(|b| format!("{b:02x}")).collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::cell::Cell;
+    use tempfile::TempDir;
+
+    fn source_file(content: &str) -> SourceFile {
+        SourceFile { path: PathBuf::from("src/lib.rs"), content: content.to_string(), language: Language::Rust }
+    }
+
+    #[test]
+    fn test_unchanged_file_served_from_cache() {
+        let root = TempDir::new().unwrap();
+        let cache = Cache::open(root.path()).unwrap();
+        let calls = Cell::new(0);
+        let compute = || {
+            calls.set(calls.get() + 1);
+            Ok(vec![])
+        };
+
+        let lookup = |path: &str, source: &str, mode| {
+            cache
+                .tokens(Path::new(path), source, Language::Rust, mode, compute)
+                .unwrap();
+        };
+
+        lookup("a.rs", "fn a() {}", NormalizeMode::Exact);
+        lookup("a.rs", "fn a() {}", NormalizeMode::Exact);
+        assert_eq!(calls.get(), 1);
+
+        lookup("a.rs", "fn b() {}", NormalizeMode::Exact);
+        lookup("a.rs", "fn a() {}", NormalizeMode::Renamed);
+        lookup("b.rs", "fn a() {}", NormalizeMode::Exact);
+        assert_eq!(calls.get(), 4);
+    }
+
+    #[test]
+    fn test_file_report_round_trip() {
+        let root = TempDir::new().unwrap();
+        let cache = Cache::open(root.path()).unwrap();
+        let file = source_file("fn check(x: i32) {\n    if x > 0 {}\n}\n");
+
+        let computed = cache.file_report(&file).unwrap();
+        let cached = cache.file_report(&file).unwrap();
+
+        assert_eq!(cached.cyclomatic.file_complexity, computed.cyclomatic.file_complexity);
+        assert_eq!(cached.cyclomatic.functions[0].name, "check");
+        assert_eq!(cached.loc.logical, computed.loc.logical);
+        assert_eq!(fs::read_dir(cache.dir()).unwrap().count(), 1);
+    }
+
+    #[test]
+    fn test_corrupt_entry_is_a_miss() {
+        let root = TempDir::new().unwrap();
+        let cache = Cache::open(root.path()).unwrap();
+        let file = source_file("fn main() {}");
+
+        cache.file_report(&file).unwrap();
+        for entry in fs::read_dir(cache.dir()).unwrap() {
+            fs::write(entry.unwrap().path(), "garbage").unwrap();
+        }
+
+        assert_eq!(cache.file_report(&file).unwrap().cyclomatic.functions[0].name, "main");
+    }
+
+    #[test]
+    fn test_other_versions_are_discarded() {
+        let root = TempDir::new().unwrap();
+        let stale = root.path().join("0.0.0-old");
+        let old_schema = root.path().join(env!("CARGO_PKG_VERSION"));
+        for dir in [&stale, &old_schema] {
+            fs::create_dir_all(dir).unwrap();
+            fs::write(dir.join("entry.report.json"), "{}").unwrap();
+        }
+
+        let cache = Cache::open(root.path()).unwrap();
+
+        assert!(!stale.exists() && !old_schema.exists());
+        assert!(cache.dir().ends_with(cache_version()));
+    }
+
+    #[test]
+    fn test_clear() {
+        let root = TempDir::new().unwrap();
+        let cache_root = root.path().join("mccabre");
+        let cache = Cache::open(&cache_root).unwrap();
+        cache.file_report(&source_file("fn main() {}")).unwrap();
+
+        Cache::clear(&cache_root).unwrap();
+        assert!(!cache_root.exists());
+        Cache::clear(&cache_root).unwrap();
+    }
+}
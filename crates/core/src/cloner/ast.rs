@@ -0,0 +1,432 @@
+use crate::cloner::detector::{Clone, CloneLocation};
+use crate::cloner::rolling_hash::token_hash;
+use crate::complexity::loc::ends_go_statement;
+use crate::tokenizer::{Language, NormalizeMode, Token, TokenType};
+use std::collections::{BTreeSet, HashMap};
+use std::path::PathBuf;
+
+/// Keywords after which a statement cannot move past its neighbours
+const BARRIERS: &[&str] = &[
+    "return", "break", "continue", "goto", "throw", "panic", "yield", "defer",
+];
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum NodeKind {
+    Statement,
+    Block,
+}
+
+/// A statement or `{ ... }` block over tokens `start..end` of one file
+#[derive(Debug)]
+struct Node {
+    kind: NodeKind,
+    start: usize,
+    end: usize,
+    children: Vec<Node>,
+}
+
+/// Structural summary of a node, computed bottom-up
+struct Summary<'a> {
+    /// Merkle hash: the node's own tokens combined with its children's hashes
+    hash: u64,
+    /// Nodes in the subtree, counting every token as a leaf
+    size: usize,
+    /// Identifiers used anywhere in the subtree, by original name
+    idents: BTreeSet<&'a str>,
+    /// Contains a jump such as `return`, so it never reorders
+    barrier: bool,
+}
+
+/// A hashed subtree that may be reported as a clone instance
+struct Candidate {
+    file: usize,
+    start: usize,
+    end: usize,
+    size: usize,
+    hash: u64,
+}
+
+/// Find subtrees of at least `min_nodes` nodes that occur more than once
+///
+/// Each file's tokens are parsed into a tree of statements and blocks. A node's hash combines
+/// its own tokens, with identifiers and literals normalized, and the hashes of its children, so
+/// renamed copies and differently formatted copies hash alike. Inside a block, runs of adjacent
+/// statements that share no identifiers are hashed in sorted order, so swapping independent
+/// statements does not break a match. Only the largest matching subtrees are reported; nodes
+/// inside an already reported instance are skipped.
+pub(crate) fn find_clones(streams: &[(PathBuf, Vec<Token>, Language)], min_nodes: usize) -> Vec<Clone> {
+    let mut candidates = Vec::new();
+
+    for (file, (_, tokens, language)) in streams.iter().enumerate() {
+        let mut pos = 0;
+        let roots = parse_sequence(tokens, *language, &mut pos, false);
+        for root in &roots {
+            summarize(root, tokens, *language, file, min_nodes, &mut candidates);
+        }
+    }
+
+    let mut groups: HashMap<(u64, usize), Vec<Candidate>> = HashMap::new();
+    for candidate in candidates {
+        groups
+            .entry((candidate.hash, candidate.size))
+            .or_default()
+            .push(candidate);
+    }
+
+    let mut groups: Vec<Vec<Candidate>> = groups.into_values().filter(|g| g.len() > 1).collect();
+    for group in &mut groups {
+        group.sort_by_key(|c| (c.file, c.start));
+    }
+    groups.sort_by(|a, b| {
+        b[0].size
+            .cmp(&a[0].size)
+            .then_with(|| (a[0].file, a[0].start).cmp(&(b[0].file, b[0].start)))
+    });
+
+    let mut covered: HashMap<usize, Vec<(usize, usize)>> = HashMap::new();
+    let mut clones = Vec::new();
+
+    for group in groups {
+        let instances: Vec<&Candidate> = group
+            .iter()
+            .filter(|c| {
+                !covered
+                    .get(&c.file)
+                    .is_some_and(|spans| spans.iter().any(|&(s, e)| s <= c.start && c.end <= e))
+            })
+            .collect();
+        if instances.len() < 2 {
+            continue;
+        }
+
+        let locations = instances
+            .iter()
+            .map(|c| {
+                let tokens = &streams[c.file].1;
+                covered.entry(c.file).or_default().push((c.start, c.end));
+                CloneLocation::from_tokens(streams[c.file].0.clone(), &tokens[c.start], &tokens[c.end - 1], 0)
+            })
+            .collect();
+
+        let length = instances[0].end - instances[0].start;
+        clones.push(Clone { id: 0, length, locations, hash: instances[0].hash, group_id: String::new() });
+    }
+
+    clones
+}
+
+/// Statements of the top level and of every block, one list of siblings per sequence
+///
+/// Each statement is a token range `start..end` that includes the blocks it opens, so a run
+/// of siblings always covers whole statements. Lists come in file order, outer before inner.
+pub(crate) fn statement_lists(tokens: &[Token], language: Language) -> Vec<Vec<(usize, usize)>> {
+    fn collect(statements: &[Node], lists: &mut Vec<Vec<(usize, usize)>>) {
+        lists.push(statements.iter().map(|s| (s.start, s.end)).collect());
+        for block in statements.iter().flat_map(|s| &s.children) {
+            collect(&block.children, lists);
+        }
+    }
+
+    let mut pos = 0;
+    let roots = parse_sequence(tokens, language, &mut pos, false);
+    let mut lists = Vec::new();
+    collect(&roots, &mut lists);
+    lists
+}
+
+/// Parse statements until the closing brace of the enclosing block, or the end of the file
+fn parse_sequence(tokens: &[Token], language: Language, pos: &mut usize, in_block: bool) -> Vec<Node> {
+    let mut nodes = Vec::new();
+
+    while *pos < tokens.len() {
+        if tokens[*pos].token_type == TokenType::RightBrace {
+            if in_block {
+                break;
+            }
+            // Unbalanced closing brace at the top level
+            *pos += 1;
+            continue;
+        }
+
+        let start = *pos;
+        let node = parse_statement(tokens, language, pos);
+        if *pos == start {
+            *pos += 1;
+        } else {
+            nodes.push(node);
+        }
+    }
+
+    nodes
+}
+
+fn parse_statement(tokens: &[Token], language: Language, pos: &mut usize) -> Node {
+    let start = *pos;
+    let mut children = Vec::new();
+    let mut depth = 0usize;
+    // Inside a Go `if`, `for`, or `switch` header, whose semicolons do not end the statement
+    let mut in_header = false;
+
+    while *pos < tokens.len() {
+        let token = &tokens[*pos];
+        match token.token_type {
+            TokenType::RightBrace => break,
+            TokenType::LeftBrace => {
+                in_header = false;
+                children.push(parse_block(tokens, language, pos));
+                if !continues_after_block(tokens, language, *pos, depth) {
+                    break;
+                }
+                continue;
+            }
+            TokenType::LeftParen | TokenType::LeftBracket => depth += 1,
+            TokenType::RightParen | TokenType::RightBracket => depth = depth.saturating_sub(1),
+            TokenType::If | TokenType::ElseIf | TokenType::For | TokenType::Switch
+                if language == Language::Go && depth == 0 =>
+            {
+                in_header = true;
+            }
+            TokenType::Semicolon if depth == 0 && !in_header => {
+                *pos += 1;
+                break;
+            }
+            _ => {}
+        }
+        *pos += 1;
+
+        // Go ends statements at line breaks where the compiler would insert a semicolon
+        if language == Language::Go
+            && depth == 0
+            && tokens.get(*pos).is_some_and(|next| next.line > token.line)
+            && ends_go_statement(&token.token_type)
+        {
+            break;
+        }
+    }
+
+    Node { kind: NodeKind::Statement, start, end: *pos, children }
+}
+
+fn parse_block(tokens: &[Token], language: Language, pos: &mut usize) -> Node {
+    let start = *pos;
+    *pos += 1;
+    let children = parse_sequence(tokens, language, pos, true);
+    if tokens.get(*pos).is_some_and(|t| t.token_type == TokenType::RightBrace) {
+        *pos += 1;
+    }
+
+    Node { kind: NodeKind::Block, start, end: *pos, children }
+}
+
+/// Whether the statement goes on after a block that closed just before `pos`
+fn continues_after_block(tokens: &[Token], language: Language, pos: usize, depth: usize) -> bool {
+    if depth > 0 {
+        return true;
+    }
+    let Some(next) = tokens.get(pos) else {
+        return false;
+    };
+    if language == Language::Go {
+        return next.line == tokens[pos - 1].line && next.token_type != TokenType::RightBrace;
+    }
+
+    match &next.token_type {
+        TokenType::Else
+        | TokenType::ElseIf
+        | TokenType::Catch
+        | TokenType::Semicolon
+        | TokenType::Comma
+        | TokenType::LeftParen
+        | TokenType::RightParen
+        | TokenType::Operator(_) => true,
+        TokenType::While => true,
+        TokenType::Identifier(word) => word == "finally",
+        _ => false,
+    }
+}
+
+/// Hash a subtree and record every node of at least `min_nodes` nodes as a candidate
+fn summarize<'a>(
+    node: &Node, tokens: &'a [Token], language: Language, file: usize, min_nodes: usize,
+    candidates: &mut Vec<Candidate>,
+) -> Summary<'a> {
+    let children: Vec<Summary> = node
+        .children
+        .iter()
+        .map(|child| summarize(child, tokens, language, file, min_nodes, candidates))
+        .collect();
+
+    let mut idents = BTreeSet::new();
+    let mut barrier = false;
+    let mut size = 1;
+    let mut items = Vec::new();
+
+    match node.kind {
+        NodeKind::Statement => {
+            let mut children_iter = node.children.iter().zip(&children).peekable();
+            let mut i = node.start;
+            while i < node.end {
+                if let Some((child, summary)) = children_iter.next_if(|(child, _)| child.start == i) {
+                    items.push(summary.hash);
+                    i = child.end;
+                    continue;
+                }
+
+                let token = &tokens[i];
+                if let TokenType::Identifier(word) = &token.token_type {
+                    if language.is_keyword(word) {
+                        barrier |= BARRIERS.contains(&word.as_str());
+                    } else {
+                        idents.insert(word.as_str());
+                        barrier |= word == "panic";
+                    }
+                }
+                items.push(leaf_hash(token, language));
+                size += 1;
+                i += 1;
+            }
+        }
+        NodeKind::Block => items.extend(canonical_order(&children)),
+    }
+
+    for child in &children {
+        size += child.size;
+        barrier |= child.barrier;
+        idents.extend(child.idents.iter().copied());
+    }
+
+    let seed = match node.kind {
+        NodeKind::Statement => 0x5354_4d54,
+        NodeKind::Block => 0x424c_4f43,
+    };
+    let hash = items.iter().fold(seed, |h, &item| combine(h, item));
+
+    if size >= min_nodes && node.end > node.start {
+        candidates.push(Candidate { file, start: node.start, end: node.end, size, hash });
+    }
+
+    Summary { hash, size, idents, barrier }
+}
+
+/// Child hashes of a block, with each run of mutually independent statements sorted
+fn canonical_order(children: &[Summary]) -> Vec<u64> {
+    let mut ordered = Vec::with_capacity(children.len());
+    let mut run: Vec<&Summary> = Vec::new();
+
+    let flush = |run: &mut Vec<&Summary>, ordered: &mut Vec<u64>| {
+        let mut hashes: Vec<u64> = run.iter().map(|s| s.hash).collect();
+        hashes.sort_unstable();
+        ordered.extend(hashes);
+        run.clear();
+    };
+
+    for child in children {
+        let independent = !child.barrier
+            && run
+                .iter()
+                .all(|other| !other.barrier && other.idents.is_disjoint(&child.idents));
+        if !independent {
+            flush(&mut run, &mut ordered);
+        }
+        run.push(child);
+    }
+    flush(&mut run, &mut ordered);
+
+    ordered
+}
+
+/// Hash of a token with identifiers and literals normalized, as in renamed mode
+fn leaf_hash(token: &Token, language: Language) -> u64 {
+    match &token.token_type {
+        TokenType::Identifier(word) if !language.is_keyword(word) => token_hash(NormalizeMode::IDENT),
+        TokenType::Literal(_) => token_hash(NormalizeMode::LIT),
+        _ => token_hash(&token.text),
+    }
+}
+
+pub(super) fn combine(hash: u64, item: u64) -> u64 {
+    (hash.rotate_left(5) ^ item).wrapping_mul(0x9e37_79b9_7f4a_7c15)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::tokenizer::Tokenizer;
+
+    fn stream(path: &str, source: &str) -> (PathBuf, Vec<Token>, Language) {
+        let tokens = Tokenizer::new(source, Language::Go)
+            .tokenize()
+            .unwrap()
+            .into_iter()
+            .filter(|t| t.token_type.is_significant())
+            .collect();
+        (PathBuf::from(path), tokens, Language::Go)
+    }
+
+    const ORIGINAL: &str = r#"
+func total(items []int) int {
+	sum := 0
+	count := 0
+	for _, item := range items {
+		if item > 0 {
+			sum += item * 2
+		}
+	}
+	return sum + count
+}
+"#;
+
+    #[test]
+    fn test_renamed_and_reformatted_copy() {
+        let copy = r#"
+func add(values []int) int {
+	acc := 1
+	n := 0
+	for _, v := range values { if v > 10 { acc += v * 3 } }
+	return acc + n
+}
+"#;
+        let clones = find_clones(&[stream("a.go", ORIGINAL), stream("b.go", copy)], 30);
+
+        assert_eq!(clones.len(), 1);
+        let lines: Vec<(usize, usize)> = clones[0].locations.iter().map(|l| (l.start_line, l.end_line)).collect();
+        assert_eq!(lines, vec![(2, 11), (2, 7)]);
+    }
+
+    #[test]
+    fn test_independent_statements_may_swap() {
+        let swapped = ORIGINAL.replace("\tsum := 0\n\tcount := 0\n", "\tcount := 0\n\tsum := 0\n");
+        let clones = find_clones(&[stream("a.go", ORIGINAL), stream("b.go", &swapped)], 30);
+
+        assert_eq!(clones.len(), 1);
+        assert_eq!(clones[0].locations[1].start_line, 2);
+    }
+
+    #[test]
+    fn test_dependent_statements_keep_order() {
+        let first = "func f() {\n\tx := load()\n\ty := x + 1\n\tz := y * 2\n\tsave(x, y, z)\n}\n";
+        let swapped = "func f() {\n\ty := x + 1\n\tx := load()\n\tz := y * 2\n\tsave(x, y, z)\n}\n";
+
+        let clones = find_clones(&[stream("a.go", first), stream("b.go", swapped)], 20);
+
+        assert!(clones.is_empty());
+    }
+
+    #[test]
+    fn test_statements_do_not_move_past_return() {
+        let first = "func f() int {\n\tif ok {\n\t\treturn 1\n\t}\n\tcleanup()\n\treturn 0\n}\n";
+        let swapped = "func f() int {\n\tcleanup()\n\tif ok {\n\t\treturn 1\n\t}\n\treturn 0\n}\n";
+
+        let clones = find_clones(&[stream("a.go", first), stream("b.go", swapped)], 15);
+
+        assert!(clones.is_empty());
+    }
+
+    #[test]
+    fn test_min_nodes_threshold() {
+        let streams = [stream("a.go", ORIGINAL), stream("b.go", ORIGINAL)];
+
+        assert_eq!(find_clones(&streams, 30).len(), 1);
+        assert!(find_clones(&streams, 500).is_empty());
+    }
+}
@@ -0,0 +1,253 @@
+use crate::Result;
+use crate::cloner::CloneLocation;
+use crate::loader::SourceFile;
+use crate::parallel::map_ordered;
+use crate::tokenizer::{Language, Token, TokenType, Tokenizer};
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
+
+/// Fewest specs in a declaration block reported when the block is copied
+pub const MIN_DECLARATION_SPECS: usize = 3;
+
+/// Declaration keywords whose grouped `( ... )` blocks are compared
+///
+/// `import` is left out: the files of one package routinely import the same packages, and
+/// goimports keeps the lists sorted, so identical import blocks say nothing about copied code.
+const KEYWORDS: &[&str] = &["const", "var", "type"];
+
+/// Top-level `const ( ... )`, `var ( ... )`, or `type ( ... )` blocks with the same tokens
+///
+/// Every instance spans one whole block, from the keyword to the closing parenthesis, whether
+/// or not the clone detector also finds it inside a longer window.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DuplicateDeclaration {
+    /// Group number, from 1 in report order
+    pub id: usize,
+    /// `const`, `var`, or `type`
+    pub keyword: String,
+    /// Specs in the block, one per declared name or name list
+    pub specs: usize,
+    /// Blocks sorted by file and line
+    pub instances: Vec<CloneLocation>,
+}
+
+/// Group the top-level declaration blocks of the Go files in `files` that are written alike
+///
+/// Blocks match when their tokens are identical, comments and layout aside. Blocks with fewer
+/// than `min_specs` specs are skipped, and so are declarations without parentheses, which
+/// declare a single spec. Groups spanning the most tokens come first.
+pub fn detect_duplicate_declarations(
+    files: &[SourceFile], min_specs: usize, jobs: usize,
+) -> Result<Vec<DuplicateDeclaration>> {
+    let per_file = map_ordered(
+        files,
+        jobs,
+        |file| -> Result<Vec<(Vec<String>, usize, CloneLocation)>> {
+            if file.language != Language::Go {
+                return Ok(Vec::new());
+            }
+            let tokens = Tokenizer::new(&file.content, file.language).tokenize()?;
+            let tokens: Vec<&Token> = tokens.iter().filter(|t| t.token_type.is_significant()).collect();
+
+            Ok(declaration_blocks(&tokens)
+                .into_iter()
+                .filter_map(|(start, close)| {
+                    let specs = count_specs(&tokens[start + 2..close]);
+                    (specs >= min_specs.max(2)).then(|| {
+                        let key = tokens[start..=close].iter().map(|t| t.text.clone()).collect();
+                        (
+                            key,
+                            specs,
+                            CloneLocation::from_tokens(file.path.clone(), tokens[start], tokens[close], 0),
+                        )
+                    })
+                })
+                .collect())
+        },
+    );
+
+    let mut groups: BTreeMap<Vec<String>, (usize, Vec<CloneLocation>)> = BTreeMap::new();
+    for blocks in per_file {
+        for (key, specs, location) in blocks? {
+            groups
+                .entry(key)
+                .or_insert_with(|| (specs, Vec::new()))
+                .1
+                .push(location);
+        }
+    }
+
+    let mut duplicates: Vec<(usize, DuplicateDeclaration)> = groups
+        .into_iter()
+        .filter(|(_, (_, instances))| instances.len() > 1)
+        .map(|(key, (specs, mut instances))| {
+            instances.sort_by(|a, b| (&a.file, a.start_line).cmp(&(&b.file, b.start_line)));
+            (
+                key.len(),
+                DuplicateDeclaration { id: 0, keyword: key[0].clone(), specs, instances },
+            )
+        })
+        .collect();
+    duplicates.sort_by(|(a_len, a), (b_len, b)| {
+        b_len.cmp(a_len).then_with(|| {
+            let first = |d: &DuplicateDeclaration| (d.instances[0].file.clone(), d.instances[0].start_line);
+            first(a).cmp(&first(b))
+        })
+    });
+
+    Ok(duplicates
+        .into_iter()
+        .enumerate()
+        .map(|(idx, (_, duplicate))| DuplicateDeclaration { id: idx + 1, ..duplicate })
+        .collect())
+}
+
+/// Indices of the keyword and closing parenthesis of every grouped top-level declaration
+fn declaration_blocks(tokens: &[&Token]) -> Vec<(usize, usize)> {
+    let mut blocks = Vec::new();
+    let (mut braces, mut parens) = (0usize, 0usize);
+    let mut i = 0;
+
+    while i < tokens.len() {
+        match &tokens[i].token_type {
+            TokenType::LeftBrace => braces += 1,
+            TokenType::RightBrace => braces = braces.saturating_sub(1),
+            TokenType::LeftParen => parens += 1,
+            TokenType::RightParen => parens = parens.saturating_sub(1),
+            TokenType::Identifier(word)
+                if braces == 0
+                    && parens == 0
+                    && KEYWORDS.contains(&word.as_str())
+                    && tokens.get(i + 1).is_some_and(|t| t.token_type == TokenType::LeftParen) =>
+            {
+                if let Some(close) = closing_paren(tokens, i + 1) {
+                    blocks.push((i, close));
+                    i = close;
+                }
+            }
+            _ => {}
+        }
+        i += 1;
+    }
+
+    blocks
+}
+
+/// Index of the `)` closing the `(` at `open`
+fn closing_paren(tokens: &[&Token], open: usize) -> Option<usize> {
+    let mut depth = 0usize;
+
+    for (offset, token) in tokens[open..].iter().enumerate() {
+        match token.token_type {
+            TokenType::LeftParen => depth += 1,
+            TokenType::RightParen => {
+                depth -= 1;
+                if depth == 0 {
+                    return Some(open + offset);
+                }
+            }
+            _ => {}
+        }
+    }
+
+    None
+}
+
+/// Specs between a block's parentheses: each starts a line, or follows a `;`, outside any
+/// nested brackets
+fn count_specs(tokens: &[&Token]) -> usize {
+    let mut specs = 0;
+    let mut depth = 0usize;
+    let mut after_separator = true;
+
+    for (i, token) in tokens.iter().enumerate() {
+        let starts_line = i == 0 || tokens[i - 1].line < token.line;
+        if depth == 0 && (starts_line || after_separator) && token.token_type != TokenType::Semicolon {
+            specs += 1;
+        }
+        after_separator = false;
+
+        match token.token_type {
+            TokenType::LeftBrace | TokenType::LeftParen | TokenType::LeftBracket => depth += 1,
+            TokenType::RightBrace | TokenType::RightParen | TokenType::RightBracket => depth = depth.saturating_sub(1),
+            TokenType::Semicolon if depth == 0 => after_separator = true,
+            _ => {}
+        }
+    }
+
+    specs
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::test_support::file;
+
+    const STATUS: &str = r#"
+const (
+	StatusOK      = 200 // success
+	StatusCreated = 201
+	StatusMoved   = 301
+)
+"#;
+
+    #[test]
+    fn test_reports_copied_blocks_with_their_span() {
+        let reformatted = "\nconst (\n\tStatusOK = 200\n\tStatusCreated = 201\n\tStatusMoved = 301\n)\n";
+        let renamed = STATUS.replace("StatusMoved", "StatusFound");
+        let files = [
+            file("a.go", &format!("{STATUS}\nfunc f() {{}}\n")),
+            file("b.go", reformatted),
+            file("c.go", &renamed),
+        ];
+
+        let found = detect_duplicate_declarations(&files, MIN_DECLARATION_SPECS, 2).unwrap();
+        assert_eq!(found.len(), 1);
+        assert_eq!(
+            (found[0].id, found[0].keyword.as_str(), found[0].specs),
+            (1, "const", 3)
+        );
+        let spans: Vec<(String, usize, usize)> = found[0]
+            .instances
+            .iter()
+            .map(|loc| (loc.file.display().to_string(), loc.start_line, loc.end_line))
+            .collect();
+        assert_eq!(spans, vec![("a.go".to_string(), 3, 7), ("b.go".to_string(), 3, 7)]);
+    }
+
+    #[test]
+    fn test_skips_small_nested_and_import_blocks() {
+        let source = r#"
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	a, b = 1, 2
+	c    = []int{
+		1,
+		2,
+	}
+)
+
+var single = map[string]int{"a": 1, "b": 2, "c": 3}
+
+func f() {
+	const (
+		x = 1
+		y = 2
+		z = 3
+	)
+}
+"#;
+        let files = [file("a.go", source), file("b.go", source)];
+
+        assert!(detect_duplicate_declarations(&files, 3, 1).unwrap().is_empty());
+        let pairs = detect_duplicate_declarations(&files, 2, 1).unwrap();
+        assert_eq!(pairs.len(), 1);
+        assert_eq!((pairs[0].keyword.as_str(), pairs[0].specs), ("var", 2));
+    }
+}
@@ -1,14 +1,60 @@
 use crate::Result;
+use crate::cache::Cache;
+use crate::cloner::ast;
+use crate::cloner::fingerprint::hash_texts;
 use crate::cloner::rolling_hash::{RollingHash, token_hash};
-use crate::tokenizer::{Language, Token, Tokenizer};
+use crate::logging::{Logger, debug};
+use crate::parallel::{Progress, default_jobs, map_ordered};
+use crate::tokenizer::{Language, NormalizeMode, Token, Tokenizer};
 use serde::{Deserialize, Serialize};
 use std::collections::HashMap;
-use std::path::PathBuf;
+use std::fmt;
+use std::path::{Path, PathBuf};
 
-/// A detected code clone
+/// How clone candidates are matched
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum CloneStrategy {
+    /// Rolling hash over windows of significant tokens
+    #[default]
+    Token,
+    /// Merkle hashes of statement and block subtrees
+    Ast,
+}
+
+impl fmt::Display for CloneStrategy {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            CloneStrategy::Token => write!(f, "token"),
+            CloneStrategy::Ast => write!(f, "ast"),
+        }
+    }
+}
+
+/// Units that token-strategy clone windows are made of
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Granularity {
+    /// Windows of tokens, which may start and end inside a statement
+    #[default]
+    Token,
+    /// Windows of whole sibling statements
+    Statement,
+}
+
+impl fmt::Display for Granularity {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Granularity::Token => write!(f, "token"),
+            Granularity::Statement => write!(f, "statement"),
+        }
+    }
+}
+
+/// A detected code clone: one group listing every instance of the duplicated sequence
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct Clone {
-    /// Unique ID for this clone group
+    /// Unique ID for this clone group, numbered from 1 in report order
     pub id: usize,
     /// Number of tokens in the cloned sequence
     pub length: usize,
@@ -17,10 +63,18 @@ pub struct Clone {
     /// Hash value of the clone (for deduplication)
     #[serde(skip)]
     pub hash: u64,
+    /// Stable ID of the group: the [`content_fingerprint`](super::fingerprint::content_fingerprint)
+    /// of its first instance, as `mccabre fingerprint` prints it
+    #[serde(default)]
+    pub group_id: String,
 }
 
 /// Location of a code clone
-#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq)]
+///
+/// Lines and columns are 1-based; columns count Unicode scalar values, so a tab or a
+/// multi-byte character is one column. `end_line`/`end_column` point just past the last
+/// character of the clone. Offsets are 0-based byte offsets into the file, end exclusive.
+#[derive(Debug, Clone, Default, Serialize, Deserialize, PartialEq, Eq)]
 pub struct CloneLocation {
     /// File path
     pub file: PathBuf,
@@ -28,133 +82,749 @@ pub struct CloneLocation {
     pub start_line: usize,
     /// Ending line number
     pub end_line: usize,
+    /// Number of tokens inside the range that did not match the other locations
+    #[serde(default)]
+    pub gap_tokens: usize,
+    /// Column of the first character
+    #[serde(default)]
+    pub start_column: usize,
+    /// Column just past the last character
+    #[serde(default)]
+    pub end_column: usize,
+    /// Byte offset of the first character
+    #[serde(default)]
+    pub start_offset: usize,
+    /// Byte offset just past the last character
+    #[serde(default)]
+    pub end_offset: usize,
+}
+
+impl CloneLocation {
+    /// Location covering `tokens` from the first to the last
+    pub(crate) fn from_tokens(file: PathBuf, first: &Token, last: &Token, gap_tokens: usize) -> Self {
+        Self {
+            file,
+            start_line: first.line,
+            end_line: last.line,
+            gap_tokens,
+            start_column: first.column,
+            end_column: last.end_column,
+            start_offset: first.offset,
+            end_offset: last.end_offset,
+        }
+    }
+}
+
+impl Clone {
+    /// Hex-encoded rolling hash of the group's first token window, shared by every instance
+    ///
+    /// It tells groups apart within one run only: span and token count do not enter it. Use
+    /// [`group_id`](Self::group_id) to follow a group across runs.
+    pub fn window_hash(&self) -> String {
+        format!("{:016x}", self.hash)
+    }
 }
 
 pub struct CloneDetector {
-    /// Minimum number of tokens to consider as a clone
-    _min_tokens: usize,
+    /// Minimum number of matched tokens for a clone to be reported
+    min_tokens: usize,
     /// Window size for rolling hash
-    window_size: usize,
+    pub(super) window_size: usize,
+    /// Token normalization applied before hashing
+    normalize: NormalizeMode,
+    /// Maximum number of mismatched tokens tolerated inside one clone
+    max_gap: usize,
+    /// Token windows or syntax subtrees
+    pub(super) strategy: CloneStrategy,
+    /// Minimum subtree size for the AST strategy
+    min_nodes: usize,
+    /// Token or statement windows
+    pub(super) granularity: Granularity,
+    /// Minimum number of statements for statement granularity
+    min_statements: usize,
+    /// Fewest instances a group needs to be reported
+    min_instances: usize,
+    /// Worker threads used to tokenize files
+    pub(super) jobs: usize,
+    /// Token streams of unchanged files are read from here
+    cache: Option<Cache>,
+    /// Told about each tokenized file
+    pub(super) progress: Option<Progress>,
+    /// Told what each matching stage found and dropped
+    logger: Option<Logger>,
 }
 
+/// Default minimum subtree size for [`CloneStrategy::Ast`]
+pub const DEFAULT_MIN_NODES: usize = 40;
+
+/// Default minimum run of statements for [`Granularity::Statement`]
+pub const DEFAULT_MIN_STATEMENTS: usize = 5;
+
 impl Default for CloneDetector {
     fn default() -> Self {
-        Self { _min_tokens: 30, window_size: 30 }
+        Self::new(30)
     }
 }
 
 impl CloneDetector {
+    /// Create a detector reporting clones of at least `min_tokens` significant tokens
+    ///
+    /// Comments and whitespace are not tokens for this purpose, so commented copies do not
+    /// reach the threshold sooner than the code they contain.
     pub fn new(min_tokens: usize) -> Self {
-        Self { _min_tokens: min_tokens, window_size: min_tokens }
+        Self {
+            min_tokens,
+            window_size: min_tokens.max(1),
+            normalize: NormalizeMode::Exact,
+            max_gap: 0,
+            strategy: CloneStrategy::Token,
+            min_nodes: DEFAULT_MIN_NODES,
+            granularity: Granularity::Token,
+            min_statements: DEFAULT_MIN_STATEMENTS,
+            min_instances: 2,
+            jobs: default_jobs(),
+            cache: None,
+            progress: None,
+            logger: None,
+        }
+    }
+
+    /// Set the token normalization mode
+    ///
+    /// [`NormalizeMode::Renamed`] finds type-2 clones (renamed identifiers, changed literals).
+    /// Reported line ranges always refer to the original source.
+    pub fn with_normalize_mode(mut self, mode: NormalizeMode) -> Self {
+        self.normalize = mode;
+        self
+    }
+
+    /// Tolerate up to `max_gap` mismatched tokens inside a clone (type-3 clones)
+    ///
+    /// Exact matches separated by a small edit are reported as one clone spanning the edit;
+    /// each location records how many of its tokens were not part of the match.
+    pub fn with_max_gap(mut self, max_gap: usize) -> Self {
+        self.max_gap = max_gap;
+        self
+    }
+
+    /// Match syntax subtrees instead of token windows
+    ///
+    /// [`CloneStrategy::Ast`] parses each file into statements and blocks and reports subtrees
+    /// of at least `min_nodes` nodes (see [`CloneDetector::with_min_nodes`]) with equal Merkle
+    /// hashes. Identifiers and literals are always normalized, adjacent statements that share no
+    /// identifiers may appear in any order, and `min_tokens`, the normalization mode, and
+    /// `max_gap` do not apply.
+    pub fn with_strategy(mut self, strategy: CloneStrategy) -> Self {
+        self.strategy = strategy;
+        self
+    }
+
+    /// Minimum subtree size for the AST strategy, counting statements, blocks, and tokens
+    pub fn with_min_nodes(mut self, min_nodes: usize) -> Self {
+        self.min_nodes = min_nodes.max(1);
+        self
+    }
+
+    /// Match runs of whole statements instead of token windows
+    ///
+    /// With [`Granularity::Statement`] every instance starts at the beginning of a statement
+    /// and ends at the end of one, a statement taking the blocks it opens along. Statements are
+    /// only matched with runs of siblings, so a clone never leaves the block it starts in, and
+    /// the threshold is `min_statements` (see [`CloneDetector::with_min_statements`]) rather
+    /// than `min_tokens`. Normalization applies to the tokens of each statement; `max_gap` does
+    /// not apply. The AST strategy always matches whole statements and ignores this setting.
+    pub fn with_granularity(mut self, granularity: Granularity) -> Self {
+        self.granularity = granularity;
+        self
+    }
+
+    /// Minimum run of statements reported with [`Granularity::Statement`]
+    pub fn with_min_statements(mut self, min_statements: usize) -> Self {
+        self.min_statements = min_statements.max(1);
+        self
+    }
+
+    /// Report only groups with at least `min_instances` instances (default: 2, every group)
+    ///
+    /// Groups are dropped before they are numbered, so ids stay sequential. A group nested in a
+    /// larger one that is dropped is kept when it has enough instances itself.
+    pub fn with_min_instances(mut self, min_instances: usize) -> Self {
+        self.min_instances = min_instances.max(2);
+        self
+    }
+
+    /// Tokenize files on `jobs` worker threads (default: available CPUs)
+    ///
+    /// Token streams are merged into one index in input order, so results do not depend on
+    /// the worker count.
+    pub fn with_jobs(mut self, jobs: usize) -> Self {
+        self.jobs = jobs.max(1);
+        self
+    }
+
+    /// Reuse token streams of unchanged files from an on-disk cache
+    pub fn with_cache(mut self, cache: Cache) -> Self {
+        self.cache = Some(cache);
+        self
+    }
+
+    /// Report each file tokenized by [`CloneDetector::detect_across_files`] under the phase name
+    /// `Detecting clones`
+    pub fn with_progress(mut self, progress: Progress) -> Self {
+        self.progress = Some(progress);
+        self
+    }
+
+    /// Log token counts, index sizes, and the groups each matching stage keeps and drops
+    pub fn with_logger(mut self, logger: Logger) -> Self {
+        self.logger = Some(logger);
+        self
+    }
+
+    /// Normalization of the token streams; the AST strategy normalizes while hashing
+    pub(super) fn token_mode(&self) -> NormalizeMode {
+        match self.strategy {
+            CloneStrategy::Token => self.normalize,
+            CloneStrategy::Ast => NormalizeMode::Exact,
+        }
+    }
+
+    fn tokenize(&self, source: &str, language: Language) -> Result<Vec<Token>> {
+        Tokenizer::new(source, language)
+            .with_normalization(self.token_mode())
+            .tokenize()
     }
 
     /// Detect clones in a single file
     pub fn detect_in_file(&self, source: &str, language: Language, file_path: PathBuf) -> Result<Vec<Clone>> {
-        let tokens = Tokenizer::new(source, language).tokenize()?;
-        let significant_tokens: Vec<&Token> = tokens.iter().filter(|t| t.token_type.is_significant()).collect();
+        let tokens = self.file_tokens(&file_path, source, language)?;
+        let windows = vec![self.window_hashes(&tokens)];
+        Ok(self.find_clones(&[(file_path, tokens, language)], &windows))
+    }
 
-        if significant_tokens.len() < self.window_size {
-            return Ok(Vec::new());
+    /// Detect clones across multiple files
+    pub fn detect_across_files(&self, files: &[(PathBuf, String, Language)]) -> Result<Vec<Clone>> {
+        let phase = self.progress.as_ref().map(|p| p.phase("Detecting clones", files.len()));
+        let (streams, windows): (Vec<_>, Vec<_>) = map_ordered(files, self.jobs, |(file_path, source, language)| {
+            let tokens = self.file_tokens(file_path, source, *language);
+            if let Some(phase) = &phase {
+                phase.tick();
+            }
+            let tokens = tokens?;
+            let windows = self.window_hashes(&tokens);
+            Ok(((file_path.clone(), tokens, *language), windows))
+        })
+        .into_iter()
+        .collect::<Result<Vec<_>>>()?
+        .into_iter()
+        .unzip();
+
+        Ok(self.find_clones(&streams, &windows))
+    }
+
+    pub(crate) fn file_tokens(&self, path: &Path, source: &str, language: Language) -> Result<Vec<Token>> {
+        match &self.cache {
+            Some(cache) => cache.tokens(path, source, language, self.token_mode(), || {
+                self.significant_tokens(source, language)
+            }),
+            None => self.significant_tokens(source, language),
         }
+    }
 
-        let mut hash_map: HashMap<u64, Vec<(usize, usize)>> = HashMap::new();
-        let mut rh = RollingHash::new(self.window_size);
+    /// Group clones in token streams, given the [`window_hashes`](Self::window_hashes) of each
+    pub(crate) fn find_clones(&self, streams: &[(PathBuf, Vec<Token>, Language)], windows: &[Vec<u64>]) -> Vec<Clone> {
+        if let Some(logger) = &self.logger {
+            for (path, tokens, _) in streams {
+                logger.debug(
+                    "tokenized file",
+                    &[("file", &path.display()), ("tokens", &tokens.len())],
+                );
+            }
+        }
 
-        let token_hashes: Vec<u64> = significant_tokens.iter().map(|t| token_hash(&t.text)).collect();
+        let mut clones = match self.strategy {
+            CloneStrategy::Token => self.find_token_clones(streams, windows),
+            CloneStrategy::Ast => {
+                let clones = ast::find_clones(streams, self.min_nodes);
+                self.log(
+                    "matched subtrees",
+                    &[("min_nodes", &self.min_nodes), ("groups", &clones.len())],
+                );
+                clones
+            }
+        };
+        let found = clones.len();
+        clones.retain(|clone| clone.locations.len() >= self.min_instances);
+        self.log(
+            "min instances filter",
+            &[
+                ("min_instances", &self.min_instances),
+                ("kept", &clones.len()),
+                ("dropped", &(found - clones.len())),
+            ],
+        );
 
-        rh.init(&token_hashes[0..self.window_size]);
-        let start_line = significant_tokens[0].line;
-        let end_line = significant_tokens[self.window_size - 1].line;
-        hash_map.entry(rh.get()).or_default().push((start_line, end_line));
+        clones.sort_by(|a, b| {
+            b.locations
+                .len()
+                .cmp(&a.locations.len())
+                .then_with(|| a.locations[0].file.cmp(&b.locations[0].file))
+                .then(a.locations[0].start_line.cmp(&b.locations[0].start_line))
+        });
 
-        for i in self.window_size..token_hashes.len() {
-            let hash = rh.roll(token_hashes[i - self.window_size], token_hashes[i]);
-            let start_line = significant_tokens[i - self.window_size + 1].line;
-            let end_line = significant_tokens[i].line;
-            hash_map.entry(hash).or_default().push((start_line, end_line));
+        for (idx, clone) in clones.iter_mut().enumerate() {
+            clone.id = idx + 1;
+            clone.group_id = self.group_id(clone, streams);
         }
 
-        let mut clones = Vec::new();
-        let mut clone_id = 0;
-
-        for (hash, locations) in hash_map {
-            if locations.len() > 1 {
-                clone_id += 1;
-                clones.push(Clone {
-                    id: clone_id,
-                    length: self.window_size,
-                    locations: locations
-                        .into_iter()
-                        .map(|(start, end)| CloneLocation { file: file_path.clone(), start_line: start, end_line: end })
-                        .collect(),
-                    hash,
-                });
+        clones
+    }
+
+    /// Fingerprint of the tokens of a group's first instance, independent of discovery order
+    ///
+    /// The AST strategy matches renamed copies whatever the normalization, so its groups are
+    /// hashed under [`NormalizeMode::Renamed`] as `mccabre fingerprint` does.
+    fn group_id(&self, clone: &Clone, streams: &[(PathBuf, Vec<Token>, Language)]) -> String {
+        let Some(first) = clone.locations.first() else {
+            return String::new();
+        };
+        let Some((_, tokens, language)) = streams.iter().find(|(path, _, _)| *path == first.file) else {
+            return String::new();
+        };
+
+        let start = tokens.partition_point(|t| t.offset < first.start_offset);
+        let end = tokens.partition_point(|t| t.offset < first.end_offset);
+        let tokens = &tokens[start..end];
+        match self.strategy {
+            CloneStrategy::Token => hash_texts(tokens.iter().map(|t| t.text.as_str())),
+            CloneStrategy::Ast => hash_texts(tokens.iter().map(|t| t.renamed_text(*language))),
+        }
+    }
+
+    fn significant_tokens(&self, source: &str, language: Language) -> Result<Vec<Token>> {
+        let tokens = self.tokenize(source, language)?;
+        Ok(tokens.into_iter().filter(|t| t.token_type.is_significant()).collect())
+    }
+
+    /// Match windows across all token streams and turn them into clone groups
+    fn find_token_clones(&self, streams: &[(PathBuf, Vec<Token>, Language)], windows: &[Vec<u64>]) -> Vec<Clone> {
+        if self.granularity == Granularity::Statement {
+            return self.find_statement_clones(streams);
+        }
+
+        let mut groups = Self::matching_windows(windows);
+        let hashes = groups.len();
+        groups.retain(|g| g.positions.len() > 1);
+        self.log_index("window index", windows, hashes, groups.len());
+
+        let mut spans = Self::coalesce(&groups, self.window_size);
+        let coalesced = spans.len();
+        if self.max_gap > 0 {
+            spans = Self::bridge_gaps(spans, self.max_gap);
+        }
+        let bridged = spans.len();
+        spans = Self::absorb_fragments(spans, self.window_size);
+        self.log(
+            "clone spans",
+            &[
+                ("coalesced", &coalesced),
+                ("bridged", &bridged),
+                ("absorbed", &(bridged - spans.len())),
+            ],
+        );
+
+        let candidates = spans.len();
+        let clones: Vec<Clone> = spans
+            .into_iter()
+            .filter_map(|span| {
+                let length = span.instances.iter().map(|i| i.end - i.start - i.gap).min()?;
+                if length < self.min_tokens {
+                    return None;
+                }
+                let locations: Vec<CloneLocation> = span
+                    .instances
+                    .iter()
+                    .map(|i| {
+                        let tokens = &streams[i.file].1;
+                        CloneLocation::from_tokens(
+                            streams[i.file].0.clone(),
+                            &tokens[i.start],
+                            &tokens[i.end - 1],
+                            i.gap,
+                        )
+                    })
+                    .collect();
+
+                Some(Clone { id: 0, length, locations, hash: span.hash, group_id: String::new() })
+            })
+            .collect();
+        self.log(
+            "min tokens filter",
+            &[
+                ("min_tokens", &self.min_tokens),
+                ("kept", &clones.len()),
+                ("dropped", &(candidates - clones.len())),
+            ],
+        );
+        clones
+    }
+
+    /// Match runs of at least `min_statements` sibling statements
+    ///
+    /// Each list of siblings is matched as a stream of its own, with one hash per statement over
+    /// its tokens in place of the token hashes, so windows and the clones grown from them start
+    /// and end on statement boundaries.
+    fn find_statement_clones(&self, streams: &[(PathBuf, Vec<Token>, Language)]) -> Vec<Clone> {
+        let lists: Vec<(usize, Vec<(usize, usize)>)> = streams
+            .iter()
+            .enumerate()
+            .flat_map(|(file, (_, tokens, language))| {
+                ast::statement_lists(tokens, *language)
+                    .into_iter()
+                    .filter(|statements| statements.len() >= self.min_statements)
+                    .map(move |statements| (file, statements))
+            })
+            .collect();
+        let windows: Vec<Vec<u64>> = lists
+            .iter()
+            .map(|(file, statements)| {
+                let tokens = &streams[*file].1;
+                let hashes: Vec<u64> = statements
+                    .iter()
+                    .map(|&(start, end)| {
+                        tokens[start..end]
+                            .iter()
+                            .fold(0, |hash, t| ast::combine(hash, token_hash(&t.text)))
+                    })
+                    .collect();
+                rolling_windows(&hashes, self.min_statements)
+            })
+            .collect();
+
+        let mut groups = Self::matching_windows(&windows);
+        let hashes = groups.len();
+        groups.retain(|g| g.positions.len() > 1);
+        self.log_index("statement index", &windows, hashes, groups.len());
+
+        let spans = Self::coalesce(&groups, self.min_statements);
+        let coalesced = spans.len();
+        let spans = Self::absorb_fragments(spans, self.min_statements);
+        self.log(
+            "clone spans",
+            &[("coalesced", &coalesced), ("absorbed", &(coalesced - spans.len()))],
+        );
+
+        spans
+            .into_iter()
+            .map(|span| {
+                let ranges: Vec<(usize, usize, usize)> = span
+                    .instances
+                    .iter()
+                    .map(|i| {
+                        let (file, statements) = &lists[i.file];
+                        (*file, statements[i.start].0, statements[i.end - 1].1)
+                    })
+                    .collect();
+                let length = ranges.iter().map(|(_, start, end)| end - start).min().unwrap_or(0);
+                let locations = ranges
+                    .iter()
+                    .map(|&(file, start, end)| {
+                        let tokens = &streams[file].1;
+                        CloneLocation::from_tokens(streams[file].0.clone(), &tokens[start], &tokens[end - 1], 0)
+                    })
+                    .collect();
+
+                Clone { id: 0, length, locations, hash: span.hash, group_id: String::new() }
+            })
+            .collect()
+    }
+
+    fn log(&self, message: &str, fields: &[(&str, &dyn fmt::Display)]) {
+        debug(self.logger.as_ref(), message, fields);
+    }
+
+    /// Log how many windows were hashed, how many distinct hashes they index under, and how many
+    /// of those occur more than once
+    fn log_index(&self, message: &str, windows: &[Vec<u64>], hashes: usize, matching: usize) {
+        let total: usize = windows.iter().map(Vec::len).sum();
+        self.log(
+            message,
+            &[
+                ("streams", &windows.len()),
+                ("windows", &total),
+                ("hashes", &hashes),
+                ("matching", &matching),
+            ],
+        );
+    }
+
+    /// Rolling hash of every window of `window_size` tokens, by start index
+    ///
+    /// Empty for the AST strategy, which hashes subtrees instead, for statement granularity,
+    /// which hashes statements once they are parsed, and for streams shorter than one window.
+    /// Depends only on the tokens, so it can be stored with them.
+    pub(crate) fn window_hashes(&self, tokens: &[Token]) -> Vec<u64> {
+        if self.strategy == CloneStrategy::Ast || self.granularity == Granularity::Statement {
+            return Vec::new();
+        }
+
+        let token_hashes: Vec<u64> = tokens.iter().map(|t| token_hash(&t.text)).collect();
+        rolling_windows(&token_hashes, self.window_size)
+    }
+
+    /// Group identical window hashes across all files
+    fn matching_windows(windows: &[Vec<u64>]) -> Vec<WindowGroup> {
+        let mut hash_map: HashMap<u64, Vec<(usize, usize)>> = HashMap::new();
+
+        for (file_idx, hashes) in windows.iter().enumerate() {
+            for (start, &hash) in hashes.iter().enumerate() {
+                hash_map.entry(hash).or_default().push((file_idx, start));
             }
         }
 
-        Ok(clones)
+        let mut groups: Vec<WindowGroup> = hash_map
+            .into_iter()
+            .map(|(hash, mut positions)| {
+                positions.sort_unstable();
+                WindowGroup { hash, positions }
+            })
+            .collect();
+        groups.sort_by(|a, b| a.positions.cmp(&b.positions));
+        groups
     }
 
-    /// Detect clones across multiple files
-    pub fn detect_across_files(&self, files: &[(PathBuf, String, Language)]) -> Result<Vec<Clone>> {
-        let mut global_hash_map: HashMap<u64, Vec<CloneLocation>> = HashMap::new();
+    /// Merge runs of consecutive matching windows into maximal spans
+    ///
+    /// A long duplicated block produces one group per window offset. When every position of
+    /// a group is followed by a position of the same next group, both describe the same clone.
+    fn coalesce(groups: &[WindowGroup], window_size: usize) -> Vec<CloneSpan> {
+        let mut owner: HashMap<(usize, usize), usize> = HashMap::new();
+        for (idx, group) in groups.iter().enumerate() {
+            for &pos in &group.positions {
+                owner.insert(pos, idx);
+            }
+        }
+
+        // Returns the group that owns all positions shifted by `offset`, if it has the same size
+        let shifted = |group: &WindowGroup, offset: isize| -> Option<usize> {
+            let mut found = None;
+            for &(file, start) in &group.positions {
+                let shifted_start = start.checked_add_signed(offset)?;
+                let idx = *owner.get(&(file, shifted_start))?;
+                if found.is_some_and(|f| f != idx) || groups[idx].positions.len() != group.positions.len() {
+                    return None;
+                }
+                found = Some(idx);
+            }
+            found
+        };
 
-        for (file_path, source, language) in files {
-            let tokens = Tokenizer::new(source, *language).tokenize()?;
-            let significant_tokens: Vec<&Token> = tokens.iter().filter(|t| t.token_type.is_significant()).collect();
+        let mut spans = Vec::new();
 
-            if significant_tokens.len() < self.window_size {
+        for group in groups {
+            if shifted(group, -1).is_some() {
                 continue;
             }
 
-            let mut rh = RollingHash::new(self.window_size);
+            let mut extra = 0;
+            while let Some(next) = shifted(group, extra as isize + 1) {
+                if std::ptr::eq(&groups[next], group) {
+                    break;
+                }
+                extra += 1;
+            }
 
-            let token_hashes: Vec<u64> = significant_tokens.iter().map(|t| token_hash(&t.text)).collect();
+            // A block repeated back to back matches itself shifted by one copy; end each
+            // instance where the next copy begins instead of discarding the overlap
+            let mut length = window_size + extra;
+            let closest = group
+                .positions
+                .windows(2)
+                .filter(|pair| pair[0].0 == pair[1].0)
+                .map(|pair| pair[1].1 - pair[0].1)
+                .min();
+            if let Some(distance) = closest
+                && distance < length
+                && distance >= window_size
+            {
+                length = distance;
+            }
 
-            rh.init(&token_hashes[0..self.window_size]);
-            let start_line = significant_tokens[0].line;
-            let end_line = significant_tokens[self.window_size - 1].line;
-            global_hash_map.entry(rh.get()).or_default().push(CloneLocation {
-                file: file_path.clone(),
-                start_line,
-                end_line,
-            });
+            let mut instances: Vec<Instance> = Vec::new();
+            for &(file, start) in &group.positions {
+                let overlaps = instances
+                    .last()
+                    .is_some_and(|prev| prev.file == file && start < prev.end);
+                if !overlaps {
+                    instances.push(Instance { file, start, end: start + length, gap: 0 });
+                }
+            }
 
-            for i in self.window_size..token_hashes.len() {
-                let hash = rh.roll(token_hashes[i - self.window_size], token_hashes[i]);
-                let start_line = significant_tokens[i - self.window_size + 1].line;
-                let end_line = significant_tokens[i].line;
-                global_hash_map.entry(hash).or_default().push(CloneLocation {
-                    file: file_path.clone(),
-                    start_line,
-                    end_line,
-                });
+            if instances.len() > 1 {
+                spans.push(CloneSpan { hash: group.hash, instances });
             }
         }
 
-        let mut clones = Vec::new();
-        let mut clone_id = 0;
+        spans
+    }
+
+    /// Join spans separated by at most `max_gap` mismatched tokens in every instance
+    fn bridge_gaps(mut spans: Vec<CloneSpan>, max_gap: usize) -> Vec<CloneSpan> {
+        loop {
+            let mut by_start: HashMap<(usize, usize), Vec<usize>> = HashMap::new();
+            for (idx, span) in spans.iter().enumerate() {
+                let first = &span.instances[0];
+                by_start.entry((first.file, first.start)).or_default().push(idx);
+            }
 
-        for (hash, mut locations) in global_hash_map {
-            if locations.len() > 1 {
-                locations.sort_by(|a, b| {
-                    a.file
-                        .cmp(&b.file)
-                        .then(a.start_line.cmp(&b.start_line))
-                        .then(a.end_line.cmp(&b.end_line))
-                });
-                locations.dedup();
+            let mut merged = None;
+
+            'search: for (a_idx, a) in spans.iter().enumerate() {
+                let first = &a.instances[0];
+                for candidate_start in first.start + 1..=first.end + max_gap {
+                    let Some(candidates) = by_start.get(&(first.file, candidate_start)) else { continue };
+
+                    for &b_idx in candidates {
+                        if b_idx != a_idx
+                            && let Some(joined) = Self::join(a, &spans[b_idx], max_gap)
+                        {
+                            merged = Some((a_idx, b_idx, joined));
+                            break 'search;
+                        }
+                    }
+                }
+            }
 
-                if locations.len() > 1 {
-                    clone_id += 1;
-                    clones.push(Clone { id: clone_id, length: self.window_size, locations, hash });
+            match merged {
+                Some((a_idx, b_idx, joined)) => {
+                    spans[a_idx] = joined;
+                    spans.swap_remove(b_idx);
                 }
+                None => return spans,
             }
         }
+    }
 
-        clones.sort_by(|a, b| b.locations.len().cmp(&a.locations.len()));
-        Ok(clones)
+    /// Drop spans that only re-report part of a larger group
+    ///
+    /// When copies differ at their edges (say, in the function name), a subset of them can match
+    /// a few tokens further than the full group, which would show up as an extra pair overlapping
+    /// the group. Such a span is dropped when every instance overlaps an instance of a group with
+    /// more members and adds fewer than `min_new` tokens of its own.
+    ///
+    /// Instances are swept in file order, so each is compared only with the instances it
+    /// overlaps. A long run of repeated tokens, as in generated tables, forms one group with
+    /// thousands of instances that would otherwise be compared with each other.
+    fn absorb_fragments(spans: Vec<CloneSpan>, min_new: usize) -> Vec<CloneSpan> {
+        let mut by_file: HashMap<usize, Vec<(usize, usize, usize)>> = HashMap::new();
+        for (idx, span) in spans.iter().enumerate() {
+            for inst in &span.instances {
+                by_file.entry(inst.file).or_default().push((inst.start, inst.end, idx));
+            }
+        }
+
+        let mut absorbed = vec![true; spans.len()];
+        for mut instances in by_file.into_values() {
+            instances.sort_unstable();
+            // Earlier instances still open at the current start; as many as clones nest deep
+            let mut open: Vec<(usize, usize, usize)> = Vec::new();
+
+            for (i, &(start, end, idx)) in instances.iter().enumerate() {
+                open.retain(|&(_, open_end, _)| open_end > start);
+                let later = instances[i + 1..]
+                    .iter()
+                    .take_while(|&&(later_start, _, _)| later_start < end);
+
+                let covered = open.iter().chain(later).any(|&(other_start, other_end, other)| {
+                    let overlap = end.min(other_end).saturating_sub(start.max(other_start));
+                    spans[other].instances.len() > spans[idx].instances.len()
+                        && overlap > 0
+                        && end - start - overlap < min_new
+                });
+                if !covered {
+                    absorbed[idx] = false;
+                }
+                open.push((start, end, idx));
+            }
+        }
+
+        spans
+            .into_iter()
+            .zip(absorbed)
+            .filter(|(_, absorbed)| !absorbed)
+            .map(|(span, _)| span)
+            .collect()
     }
+
+    /// Join two spans if `b` follows `a` within the gap budget in every instance
+    fn join(a: &CloneSpan, b: &CloneSpan, max_gap: usize) -> Option<CloneSpan> {
+        if a.instances.len() != b.instances.len() {
+            return None;
+        }
+
+        let mut instances = Vec::with_capacity(a.instances.len());
+
+        for (x, y) in a.instances.iter().zip(&b.instances) {
+            if x.file != y.file || y.start <= x.start || y.end <= x.end {
+                return None;
+            }
+
+            let gap = x.gap + y.gap + y.start.saturating_sub(x.end);
+            if y.start > x.end + max_gap || gap > max_gap {
+                return None;
+            }
+
+            instances.push(Instance { file: x.file, start: x.start, end: y.end, gap });
+        }
+
+        let overlaps = instances
+            .windows(2)
+            .any(|pair| pair[0].file == pair[1].file && pair[1].start < pair[0].end);
+        if overlaps {
+            return None;
+        }
+
+        Some(CloneSpan { hash: a.hash, instances })
+    }
+}
+
+/// Rolling hash of every window of `window_size` values, by start index; empty when there are
+/// fewer values than that
+fn rolling_windows(hashes: &[u64], window_size: usize) -> Vec<u64> {
+    if hashes.len() < window_size {
+        return Vec::new();
+    }
+
+    let mut rh = RollingHash::new(window_size);
+    rh.init(&hashes[0..window_size]);
+
+    let mut windows = Vec::with_capacity(hashes.len() - window_size + 1);
+    windows.push(rh.get());
+    for i in window_size..hashes.len() {
+        windows.push(rh.roll(hashes[i - window_size], hashes[i]));
+    }
+    windows
+}
+
+/// Window positions `(file index, token index)` sharing one rolling hash
+struct WindowGroup {
+    hash: u64,
+    positions: Vec<(usize, usize)>,
+}
+
+/// A clone group expressed in token indices
+struct CloneSpan {
+    hash: u64,
+    instances: Vec<Instance>,
+}
+
+/// One occurrence of a clone: tokens `start..end` of a file, `gap` of which did not match
+#[derive(Debug, Clone, Copy)]
+struct Instance {
+    file: usize,
+    start: usize,
+    end: usize,
+    gap: usize,
 }
 
 #[cfg(test)]
@@ -178,6 +848,56 @@ fn simple() {
         assert_eq!(clones.len(), 0);
     }
 
+    #[test]
+    fn test_identical_functions_form_one_group() {
+        let body = |name: &str| {
+            format!(
+                "func {name}(input string) string {{\n\ttrimmed := strings.TrimSpace(input)\n\tif len(trimmed) == 0 {{\n\t\treturn \"\"\n\t}}\n\treturn strings.ToLower(trimmed)\n}}\n\n"
+            )
+        };
+        let source = format!("package main\n\n{}{}{}", body("a"), body("b"), body("c"));
+
+        let clones = CloneDetector::new(10)
+            .detect_in_file(&source, Language::Go, PathBuf::from("not_dry.go"))
+            .unwrap();
+
+        assert_eq!(clones.len(), 1);
+        assert_eq!(clones[0].id, 1);
+        assert_eq!(clones[0].locations.len(), 3);
+        assert_eq!(clones[0].window_hash().len(), 16);
+        let starts: Vec<usize> = clones[0].locations.iter().map(|l| l.start_line).collect();
+        assert_eq!(starts, vec![3, 11, 19]);
+    }
+
+    #[test]
+    fn test_locations_select_exact_region() {
+        let body = |name: &str| {
+            format!(
+                "func {name}(input string) string {{\n\ttrimmed := strings.TrimSpace(input) // é\n\tif len(trimmed) == 0 {{\n\t\treturn \"\"\n\t}}\n\treturn strings.ToLower(trimmed)\n}}\n"
+            )
+        };
+        let source = format!("package main\n\n{}\n{}", body("a"), body("b"));
+
+        let clones = CloneDetector::new(10)
+            .detect_in_file(&source, Language::Go, PathBuf::from("not_dry.go"))
+            .unwrap();
+
+        assert_eq!(clones.len(), 1);
+        let regions: Vec<&str> = clones[0]
+            .locations
+            .iter()
+            .map(|l| &source[l.start_offset..l.end_offset])
+            .collect();
+        assert_eq!(regions[0], regions[1]);
+        assert!(regions[0].ends_with('}'));
+
+        // Offsets are bytes, so the `é` before the second copy shifts them but not its columns;
+        // the names differ, so the clone starts at the opening parenthesis
+        let second = &clones[0].locations[1];
+        assert_eq!((second.start_line, second.start_column), (11, 7));
+        assert_eq!((second.end_line, second.end_column), (17, 2));
+    }
+
     #[test]
     fn test_detect_simple_clone() {
         let source = r#"
@@ -241,6 +961,28 @@ fn another() {
         }
     }
 
+    #[test]
+    fn test_identical_helper_in_two_files() {
+        let helper = "func normalize(input string) string {\n\ttrimmed := strings.TrimSpace(input)\n\tif len(trimmed) == 0 {\n\t\treturn \"\"\n\t}\n\treturn strings.ToLower(trimmed)\n}\n";
+        let a = format!("package a\n\nimport \"strings\"\n\n{helper}");
+        let b =
+            format!("package b\n\nimport \"strings\"\n\nfunc Parse(s string) int {{\n\treturn len(s)\n}}\n\n{helper}");
+        let files = vec![
+            (PathBuf::from("a.go"), a, Language::Go),
+            (PathBuf::from("b.go"), b, Language::Go),
+        ];
+
+        let clones = CloneDetector::new(20).detect_across_files(&files).unwrap();
+
+        assert_eq!(clones.len(), 1);
+        let spans: Vec<(&str, usize, usize)> = clones[0]
+            .locations
+            .iter()
+            .map(|l| (l.file.to_str().unwrap(), l.start_line, l.end_line))
+            .collect();
+        assert_eq!(spans, vec![("a.go", 5, 11), ("b.go", 9, 15)]);
+    }
+
     #[test]
     fn test_min_tokens_threshold() {
         let source = "let x = 5; let y = 10; let x = 5; let y = 10;";
@@ -257,4 +999,514 @@ fn another() {
 
         assert!(clones2.len() <= clones1.len());
     }
+
+    #[test]
+    fn test_trivial_repeats_below_threshold() {
+        let source = r#"
+func a() error {
+	return nil
+}
+
+func b() error {
+	return nil
+}
+
+func c() error {
+	return nil
+}
+"#;
+        let clones = CloneDetector::new(30)
+            .detect_in_file(source, Language::Go, PathBuf::from("small.go"))
+            .unwrap();
+        assert!(clones.is_empty());
+    }
+
+    #[test]
+    fn test_reported_clones_meet_threshold() {
+        let source = "let x = 5; let y = 10; let x = 5; let y = 10; let z = 1;";
+
+        for min_tokens in [3, 5, 8, 10] {
+            let clones = CloneDetector::new(min_tokens)
+                .detect_in_file(source, Language::Rust, PathBuf::from("test.rs"))
+                .unwrap();
+            assert!(!clones.is_empty(), "expected a clone at min_tokens = {min_tokens}");
+            assert!(clones.iter().all(|c| c.length >= min_tokens));
+        }
+    }
+
+    #[test]
+    fn test_comments_do_not_count_toward_threshold() {
+        // Each block has 7 code tokens; the comments would push it over 10 if they counted
+        let source = r#"
+// compute the first value from the inputs given
+let x = a + b;
+// compute the first value from the inputs given
+let x = a + b;
+"#;
+        let clones = CloneDetector::new(10)
+            .detect_in_file(source, Language::Rust, PathBuf::from("test.rs"))
+            .unwrap();
+        assert!(clones.is_empty());
+
+        let clones = CloneDetector::new(7)
+            .detect_in_file(source, Language::Rust, PathBuf::from("test.rs"))
+            .unwrap();
+        assert_eq!(clones.len(), 1);
+        assert_eq!(clones[0].length, 7);
+    }
+
+    #[test]
+    fn test_comments_do_not_affect_matches() {
+        let body = "\tfor _, v := range values {\n\t\tif v > limit {\n\t\t\tcount++\n\t\t}\n\t}\n\treturn count\n";
+        let commented = format!(
+            "package a\n\n// Count values above the limit.\nfunc Count(values []int, limit int) (count int) {{\n\t/* scan everything */\n{body}}}\n"
+        );
+        let plain = format!("package a\n\nfunc Count(values []int, limit int) (count int) {{\n{body}}}\n");
+        let files = vec![
+            (PathBuf::from("commented.go"), commented, Language::Go),
+            (PathBuf::from("plain.go"), plain, Language::Go),
+        ];
+
+        let clones = CloneDetector::new(20).detect_across_files(&files).unwrap();
+        assert_eq!(clones.len(), 1);
+        let commented = clones[0]
+            .locations
+            .iter()
+            .find(|l| l.file.ends_with("commented.go"))
+            .unwrap();
+        assert_eq!(commented.gap_tokens, 0);
+        assert_eq!((commented.start_line, commented.end_line), (1, 12));
+
+        // A copied comment block is not a clone of anything when the code under it differs
+        let header = "// Package util holds helpers shared by the handlers. Each helper\n// validates its input, logs failures, and returns a wrapped error.\n";
+        let files = vec![
+            (
+                PathBuf::from("a.go"),
+                format!("{header}package util\n\nfunc A(x int) int {{\n\treturn x * 2\n}}\n"),
+                Language::Go,
+            ),
+            (
+                PathBuf::from("b.go"),
+                format!("{header}package util\n\nvar names = map[string]bool{{\"a\": true}}\n"),
+                Language::Go,
+            ),
+        ];
+        assert!(CloneDetector::new(10).detect_across_files(&files).unwrap().is_empty());
+    }
+
+    #[test]
+    fn test_renamed_mode_detects_type2_clones() {
+        let file1 = r#"
+func processUserInput(input string) string {
+	trimmed := strings.TrimSpace(input)
+	if len(trimmed) == 0 {
+		return ""
+	}
+	return strings.ToLower(trimmed)
+}
+"#;
+        let file2 = r#"
+// unrelated header line
+func processProductName(name string) string {
+	cleaned := strings.TrimSpace(name)
+	if len(cleaned) == 0 {
+		return "-"
+	}
+	return strings.ToLower(cleaned)
+}
+"#;
+        let files = vec![
+            (PathBuf::from("user.go"), file1.to_string(), Language::Go),
+            (PathBuf::from("product.go"), file2.to_string(), Language::Go),
+        ];
+
+        let exact = CloneDetector::new(20).detect_across_files(&files).unwrap();
+        assert!(exact.is_empty());
+
+        let renamed = CloneDetector::new(20)
+            .with_normalize_mode(NormalizeMode::Renamed)
+            .detect_across_files(&files)
+            .unwrap();
+        assert!(!renamed.is_empty());
+
+        for clone in &renamed {
+            let user = clone.locations.iter().find(|l| l.file.ends_with("user.go")).unwrap();
+            let product = clone.locations.iter().find(|l| l.file.ends_with("product.go")).unwrap();
+            assert_eq!(product.start_line, user.start_line + 1);
+            assert_eq!(product.end_line, user.end_line + 1);
+            assert!(user.start_line >= 2 && user.end_line <= 8);
+        }
+    }
+
+    #[test]
+    fn test_strings_mode_ignores_literal_text_only() {
+        let english = r#"
+func greet(w io.Writer, name string, visits int) {
+	if visits == 0 {
+		fmt.Fprintf(w, "Welcome, %s!\n", name)
+		return
+	}
+	fmt.Fprintf(w, "Hello again, %s (visit %d)\n", name, visits)
+}
+"#;
+        let french = english
+            .replace("Welcome, %s!", "Bienvenue, %s !")
+            .replace("Hello again, %s (visit %d)", "Re-bonjour, %s (visite %d)");
+        let renamed = french.replace("visits", "count");
+        let files = |other: &str| {
+            vec![
+                (PathBuf::from("en.go"), english.to_string(), Language::Go),
+                (PathBuf::from("fr.go"), other.to_string(), Language::Go),
+            ]
+        };
+        let detect = |mode, other: &str| {
+            CloneDetector::new(40)
+                .with_normalize_mode(mode)
+                .detect_across_files(&files(other))
+                .unwrap()
+        };
+
+        assert!(detect(NormalizeMode::Exact, &french).is_empty());
+        let clones = detect(NormalizeMode::Strings, &french);
+        assert_eq!(clones.len(), 1);
+        assert_eq!(
+            (clones[0].locations[0].start_line, clones[0].locations[0].end_line),
+            (2, 8)
+        );
+
+        assert!(detect(NormalizeMode::Strings, &renamed).is_empty());
+        assert!(!detect(NormalizeMode::Renamed, &renamed).is_empty());
+    }
+
+    const SUM_PLAIN: &str = r#"
+func sumValues(values []int, scale int) int {
+	total := 0
+	for _, v := range values {
+		total += v * scale
+		count = count + 1
+	}
+	return total / count
+}
+"#;
+
+    const SUM_GUARDED: &str = r#"
+func sumValues(values []int, scale int) int {
+	total := 0
+	for _, v := range values {
+		if v < 0 { continue }
+		total += v * scale
+		count = count + 1
+	}
+	return total / count
+}
+"#;
+
+    fn guard_files() -> Vec<(PathBuf, String, Language)> {
+        vec![
+            (PathBuf::from("plain.go"), SUM_PLAIN.to_string(), Language::Go),
+            (PathBuf::from("guarded.go"), SUM_GUARDED.to_string(), Language::Go),
+        ]
+    }
+
+    #[test]
+    fn test_added_guard_splits_exact_clones() {
+        let clones = CloneDetector::new(10).detect_across_files(&guard_files()).unwrap();
+
+        assert_eq!(clones.len(), 2);
+        for clone in &clones {
+            assert!(clone.locations.iter().all(|l| l.gap_tokens == 0));
+        }
+    }
+
+    #[test]
+    fn test_logger_reports_stages_without_changing_results() {
+        use std::sync::{Arc, Mutex};
+
+        let seen = Arc::new(Mutex::new(Vec::new()));
+        let logger = Logger::new({
+            let seen = Arc::clone(&seen);
+            move |line| seen.lock().unwrap().push(line.to_string())
+        });
+        let detector = || CloneDetector::new(10).with_max_gap(7).with_jobs(1);
+
+        let expected = detector().detect_across_files(&guard_files()).unwrap();
+        let clones = detector()
+            .with_logger(logger)
+            .detect_across_files(&guard_files())
+            .unwrap();
+        assert_eq!(format!("{clones:?}"), format!("{expected:?}"));
+
+        let seen = seen.lock().unwrap();
+        let stages = [
+            "msg=\"tokenized file\" file=plain.go tokens=",
+            "msg=\"tokenized file\" file=guarded.go tokens=",
+            "msg=\"window index\" streams=2 windows=",
+            "msg=\"clone spans\" coalesced=",
+            "msg=\"min tokens filter\" min_tokens=10 kept=1 dropped=0",
+            "msg=\"min instances filter\" min_instances=2 kept=1 dropped=0",
+        ];
+        assert_eq!(seen.len(), stages.len());
+        for (line, stage) in seen.iter().zip(stages) {
+            assert!(line.starts_with(stage), "{line}");
+        }
+    }
+
+    #[test]
+    fn test_max_gap_merges_guarded_clone() {
+        let clones = CloneDetector::new(10)
+            .with_max_gap(7)
+            .detect_across_files(&guard_files())
+            .unwrap();
+        assert_eq!(clones.len(), 1);
+
+        let clone = &clones[0];
+        let plain = clone.locations.iter().find(|l| l.file.ends_with("plain.go")).unwrap();
+        let guarded = clone.locations.iter().find(|l| l.file.ends_with("guarded.go")).unwrap();
+
+        assert_eq!((plain.start_line, plain.end_line), (2, 9));
+        assert_eq!((guarded.start_line, guarded.end_line), (2, 10));
+        assert_eq!(plain.gap_tokens, 0);
+        assert_eq!(guarded.gap_tokens, 7);
+        let total_tokens = Tokenizer::new(SUM_PLAIN, Language::Go)
+            .tokenize()
+            .unwrap()
+            .into_iter()
+            .filter(|t| t.token_type.is_significant())
+            .count();
+        assert_eq!(clone.length, total_tokens);
+    }
+
+    #[test]
+    fn test_max_gap_too_small_keeps_clones_apart() {
+        let clones = CloneDetector::new(10)
+            .with_max_gap(6)
+            .detect_across_files(&guard_files())
+            .unwrap();
+        assert_eq!(clones.len(), 2);
+    }
+
+    #[test]
+    fn test_clone_ids_are_sequential() {
+        let clones = CloneDetector::new(10).detect_across_files(&guard_files()).unwrap();
+        let ids: Vec<usize> = clones.iter().map(|c| c.id).collect();
+        assert_eq!(ids, vec![1, 2]);
+    }
+
+    #[test]
+    fn test_min_instances_drops_pairs() {
+        let detector = || CloneDetector::new(10).with_min_instances(3);
+        assert_eq!(
+            CloneDetector::new(10)
+                .detect_across_files(&guard_files())
+                .unwrap()
+                .len(),
+            2
+        );
+        assert!(detector().detect_across_files(&guard_files()).unwrap().is_empty());
+
+        let mut files = guard_files();
+        files.push((PathBuf::from("copy.go"), SUM_GUARDED.to_string(), Language::Go));
+        let clones = detector().detect_across_files(&files).unwrap();
+        assert!(!clones.is_empty());
+        assert!(clones.iter().all(|c| c.locations.len() >= 3));
+        assert_eq!(clones[0].id, 1);
+    }
+
+    #[test]
+    fn test_results_independent_of_jobs() {
+        let files: Vec<(PathBuf, String, Language)> = (0..12)
+            .map(|i| {
+                let source = if i % 2 == 0 { SUM_PLAIN } else { SUM_GUARDED };
+                (PathBuf::from(format!("f{i}.go")), source.to_string(), Language::Go)
+            })
+            .collect();
+        let run = |jobs| {
+            let clones = CloneDetector::new(10)
+                .with_jobs(jobs)
+                .detect_across_files(&files)
+                .unwrap();
+            serde_json::to_string(&clones).unwrap()
+        };
+
+        let serial = run(1);
+        for jobs in [2, 4, 16] {
+            assert_eq!(run(jobs), serial);
+        }
+    }
+
+    /// The pairwise check `absorb_fragments` replaced, kept to compare results with
+    fn absorb_fragments_pairwise(spans: Vec<CloneSpan>, min_new: usize) -> Vec<CloneSpan> {
+        let mut by_file: HashMap<usize, Vec<(usize, usize, usize)>> = HashMap::new();
+        for (idx, span) in spans.iter().enumerate() {
+            for inst in &span.instances {
+                by_file.entry(inst.file).or_default().push((inst.start, inst.end, idx));
+            }
+        }
+
+        let absorbed: Vec<bool> = spans
+            .iter()
+            .map(|span| {
+                span.instances.iter().all(|inst| {
+                    by_file[&inst.file].iter().any(|&(start, end, other)| {
+                        let overlap = inst.end.min(end).saturating_sub(inst.start.max(start));
+                        spans[other].instances.len() > span.instances.len()
+                            && overlap > 0
+                            && inst.end - inst.start - overlap < min_new
+                    })
+                })
+            })
+            .collect();
+
+        spans
+            .into_iter()
+            .zip(absorbed)
+            .filter(|(_, absorbed)| !absorbed)
+            .map(|(span, _)| span)
+            .collect()
+    }
+
+    #[test]
+    fn test_absorb_fragments_matches_pairwise_check() {
+        let root = Path::new(env!("CARGO_MANIFEST_DIR"));
+        let sources: Vec<(String, Language)> = ["../../examples/not_dry.go", "../../examples/complex.js"]
+            .into_iter()
+            .map(|path| root.join(path))
+            .chain(
+                std::fs::read_dir(root.join("testdata/multipkg"))
+                    .unwrap()
+                    .map(|entry| entry.unwrap().path()),
+            )
+            .map(|path| {
+                let language = Language::from_path(&path).unwrap();
+                (std::fs::read_to_string(path).unwrap(), language)
+            })
+            .chain([
+                (SUM_PLAIN.repeat(3), Language::Go),
+                (SUM_GUARDED.repeat(2), Language::Go),
+            ])
+            .collect();
+
+        let debug = |spans: &[CloneSpan]| -> Vec<String> {
+            spans
+                .iter()
+                .map(|span| format!("{:x} {:?}", span.hash, span.instances))
+                .collect()
+        };
+
+        for mode in [NormalizeMode::Exact, NormalizeMode::Renamed] {
+            for (min_tokens, max_gap) in [(10, 0), (20, 0), (10, 4), (30, 2)] {
+                let detector = CloneDetector::new(min_tokens).with_normalize_mode(mode);
+                let windows: Vec<Vec<u64>> = sources
+                    .iter()
+                    .map(|(source, language)| {
+                        let tokens = detector.significant_tokens(source, *language).unwrap();
+                        detector.window_hashes(&tokens)
+                    })
+                    .collect();
+                let spans = || {
+                    let mut groups = CloneDetector::matching_windows(&windows);
+                    groups.retain(|g| g.positions.len() > 1);
+                    let spans = CloneDetector::coalesce(&groups, detector.window_size);
+                    if max_gap > 0 { CloneDetector::bridge_gaps(spans, max_gap) } else { spans }
+                };
+
+                let swept = CloneDetector::absorb_fragments(spans(), detector.window_size);
+                let pairwise = absorb_fragments_pairwise(spans(), detector.window_size);
+                assert!(!swept.is_empty());
+                assert_eq!(
+                    debug(&swept),
+                    debug(&pairwise),
+                    "{mode:?}, {min_tokens} tokens, gap {max_gap}"
+                );
+            }
+        }
+    }
+
+    #[test]
+    fn test_long_repeated_token_run() {
+        let row = format!("\t{},\n", vec!["0x00"; 16].join(", "));
+        let source = format!("package blob\n\nvar data = []byte{{\n{}}}\n", row.repeat(5_000));
+
+        let clones = CloneDetector::new(30)
+            .detect_in_file(&source, Language::Go, PathBuf::from("blob.go"))
+            .unwrap();
+        assert!(clones[0].locations.len() > 2_500);
+        let ends: Vec<usize> = clones[0].locations.iter().map(|l| l.end_offset).collect();
+        assert!(
+            clones[0].locations[1..]
+                .iter()
+                .zip(&ends)
+                .all(|(l, end)| l.start_offset >= *end)
+        );
+    }
+
+    const SEEDED: &str = r#"package main
+
+func seeded(kind string) int {
+	total := seed(kind, "a")
+	total += compute(kind, 1)
+	if total > 10 {
+		total -= 3
+	}
+	for i := 0; i < total; i++ {
+		total += i
+	}
+	results = append(results, total)
+	return finish(total, "a")
+}
+"#;
+
+    #[test]
+    fn test_statement_granularity_keeps_whole_statements() {
+        let files = vec![
+            (PathBuf::from("a.go"), SEEDED.to_string(), Language::Go),
+            (PathBuf::from("b.go"), SEEDED.replace("\"a\"", "\"b\""), Language::Go),
+        ];
+        let span = |clones: &[Clone]| {
+            let l = &clones[0].locations[0];
+            ((l.start_line, l.start_column), (l.end_line, l.end_column))
+        };
+
+        let tokens = CloneDetector::new(20).detect_across_files(&files).unwrap();
+        assert_eq!(span(&tokens), ((4, 25), (13, 22)));
+
+        let statements = |min_statements| {
+            CloneDetector::new(20)
+                .with_granularity(Granularity::Statement)
+                .with_min_statements(min_statements)
+                .detect_across_files(&files)
+                .unwrap()
+        };
+        let clones = statements(4);
+        assert_eq!(clones.len(), 1);
+        assert_eq!(span(&clones), ((5, 2), (12, 34)));
+        assert!(statements(5).is_empty());
+
+        let renamed = CloneDetector::new(20)
+            .with_granularity(Granularity::Statement)
+            .with_normalize_mode(NormalizeMode::Renamed)
+            .detect_across_files(&files)
+            .unwrap();
+        assert_eq!(span(&renamed), ((4, 2), (13, 27)));
+    }
+
+    #[test]
+    fn test_ast_strategy_matches_renamed_copy() {
+        let renamed = SUM_PLAIN.replace("total", "acc").replace("values", "xs");
+        let files = vec![
+            (PathBuf::from("a.go"), SUM_PLAIN.to_string(), Language::Go),
+            (PathBuf::from("b.go"), renamed, Language::Go),
+        ];
+
+        assert!(CloneDetector::new(30).detect_across_files(&files).unwrap().is_empty());
+
+        let clones = CloneDetector::new(30)
+            .with_strategy(CloneStrategy::Ast)
+            .with_min_nodes(20)
+            .detect_across_files(&files)
+            .unwrap();
+        assert_eq!(clones.len(), 1);
+        assert_eq!(clones[0].id, 1);
+        assert_eq!(clones[0].locations[0].start_line, 2);
+    }
 }
@@ -0,0 +1,308 @@
+use crate::Result;
+use crate::cloner::CloneLocation;
+use crate::loader::SourceFile;
+use crate::parallel::map_ordered;
+use crate::rules::matching_brace;
+use crate::tokenizer::{Language, NormalizeMode, Token, TokenType, Tokenizer};
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
+
+/// Fewest copies of one error-handling block reported as a cluster
+pub const MIN_CLUSTER_SIZE: usize = 3;
+
+/// Structurally identical `if err != nil { ... }` blocks
+///
+/// The blocks are far below any useful clone-detection threshold, but a shape repeated many
+/// times is a sign that a helper would pay off.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ErrcheckCluster {
+    /// Cluster number, from 1 in report order
+    pub id: usize,
+    /// The block with names and literals normalized, e.g. `if err != nil { return nil, fmt.Errorf(LIT, IDENT, err) }`
+    pub shape: String,
+    /// Blocks sorted by file and line, from the `if` to the closing brace
+    pub instances: Vec<CloneLocation>,
+}
+
+/// Group the Go error checks of `files` by shape
+///
+/// A check is an `if` whose condition (after any init statement) is `<name> != nil` for a
+/// name that is `err` or ends in `err`/`Err`, and that has no `else`. Variables become `IDENT`
+/// and literals `LIT`, and the error variable is always shown as `err`, so `closeErr` and `err`
+/// checks match. A block that only passes the error up, such as `return err` or
+/// `return nil, err`, is idiomatic and never counted.
+///
+/// Clusters with at least `min_size` blocks are returned, largest first.
+pub fn detect_errcheck_clusters(files: &[SourceFile], min_size: usize, jobs: usize) -> Result<Vec<ErrcheckCluster>> {
+    let per_file = map_ordered(
+        files,
+        jobs,
+        |file| -> Result<Vec<(Vec<String>, String, CloneLocation)>> {
+            if file.language != Language::Go {
+                return Ok(Vec::new());
+            }
+            let tokens = Tokenizer::new(&file.content, file.language).tokenize()?;
+            let tokens: Vec<&Token> = tokens.iter().filter(|t| t.token_type.is_significant()).collect();
+
+            Ok(error_checks(&tokens)
+                .into_iter()
+                .map(|(start, open, close, var)| {
+                    let shape_tokens = shape_tokens(&tokens[start..=close], &tokens[start + 1..open]);
+                    let key = shape_key(&shape_tokens, var);
+                    let shape = shape_text(&shape_tokens, &key);
+                    (
+                        key,
+                        shape,
+                        CloneLocation::from_tokens(file.path.clone(), tokens[start], tokens[close], 0),
+                    )
+                })
+                .collect())
+        },
+    );
+
+    let mut groups: BTreeMap<Vec<String>, (String, Vec<CloneLocation>)> = BTreeMap::new();
+    for check in per_file {
+        for (key, shape, location) in check? {
+            groups
+                .entry(key)
+                .or_insert_with(|| (shape, Vec::new()))
+                .1
+                .push(location);
+        }
+    }
+
+    let mut clusters: Vec<ErrcheckCluster> = groups
+        .into_values()
+        .filter(|(_, instances)| instances.len() >= min_size.max(2))
+        .map(|(shape, mut instances)| {
+            instances.sort_by(|a, b| (&a.file, a.start_line).cmp(&(&b.file, b.start_line)));
+            ErrcheckCluster { id: 0, shape, instances }
+        })
+        .collect();
+
+    clusters.sort_by(|a, b| {
+        b.instances
+            .len()
+            .cmp(&a.instances.len())
+            .then_with(|| a.shape.cmp(&b.shape))
+    });
+    for (idx, cluster) in clusters.iter_mut().enumerate() {
+        cluster.id = idx + 1;
+    }
+
+    Ok(clusters)
+}
+
+/// `(if index, body open, body close, error variable)` of every counted error check
+fn error_checks<'a>(tokens: &[&'a Token]) -> Vec<(usize, usize, usize, &'a str)> {
+    let mut checks = Vec::new();
+
+    for start in (0..tokens.len()).filter(|&i| tokens[i].token_type == TokenType::If) {
+        let Some(open) = header_end(tokens, start) else { continue };
+        let Some(close) = matching_brace(tokens, open) else { continue };
+        let Some(var) = checked_error(&tokens[start + 1..open]) else { continue };
+
+        let has_else = tokens
+            .get(close + 1)
+            .is_some_and(|t| matches!(t.token_type, TokenType::Else | TokenType::ElseIf));
+        if !has_else && !is_bare_return(&tokens[open + 1..close], var) {
+            checks.push((start, open, close, var));
+        }
+    }
+
+    checks
+}
+
+/// Index of the `{` opening the body of the `if` at `start`
+fn header_end(tokens: &[&Token], start: usize) -> Option<usize> {
+    let mut depth = 0usize;
+
+    (start + 1..tokens.len()).find(|&i| match tokens[i].token_type {
+        TokenType::LeftParen | TokenType::LeftBracket => {
+            depth += 1;
+            false
+        }
+        TokenType::RightParen | TokenType::RightBracket => {
+            depth = depth.saturating_sub(1);
+            false
+        }
+        TokenType::LeftBrace => depth == 0,
+        _ => false,
+    })
+}
+
+/// The error variable when the condition, after any init statement, is `<err> != nil`
+fn checked_error<'a>(header: &[&'a Token]) -> Option<&'a str> {
+    let condition = match header.iter().rposition(|t| t.token_type == TokenType::Semicolon) {
+        Some(semicolon) => &header[semicolon + 1..],
+        None => header,
+    };
+
+    match condition {
+        [name, op, nil] if op.text == "!=" && nil.text == "nil" => match &name.token_type {
+            TokenType::Identifier(var) if is_error_name(var) => Some(var),
+            _ => None,
+        },
+        _ => None,
+    }
+}
+
+fn is_error_name(name: &str) -> bool {
+    name == "err" || name.ends_with("err") || name.ends_with("Err")
+}
+
+/// Whether the body is a single `return` of plain values ending in the error itself
+fn is_bare_return(body: &[&Token], var: &str) -> bool {
+    let Some((first, values)) = body.split_first() else { return false };
+    if first.text != "return" || values.last().is_none_or(|last| last.text != var) {
+        return false;
+    }
+
+    values.iter().enumerate().all(|(i, t)| match i % 2 {
+        0 => matches!(t.token_type, TokenType::Identifier(_) | TokenType::Literal(_)),
+        _ => t.token_type == TokenType::Comma,
+    })
+}
+
+/// The `if` and everything from the condition on, with the init statement left out
+fn shape_tokens<'a>(block: &[&'a Token], header: &[&Token]) -> Vec<&'a Token> {
+    let skip = header
+        .iter()
+        .rposition(|t| t.token_type == TokenType::Semicolon)
+        .map_or(0, |semicolon| semicolon + 1);
+
+    std::iter::once(block[0])
+        .chain(block[1 + skip..].iter().copied())
+        .collect()
+}
+
+/// Normalized texts of the shape tokens
+///
+/// Called functions and selector names keep their text, so `fmt.Errorf(...)` and
+/// `log.Printf(...)` are different shapes, while variables become `IDENT`.
+fn shape_key(tokens: &[&Token], var: &str) -> Vec<String> {
+    let is_dot = |i: usize| tokens.get(i).is_some_and(|t| t.text == ".");
+
+    tokens
+        .iter()
+        .enumerate()
+        .map(|(i, t)| match &t.token_type {
+            TokenType::Identifier(word) if word == var => "err".to_string(),
+            TokenType::Identifier(word) if word == "nil" || Language::Go.is_keyword(word) => word.clone(),
+            TokenType::Identifier(word)
+                if is_dot(i + 1)
+                    || i > 0 && is_dot(i - 1)
+                    || tokens
+                        .get(i + 1)
+                        .is_some_and(|next| next.token_type == TokenType::LeftParen) =>
+            {
+                word.clone()
+            }
+            TokenType::Identifier(_) => NormalizeMode::IDENT.to_string(),
+            TokenType::Literal(_) => NormalizeMode::LIT.to_string(),
+            _ => t.text.clone(),
+        })
+        .collect()
+}
+
+/// The key as one line, spaced like the source; statements on separate lines are joined by `; `
+fn shape_text(tokens: &[&Token], key: &[String]) -> String {
+    let mut text = String::new();
+
+    for (i, word) in key.iter().enumerate() {
+        if i > 0 {
+            let (last, token) = (tokens[i - 1], tokens[i]);
+            if last.line < token.line && key[i - 1] != "{" && word != "}" {
+                text.push_str("; ");
+            } else if last.end_offset < token.offset {
+                text.push(' ');
+            }
+        }
+        text.push_str(word);
+    }
+
+    text
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::test_support::file;
+
+    const LOADERS: &str = r#"
+func loadUser(id string) (*User, error) {
+	row, err := db.Query(id)
+	if err != nil {
+		return nil, fmt.Errorf("load user %s: %w", id, err)
+	}
+	if err := row.Scan(); err != nil {
+		return nil, fmt.Errorf("scan user %s: %w", id, err)
+	}
+	if closeErr := row.Close(); closeErr != nil {
+		return nil, fmt.Errorf("close %s: %w", id, closeErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err != nil {
+		log.Print(err)
+	} else {
+		return nil, nil
+	}
+	return row.User(), nil
+}
+"#;
+
+    #[test]
+    fn test_clusters_structurally_identical_checks() {
+        let clusters = detect_errcheck_clusters(&[file("user.go", LOADERS)], 3, 1).unwrap();
+
+        assert_eq!(clusters.len(), 1);
+        assert_eq!(clusters[0].id, 1);
+        assert_eq!(
+            clusters[0].shape,
+            "if err != nil { return nil, fmt.Errorf(LIT, IDENT, err) }"
+        );
+        let lines: Vec<_> = clusters[0]
+            .instances
+            .iter()
+            .map(|i| (i.start_line, i.end_line))
+            .collect();
+        assert_eq!(lines, vec![(5, 7), (8, 10), (11, 13)]);
+    }
+
+    #[test]
+    fn test_clusters_span_files_and_respect_min_size() {
+        let logged = r#"
+func save(path string) {
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		log.Printf("save %s: %v", path, err)
+		return
+	}
+}
+"#;
+        let files = [file("a.go", logged), file("b.go", logged), file("c.go", LOADERS)];
+
+        let clusters = detect_errcheck_clusters(&files, 2, 2).unwrap();
+        assert_eq!(clusters.len(), 2);
+        assert_eq!(clusters[0].instances.len(), 3);
+        assert_eq!(
+            clusters[1].shape,
+            "if err != nil { log.Printf(LIT, IDENT, err); return }"
+        );
+        assert_eq!(clusters[1].instances[1].file, std::path::PathBuf::from("b.go"));
+
+        assert_eq!(detect_errcheck_clusters(&files, 3, 2).unwrap().len(), 1);
+    }
+
+    #[test]
+    fn test_other_languages_are_skipped() {
+        let file = SourceFile::new("lib.rs", "fn f() { if err != nil { g(err) } }").unwrap();
+        assert!(
+            detect_errcheck_clusters(&[file.clone(), file.clone(), file], 2, 1)
+                .unwrap()
+                .is_empty()
+        );
+    }
+}
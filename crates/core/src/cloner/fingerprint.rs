@@ -0,0 +1,188 @@
+use crate::cloner::{Clone, NormalizeMode};
+use crate::loader::SourceFile;
+use crate::tokenizer::{Language, Tokenizer};
+use crate::{MccabreError, Result};
+use serde::{Deserialize, Serialize};
+use sha2::{Digest, Sha256};
+use std::path::PathBuf;
+
+/// Prefix of every hashed stream; changing the algorithm means changing this tag
+pub const ALGORITHM: &str = "mccabre-clone-v1";
+
+/// Position-independent content hash of one clone group
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct CloneFingerprint {
+    /// 16 hex digits: the first 8 bytes of the SHA-256 digest
+    pub fingerprint: String,
+    pub token_count: usize,
+    pub instances: usize,
+    /// First instance of the group, for finding it again
+    pub file: PathBuf,
+    pub start_line: usize,
+    pub end_line: usize,
+}
+
+/// Hash a token sequence the way [`fingerprint_clones`] does
+///
+/// The text is tokenized with `mode` and only significant tokens are kept, so whitespace,
+/// comments, and line positions never affect the result. With [`NormalizeMode::Renamed`],
+/// renamed identifiers and changed literals do not either.
+pub fn content_fingerprint(source: &str, language: Language, mode: NormalizeMode) -> Result<String> {
+    let tokens = Tokenizer::new(source, language).with_normalization(mode).tokenize()?;
+
+    Ok(hash_texts(
+        tokens
+            .iter()
+            .filter(|t| t.token_type.is_significant())
+            .map(|t| t.text.as_str()),
+    ))
+}
+
+/// Fingerprint of significant token texts that are already normalized
+pub(crate) fn hash_texts<'a>(texts: impl IntoIterator<Item = &'a str>) -> String {
+    let mut hasher = Sha256::new();
+    hasher.update(ALGORITHM.as_bytes());
+    for text in texts {
+        hasher.update([0]);
+        hasher.update(text.as_bytes());
+    }
+
+    let digest = hasher.finalize();
+    digest[..8].iter().map(|byte| format!("{byte:02x}")).collect()
+}
+
+/// Fingerprint every clone group from the text of its first instance
+///
+/// `files` must hold the sources the clones were detected in. Results are sorted by
+/// fingerprint, so two runs over the same code produce identical output.
+pub fn fingerprint_clones(
+    clones: &[Clone], files: &[SourceFile], mode: NormalizeMode,
+) -> Result<Vec<CloneFingerprint>> {
+    let mut fingerprints = Vec::with_capacity(clones.len());
+
+    for clone in clones {
+        let Some(first) = clone.locations.first() else { continue };
+        let file = files
+            .iter()
+            .find(|f| f.path == first.file)
+            .ok_or_else(|| MccabreError::NoMatch(first.file.display().to_string()))?;
+        let text = file.content.get(first.start_offset..first.end_offset).ok_or_else(|| {
+            MccabreError::TokenizationError(format!("clone range out of bounds in {}", first.file.display()))
+        })?;
+
+        fingerprints.push(CloneFingerprint {
+            fingerprint: content_fingerprint(text, file.language, mode)?,
+            token_count: clone.length,
+            instances: clone.locations.len(),
+            file: first.file.clone(),
+            start_line: first.start_line,
+            end_line: first.end_line,
+        });
+    }
+
+    fingerprints.sort_by(|a, b| (&a.fingerprint, &a.file, a.start_line).cmp(&(&b.fingerprint, &b.file, b.start_line)));
+    Ok(fingerprints)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::CloneDetector;
+
+    const SUM: &str = "func sum(values []int) int {\n\ttotal := 0\n\tfor _, v := range values {\n\t\tif v > 0 {\n\t\t\ttotal += v\n\t\t}\n\t}\n\treturn total\n}\n";
+
+    fn fingerprint(source: &str) -> String {
+        content_fingerprint(source, Language::Go, NormalizeMode::Exact).unwrap()
+    }
+
+    #[test]
+    fn test_cosmetic_edits_keep_fingerprint() {
+        let reformatted = "func sum(values []int) int {\n\t// add the positives\n\ttotal := 0\n\n\tfor _, v := range values { if v > 0 { total += v } }\n\treturn total\n}\n";
+
+        assert_eq!(fingerprint(SUM).len(), 16);
+        assert_eq!(fingerprint(SUM), fingerprint(reformatted));
+        assert_ne!(fingerprint(SUM), fingerprint(&SUM.replace("v > 0", "v >= 0")));
+    }
+
+    #[test]
+    fn test_renames_follow_the_normalize_mode() {
+        let renamed = SUM.replace("total", "acc");
+
+        assert_ne!(fingerprint(SUM), fingerprint(&renamed));
+        assert_eq!(
+            content_fingerprint(SUM, Language::Go, NormalizeMode::Renamed).unwrap(),
+            content_fingerprint(&renamed, Language::Go, NormalizeMode::Renamed).unwrap()
+        );
+    }
+
+    #[test]
+    fn test_fingerprints_ignore_position() {
+        let files = vec![
+            SourceFile::new("a.go", format!("package a\n\n{SUM}")).unwrap(),
+            SourceFile::new("b.go", format!("package b\n\nfunc other() {{}}\n\n{SUM}")).unwrap(),
+        ];
+        let moved = vec![
+            SourceFile::new("a.go", format!("package a\n\n\n\n\n{SUM}")).unwrap(),
+            files[1].clone(),
+        ];
+
+        let run = |files: &[SourceFile]| {
+            let sources: Vec<_> = files
+                .iter()
+                .map(|f| (f.path.clone(), f.content.clone(), f.language))
+                .collect();
+            let clones = CloneDetector::new(20).detect_across_files(&sources).unwrap();
+            fingerprint_clones(&clones, files, NormalizeMode::Exact).unwrap()
+        };
+
+        let before = run(&files);
+        let after = run(&moved);
+        assert_eq!(before.len(), 1);
+        assert_eq!(before[0].instances, 2);
+        assert_eq!(before[0].fingerprint, after[0].fingerprint);
+        assert_ne!(before[0].start_line, after[0].start_line);
+    }
+
+    #[test]
+    fn test_group_ids_match_fingerprints() {
+        use crate::cloner::CloneStrategy;
+
+        let files = vec![
+            SourceFile::new("a.go", format!("package a\n\n{SUM}")).unwrap(),
+            SourceFile::new("b.go", format!("package b\n\n{}", SUM.replace("total", "acc"))).unwrap(),
+            SourceFile::new("c.go", format!("package c\n\n{SUM}")).unwrap(),
+        ];
+        let sources: Vec<_> = files
+            .iter()
+            .map(|f| (f.path.clone(), f.content.clone(), f.language))
+            .collect();
+
+        let settings = [
+            (CloneStrategy::Token, NormalizeMode::Exact, NormalizeMode::Exact),
+            (CloneStrategy::Token, NormalizeMode::Renamed, NormalizeMode::Renamed),
+            (CloneStrategy::Ast, NormalizeMode::Exact, NormalizeMode::Renamed),
+        ];
+        for (strategy, normalize, mode) in settings {
+            let detector = |jobs| {
+                CloneDetector::new(20)
+                    .with_normalize_mode(normalize)
+                    .with_strategy(strategy)
+                    .with_min_nodes(10)
+                    .with_jobs(jobs)
+            };
+            let clones = detector(3).detect_across_files(&sources).unwrap();
+            assert!(!clones.is_empty());
+            let fingerprints = fingerprint_clones(&clones, &files, mode).unwrap();
+            for clone in &clones {
+                assert!(fingerprints.iter().any(|fp| fp.fingerprint == clone.group_id), "{mode}");
+            }
+
+            let mut reversed = sources.clone();
+            reversed.reverse();
+            let again = detector(1).detect_across_files(&reversed).unwrap();
+            let ids = |clones: &[Clone]| clones.iter().map(|c| c.group_id.clone()).collect::<Vec<_>>();
+            assert_eq!(ids(&again), ids(&clones));
+        }
+    }
+}
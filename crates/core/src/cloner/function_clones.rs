@@ -0,0 +1,254 @@
+use crate::Result;
+use crate::cloner::{CloneLocation, SimilarFunction};
+use crate::complexity::CyclomaticMetrics;
+use crate::loader::SourceFile;
+use crate::parallel::map_ordered;
+use crate::tokenizer::{NormalizeMode, Token, TokenType, Tokenizer};
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
+
+/// Functions whose bodies match, whatever their names, parameters, and results
+///
+/// Only the tokens from the body's opening brace to its closing brace are compared, so two
+/// functions with different signatures but the same body fall in one group even when the clone
+/// detector's windows split them differently.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct FunctionClone {
+    /// Group number, from 1 in report order
+    pub id: usize,
+    /// Whether every body has the same tokens; otherwise they differ only in identifiers and
+    /// literals (type-2)
+    pub exact: bool,
+    /// Tokens in each body, braces included
+    pub tokens: usize,
+    /// Functions sorted by file and line, each located from its first token to its closing brace
+    pub functions: Vec<SimilarFunction>,
+}
+
+struct Body {
+    name: String,
+    location: CloneLocation,
+    exact: Vec<String>,
+    renamed: Vec<String>,
+}
+
+/// Group the functions of `files` whose bodies are the same, comments and layout aside
+///
+/// Bodies are grouped after renaming identifiers and literals, and a group is exact when its
+/// bodies also match verbatim. Bodies with fewer than `min_tokens` tokens are left out, and so
+/// are groups made only of closures nested in the functions of another group, which repeat it.
+/// Groups with the longest bodies come first.
+pub fn detect_function_clones(files: &[SourceFile], min_tokens: usize, jobs: usize) -> Result<Vec<FunctionClone>> {
+    let per_file = map_ordered(files, jobs, |file| -> Result<Vec<Body>> {
+        let metrics = CyclomaticMetrics::calculate(&file.content, file.language)?;
+        let tokenize = |mode| -> Result<Vec<Token>> {
+            let tokens = Tokenizer::new(&file.content, file.language)
+                .with_normalization(mode)
+                .tokenize()?;
+            Ok(tokens.into_iter().filter(|t| t.token_type.is_significant()).collect())
+        };
+        let (exact, renamed) = (tokenize(NormalizeMode::Exact)?, tokenize(NormalizeMode::Renamed)?);
+
+        Ok(metrics
+            .functions
+            .iter()
+            .filter_map(|func| {
+                let first = exact.partition_point(|t| (t.line, t.column) < (func.line, func.column));
+                let last = exact.partition_point(|t| (t.line, t.column) < (func.end_line, func.end_column));
+                let open = body_start(&exact[first..last])? + first;
+                if last - open < min_tokens.max(1) {
+                    return None;
+                }
+
+                let text = |tokens: &[Token]| tokens[open..last].iter().map(|t| t.text.clone()).collect();
+                Some(Body {
+                    name: func.name.clone(),
+                    location: CloneLocation::from_tokens(file.path.clone(), &exact[first], &exact[last - 1], 0),
+                    exact: text(&exact),
+                    renamed: text(&renamed),
+                })
+            })
+            .collect())
+    });
+
+    let mut groups: BTreeMap<Vec<String>, Vec<Body>> = BTreeMap::new();
+    for bodies in per_file {
+        for body in bodies? {
+            groups.entry(body.renamed.clone()).or_default().push(body);
+        }
+    }
+
+    let clones: Vec<FunctionClone> = groups
+        .into_values()
+        .filter(|bodies| bodies.len() > 1)
+        .map(|mut bodies| {
+            bodies.sort_by(|a, b| {
+                (&a.location.file, a.location.start_line).cmp(&(&b.location.file, b.location.start_line))
+            });
+            FunctionClone {
+                id: 0,
+                exact: bodies.iter().all(|body| body.exact == bodies[0].exact),
+                tokens: bodies[0].exact.len(),
+                functions: bodies
+                    .into_iter()
+                    .map(|body| SimilarFunction { name: body.name, location: body.location })
+                    .collect(),
+            }
+        })
+        .collect();
+
+    let repeated: Vec<bool> = clones
+        .iter()
+        .enumerate()
+        .map(|(i, group)| {
+            group.functions.iter().all(|f| {
+                clones
+                    .iter()
+                    .enumerate()
+                    .any(|(j, other)| j != i && other.functions.iter().any(|o| encloses(&o.location, &f.location)))
+            })
+        })
+        .collect();
+    let mut clones: Vec<FunctionClone> = clones
+        .into_iter()
+        .zip(repeated)
+        .filter_map(|(clone, repeated)| (!repeated).then_some(clone))
+        .collect();
+
+    clones.sort_by(|a, b| {
+        let first = |c: &FunctionClone| (c.functions[0].location.file.clone(), c.functions[0].location.start_line);
+        b.tokens.cmp(&a.tokens).then_with(|| first(a).cmp(&first(b)))
+    });
+
+    Ok(clones
+        .into_iter()
+        .enumerate()
+        .map(|(idx, clone)| FunctionClone { id: idx + 1, ..clone })
+        .collect())
+}
+
+/// Index of the `{` opening the body of a function spanning `tokens`, which end at its `}`
+///
+/// The body brace is the match of the last one, so braces in the signature, such as a
+/// `struct{}` result, are passed over.
+fn body_start(tokens: &[Token]) -> Option<usize> {
+    if tokens.last()?.token_type != TokenType::RightBrace {
+        return None;
+    }
+
+    let mut depth = 0usize;
+    for (i, token) in tokens.iter().enumerate().rev() {
+        match token.token_type {
+            TokenType::RightBrace => depth += 1,
+            TokenType::LeftBrace => {
+                depth -= 1;
+                if depth == 0 {
+                    return Some(i);
+                }
+            }
+            _ => {}
+        }
+    }
+
+    None
+}
+
+/// Whether `inner` lies within `outer` without being the same function
+fn encloses(outer: &CloneLocation, inner: &CloneLocation) -> bool {
+    outer.file == inner.file
+        && outer.start_offset <= inner.start_offset
+        && inner.end_offset <= outer.end_offset
+        && outer.start_offset != inner.start_offset
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::test_support::file;
+
+    const SUM: &str = r#"
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		if v > 0 {
+			total += v
+		}
+	}
+	return total
+}
+"#;
+
+    fn names(clone: &FunctionClone) -> Vec<&str> {
+        clone.functions.iter().map(|f| f.name.as_str()).collect()
+    }
+
+    #[test]
+    fn test_groups_bodies_regardless_of_signature() {
+        let renamed_signature = SUM
+            .replace(
+                "func sum(values []int) int",
+                "func (s *Stats) Positive(values []int) (n int, ok bool)",
+            )
+            .replace("return total", "return total, true");
+        let exact_signature = SUM.replace("func sum(values []int) int", "func addAll(values []int) int64");
+        let files = [
+            file("a.go", SUM),
+            file("b.go", &exact_signature),
+            file("c.go", &renamed_signature),
+        ];
+
+        let found = detect_function_clones(&files, 20, 2).unwrap();
+        assert_eq!(found.len(), 1);
+        assert_eq!((found[0].id, found[0].exact), (1, true));
+        assert_eq!(names(&found[0]), ["sum", "addAll"]);
+        assert_eq!(found[0].functions[0].location.start_line, 3);
+        assert_eq!(found[0].functions[0].location.end_line, 11);
+    }
+
+    #[test]
+    fn test_renamed_bodies_are_type_2_groups() {
+        let renamed = SUM
+            .replace("func sum", "func count")
+            .replace("total", "n")
+            .replace("v > 0", "v > 10");
+        let files = [file("a.go", SUM), file("b.go", &renamed)];
+
+        let found = detect_function_clones(&files, 20, 1).unwrap();
+        assert_eq!(found.len(), 1);
+        assert!(!found[0].exact);
+        assert_eq!(names(&found[0]), ["sum", "count"]);
+    }
+
+    #[test]
+    fn test_skips_short_bodies_and_repeated_closures() {
+        let source = r#"
+func run(items []int) int {
+	helper := func() int {
+		total := 0
+		for _, v := range items {
+			total += v * 2
+		}
+		return total
+	}
+	return helper()
+}
+
+func size() int { return 1 }
+"#;
+        let files = [
+            file("a.go", source),
+            file("b.go", &source.replace("func run", "func walk")),
+        ];
+
+        let found = detect_function_clones(&files, 20, 1).unwrap();
+        assert_eq!(found.len(), 1);
+        assert_eq!(names(&found[0]), ["run", "walk"]);
+        assert!(
+            detect_function_clones(&files, 1, 1)
+                .unwrap()
+                .iter()
+                .any(|c| names(c) == ["size", "size"])
+        );
+    }
+}
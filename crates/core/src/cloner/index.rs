@@ -0,0 +1,237 @@
+use crate::cache::cache_version;
+use crate::cloner::{Clone, CloneDetector, CloneStrategy, Granularity};
+use crate::parallel::map_ordered;
+use crate::tokenizer::{Language, NormalizeMode, Token};
+use crate::{MccabreError, Result};
+use serde::{Deserialize, Serialize};
+use sha2::{Digest, Sha256};
+use std::collections::BTreeMap;
+use std::fs;
+use std::path::{Path, PathBuf};
+
+/// Token streams and window hashes of every file in a clone run, kept between runs
+///
+/// [`CloneDetector::detect_with_index`] re-tokenizes only files whose content changed since
+/// the index was last updated and forgets files that are no longer part of the run, so the
+/// clones found are the same as with [`CloneDetector::detect_across_files`]. An index built
+/// with other settings (window size, normalization, strategy, granularity), another tool
+/// version, or another [cache schema](crate::cache::CACHE_SCHEMA) is discarded as a whole
+/// rather than mixed with fresh entries.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct CloneIndex {
+    version: String,
+    settings: IndexSettings,
+    files: BTreeMap<PathBuf, IndexEntry>,
+}
+
+/// Detector settings the stored token streams and window hashes depend on
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct IndexSettings {
+    window_size: usize,
+    normalize: NormalizeMode,
+    strategy: CloneStrategy,
+    #[serde(default)]
+    granularity: Granularity,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct IndexEntry {
+    /// SHA-256 of the language and content
+    digest: String,
+    tokens: Vec<Token>,
+    windows: Vec<u64>,
+}
+
+impl CloneIndex {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Read an index written by [`CloneIndex::save`]
+    pub fn load<P: AsRef<Path>>(path: P) -> Result<Self> {
+        let path = path.as_ref();
+        let bytes = fs::read(path).map_err(|e| MccabreError::FileRead { path: path.to_path_buf(), source: e })?;
+        serde_json::from_slice(&bytes)
+            .map_err(|e| MccabreError::InvalidIndex { path: path.to_path_buf(), message: e.to_string() })
+    }
+
+    /// Write the index to `path`, replacing any previous file in one step
+    pub fn save<P: AsRef<Path>>(&self, path: P) -> Result<()> {
+        let path = path.as_ref();
+        let bytes = serde_json::to_vec(self).map_err(std::io::Error::from)?;
+
+        let partial = path.with_extension(format!("partial.{}", std::process::id()));
+        fs::write(&partial, bytes).map_err(|e| MccabreError::FileRead { path: partial.clone(), source: e })?;
+        fs::rename(&partial, path).map_err(|e| {
+            let _ = fs::remove_file(&partial);
+            MccabreError::FileRead { path: path.to_path_buf(), source: e }
+        })
+    }
+
+    /// Number of indexed files
+    pub fn len(&self) -> usize {
+        self.files.len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.files.is_empty()
+    }
+
+    pub fn contains(&self, path: &Path) -> bool {
+        self.files.contains_key(path)
+    }
+}
+
+impl CloneDetector {
+    /// Detect clones across `files`, reusing and then updating `index`
+    ///
+    /// Each file whose content digest matches its entry is served from the index; the others
+    /// are tokenized (through the cache, if any) and stored. Entries for paths not in `files`
+    /// are dropped, so deleted files never produce clones. Results are identical to
+    /// [`CloneDetector::detect_across_files`] on the same files.
+    pub fn detect_with_index(
+        &self, index: &mut CloneIndex, files: &[(PathBuf, String, Language)],
+    ) -> Result<Vec<Clone>> {
+        let settings = IndexSettings {
+            window_size: self.window_size,
+            normalize: self.token_mode(),
+            strategy: self.strategy,
+            granularity: self.granularity,
+        };
+        let version = cache_version();
+        if index.version != version || index.settings != settings {
+            *index = CloneIndex { version, settings, files: BTreeMap::new() };
+        }
+
+        let phase = self.progress.as_ref().map(|p| p.phase("Detecting clones", files.len()));
+        let fresh = map_ordered(
+            files,
+            self.jobs,
+            |(path, source, language)| -> Result<(String, Option<IndexEntry>)> {
+                let digest = content_digest(source, *language);
+                let entry = match index.files.get(path) {
+                    Some(entry) if entry.digest == digest => None,
+                    _ => {
+                        let tokens = self.file_tokens(path, source, *language)?;
+                        let windows = self.window_hashes(&tokens);
+                        Some(IndexEntry { digest: digest.clone(), tokens, windows })
+                    }
+                };
+                if let Some(phase) = &phase {
+                    phase.tick();
+                }
+                Ok((digest, entry))
+            },
+        );
+
+        let mut previous = std::mem::take(&mut index.files);
+        let mut streams = Vec::with_capacity(files.len());
+        let mut windows = Vec::with_capacity(files.len());
+        let mut digests = Vec::with_capacity(files.len());
+        for ((path, source, language), result) in files.iter().zip(fresh) {
+            let (digest, entry) = result?;
+            let entry = match entry.or_else(|| previous.remove(path)) {
+                Some(entry) => entry,
+                // A path listed twice already took its entry out of `previous`
+                None => {
+                    let tokens = self.file_tokens(path, source, *language)?;
+                    let windows = self.window_hashes(&tokens);
+                    IndexEntry { digest, tokens, windows }
+                }
+            };
+            streams.push((path.clone(), entry.tokens, *language));
+            windows.push(entry.windows);
+            digests.push(entry.digest);
+        }
+
+        let clones = self.find_clones(&streams, &windows);
+
+        index.files = streams
+            .into_iter()
+            .zip(windows)
+            .zip(digests)
+            .map(|(((path, tokens, _), windows), digest)| (path, IndexEntry { digest, tokens, windows }))
+            .collect();
+
+        Ok(clones)
+    }
+}
+
+fn content_digest(source: &str, language: Language) -> String {
+    let mut hasher = Sha256::new();
+    hasher.update(format!("{language:?}").as_bytes());
+    hasher.update([0]);
+    hasher.update(source.as_bytes());
+
+    hasher.finalize().iter().map(|b| format!("{b:02x}")).collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::test_support::{BODY, source};
+    use tempfile::TempDir;
+
+    fn json(clones: Result<Vec<Clone>>) -> String {
+        serde_json::to_string(&clones.unwrap()).unwrap()
+    }
+
+    #[test]
+    fn test_index_matches_fresh_build_across_updates() {
+        let detector = CloneDetector::new(20).with_jobs(2);
+        let mut index = CloneIndex::new();
+
+        let mut files = vec![
+            source("a.go", BODY),
+            source("b.go", BODY),
+            source("c.go", "func other() {}"),
+        ];
+        assert_eq!(
+            json(detector.detect_with_index(&mut index, &files)),
+            json(detector.detect_across_files(&files))
+        );
+        assert_eq!(index.len(), 3);
+
+        files[2] = source("c.go", BODY);
+        files.remove(0);
+        let clones = detector.detect_with_index(&mut index, &files).unwrap();
+        assert_eq!(clones[0].locations.len(), 2);
+        assert_eq!(json(Ok(clones)), json(detector.detect_across_files(&files)));
+        assert!(!index.contains(Path::new("a.go")));
+        assert_eq!(index.len(), 2);
+    }
+
+    #[test]
+    fn test_saved_index_reloads_and_resets_on_new_settings() {
+        let temp = TempDir::new().unwrap();
+        let path = temp.path().join("clones.index");
+        let files = vec![source("a.go", BODY), source("b.go", BODY)];
+
+        let detector = CloneDetector::new(20);
+        let mut index = CloneIndex::new();
+        let clones = json(detector.detect_with_index(&mut index, &files));
+        index.save(&path).unwrap();
+
+        let mut loaded = CloneIndex::load(&path).unwrap();
+        assert_eq!(loaded.settings, index.settings);
+        assert_eq!(loaded.len(), 2);
+        assert_eq!(json(detector.detect_with_index(&mut loaded, &files)), clones);
+
+        let ast = CloneDetector::new(20)
+            .with_strategy(CloneStrategy::Ast)
+            .with_min_nodes(10);
+        assert_eq!(
+            json(ast.detect_with_index(&mut loaded, &files)),
+            json(ast.detect_across_files(&files))
+        );
+        assert_ne!(loaded.settings, index.settings);
+
+        fs::write(&path, "not an index").unwrap();
+        assert!(matches!(
+            CloneIndex::load(&path),
+            Err(MccabreError::InvalidIndex { .. })
+        ));
+    }
+}
@@ -0,0 +1,136 @@
+use crate::cloner::CloneLocation;
+use similar::{Algorithm, DiffTag, capture_diff_slices, group_diff_ops};
+use std::fmt;
+
+/// Unchanged lines kept around each change, as `diff -u` does
+pub const DIFF_CONTEXT: usize = 3;
+
+/// One line of a [`DiffHunk`]
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum DiffLine {
+    /// Line found in both instances, as written in the first
+    Context(String),
+    /// Line only in the first instance
+    Removed(String),
+    /// Line only in the second instance
+    Added(String),
+}
+
+/// A run of changed lines between two clone instances with the context around it
+///
+/// Starts are file line numbers, 1-based, so a hunk points at the source rather than at the
+/// instance.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct DiffHunk {
+    pub old_start: usize,
+    pub old_lines: usize,
+    pub new_start: usize,
+    pub new_lines: usize,
+    pub lines: Vec<DiffLine>,
+}
+
+impl DiffHunk {
+    /// The `@@ -start,lines +start,lines @@` line that opens the hunk
+    pub fn header(&self) -> String {
+        format!(
+            "@@ -{},{} +{},{} @@",
+            self.old_start, self.old_lines, self.new_start, self.new_lines
+        )
+    }
+}
+
+impl fmt::Display for DiffHunk {
+    /// The hunk in unified diff form, header line included
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        writeln!(f, "{}", self.header())?;
+        for line in &self.lines {
+            match line {
+                DiffLine::Context(text) => writeln!(f, " {text}")?,
+                DiffLine::Removed(text) => writeln!(f, "-{text}")?,
+                DiffLine::Added(text) => writeln!(f, "+{text}")?,
+            }
+        }
+        Ok(())
+    }
+}
+
+/// Line diff from the instance at `old` to the one at `new`, given the sources of their files
+///
+/// Whole lines from each instance's start line to its end line are compared, with leading and
+/// trailing whitespace ignored so that a copy indented differently still matches line for line.
+/// Instances of an exact clone differ only in layout and comments, so they usually give no hunks;
+/// renamed and gapped clones give one hunk per run of differing lines. Uses Myers' algorithm from
+/// the `similar` crate.
+pub fn diff_instances(old: &CloneLocation, old_source: &str, new: &CloneLocation, new_source: &str) -> Vec<DiffHunk> {
+    let old_text = instance_lines(old_source, old);
+    let new_text = instance_lines(new_source, new);
+    let old_keys: Vec<&str> = old_text.iter().map(|line| line.trim()).collect();
+    let new_keys: Vec<&str> = new_text.iter().map(|line| line.trim()).collect();
+
+    let ops = capture_diff_slices(Algorithm::Myers, &old_keys, &new_keys);
+    group_diff_ops(ops, DIFF_CONTEXT)
+        .into_iter()
+        .map(|group| {
+            let mut lines = Vec::new();
+            for op in &group {
+                let (tag, old_range, new_range) = op.as_tag_tuple();
+                match tag {
+                    DiffTag::Equal => lines.extend(old_range.map(|i| DiffLine::Context(old_text[i].to_string()))),
+                    DiffTag::Delete => lines.extend(old_range.map(|i| DiffLine::Removed(old_text[i].to_string()))),
+                    DiffTag::Insert => lines.extend(new_range.map(|i| DiffLine::Added(new_text[i].to_string()))),
+                    DiffTag::Replace => {
+                        lines.extend(old_range.map(|i| DiffLine::Removed(old_text[i].to_string())));
+                        lines.extend(new_range.map(|i| DiffLine::Added(new_text[i].to_string())));
+                    }
+                }
+            }
+
+            let (first, last) = (&group[0], &group[group.len() - 1]);
+            DiffHunk {
+                old_start: old.start_line + first.old_range().start,
+                old_lines: last.old_range().end - first.old_range().start,
+                new_start: new.start_line + first.new_range().start,
+                new_lines: last.new_range().end - first.new_range().start,
+                lines,
+            }
+        })
+        .collect()
+}
+
+/// Lines `start_line..=end_line` of `source`
+fn instance_lines<'a>(source: &'a str, location: &CloneLocation) -> Vec<&'a str> {
+    source
+        .lines()
+        .skip(location.start_line.saturating_sub(1))
+        .take(location.end_line + 1 - location.start_line.max(1))
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::path::PathBuf;
+
+    fn location(start_line: usize, end_line: usize) -> CloneLocation {
+        CloneLocation { file: PathBuf::from("a.go"), start_line, end_line, ..Default::default() }
+    }
+
+    #[test]
+    fn test_gapped_instances_show_differing_lines() {
+        let old = "package a\n\nfunc f() {\n\tx := load()\n\ty := x + 1\n\tsave(y)\n}\n";
+        let new = "func g() {\n\t\tx := load()\n\t\ty := x * 2\n\t\tsave(y)\n}\n";
+
+        let hunks = diff_instances(&location(3, 7), old, &location(1, 5), new);
+        assert_eq!(hunks.len(), 1);
+        assert_eq!(
+            hunks[0].to_string(),
+            "@@ -3,5 +1,5 @@\n-func f() {\n+func g() {\n \tx := load()\n-\ty := x + 1\n+\t\ty := x * 2\n \tsave(y)\n }\n"
+        );
+    }
+
+    #[test]
+    fn test_identical_instances_have_no_hunks() {
+        let source = "func f() {\n\treturn 1\n}\n\nfunc f() {\n    return 1\n}\n";
+        assert!(diff_instances(&location(1, 3), source, &location(5, 7), source).is_empty());
+    }
+}
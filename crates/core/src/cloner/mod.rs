@@ -1,5 +1,27 @@
+pub(crate) mod ast;
+pub mod declarations;
 pub mod detector;
+pub mod errcheck;
+pub mod fingerprint;
+pub mod function_clones;
+pub mod index;
+pub mod instance_diff;
 pub mod rolling_hash;
+pub mod similarity;
+pub mod stream;
+#[cfg(test)]
+pub(crate) mod test_support;
 
-pub use detector::{Clone, CloneDetector, CloneLocation};
+pub use crate::tokenizer::NormalizeMode;
+pub use declarations::{DuplicateDeclaration, MIN_DECLARATION_SPECS, detect_duplicate_declarations};
+pub use detector::{
+    Clone, CloneDetector, CloneLocation, CloneStrategy, DEFAULT_MIN_NODES, DEFAULT_MIN_STATEMENTS, Granularity,
+};
+pub use errcheck::{ErrcheckCluster, MIN_CLUSTER_SIZE, detect_errcheck_clusters};
+pub use fingerprint::{CloneFingerprint, fingerprint_clones};
+pub use function_clones::{FunctionClone, detect_function_clones};
+pub use index::CloneIndex;
+pub use instance_diff::{DIFF_CONTEXT, DiffHunk, DiffLine, diff_instances};
 pub use rolling_hash::RollingHash;
+pub use similarity::{NGRAM_SIZE, SimilarFunction, SimilarPair, detect_similar_functions};
+pub use stream::CloneStream;
@@ -0,0 +1,271 @@
+use crate::Result;
+use crate::cloner::CloneLocation;
+use crate::complexity::CyclomaticMetrics;
+use crate::loader::SourceFile;
+use crate::parallel::map_ordered;
+use crate::tokenizer::{NormalizeMode, Token, Tokenizer};
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use std::hash::{DefaultHasher, Hash, Hasher};
+
+/// Tokens per n-gram compared between function bodies
+pub const NGRAM_SIZE: usize = 3;
+
+/// Two functions whose bodies are alike overall, though no clone window may cover them
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SimilarPair {
+    /// Pair number, from 1 in report order
+    pub id: usize,
+    /// Jaccard similarity of the two sets of token n-grams, from 0 to 1
+    pub score: f64,
+    /// The function first in file order, then the other
+    pub functions: [SimilarFunction; 2],
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SimilarFunction {
+    pub name: String,
+    /// The function from its first token to its closing brace
+    pub location: CloneLocation,
+}
+
+struct Body {
+    name: String,
+    location: CloneLocation,
+    /// Sorted, deduplicated hashes of every [`NGRAM_SIZE`] consecutive tokens
+    ngrams: Vec<u64>,
+}
+
+/// Pair the functions of `files` whose similarity is at least `threshold`
+///
+/// Each body is reduced to the set of its token n-grams under `mode`, and two bodies score
+/// the size of the intersection over the size of the union. Scattered one-token edits lower
+/// the score a little each instead of splitting the body into windows too short to report.
+/// Functions with fewer than `min_tokens` tokens are left out, as are a function and a closure
+/// nested inside it.
+///
+/// Pairs are returned with the highest score first.
+pub fn detect_similar_functions(
+    files: &[SourceFile], threshold: f64, min_tokens: usize, mode: NormalizeMode, jobs: usize,
+) -> Result<Vec<SimilarPair>> {
+    let per_file = map_ordered(files, jobs, |file| -> Result<Vec<Body>> {
+        let metrics = CyclomaticMetrics::calculate(&file.content, file.language)?;
+        let tokens = Tokenizer::new(&file.content, file.language)
+            .with_normalization(mode)
+            .tokenize()?;
+        let tokens: Vec<&Token> = tokens.iter().filter(|t| t.token_type.is_significant()).collect();
+
+        Ok(metrics
+            .functions
+            .iter()
+            .filter_map(|func| {
+                let first = tokens.partition_point(|t| (t.line, t.column) < (func.line, func.column));
+                let last = tokens.partition_point(|t| (t.line, t.column) < (func.end_line, func.end_column));
+                let body = &tokens[first..last];
+                if body.len() < min_tokens.max(NGRAM_SIZE) {
+                    return None;
+                }
+
+                Some(Body {
+                    name: func.name.clone(),
+                    location: CloneLocation::from_tokens(file.path.clone(), body[0], body[body.len() - 1], 0),
+                    ngrams: ngrams(body),
+                })
+            })
+            .collect())
+    });
+    let bodies: Vec<Body> = per_file
+        .into_iter()
+        .collect::<Result<Vec<_>>>()?
+        .into_iter()
+        .flatten()
+        .collect();
+
+    // Prefix filtering: with n-grams ordered rarest first, two bodies that share at least
+    // `threshold` of their n-grams must share one among the first few of each, so only those
+    // are indexed and every candidate found through them is then scored in full.
+    let mut frequency: HashMap<u64, usize> = HashMap::new();
+    for ngram in bodies.iter().flat_map(|body| &body.ngrams) {
+        *frequency.entry(*ngram).or_default() += 1;
+    }
+    let prefixes: Vec<Vec<u64>> = bodies
+        .iter()
+        .map(|body| {
+            let mut ordered = body.ngrams.clone();
+            ordered.sort_by_key(|ngram| (frequency[ngram], *ngram));
+            let required = (threshold * ordered.len() as f64 - 1e-9).ceil() as usize;
+            ordered.truncate(ordered.len() + 1 - required.clamp(1, ordered.len()));
+            ordered
+        })
+        .collect();
+
+    let mut postings: HashMap<u64, Vec<usize>> = HashMap::new();
+    for (i, prefix) in prefixes.iter().enumerate() {
+        for &ngram in prefix {
+            postings.entry(ngram).or_default().push(i);
+        }
+    }
+
+    let indices: Vec<usize> = (0..bodies.len()).collect();
+    let per_body = map_ordered(&indices, jobs, |&i| {
+        let body = &bodies[i];
+        let mut candidates: Vec<usize> = prefixes[i]
+            .iter()
+            .flat_map(|ngram| postings[ngram].iter().copied().take_while(|&j| j < i))
+            .collect();
+        candidates.sort_unstable();
+        candidates.dedup();
+
+        candidates
+            .into_iter()
+            .filter(|&j| !nested(&bodies[j].location, &body.location))
+            .filter_map(|j| {
+                let common = shared(&bodies[j].ngrams, &body.ngrams);
+                let union = bodies[j].ngrams.len() + body.ngrams.len() - common;
+                let score = common as f64 / union as f64;
+                (score >= threshold).then_some((j, score))
+            })
+            .collect::<Vec<_>>()
+    });
+
+    let mut pairs: Vec<SimilarPair> = per_body
+        .into_iter()
+        .enumerate()
+        .flat_map(|(i, matches)| matches.into_iter().map(move |(j, score)| (j, i, score)))
+        .map(|(j, i, score)| {
+            let function = |body: &Body| SimilarFunction { name: body.name.clone(), location: body.location.clone() };
+            SimilarPair { id: 0, score, functions: [function(&bodies[j]), function(&bodies[i])] }
+        })
+        .collect();
+
+    let position = |f: &SimilarFunction| (f.location.file.clone(), f.location.start_line, f.location.start_column);
+    pairs.sort_by(|a, b| {
+        b.score
+            .total_cmp(&a.score)
+            .then_with(|| position(&a.functions[0]).cmp(&position(&b.functions[0])))
+            .then_with(|| position(&a.functions[1]).cmp(&position(&b.functions[1])))
+    });
+    for (idx, pair) in pairs.iter_mut().enumerate() {
+        pair.id = idx + 1;
+    }
+
+    Ok(pairs)
+}
+
+fn ngrams(tokens: &[&Token]) -> Vec<u64> {
+    let mut hashes: Vec<u64> = tokens
+        .windows(NGRAM_SIZE)
+        .map(|window| {
+            let mut hasher = DefaultHasher::new();
+            for token in window {
+                token.text.hash(&mut hasher);
+            }
+            hasher.finish()
+        })
+        .collect();
+    hashes.sort_unstable();
+    hashes.dedup();
+    hashes
+}
+
+/// Number of values in both sorted lists
+fn shared(a: &[u64], b: &[u64]) -> usize {
+    let (mut i, mut j, mut count) = (0, 0, 0);
+    while i < a.len() && j < b.len() {
+        match a[i].cmp(&b[j]) {
+            std::cmp::Ordering::Less => i += 1,
+            std::cmp::Ordering::Greater => j += 1,
+            std::cmp::Ordering::Equal => {
+                count += 1;
+                i += 1;
+                j += 1;
+            }
+        }
+    }
+    count
+}
+
+/// Whether two locations overlap, as a closure and its enclosing function do
+fn nested(a: &CloneLocation, b: &CloneLocation) -> bool {
+    a.file == b.file && a.start_offset < b.end_offset && b.start_offset < a.end_offset
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::test_support::file;
+
+    const SCALE: &str = r#"
+func scaleAll(values []int, factor int) []int {
+	out := make([]int, 0, len(values))
+	for _, v := range values {
+		if v < 0 {
+			continue
+		}
+		out = append(out, v*factor)
+	}
+	sort.Ints(out)
+	return out
+}
+"#;
+
+    #[test]
+    fn test_pairs_functions_with_scattered_edits() {
+        let edited = SCALE
+            .replace("scaleAll", "shiftAll")
+            .replace("v < 0", "v > 100")
+            .replace("v*factor", "v+factor")
+            .replace("sort.Ints(out)\n", "");
+        let unrelated = "func greet(name string) string {\n\treturn \"hello \" + name + \"!\"\n}\n";
+        let files = [file("a.go", SCALE), file("b.go", &format!("{edited}{unrelated}"))];
+
+        let pairs = detect_similar_functions(&files, 0.6, 10, NormalizeMode::Exact, 2).unwrap();
+        assert_eq!(pairs.len(), 1);
+        let names: Vec<&str> = pairs[0].functions.iter().map(|f| f.name.as_str()).collect();
+        assert_eq!(names, vec!["scaleAll", "shiftAll"]);
+        assert!(pairs[0].score > 0.6 && pairs[0].score < 1.0, "{}", pairs[0].score);
+        assert_eq!(pairs[0].functions[1].location.start_line, 3);
+
+        let strict = detect_similar_functions(&files, 0.95, 10, NormalizeMode::Exact, 2).unwrap();
+        assert!(strict.is_empty());
+    }
+
+    #[test]
+    fn test_skips_short_and_nested_functions() {
+        let outer = r#"
+func outer() int {
+	total := 0
+	for i := 0; i < 10; i++ {
+		total += i
+	}
+	inner := func() int {
+		total := 0
+		for i := 0; i < 10; i++ {
+			total += i
+		}
+		return total
+	}
+	return total + inner()
+}
+"#;
+        let files = [file("a.go", outer)];
+        assert!(
+            detect_similar_functions(&files, 0.1, 5, NormalizeMode::Exact, 1)
+                .unwrap()
+                .is_empty()
+        );
+
+        let files = [file("a.go", SCALE), file("b.go", SCALE)];
+        assert_eq!(
+            detect_similar_functions(&files, 0.9, 10, NormalizeMode::Exact, 1).unwrap()[0].score,
+            1.0
+        );
+        assert!(
+            detect_similar_functions(&files, 0.9, 500, NormalizeMode::Exact, 1)
+                .unwrap()
+                .is_empty()
+        );
+    }
+}
@@ -0,0 +1,108 @@
+use crate::Result;
+use crate::cloner::{Clone, CloneDetector};
+use crate::tokenizer::{Language, Token};
+use std::path::PathBuf;
+
+/// Clone detection fed one file at a time, for services that receive sources as they arrive
+///
+/// Each file is tokenized when it is added and its source is no longer needed, so while files
+/// arrive memory grows with the index, the significant tokens of every file plus one hash per
+/// token window, rather than with the text received. Groups can only be formed once the last
+/// file is in, since a later file may extend or join any of them: no clone is known before
+/// [`CloneStream::finish`], which builds every group while the index is still held, so its peak
+/// is the index plus the whole output, and takes about as long as
+/// [`CloneDetector::detect_across_files`] spends after tokenizing. Files are tokenized on the
+/// caller's thread, one per call, so the detector's worker count does not apply; callers that
+/// receive many files at once are better served by `detect_across_files`.
+///
+/// The clones found are the same as `detect_across_files` finds for the same files in the same
+/// order.
+pub struct CloneStream {
+    detector: CloneDetector,
+    streams: Vec<(PathBuf, Vec<Token>, Language)>,
+    windows: Vec<Vec<u64>>,
+}
+
+impl CloneStream {
+    /// Start an empty stream matching files with `detector`'s settings
+    pub fn new(detector: CloneDetector) -> Self {
+        Self { detector, streams: Vec::new(), windows: Vec::new() }
+    }
+
+    /// Tokenize `source` and add it to the index under `path`, whose extension picks the language
+    ///
+    /// Fails with [`MccabreError::UnsupportedFileType`](crate::MccabreError::UnsupportedFileType)
+    /// for an unknown extension, leaving the stream as it was.
+    pub fn add_file(&mut self, path: impl Into<PathBuf>, source: &str) -> Result<()> {
+        let path = path.into();
+        let language = Language::from_path(&path)?;
+        let tokens = self.detector.file_tokens(&path, source, language)?;
+
+        self.windows.push(self.detector.window_hashes(&tokens));
+        self.streams.push((path, tokens, language));
+        Ok(())
+    }
+
+    /// Number of files added so far
+    pub fn len(&self) -> usize {
+        self.streams.len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.streams.is_empty()
+    }
+
+    /// Detect clones across every added file, as [`CloneDetector::detect_across_files`] returns
+    pub fn finish(self) -> Vec<Clone> {
+        self.detector.find_clones(&self.streams, &self.windows)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::MccabreError;
+    use crate::cloner::CloneStrategy;
+    use crate::cloner::test_support::{BODY, source};
+
+    #[test]
+    fn test_stream_matches_batch_detection() {
+        let files: Vec<(PathBuf, String, Language)> =
+            ["a.go", "b.go", "c.go"].iter().map(|name| source(name, BODY)).collect();
+
+        for detector in [
+            || CloneDetector::new(20),
+            || {
+                CloneDetector::new(20)
+                    .with_strategy(CloneStrategy::Ast)
+                    .with_min_nodes(10)
+            },
+        ] {
+            let mut stream = CloneStream::new(detector());
+            for (path, source, _) in &files {
+                stream.add_file(path.clone(), source).unwrap();
+            }
+            assert_eq!(stream.len(), 3);
+
+            let groups = stream.finish();
+            assert!(!groups.is_empty());
+            assert_eq!(
+                serde_json::to_string(&groups).unwrap(),
+                serde_json::to_string(&detector().detect_across_files(&files).unwrap()).unwrap()
+            );
+        }
+    }
+
+    #[test]
+    fn test_unknown_extension_leaves_stream_unchanged() {
+        let mut stream = CloneStream::new(CloneDetector::new(20));
+        stream.add_file("a.go", BODY).unwrap();
+
+        assert!(matches!(
+            stream.add_file("notes.txt", BODY),
+            Err(MccabreError::UnsupportedFileType(_))
+        ));
+        assert_eq!(stream.len(), 1);
+        assert!(stream.finish().is_empty());
+    }
+}
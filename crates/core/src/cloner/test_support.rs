@@ -0,0 +1,27 @@
+use crate::loader::SourceFile;
+use crate::tokenizer::Language;
+use std::path::PathBuf;
+
+/// Go function of about 40 tokens, reported as a clone at a 20-token minimum
+pub(crate) const BODY: &str = r#"
+func process(items []string) int {
+	count := 0
+	for _, item := range items {
+		if strings.HasPrefix(item, "--") {
+			continue
+		}
+		count += len(item)
+	}
+	return count
+}
+"#;
+
+/// Go file `name` holding `content` after a `package main` line
+pub(crate) fn file(name: &str, content: &str) -> SourceFile {
+    SourceFile::new(name, format!("package main\n{content}")).unwrap()
+}
+
+/// The same file as [`file`] in the form [`CloneDetector`](super::CloneDetector) reads
+pub(crate) fn source(name: &str, content: &str) -> (PathBuf, String, Language) {
+    (PathBuf::from(name), format!("package main\n{content}"), Language::Go)
+}
@@ -0,0 +1,336 @@
+use crate::baseline::normalize_path;
+use crate::reporter::json::{JsonCloneGroup, JsonReport};
+use crate::{MccabreError, Result};
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, BTreeSet};
+use std::fs;
+use std::path::{Path, PathBuf};
+
+/// Changes between two JSON reports of the same code base, older first
+///
+/// Functions are matched on file path and qualified name, such as `(*Server).Handle`, so moved
+/// code still matches; several functions of one name in a file, such as `init`, are paired in
+/// line order. Clone groups are matched on their content-based `groupId`, so a group found in
+/// both reports is the same code wherever it sits, and on `windowHash` (`fingerprint` in older
+/// reports) for reports written before group ids existed.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Comparison {
+    /// Functions whose cyclomatic complexity changed, were added, or were removed, by file and name
+    pub functions: Vec<FunctionChange>,
+    /// Groups in the newer report only
+    pub clones_introduced: Vec<CloneChange>,
+    /// Groups in the older report only
+    pub clones_resolved: Vec<CloneChange>,
+    pub old_totals: Totals,
+    pub new_totals: Totals,
+}
+
+/// Cyclomatic complexity of one function in each report; `None` where it does not exist
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct FunctionChange {
+    pub file: PathBuf,
+    pub function: String,
+    pub old: Option<usize>,
+    pub new: Option<usize>,
+}
+
+/// A clone group found in only one of the reports, located at its first instance
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct CloneChange {
+    pub group_id: String,
+    pub token_count: usize,
+    pub instances: usize,
+    pub file: PathBuf,
+    pub start_line: usize,
+    pub end_line: usize,
+}
+
+/// Figures of a whole report that the comparison tracks
+#[derive(Debug, Clone, Copy, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Totals {
+    /// Sum of every function's cyclomatic complexity
+    pub complexity: usize,
+    pub clone_groups: usize,
+    /// Share of physical lines inside a clone, from 0 to 1, as in the report's `duplicationRatio`
+    pub duplication_ratio: f64,
+}
+
+/// A tracked figure that grew by more than the tolerance
+#[derive(Debug, Clone, PartialEq)]
+pub struct Regression {
+    /// What grew, as `total complexity`
+    pub measure: &'static str,
+    /// Growth relative to the older report, in percent; infinite when that was 0
+    pub growth: f64,
+    pub tolerance: f64,
+}
+
+impl std::fmt::Display for Regression {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        if self.growth.is_infinite() {
+            write!(f, "{} grew from 0 (tolerance {}%)", self.measure, self.tolerance)
+        } else {
+            write!(
+                f,
+                "{} grew {:.1}% (tolerance {}%)",
+                self.measure, self.growth, self.tolerance
+            )
+        }
+    }
+}
+
+impl FunctionChange {
+    /// Complexity gained, negative when lost; an added function gains all of its own
+    pub fn delta(&self) -> i64 {
+        self.new.unwrap_or(0) as i64 - self.old.unwrap_or(0) as i64
+    }
+}
+
+impl Comparison {
+    pub fn new(old: &JsonReport, new: &JsonReport) -> Self {
+        let functions = function_changes(old, new);
+        let (old_groups, new_groups) = (clone_groups(old), clone_groups(new));
+        let introduced = |from: &BTreeMap<String, &JsonCloneGroup>, other: &BTreeMap<String, &JsonCloneGroup>| {
+            from.iter()
+                .filter(|(key, _)| !other.contains_key(*key))
+                .filter_map(|(key, group)| clone_change(key, group))
+                .collect()
+        };
+
+        Self {
+            functions,
+            clones_introduced: introduced(&new_groups, &old_groups),
+            clones_resolved: introduced(&old_groups, &new_groups),
+            old_totals: Totals::of(old),
+            new_totals: Totals::of(new),
+        }
+    }
+
+    /// Compare two JSON reports written by `--format json`
+    ///
+    /// Fails with [`MccabreError::InvalidReport`] when a file is not such a report.
+    pub fn from_files(old: &Path, new: &Path) -> Result<Self> {
+        Ok(Self::new(&load(old)?, &load(new)?))
+    }
+
+    /// Complexity gained by functions that grew or were added
+    pub fn complexity_added(&self) -> usize {
+        self.functions.iter().map(|f| f.delta().max(0) as usize).sum()
+    }
+
+    /// Complexity lost by functions that shrank or were removed
+    pub fn complexity_removed(&self) -> usize {
+        self.functions.iter().map(|f| (-f.delta()).max(0) as usize).sum()
+    }
+
+    /// Tracked figures that grew by more than `tolerance` percent of their older value
+    ///
+    /// Total complexity, the number of clone groups, and the duplication ratio are checked. A
+    /// figure that was 0 and is no longer always counts as a regression.
+    pub fn regressions(&self, tolerance: f64) -> Vec<Regression> {
+        let (old, new) = (self.old_totals, self.new_totals);
+        [
+            ("total complexity", old.complexity as f64, new.complexity as f64),
+            ("clone groups", old.clone_groups as f64, new.clone_groups as f64),
+            ("duplication ratio", old.duplication_ratio, new.duplication_ratio),
+        ]
+        .into_iter()
+        .filter_map(|(measure, old, new)| {
+            let growth = if old > 0.0 {
+                (new - old) / old * 100.0
+            } else if new > 0.0 {
+                f64::INFINITY
+            } else {
+                0.0
+            };
+            (growth > tolerance).then_some(Regression { measure, growth, tolerance })
+        })
+        .collect()
+    }
+
+    pub fn to_json(&self) -> serde_json::Result<String> {
+        serde_json::to_string_pretty(self)
+    }
+}
+
+impl Totals {
+    fn of(report: &JsonReport) -> Self {
+        Self {
+            complexity: report.complexity.iter().map(|f| f.cyclomatic).sum(),
+            clone_groups: report.clones.len(),
+            duplication_ratio: report.summary.duplication_ratio,
+        }
+    }
+}
+
+fn load(path: &Path) -> Result<JsonReport> {
+    let content =
+        fs::read_to_string(path).map_err(|e| MccabreError::FileRead { path: path.to_path_buf(), source: e })?;
+    serde_json::from_str(&content)
+        .map_err(|e| MccabreError::InvalidReport { path: path.to_path_buf(), message: e.to_string() })
+}
+
+/// Pair up the functions of both reports and keep those whose complexity differs
+fn function_changes(old: &JsonReport, new: &JsonReport) -> Vec<FunctionChange> {
+    type Scores = BTreeMap<(PathBuf, String), Vec<(usize, usize)>>;
+    let scores = |report: &JsonReport| {
+        let mut scores = Scores::new();
+        for func in &report.complexity {
+            scores
+                .entry((normalize_path(&func.file), func.function.clone()))
+                .or_default()
+                .push((func.line, func.cyclomatic));
+        }
+        for lines in scores.values_mut() {
+            lines.sort_unstable();
+        }
+        scores
+    };
+    let (old_scores, new_scores) = (scores(old), scores(new));
+    let keys: BTreeSet<&(PathBuf, String)> = old_scores.keys().chain(new_scores.keys()).collect();
+
+    let mut changes = Vec::new();
+    for key in keys {
+        let (old_lines, new_lines) = (old_scores.get(key), new_scores.get(key));
+        let at = |lines: Option<&Vec<(usize, usize)>>, i: usize| lines.and_then(|l| l.get(i)).map(|l| l.1);
+        let count = old_lines.map_or(0, Vec::len).max(new_lines.map_or(0, Vec::len));
+
+        for i in 0..count {
+            let (old, new) = (at(old_lines, i), at(new_lines, i));
+            if old != new {
+                changes.push(FunctionChange { file: key.0.clone(), function: key.1.clone(), old, new });
+            }
+        }
+    }
+
+    changes
+}
+
+/// Groups keyed on their group id, or their window hash when the report has none
+fn clone_groups(report: &JsonReport) -> BTreeMap<String, &JsonCloneGroup> {
+    let mut groups = BTreeMap::new();
+    for group in &report.clones {
+        let key = if group.group_id.is_empty() { &group.window_hash } else { &group.group_id };
+        groups.entry(key.clone()).or_insert(group);
+    }
+    groups
+}
+
+fn clone_change(key: &str, group: &JsonCloneGroup) -> Option<CloneChange> {
+    let first = group.instances.first()?;
+    Some(CloneChange {
+        group_id: key.to_string(),
+        token_count: group.token_count,
+        instances: group.instances.len(),
+        file: first.file.clone(),
+        start_line: first.start_line,
+        end_line: first.end_line,
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::Config;
+    use crate::loader::SourceFile;
+    use crate::reporter::json::JsonReport;
+
+    const SWITCH: &str = "
+	switch kind {
+	case 1:
+		total += 1
+	case 2:
+		total += 2
+	case 3:
+		total += 3
+	}
+	if total > 10 && kind != 4 {
+		return total
+	}
+	for i := 0; i < total; i++ {
+		total -= i
+	}";
+
+    fn report(files: &[(&str, String)]) -> JsonReport {
+        let sources: Vec<SourceFile> = files
+            .iter()
+            .map(|(path, content)| SourceFile::new(path, content.clone()).unwrap())
+            .collect();
+        let mut config = Config::default();
+        config.clones.min_tokens = 20;
+        JsonReport::from_report(&crate::analyze_sources(&sources, &config).unwrap())
+    }
+
+    fn function(name: &str, body: &str) -> String {
+        format!("func {name}(kind int) int {{\n\ttotal := 0{body}\n\treturn total\n}}\n")
+    }
+
+    #[test]
+    fn test_function_deltas_by_name() {
+        let old = report(&[(
+            "a.go",
+            format!("package a\n\n{}{}", function("grows", ""), function("removed", SWITCH)),
+        )]);
+        let new = report(&[(
+            "./a.go",
+            format!("package a\n\n{}{}", function("added", ""), function("grows", SWITCH)),
+        )]);
+        let comparison = Comparison::new(&old, &new);
+
+        let changes: Vec<(&str, Option<usize>, Option<usize>)> = comparison
+            .functions
+            .iter()
+            .map(|f| (f.function.as_str(), f.old, f.new))
+            .collect();
+        assert_eq!(
+            changes,
+            vec![
+                ("added", None, Some(1)),
+                ("grows", Some(1), Some(8)),
+                ("removed", Some(8), None)
+            ]
+        );
+        assert_eq!(comparison.complexity_added(), 8);
+        assert_eq!(comparison.complexity_removed(), 8);
+        assert!(comparison.functions.iter().all(|f| f.file == Path::new("a.go")));
+    }
+
+    #[test]
+    fn test_clone_groups_and_regressions() {
+        let old = report(&[("a.go", format!("package a\n\n{}", function("first", SWITCH)))]);
+        let new = report(&[
+            ("a.go", format!("package a\n\n{}", function("first", SWITCH))),
+            ("b.go", format!("package b\n\n{}", function("second", SWITCH))),
+        ]);
+        let comparison = Comparison::new(&old, &new);
+
+        assert_eq!(comparison.clones_introduced.len(), 1);
+        assert_eq!(comparison.clones_introduced[0].instances, 2);
+        assert!(comparison.clones_resolved.is_empty());
+
+        let measures: Vec<&str> = comparison.regressions(5.0).iter().map(|r| r.measure).collect();
+        assert_eq!(measures, vec!["total complexity", "clone groups", "duplication ratio"]);
+        assert_eq!(
+            comparison.regressions(150.0)[0].to_string(),
+            "clone groups grew from 0 (tolerance 150%)"
+        );
+
+        let back = Comparison::new(&new, &old);
+        assert_eq!(back.clones_resolved, comparison.clones_introduced);
+        assert!(back.regressions(0.0).is_empty());
+    }
+
+    #[test]
+    fn test_invalid_report_file() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("report.json");
+        fs::write(&path, "{\"complexity\": []}").unwrap();
+
+        let err = Comparison::from_files(&path, &path).unwrap_err();
+        assert!(matches!(err, MccabreError::InvalidReport { .. }));
+    }
+}
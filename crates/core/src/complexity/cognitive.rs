@@ -0,0 +1,255 @@
+use crate::tokenizer::{Language, Token, TokenType};
+
+/// Calculate cognitive complexity for the tokens of a single function body
+///
+/// Follows the SonarSource cognitive complexity rules:
+/// - `if`, ternaries, `switch`/`match`, loops, and `catch` add 1 plus the current nesting level
+/// - `else` and `else if` add 1 without a nesting penalty
+/// - each sequence of identical boolean operators adds 1 (`a && b && c` counts once),
+///   and switching operators starts a new sequence (`a && b || c` counts twice)
+/// - `break`/`continue` to a label and `goto` add 1
+///
+/// Nesting increases inside the blocks of the structures above. The tokens are expected to be
+/// significant tokens with nested functions already removed, since those are scored separately.
+pub fn cognitive_complexity(tokens: &[&Token], language: Language) -> usize {
+    let mut score = 0;
+    let mut nesting = 0;
+    // Each open brace records whether it raised nesting and the paren depth outside it
+    let mut scopes: Vec<(bool, usize)> = Vec::new();
+    let mut paren_depth = 0usize;
+    let mut pending_nesting = false;
+    let mut last_logical: Option<&TokenType> = None;
+
+    for (i, token) in tokens.iter().enumerate() {
+        match &token.token_type {
+            TokenType::If => {
+                let after_else = i > 0 && matches!(tokens[i - 1].token_type, TokenType::Else);
+                if !after_else {
+                    score += 1 + nesting;
+                }
+                pending_nesting = true;
+            }
+            TokenType::Else | TokenType::ElseIf => {
+                score += 1;
+                pending_nesting = true;
+            }
+            TokenType::While
+            | TokenType::For
+            | TokenType::Loop
+            | TokenType::Match
+            | TokenType::Switch
+            | TokenType::Catch => {
+                score += 1 + nesting;
+                pending_nesting = true;
+            }
+            TokenType::Ternary if is_conditional_operator(tokens, i, language) => {
+                score += 1 + nesting;
+            }
+            op @ (TokenType::LogicalAnd | TokenType::LogicalOr) => {
+                if last_logical != Some(op) {
+                    score += 1;
+                }
+                last_logical = Some(op);
+            }
+            TokenType::Identifier(word) if word == "goto" => score += 1,
+            TokenType::Identifier(word)
+                if (word == "break" || word == "continue") && has_label(tokens, i, language) =>
+            {
+                score += 1;
+            }
+            TokenType::LeftParen | TokenType::LeftBracket => paren_depth += 1,
+            TokenType::RightParen | TokenType::RightBracket => paren_depth = paren_depth.saturating_sub(1),
+            TokenType::LeftBrace => {
+                let raises = pending_nesting && paren_depth == 0;
+                if raises {
+                    nesting += 1;
+                    pending_nesting = false;
+                }
+                scopes.push((raises, paren_depth));
+                paren_depth = 0;
+                last_logical = None;
+            }
+            TokenType::RightBrace => {
+                if let Some((raised, outer_depth)) = scopes.pop() {
+                    if raised {
+                        nesting -= 1;
+                    }
+                    paren_depth = outer_depth;
+                }
+                last_logical = None;
+            }
+            TokenType::Semicolon => {
+                // Go uses semicolons inside `for` and `if` headers, so only other languages end
+                // a pending control structure here (e.g. the `while` of a do-while loop)
+                if paren_depth == 0 && language != Language::Go {
+                    pending_nesting = false;
+                }
+                last_logical = None;
+            }
+            TokenType::Comma => last_logical = None,
+            _ => {}
+        }
+    }
+
+    score
+}
+
+/// `?` is a conditional operator unless it is Rust's try operator or TypeScript's optional syntax
+fn is_conditional_operator(tokens: &[&Token], i: usize, language: Language) -> bool {
+    if language == Language::Rust {
+        return false;
+    }
+
+    let prev_is_ternary = i > 0 && matches!(tokens[i - 1].token_type, TokenType::Ternary);
+    let optional = tokens.get(i + 1).is_some_and(|next| {
+        matches!(
+            &next.token_type,
+            TokenType::Ternary
+                | TokenType::RightParen
+                | TokenType::Comma
+                | TokenType::Semicolon
+                | TokenType::Unknown('.' | ':')
+        ) || matches!(&next.token_type, TokenType::Operator(op) if op.starts_with('='))
+    });
+
+    !prev_is_ternary && !optional
+}
+
+/// Whether the `break`/`continue` at `i` jumps to a label
+fn has_label(tokens: &[&Token], i: usize, language: Language) -> bool {
+    let Some(next) = tokens.get(i + 1) else { return false };
+
+    match (&next.token_type, language) {
+        (TokenType::Literal(text), Language::Rust) => text.starts_with('\''),
+        (TokenType::Identifier(label), Language::Go | Language::JavaScript | Language::TypeScript | Language::Java) => {
+            next.line == tokens[i].line && !language.is_keyword(label)
+        }
+        _ => false,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use crate::complexity::CyclomaticMetrics;
+    use crate::tokenizer::Language;
+
+    fn cognitive(source: &str, language: Language) -> usize {
+        let metrics = CyclomaticMetrics::calculate(source, language).unwrap();
+        assert_eq!(metrics.functions.len(), 1);
+        metrics.functions[0].cognitive
+    }
+
+    #[test]
+    fn test_straight_line_code() {
+        let source = "function add(a, b) {\n    return a + b;\n}\n";
+        assert_eq!(cognitive(source, Language::JavaScript), 0);
+    }
+
+    #[test]
+    fn test_flat_switch_counts_once() {
+        let source = r#"
+function label(code) {
+    switch (code) {
+        case 1: return "one";
+        case 2: return "two";
+        case 3: return "three";
+        case 4: return "four";
+        case 5: return "five";
+        default: return "many";
+    }
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::JavaScript).unwrap();
+        assert_eq!(metrics.functions[0].cognitive, 1);
+        assert!(metrics.functions[0].cyclomatic > 5);
+    }
+
+    #[test]
+    fn test_nesting_increments() {
+        let source = r#"
+func walk(items []int) int {
+	total := 0
+	for _, item := range items {
+		if item > 0 {
+			for i := 0; i < item; i++ {
+				total += i
+			}
+		}
+	}
+	return total
+}
+"#;
+        // for (+1), nested if (+2), doubly nested for (+3)
+        assert_eq!(cognitive(source, Language::Go), 6);
+    }
+
+    #[test]
+    fn test_else_branches_have_no_nesting_penalty() {
+        let source = r#"
+fn sign(x: i32) -> i32 {
+    if x > 0 {
+        1
+    } else if x < 0 {
+        -1
+    } else {
+        0
+    }
+}
+"#;
+        assert_eq!(cognitive(source, Language::Rust), 3);
+    }
+
+    #[test]
+    fn test_boolean_sequences() {
+        let same = "function f(a, b, c) {\n    if (a && b && c) { return 1; }\n}\n";
+        assert_eq!(cognitive(same, Language::JavaScript), 2);
+
+        let mixed = "function f(a, b, c, d) {\n    if (a && b || c && d) { return 1; }\n}\n";
+        assert_eq!(cognitive(mixed, Language::JavaScript), 4);
+    }
+
+    #[test]
+    fn test_labeled_jumps() {
+        let source = r#"
+func find(grid [][]int, target int) bool {
+outer:
+	for _, row := range grid {
+		for _, v := range row {
+			if v == target {
+				break outer
+			}
+			if v < 0 {
+				continue
+			}
+		}
+	}
+	return false
+}
+"#;
+        // for (+1), for (+2), if (+3), labeled break (+1), if (+3); plain continue is free
+        assert_eq!(cognitive(source, Language::Go), 10);
+    }
+
+    #[test]
+    fn test_ternary_and_rust_try_operator() {
+        let js = "function pick(a, b) {\n    return a > b ? a : b;\n}\n";
+        assert_eq!(cognitive(js, Language::JavaScript), 1);
+
+        let rust = "fn parse(s: &str) -> Result<i32, Error> {\n    let v = s.parse::<i32>()?;\n    Ok(v)\n}\n";
+        assert_eq!(cognitive(rust, Language::Rust), 0);
+    }
+
+    #[test]
+    fn test_nested_closure_scored_separately() {
+        let source = r#"
+fn count(items: &[i32]) -> usize {
+    items.iter().filter(|x| {
+        if **x > 0 { true } else { false }
+    }).count()
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::Rust).unwrap();
+        assert_eq!(metrics.functions[0].cognitive, 0);
+        assert_eq!(metrics.functions[1].cognitive, 2);
+    }
+}
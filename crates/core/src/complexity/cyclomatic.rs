@@ -1,15 +1,109 @@
-use crate::Result;
+use crate::complexity::cognitive::cognitive_complexity;
+use crate::complexity::halstead::HalsteadMetrics;
+use crate::complexity::loc::count_statements;
+use crate::complexity::nesting::max_nesting_depth;
+use crate::complexity::parameters::parameter_names;
 use crate::tokenizer::{Language, Token, TokenType, Tokenizer};
+use crate::{MccabreError, Result};
 use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use std::fmt;
+use std::fs;
+use std::path::Path;
+use std::str::FromStr;
 
-#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+/// Risk tier of a complexity score, ordered from lowest to highest
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "kebab-case")]
 pub enum Severity {
+    #[default]
     Low,
+    #[serde(alias = "medium")]
     Moderate,
     High,
     VeryHigh,
 }
 
+impl Severity {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Severity::Low => "low",
+            Severity::Moderate => "moderate",
+            Severity::High => "high",
+            Severity::VeryHigh => "very-high",
+        }
+    }
+}
+
+impl fmt::Display for Severity {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(self.as_str())
+    }
+}
+
+impl FromStr for Severity {
+    type Err = String;
+
+    /// Parse a tier name; `medium` is accepted for `moderate`
+    fn from_str(value: &str) -> std::result::Result<Self, Self::Err> {
+        match value.to_lowercase().replace('_', "-").as_str() {
+            "low" => Ok(Severity::Low),
+            "moderate" | "medium" => Ok(Severity::Moderate),
+            "high" => Ok(Severity::High),
+            "very-high" => Ok(Severity::VeryHigh),
+            _ => Err("use: low, moderate, high, or very-high".to_string()),
+        }
+    }
+}
+
+/// Lowest complexity of each severity tier above `low`
+///
+/// The defaults follow the usual risk ranges from the literature: 1-10 low, 11-20 moderate,
+/// 21-50 high, and above 50 very high.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(default)]
+pub struct SeverityBands {
+    /// Lowest complexity rated moderate (default: 11)
+    #[serde(alias = "medium")]
+    pub moderate: usize,
+    /// Lowest complexity rated high (default: 21)
+    pub high: usize,
+    /// Lowest complexity rated very high (default: 51)
+    pub very_high: usize,
+}
+
+impl Default for SeverityBands {
+    fn default() -> Self {
+        Self { moderate: 11, high: 21, very_high: 51 }
+    }
+}
+
+impl SeverityBands {
+    pub fn classify(&self, complexity: usize) -> Severity {
+        if complexity >= self.very_high {
+            Severity::VeryHigh
+        } else if complexity >= self.high {
+            Severity::High
+        } else if complexity >= self.moderate {
+            Severity::Moderate
+        } else {
+            Severity::Low
+        }
+    }
+
+    /// Check that the tiers start in increasing order
+    pub fn validate(&self) -> std::result::Result<(), String> {
+        if self.moderate < self.high && self.high < self.very_high {
+            Ok(())
+        } else {
+            Err(format!(
+                "severity bands must increase (moderate {}, high {}, very_high {})",
+                self.moderate, self.high, self.very_high
+            ))
+        }
+    }
+}
+
 /// Cyclomatic Complexity metrics for a file
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct CyclomaticMetrics {
@@ -19,14 +113,166 @@ pub struct CyclomaticMetrics {
     pub functions: Vec<FunctionComplexity>,
 }
 
-#[derive(Debug, Clone, Serialize, Deserialize)]
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
 pub struct FunctionComplexity {
     /// Function name (if identifiable)
     pub name: String,
     /// Cyclomatic complexity value
-    pub complexity: usize,
+    pub cyclomatic: usize,
+    /// Cognitive complexity value (SonarSource rules)
+    pub cognitive: usize,
+    /// Deepest level of nested block statements
+    #[serde(default)]
+    pub max_nesting: usize,
+    /// Lines from the body's opening brace to its closing brace, 1 for a one-line body
+    #[serde(default)]
+    pub lines: usize,
+    /// Statements in the body, those of nested functions and closures included
+    #[serde(default)]
+    pub statements: usize,
+    /// Parameter names, one per parameter; unnamed Go parameters are listed by type
+    #[serde(default)]
+    pub parameters: Vec<String>,
+    /// `return` statements in the body, bare ones included, but not those of nested functions
+    #[serde(default)]
+    pub returns: usize,
     /// Line number where function starts
     pub line: usize,
+    /// Column where the function starts, in Unicode scalar values (1-based)
+    #[serde(default)]
+    pub column: usize,
+    /// Line of the body's closing brace
+    #[serde(default)]
+    pub end_line: usize,
+    /// Column just past the body's closing brace
+    #[serde(default)]
+    pub end_column: usize,
+    /// Halstead operator and operand counts for the function
+    #[serde(default)]
+    pub halstead: HalsteadMetrics,
+    /// Tier of the cyclomatic complexity, under the default bands until the report is classified
+    #[serde(default)]
+    pub severity: Severity,
+}
+
+/// One construct that adds a point to a function's cyclomatic complexity
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct DecisionPoint {
+    /// Source text of the construct, such as `if`, `case`, or `&&`
+    pub construct: String,
+    pub line: usize,
+    pub column: usize,
+}
+
+/// Significant tokens of one detected function
+#[derive(Debug, Clone)]
+pub struct FunctionTokens<'a> {
+    pub name: String,
+    /// Line of the first header token
+    pub line: usize,
+    /// Signature tokens from the function keyword (or closure parameters) to the body
+    pub header: Vec<&'a Token>,
+    /// Body tokens from `{` to `}`, without the bodies of nested functions and closures
+    pub body: Vec<&'a Token>,
+    /// Body tokens including nested functions and closures
+    pub full_body: Vec<&'a Token>,
+}
+
+/// Split a file's tokens into its functions, closures, and function literals, in source order
+pub fn function_tokens(tokens: &[Token], language: Language) -> Vec<FunctionTokens<'_>> {
+    let tokens: Vec<&Token> = tokens.iter().filter(|t| t.token_type.is_significant()).collect();
+    let mut spans: Vec<FunctionSpan> = (0..tokens.len())
+        .filter_map(|i| CyclomaticMetrics::function_at(&tokens, i, language))
+        .collect();
+    spans.sort_by_key(|s| s.start);
+    if language == Language::Go {
+        name_function_literals(&mut spans);
+    }
+
+    let nested: HashMap<usize, usize> = spans.iter().map(|s| (s.start, s.body_end)).collect();
+
+    spans
+        .into_iter()
+        .map(|span| {
+            let mut body = Vec::new();
+            let mut i = span.body_start;
+
+            while i <= span.body_end {
+                if i > span.body_start
+                    && let Some(&nested_end) = nested.get(&i)
+                {
+                    i = nested_end + 1;
+                    continue;
+                }
+                body.push(tokens[i]);
+                i += 1;
+            }
+
+            FunctionTokens {
+                name: span.name,
+                line: span.line,
+                header: tokens[span.start..span.body_start].to_vec(),
+                body,
+                full_body: tokens[span.body_start..=span.body_end].to_vec(),
+            }
+        })
+        .collect()
+}
+
+/// Token range of a detected function: header start, body braces, and name
+struct FunctionSpan {
+    name: String,
+    line: usize,
+    start: usize,
+    body_start: usize,
+    body_end: usize,
+    /// A Go function literal, named after the function around it
+    literal: bool,
+}
+
+/// Name each Go function literal after the function it appears in, as the Go toolchain does
+///
+/// The literals directly inside `outer` are `outer$func1`, `outer$func2`, ... in source order,
+/// whether they are assigned, passed as callbacks, deferred, or started as goroutines. A
+/// literal inside `outer$func1` is `outer$func1$1`, and literals outside any function, in
+/// package-level `var` declarations, are `glob$func1`, ... `spans` must be sorted by start.
+fn name_function_literals(spans: &mut [FunctionSpan]) {
+    // Enclosing spans: last body token, name, and literals named inside so far
+    let mut enclosing: Vec<(usize, String, usize)> = Vec::new();
+    let mut package_literals = 0;
+
+    for span in spans.iter_mut() {
+        while enclosing.last().is_some_and(|(end, _, _)| *end < span.start) {
+            enclosing.pop();
+        }
+        if span.literal {
+            span.name = match enclosing.last_mut() {
+                Some((_, name, count)) => {
+                    *count += 1;
+                    match name.contains('$') {
+                        true => format!("{name}${count}"),
+                        false => format!("{name}$func{count}"),
+                    }
+                }
+                None => {
+                    package_literals += 1;
+                    format!("glob$func{package_literals}")
+                }
+            };
+        }
+        enclosing.push((span.body_end, span.name.clone(), 0));
+    }
+}
+
+/// Detect the functions in a source file and return their cyclomatic complexity
+///
+/// Each function, closure, and nested function literal gets its own entry, in source order.
+pub fn analyze_file(path: &Path) -> Result<Vec<FunctionComplexity>> {
+    let language = Language::from_path(path)?;
+    let source =
+        fs::read_to_string(path).map_err(|e| MccabreError::FileRead { path: path.to_path_buf(), source: e })?;
+
+    Ok(CyclomaticMetrics::calculate(&source, language)?.functions)
 }
 
 impl CyclomaticMetrics {
@@ -43,69 +289,260 @@ impl CyclomaticMetrics {
         Ok(CyclomaticMetrics { file_complexity, functions })
     }
 
-    /// Attempt to detect function boundaries and calculate per-function complexity
+    /// Decision points of each function in `source`, keyed by the function's `(line, column)`
+    ///
+    /// Each list holds, in source order, the constructs counted toward
+    /// [`FunctionComplexity::cyclomatic`], so a function scores one more than its list is long.
+    pub fn explain(source: &str, language: Language) -> Result<HashMap<(usize, usize), Vec<DecisionPoint>>> {
+        let tokens = Tokenizer::new(source, language).tokenize()?;
+
+        Ok(function_tokens(&tokens, language)
+            .into_iter()
+            .map(|func| {
+                let column = func.header.first().map_or(0, |t| t.column);
+                let points = func
+                    .body
+                    .iter()
+                    .filter(|t| t.token_type.is_decision_point())
+                    .map(|t| DecisionPoint { construct: t.text.clone(), line: t.line, column: t.column })
+                    .collect();
+                ((func.line, column), points)
+            })
+            .collect())
+    }
+
+    /// Detect function boundaries and calculate per-function complexity
     ///
     /// Look for function patterns:
-    /// - Rust: "fn" identifier "(" ... ")" "{"
-    /// - JS/TS: "function" identifier "(" ... ")" "{"
-    /// - Go: "func" identifier "(" ... ")" "{"
-    /// - Java/C++: type identifier "(" ... ")" "{"
-    fn detect_functions(tokens: &[Token], _language: Language) -> Vec<FunctionComplexity> {
-        let mut functions = Vec::new();
-        let mut i = 0;
-
-        while i < tokens.len() {
-            let is_function_keyword = if let TokenType::Identifier(name) = &tokens[i].token_type {
-                name == "fn" || name == "func" || name == "function"
-            } else {
-                false
-            };
+    /// - Rust: "fn" identifier "(" ... ")" "{" and block closures "|args| {"
+    /// - JS/TS: "function" identifier "(" ... ")" "{" and arrow functions "(args) => {"
+    /// - Go: "func" identifier "(" ... ")" "{" and function literals "func(...) {"
+    ///
+    /// Nested functions and closures are reported as functions of their own, and their
+    /// decision points are not counted toward the enclosing function.
+    fn detect_functions(tokens: &[Token], language: Language) -> Vec<FunctionComplexity> {
+        function_tokens(tokens, language)
+            .into_iter()
+            .map(|func| {
+                let decision_points = func.body.iter().filter(|t| t.token_type.is_decision_point()).count();
+                let cyclomatic = if decision_points == 0 { 1 } else { decision_points + 1 };
+                let cognitive = cognitive_complexity(&func.body, language);
+                let halstead = HalsteadMetrics::from_tokens(func.header.iter().chain(&func.body).copied(), language);
+                let max_nesting = max_nesting_depth(&func.full_body, language);
+                let lines = match (func.full_body.first(), func.full_body.last()) {
+                    (Some(open), Some(close)) => close.line - open.line + 1,
+                    _ => 0,
+                };
+                let inner = func
+                    .full_body
+                    .get(1..func.full_body.len().saturating_sub(1))
+                    .unwrap_or_default();
+                let statements = count_statements(inner.iter().copied(), language);
+                let parameters = parameter_names(&func.header, language);
+                let returns = count_returns(&func.body);
 
-            if is_function_keyword {
-                let mut name = "anonymous".to_string();
-                let line = tokens[i].line;
+                let (column, end_line, end_column) = match (func.header.first(), func.full_body.last()) {
+                    (Some(first), Some(last)) => (first.column, last.line, last.end_column),
+                    _ => (0, func.line, 0),
+                };
 
-                if i + 1 < tokens.len()
-                    && let TokenType::Identifier(id) = &tokens[i + 1].token_type
-                {
-                    name = id.clone();
+                FunctionComplexity {
+                    name: func.name,
+                    cyclomatic,
+                    cognitive,
+                    max_nesting,
+                    lines,
+                    statements,
+                    parameters,
+                    returns,
+                    line: func.line,
+                    column,
+                    end_line,
+                    end_column,
+                    halstead,
+                    severity: SeverityBands::default().classify(cyclomatic),
                 }
+            })
+            .collect()
+    }
 
-                let body_start = Self::find_next_token(tokens, i, TokenType::LeftBrace);
+    /// Recognize a function, closure, or arrow function starting at `i`
+    fn function_at(tokens: &[&Token], i: usize, language: Language) -> Option<FunctionSpan> {
+        match &tokens[i].token_type {
+            TokenType::Identifier(kw) if kw == "fn" || kw == "func" || kw == "function" => {
+                let (name, literal) = match tokens.get(i + 1).map(|t| &t.token_type) {
+                    Some(TokenType::Identifier(id)) => (id.clone(), false),
+                    _ => match Self::method_name(tokens, i + 1) {
+                        Some(name) => (name, false),
+                        None => (
+                            Self::assigned_name(tokens, i).unwrap_or_else(|| "anonymous".to_string()),
+                            kw == "func",
+                        ),
+                    },
+                };
+                let body_start = Self::find_body(tokens, i + 1)?;
+                let span = Self::span(tokens, name, i, body_start)?;
+                Some(FunctionSpan { literal, ..span })
+            }
+            TokenType::Operator(op)
+                if op == "=>" && matches!(language, Language::JavaScript | Language::TypeScript) =>
+            {
+                if !matches!(tokens.get(i + 1)?.token_type, TokenType::LeftBrace) {
+                    return None;
+                }
+                let start = match tokens[i.checked_sub(1)?].token_type {
+                    TokenType::RightParen => Self::find_matching_paren(tokens, i - 1)?,
+                    TokenType::Identifier(_) => i - 1,
+                    _ => return None,
+                };
+                let name = Self::assigned_name(tokens, start).unwrap_or_else(|| "anonymous".to_string());
+                Self::span(tokens, name, start, i + 1)
+            }
+            TokenType::Operator(_) | TokenType::LogicalOr if language == Language::Rust => {
+                if !Self::opens_closure(tokens, i) {
+                    return None;
+                }
+                let params_end = match &tokens[i].token_type {
+                    TokenType::LogicalOr => i,
+                    _ => (i + 1..tokens.len())
+                        .take_while(|&j| Self::in_closure_params(tokens[j]))
+                        .find(|&j| matches!(&tokens[j].token_type, TokenType::Operator(op) if op == "|"))?,
+                };
+                let body_start = match &tokens.get(params_end + 1)?.token_type {
+                    TokenType::LeftBrace => params_end + 1,
+                    TokenType::Operator(op) if op == "->" => Self::find_body(tokens, params_end + 2)?,
+                    _ => return None,
+                };
+                let name = Self::assigned_name(tokens, i).unwrap_or_else(|| "anonymous".to_string());
+                Self::span(tokens, name, i, body_start)
+            }
+            _ => None,
+        }
+    }
 
-                if let Some(body_start_idx) = body_start
-                    && let Some(body_end_idx) = Self::find_matching_brace(tokens, body_start_idx)
-                {
-                    let decision_points = tokens[body_start_idx..=body_end_idx]
-                        .iter()
-                        .filter(|t| t.token_type.is_decision_point())
-                        .count();
+    fn span(tokens: &[&Token], name: String, start: usize, body_start: usize) -> Option<FunctionSpan> {
+        let body_end = Self::find_matching_brace(tokens, body_start)?;
+        Some(FunctionSpan { name, line: tokens[start].line, start, body_start, body_end, literal: false })
+    }
+
+    /// A `|` (or `||`) opens a Rust closure when it appears where an expression starts
+    fn opens_closure(tokens: &[&Token], i: usize) -> bool {
+        let opener = match &tokens[i].token_type {
+            TokenType::LogicalOr => true,
+            TokenType::Operator(op) => op.starts_with('|'),
+            _ => false,
+        };
+        if !opener || i == 0 {
+            return false;
+        }
 
-                    let complexity = if decision_points == 0 { 1 } else { decision_points + 1 };
+        match &tokens[i - 1].token_type {
+            TokenType::LeftParen | TokenType::Comma => true,
+            TokenType::Operator(op) => op == "=",
+            TokenType::Identifier(id) => id == "move",
+            _ => false,
+        }
+    }
 
-                    functions.push(FunctionComplexity { name, complexity, line });
+    fn in_closure_params(token: &Token) -> bool {
+        !matches!(
+            token.token_type,
+            TokenType::LeftBrace | TokenType::RightBrace | TokenType::Semicolon | TokenType::LogicalOr
+        )
+    }
 
-                    i = body_end_idx + 1;
-                    continue;
+    /// Name of a Go method qualified by its receiver type: `func (s *Server) Handle(` is
+    /// `(*Server).Handle` and `func (Server) Close(` is `Server.Close`, as Go itself prints them
+    ///
+    /// Type parameters of a generic receiver are dropped, so `(s *Set[T])` gives `(*Set).Add`,
+    /// and the package is left out, since every report pairs the name with its file.
+    /// A function literal's parameter list is followed by its results or body instead, never by
+    /// an identifier and a second parameter list.
+    fn method_name(tokens: &[&Token], open: usize) -> Option<String> {
+        if tokens.get(open)?.token_type != TokenType::LeftParen {
+            return None;
+        }
+        let close = (open..tokens.len()).find(|&j| tokens[j].token_type == TokenType::RightParen)?;
+        let TokenType::Identifier(name) = &tokens.get(close + 1)?.token_type else {
+            return None;
+        };
+        if tokens.get(close + 2)?.token_type != TokenType::LeftParen {
+            return None;
+        }
+
+        let mut receiver = &tokens[open + 1..close];
+        if let Some(bracket) = receiver.iter().position(|t| t.token_type == TokenType::LeftBracket) {
+            receiver = &receiver[..bracket];
+        }
+        if receiver.len() > 1 && matches!(receiver[0].token_type, TokenType::Identifier(_)) {
+            receiver = &receiver[1..];
+        }
+        let receiver: String = receiver.iter().map(|t| t.text.as_str()).collect();
+
+        Some(match receiver.starts_with('*') {
+            true => format!("({receiver}).{name}"),
+            false => format!("{receiver}.{name}"),
+        })
+    }
+
+    /// Name taken from an assignment such as `let name = |x| {` or `const name = () => {`
+    fn assigned_name(tokens: &[&Token], start: usize) -> Option<String> {
+        let eq = tokens.get(start.checked_sub(1)?)?;
+        if !matches!(&eq.token_type, TokenType::Operator(op) if op == "=") {
+            return None;
+        }
+        match &tokens.get(start.checked_sub(2)?)?.token_type {
+            TokenType::Identifier(id) => Some(id.clone()),
+            _ => None,
+        }
+    }
+
+    /// Find the opening brace of a function body that follows a signature starting at `start`
+    ///
+    /// Gives up when the signature ends without a body: a `;`, a closing brace, a bracket
+    /// closing an enclosing expression, or another function keyword. This keeps function
+    /// types such as Go's `func(int) int` parameters from claiming the next body.
+    fn find_body(tokens: &[&Token], start: usize) -> Option<usize> {
+        let mut depth = 0usize;
+
+        for (offset, token) in tokens[start..].iter().enumerate() {
+            match &token.token_type {
+                TokenType::LeftParen | TokenType::LeftBracket => depth += 1,
+                TokenType::RightParen | TokenType::RightBracket => depth = depth.checked_sub(1)?,
+                TokenType::LeftBrace if depth == 0 => return Some(start + offset),
+                TokenType::RightBrace | TokenType::Semicolon if depth == 0 => return None,
+                TokenType::Identifier(kw) if depth == 0 && (kw == "fn" || kw == "func" || kw == "function") => {
+                    return None;
                 }
+                _ => {}
             }
-
-            i += 1;
         }
 
-        functions
+        None
     }
 
-    /// Find the next token of a specific type
-    fn find_next_token(tokens: &[Token], start: usize, token_type: TokenType) -> Option<usize> {
-        tokens[start..]
-            .iter()
-            .position(|t| std::mem::discriminant(&t.token_type) == std::mem::discriminant(&token_type))
-            .map(|pos| start + pos)
+    /// Find the opening parenthesis matching the closing one at `close_idx`
+    fn find_matching_paren(tokens: &[&Token], close_idx: usize) -> Option<usize> {
+        let mut depth = 0;
+
+        for idx in (0..=close_idx).rev() {
+            match tokens[idx].token_type {
+                TokenType::RightParen => depth += 1,
+                TokenType::LeftParen => {
+                    depth -= 1;
+                    if depth == 0 {
+                        return Some(idx);
+                    }
+                }
+                _ => {}
+            }
+        }
+
+        None
     }
 
     /// Find the matching closing brace for an opening brace
-    fn find_matching_brace(tokens: &[Token], open_idx: usize) -> Option<usize> {
+    fn find_matching_brace(tokens: &[&Token], open_idx: usize) -> Option<usize> {
         let mut depth = 0;
 
         for (offset, token) in tokens[open_idx..].iter().enumerate() {
@@ -124,22 +561,24 @@ impl CyclomaticMetrics {
         None
     }
 
-    /// Get severity level based on complexity threshold
-    /// Standard thresholds from literature:
-    /// 1-10: Simple, low risk
-    /// 11-20: More complex, moderate risk
-    /// 21-50: Complex, high risk
-    /// 50+: Very complex, very high risk
+    /// Get severity level of the file complexity under the default [`SeverityBands`]
     pub fn severity(&self) -> Severity {
-        match self.file_complexity {
-            1..=10 => Severity::Low,
-            11..=20 => Severity::Moderate,
-            21..=50 => Severity::High,
-            _ => Severity::VeryHigh,
-        }
+        SeverityBands::default().classify(self.file_complexity)
     }
 }
 
+/// Number of `return` keywords among a function's own tokens; a property named `return`, as in
+/// JavaScript's `iterator.return()`, is not one
+fn count_returns(body: &[&Token]) -> usize {
+    body.iter()
+        .enumerate()
+        .filter(|&(i, t)| {
+            matches!(&t.token_type, TokenType::Identifier(word) if word == "return")
+                && i.checked_sub(1).is_none_or(|prev| body[prev].text != ".")
+        })
+        .count()
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -234,7 +673,7 @@ fn complex() {
 
         if !metrics.functions.is_empty() {
             for func in &metrics.functions {
-                assert!(func.complexity >= 1);
+                assert!(func.cyclomatic >= 1);
                 assert!(!func.name.is_empty());
             }
         }
@@ -275,6 +714,27 @@ function hello() {
         );
     }
 
+    #[test]
+    fn test_custom_severity_bands() {
+        let bands = SeverityBands { moderate: 5, high: 8, very_high: 12 };
+
+        assert_eq!(bands.classify(4), Severity::Low);
+        assert_eq!(bands.classify(5), Severity::Moderate);
+        assert_eq!(bands.classify(11), Severity::High);
+        assert_eq!(bands.classify(12), Severity::VeryHigh);
+        assert!(bands.validate().is_ok());
+        assert!(
+            SeverityBands { moderate: 5, high: 5, very_high: 12 }
+                .validate()
+                .is_err()
+        );
+
+        assert!(Severity::High > Severity::Moderate);
+        assert_eq!("medium".parse(), Ok(Severity::Moderate));
+        assert_eq!("very-high".parse(), Ok(Severity::VeryHigh));
+        assert_eq!(serde_json::to_string(&Severity::VeryHigh).unwrap(), "\"very-high\"");
+    }
+
     #[test]
     fn test_logical_operators() {
         let source = r#"
@@ -284,4 +744,408 @@ if (x || y || z) { }
         let metrics = CyclomaticMetrics::calculate(source, Language::JavaScript).unwrap();
         assert_eq!(metrics.file_complexity, 7);
     }
+
+    #[test]
+    fn test_short_circuit_chains() {
+        let source = r#"
+func mixed(a, b, c bool) bool {
+	return a && b || c
+}
+
+func grouped(a, b, c, d bool) bool {
+	return (a || b) && !(c || (d && a))
+}
+
+func bitwise(a, b, c int) int {
+	return a & b | c &^ a
+}
+
+func loop(i, n int, done, force bool) {
+	for i < n && !done || force {
+		i++
+	}
+}
+
+func tag(x, y int) string {
+	switch x > 0 && y > 0 {
+	case true:
+		return "both"
+	default:
+		return "not both"
+	}
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::Go).unwrap();
+
+        assert_eq!(complexity_of(&metrics, "mixed").cyclomatic, 3);
+        assert_eq!(complexity_of(&metrics, "grouped").cyclomatic, 5);
+        assert_eq!(complexity_of(&metrics, "bitwise").cyclomatic, 1);
+        assert_eq!(complexity_of(&metrics, "loop").cyclomatic, 4);
+        assert_eq!(complexity_of(&metrics, "tag").cyclomatic, 4);
+    }
+
+    fn complexity_of<'a>(metrics: &'a CyclomaticMetrics, name: &str) -> &'a FunctionComplexity {
+        metrics.functions.iter().find(|f| f.name == name).unwrap()
+    }
+
+    #[test]
+    fn test_function_scores_and_lines() {
+        let source = r#"
+fn first(x: i32) -> i32 {
+    if x > 0 { 1 } else { 0 }
+}
+
+fn second(items: &[i32]) {
+    for item in items {
+        if *item > 0 && *item < 10 {
+            println!("{}", item);
+        }
+    }
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::Rust).unwrap();
+        assert_eq!(metrics.functions.len(), 2);
+
+        let first = complexity_of(&metrics, "first");
+        assert_eq!((first.cyclomatic, first.line), (2, 2));
+
+        let second = complexity_of(&metrics, "second");
+        assert_eq!((second.cyclomatic, second.line), (4, 6));
+    }
+
+    #[test]
+    fn test_nested_function_counted_separately() {
+        let source = r#"
+fn outer(x: i32) -> i32 {
+    fn inner(y: i32) -> bool {
+        y > 0 && y < 100
+    }
+
+    if inner(x) { x } else { 0 }
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::Rust).unwrap();
+        assert_eq!(metrics.functions.len(), 2);
+        assert_eq!(complexity_of(&metrics, "outer").cyclomatic, 2);
+        assert_eq!(complexity_of(&metrics, "inner").cyclomatic, 2);
+        assert_eq!(complexity_of(&metrics, "inner").line, 3);
+    }
+
+    #[test]
+    fn test_rust_closure_counted_separately() {
+        let source = r#"
+fn filter(items: Vec<i32>) -> Vec<i32> {
+    let keep = |x: &i32| {
+        if *x > 0 { true } else { false }
+    };
+    items.into_iter().filter(|x| {
+        match x { 0 => false, _ => keep(x) }
+    }).collect()
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::Rust).unwrap();
+        let names: Vec<&str> = metrics.functions.iter().map(|f| f.name.as_str()).collect();
+        assert_eq!(names, vec!["filter", "keep", "anonymous"]);
+        assert_eq!(complexity_of(&metrics, "filter").cyclomatic, 1);
+        assert_eq!(complexity_of(&metrics, "keep").cyclomatic, 2);
+        assert_eq!(metrics.functions[2].cyclomatic, 2);
+    }
+
+    #[test]
+    fn test_go_function_literal_counted_separately() {
+        let source = r#"
+func process(values []int, apply func(int) int) int {
+	total := 0
+	handler := func(v int) {
+		if v > 0 || v < -10 {
+			total += apply(v)
+		}
+	}
+	for _, v := range values {
+		handler(v)
+	}
+	return total
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::Go).unwrap();
+        assert_eq!(metrics.functions.len(), 2);
+        assert_eq!(complexity_of(&metrics, "process").cyclomatic, 2);
+
+        let literal = complexity_of(&metrics, "process$func1");
+        assert_eq!((literal.cyclomatic, literal.line), (3, 4));
+    }
+
+    #[test]
+    fn test_go_function_literals_named_after_enclosing_function() {
+        let source = r#"
+var fallback = func() error { return nil }
+
+func (w *Worker) processUserInput(inputs []string) {
+	var wg sync.WaitGroup
+	defer func() {
+		if r := recover(); r != nil {
+			log.Print(r)
+		}
+	}()
+	for _, in := range inputs {
+		wg.Add(1)
+		go func(s string) {
+			defer wg.Done()
+			sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+		}(in)
+	}
+	wg.Wait()
+}
+
+func run() {
+	go func() {}()
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::Go).unwrap();
+        let names: Vec<&str> = metrics.functions.iter().map(|f| f.name.as_str()).collect();
+        assert_eq!(
+            names,
+            vec![
+                "glob$func1",
+                "(*Worker).processUserInput",
+                "(*Worker).processUserInput$func1",
+                "(*Worker).processUserInput$func2",
+                "(*Worker).processUserInput$func2$1",
+                "run",
+                "run$func1",
+            ]
+        );
+
+        // The deferred and goroutine closures are scored on their own, not in the method
+        assert_eq!(complexity_of(&metrics, "(*Worker).processUserInput").cyclomatic, 2);
+        assert_eq!(
+            complexity_of(&metrics, "(*Worker).processUserInput$func1").cyclomatic,
+            2
+        );
+        assert_eq!(complexity_of(&metrics, "(*Worker).processUserInput$func2").line, 13);
+    }
+
+    #[test]
+    fn test_returns_counted_per_function() {
+        let source = r#"
+func parse(s string) (n int, err error) {
+	if s == "" {
+		return
+	}
+	check := func(c rune) bool {
+		if c < '0' {
+			return false
+		}
+		return true
+	}
+	for _, c := range s {
+		if !check(c) {
+			return 0, errSyntax
+		}
+	}
+	return len(s), nil
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::Go).unwrap();
+        assert_eq!(complexity_of(&metrics, "parse").returns, 3);
+        assert_eq!(complexity_of(&metrics, "parse$func1").returns, 2);
+
+        let metrics =
+            CyclomaticMetrics::calculate("function close(it) { it.return(); }", Language::JavaScript).unwrap();
+        assert_eq!(metrics.functions[0].returns, 0);
+    }
+
+    #[test]
+    fn test_explain_lists_each_decision_point() {
+        let source = r#"
+func process(values []int, apply func(int) int) int {
+	total := 0
+	handler := func(v int) {
+		if v > 0 || v < -10 {
+			total += apply(v)
+		}
+	}
+	for _, v := range values {
+		handler(v)
+	}
+	return total
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::Go).unwrap();
+        let explained = CyclomaticMetrics::explain(source, Language::Go).unwrap();
+        let points = |name: &str| {
+            let func = complexity_of(&metrics, name);
+            let points = &explained[&(func.line, func.column)];
+            assert_eq!(points.len() + 1, func.cyclomatic);
+            points
+                .iter()
+                .map(|p| (p.construct.as_str(), p.line, p.column))
+                .collect::<Vec<_>>()
+        };
+
+        assert_eq!(points("process"), vec![("for", 9, 2)]);
+        assert_eq!(points("process$func1"), vec![("if", 5, 3), ("||", 5, 12)]);
+    }
+
+    #[test]
+    fn test_go_generics() {
+        let source = r#"
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+func Sum[T Number](xs []T) T {
+	var total T
+	for _, x := range xs {
+		total += x
+	}
+	return total
+}
+
+func Filter[T any, P interface{ func(T) bool }](xs []T, keep P) []T {
+	var out []T
+	for _, x := range xs {
+		if keep(x) || len(out) == 0 {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::Go).unwrap();
+        let names: Vec<&str> = metrics.functions.iter().map(|f| f.name.as_str()).collect();
+
+        // The constraint unions add no decision points and the `func` inside a
+        // constraint is not mistaken for a function literal
+        assert_eq!(names, vec!["Sum", "Filter"]);
+        assert_eq!(metrics.file_complexity, 5);
+        assert_eq!(complexity_of(&metrics, "Sum").cyclomatic, 2);
+        assert_eq!(complexity_of(&metrics, "Sum").line, 6);
+        assert_eq!(complexity_of(&metrics, "Filter").cyclomatic, 4);
+        assert_eq!(complexity_of(&metrics, "Filter").max_nesting, 2);
+    }
+
+    #[test]
+    fn test_go_methods_named_after_receiver() {
+        let source = r#"
+func (s *Set[T]) Add(v T) {
+	if s.items == nil {
+		s.items = map[T]bool{}
+	}
+	s.items[v] = true
+}
+
+func (Server) Close() error { return nil }
+
+func run(next func(int) (int, error)) {
+	go func(v int) (int, error) { return next(v) }(1)
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::Go).unwrap();
+        let names: Vec<&str> = metrics.functions.iter().map(|f| f.name.as_str()).collect();
+
+        assert_eq!(names, vec!["(*Set).Add", "Server.Close", "run", "run$func1"]);
+        assert_eq!(complexity_of(&metrics, "(*Set).Add").cyclomatic, 2);
+    }
+
+    #[test]
+    fn test_javascript_arrow_functions() {
+        let source = r#"
+function render(items) {
+    const visible = items.filter(item => {
+        return item.enabled && !item.hidden;
+    });
+    const format = (item) => {
+        if (item.label) {
+            return item.label;
+        }
+        return "untitled";
+    };
+    return visible.map(format);
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::JavaScript).unwrap();
+        let names: Vec<&str> = metrics.functions.iter().map(|f| f.name.as_str()).collect();
+        assert_eq!(names, vec!["render", "anonymous", "format"]);
+        assert_eq!(complexity_of(&metrics, "render").cyclomatic, 1);
+        assert_eq!(metrics.functions[1].cyclomatic, 2);
+        assert_eq!(complexity_of(&metrics, "format").cyclomatic, 2);
+    }
+
+    #[test]
+    fn test_declaration_without_body_is_skipped() {
+        let source = r#"
+trait Shape {
+    fn area(&self) -> f64;
+}
+
+fn total(shapes: &[Box<dyn Shape>]) -> f64 {
+    shapes.iter().map(|s| s.area()).sum()
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::Rust).unwrap();
+        assert_eq!(metrics.functions.len(), 1);
+        assert_eq!(metrics.functions[0].name, "total");
+        assert_eq!(metrics.functions[0].line, 6);
+    }
+
+    #[test]
+    fn test_function_halstead() {
+        let source = r#"
+package main
+
+func add(a, b int) int {
+	return a + b
+}
+
+func main() {
+	fmt.Println(add(1, 2))
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::Go).unwrap();
+        let add = &metrics.functions[0].halstead;
+
+        assert_eq!(metrics.functions[0].name, "add");
+        assert_eq!((add.distinct_operators, add.total_operators), (6, 6));
+        assert_eq!((add.distinct_operands, add.total_operands), (4, 7));
+        assert!((add.difficulty() - 5.25).abs() < 1e-9);
+    }
+
+    #[test]
+    fn test_function_halstead_excludes_nested_functions() {
+        let source = r#"
+func outer() {
+	f := func() { work(1, 2, 3) }
+	f()
+}
+"#;
+        let metrics = CyclomaticMetrics::calculate(source, Language::Go).unwrap();
+        let outer = &metrics.functions[0].halstead;
+
+        // Operators: func ( { : = (   Operands: outer f f
+        assert_eq!(metrics.functions[0].name, "outer");
+        assert_eq!((outer.total_operators, outer.total_operands), (6, 3));
+    }
+
+    #[test]
+    fn test_analyze_file() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let path = dir.path().join("main.go");
+        std::fs::write(&path, "package main\n\nfunc main() {\n\tif true {\n\t}\n}\n").unwrap();
+
+        let functions = analyze_file(&path).unwrap();
+        assert_eq!(functions.len(), 1);
+        assert_eq!(functions[0].name, "main");
+        assert_eq!(functions[0].line, 3);
+        assert_eq!(functions[0].cyclomatic, 2);
+    }
+
+    #[test]
+    fn test_analyze_file_unsupported() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let path = dir.path().join("notes.txt");
+        std::fs::write(&path, "fn main() {}").unwrap();
+
+        assert!(matches!(analyze_file(&path), Err(MccabreError::UnsupportedFileType(_))));
+    }
 }
@@ -0,0 +1,144 @@
+use crate::Result;
+use crate::tokenizer::{Language, Token, TokenType, Tokenizer};
+use serde::{Deserialize, Serialize};
+use std::collections::HashSet;
+
+/// Halstead token counts for a file or function
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct HalsteadMetrics {
+    /// Number of distinct operators (n1)
+    pub distinct_operators: usize,
+    /// Number of distinct operands (n2)
+    pub distinct_operands: usize,
+    /// Total number of operators (N1)
+    pub total_operators: usize,
+    /// Total number of operands (N2)
+    pub total_operands: usize,
+}
+
+impl HalsteadMetrics {
+    /// Count operators and operands in source code
+    ///
+    /// Operands are identifiers and literals. Operators are everything else that is
+    /// significant: language keywords, operator symbols, and punctuation. Paired brackets
+    /// count once, on the opening bracket.
+    pub fn calculate(source: &str, language: Language) -> Result<Self> {
+        let tokens = Tokenizer::new(source, language).tokenize()?;
+        Ok(Self::from_tokens(&tokens, language))
+    }
+
+    /// Count operators and operands in an already tokenized sequence
+    pub fn from_tokens<'a>(tokens: impl IntoIterator<Item = &'a Token>, language: Language) -> Self {
+        let mut operators = HashSet::new();
+        let mut operands = HashSet::new();
+        let mut metrics = Self::default();
+
+        for token in tokens.into_iter().filter(|t| t.token_type.is_significant()) {
+            match &token.token_type {
+                TokenType::Identifier(name) if !language.is_keyword(name) => {
+                    operands.insert(token.text.as_str());
+                    metrics.total_operands += 1;
+                }
+                TokenType::Literal(_) => {
+                    operands.insert(token.text.as_str());
+                    metrics.total_operands += 1;
+                }
+                TokenType::RightParen | TokenType::RightBrace | TokenType::RightBracket => {}
+                _ => {
+                    operators.insert(token.text.as_str());
+                    metrics.total_operators += 1;
+                }
+            }
+        }
+
+        metrics.distinct_operators = operators.len();
+        metrics.distinct_operands = operands.len();
+        metrics
+    }
+
+    /// Program vocabulary: n = n1 + n2
+    pub fn vocabulary(&self) -> usize {
+        self.distinct_operators + self.distinct_operands
+    }
+
+    /// Program length: N = N1 + N2
+    pub fn length(&self) -> usize {
+        self.total_operators + self.total_operands
+    }
+
+    /// Program volume: V = N * log2(n)
+    pub fn volume(&self) -> f64 {
+        let vocabulary = self.vocabulary();
+        if vocabulary == 0 { 0.0 } else { self.length() as f64 * (vocabulary as f64).log2() }
+    }
+
+    /// Difficulty: D = (n1 / 2) * (N2 / n2)
+    pub fn difficulty(&self) -> f64 {
+        if self.distinct_operands == 0 {
+            0.0
+        } else {
+            (self.distinct_operators as f64 / 2.0) * (self.total_operands as f64 / self.distinct_operands as f64)
+        }
+    }
+
+    /// Effort: E = D * V
+    pub fn effort(&self) -> f64 {
+        self.difficulty() * self.volume()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_counts() {
+        let metrics = HalsteadMetrics::calculate("let x = a + a;", Language::Rust).unwrap();
+
+        // Operators: let = + ;   Operands: x a a
+        assert_eq!(metrics.total_operators, 4);
+        assert_eq!(metrics.distinct_operators, 4);
+        assert_eq!(metrics.total_operands, 3);
+        assert_eq!(metrics.distinct_operands, 2);
+        assert_eq!(metrics.vocabulary(), 6);
+        assert_eq!(metrics.length(), 7);
+    }
+
+    #[test]
+    fn test_brackets_count_once() {
+        let metrics = HalsteadMetrics::calculate("f(x)", Language::JavaScript).unwrap();
+        assert_eq!(metrics.total_operators, 1);
+        assert_eq!(metrics.total_operands, 2);
+    }
+
+    #[test]
+    fn test_volume() {
+        let metrics = HalsteadMetrics::calculate("let x = a + a;", Language::Rust).unwrap();
+        assert!((metrics.volume() - 7.0 * 6f64.log2()).abs() < 1e-9);
+
+        let empty = HalsteadMetrics::calculate("", Language::Rust).unwrap();
+        assert_eq!(empty.volume(), 0.0);
+    }
+
+    #[test]
+    fn test_difficulty_and_effort() {
+        let source = "func add(a, b int) int {\n\treturn a + b\n}";
+        let metrics = HalsteadMetrics::calculate(source, Language::Go).unwrap();
+
+        // Operators: func ( , { return +   Operands: add a b int int a b
+        assert_eq!((metrics.distinct_operators, metrics.total_operators), (6, 6));
+        assert_eq!((metrics.distinct_operands, metrics.total_operands), (4, 7));
+        assert!((metrics.difficulty() - 5.25).abs() < 1e-9);
+        assert!((metrics.effort() - 5.25 * 13.0 * 10f64.log2()).abs() < 1e-9);
+
+        let empty = HalsteadMetrics::default();
+        assert_eq!((empty.difficulty(), empty.effort()), (0.0, 0.0));
+    }
+
+    #[test]
+    fn test_comments_ignored() {
+        let with = HalsteadMetrics::calculate("// note about x\nlet x = 1;", Language::Rust).unwrap();
+        let without = HalsteadMetrics::calculate("let x = 1;", Language::Rust).unwrap();
+        assert_eq!(with, without);
+    }
+}
@@ -1,5 +1,5 @@
 use crate::Result;
-use crate::tokenizer::{Language, TokenType, Tokenizer};
+use crate::tokenizer::{Language, Token, TokenType, Tokenizer};
 use serde::{Deserialize, Serialize};
 use std::collections::HashMap;
 use std::path::{Path, PathBuf};
@@ -22,13 +22,17 @@ pub struct LocMetrics {
     pub comments: usize,
     /// Number of blank lines
     pub blank: usize,
+    /// Number of statements
+    #[serde(default)]
+    pub statements: usize,
 }
 
 impl LocMetrics {
     pub fn calculate(source: &str, language: Language) -> Result<Self> {
         let tokens = Tokenizer::new(source, language).tokenize()?;
-        let physical = if source.is_empty() { 0 } else { source.split('\n').count() };
+        let physical = physical_lines(source);
         let mut line_types = vec![LineKind::Blank; physical];
+        let lines: Vec<&str> = source.split('\n').collect();
 
         for token in &tokens {
             let line_idx = token.line.saturating_sub(1);
@@ -38,11 +42,20 @@ impl LocMetrics {
 
             match token.token_type {
                 _ if token.token_type.is_significant() => {
-                    line_types[line_idx] = LineKind::Code;
+                    // A raw string spanning lines is code on every line it covers
+                    let end_idx = token.end_line.saturating_sub(1).clamp(line_idx, physical - 1);
+                    line_types[line_idx..=end_idx].fill(LineKind::Code);
                 }
                 TokenType::Comment => {
-                    if line_types[line_idx] != LineKind::Code {
-                        line_types[line_idx] = LineKind::Comment;
+                    let end_idx = if token.text == "/**/" {
+                        block_comment_end(&lines, line_idx, token.column).min(physical - 1)
+                    } else {
+                        line_idx
+                    };
+                    for kind in &mut line_types[line_idx..=end_idx] {
+                        if *kind != LineKind::Code {
+                            *kind = LineKind::Comment;
+                        }
                     }
                 }
                 _ => {}
@@ -58,8 +71,17 @@ impl LocMetrics {
         let comments = line_types.iter().filter(|&&t| t == LineKind::Comment).count();
         let blank = line_types.iter().filter(|&&t| t == LineKind::Blank).count();
         let logical = physical - comments - blank;
+        let statements = count_statements(&tokens, language);
 
-        Ok(LocMetrics { physical, logical, comments, blank })
+        Ok(LocMetrics { physical, logical, comments, blank, statements })
+    }
+
+    /// Share of commented lines among lines that hold code or comments
+    ///
+    /// Returns `comments / (logical + comments)`, or 0 for a file with neither.
+    pub fn comment_density(&self) -> f64 {
+        let total = self.logical + self.comments;
+        if total == 0 { 0.0 } else { self.comments as f64 / total as f64 }
     }
 
     /// Add two LocMetrics together
@@ -69,6 +91,107 @@ impl LocMetrics {
             logical: self.logical + other.logical,
             comments: self.comments + other.comments,
             blank: self.blank + other.blank,
+            statements: self.statements + other.statements,
+        }
+    }
+}
+
+/// Lines of a source as [`LocMetrics::physical`] counts them, without tokenizing it
+pub fn physical_lines(source: &str) -> usize {
+    if source.is_empty() { 0 } else { source.split('\n').count() }
+}
+
+/// Index of the line that closes a block comment opened at `line_idx`, `column`
+fn block_comment_end(lines: &[&str], line_idx: usize, column: usize) -> usize {
+    let opened: String = lines[line_idx].chars().skip(column + 1).collect();
+    if opened.contains("*/") {
+        return line_idx;
+    }
+
+    lines[line_idx + 1..]
+        .iter()
+        .position(|line| line.contains("*/"))
+        .map_or(lines.len() - 1, |offset| line_idx + 1 + offset)
+}
+
+/// Count statements in a token stream
+///
+/// Go statements end where the compiler inserts a semicolon: at a line break after an
+/// identifier, literal, closing bracket, `++`/`--`, or keyword such as `return`, plus explicit
+/// semicolons outside `if`/`for`/`switch` headers. Other languages count the semicolons that
+/// end statements, ignoring those inside parentheses or brackets such as a C-style `for` header.
+pub(crate) fn count_statements<'a>(tokens: impl IntoIterator<Item = &'a Token>, language: Language) -> usize {
+    let mut statements = 0;
+    let mut paren_depth = 0usize;
+    let mut in_header = false;
+    let mut last: Option<&Token> = None;
+
+    for token in tokens.into_iter().filter(|t| t.token_type.is_significant()) {
+        if language == Language::Go
+            && let Some(prev) = last
+            && prev.line < token.line
+            && ends_go_statement(&prev.token_type)
+        {
+            statements += 1;
+        }
+
+        match token.token_type {
+            TokenType::If | TokenType::For | TokenType::Switch => in_header = true,
+            TokenType::LeftBrace => in_header = false,
+            TokenType::LeftParen | TokenType::LeftBracket => paren_depth += 1,
+            TokenType::RightParen | TokenType::RightBracket => paren_depth = paren_depth.saturating_sub(1),
+            TokenType::Semicolon if paren_depth == 0 && !(language == Language::Go && in_header) => statements += 1,
+            _ => {}
+        }
+        last = Some(token);
+    }
+
+    if language == Language::Go && last.is_some_and(|t| ends_go_statement(&t.token_type)) {
+        statements += 1;
+    }
+
+    statements
+}
+
+/// Whether a Go line ending in this token gets an automatic semicolon
+pub(crate) fn ends_go_statement(token_type: &TokenType) -> bool {
+    match token_type {
+        TokenType::Identifier(_) | TokenType::Literal(_) => true,
+        TokenType::RightParen | TokenType::RightBracket | TokenType::RightBrace => true,
+        TokenType::Operator(op) => op == "++" || op == "--",
+        _ => false,
+    }
+}
+
+/// Size and comment metrics for a single file
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq)]
+pub struct FileMetrics {
+    /// File path
+    pub path: PathBuf,
+    /// Total number of lines in the file
+    pub physical: usize,
+    /// Number of non-blank, non-comment lines
+    pub logical: usize,
+    /// Number of statements
+    pub statements: usize,
+    /// Number of comment lines
+    pub comments: usize,
+    /// Number of blank lines
+    pub blank: usize,
+    /// `comments / (logical + comments)`, from 0 to 1
+    pub comment_density: f64,
+}
+
+impl FileMetrics {
+    pub fn new(path: PathBuf, loc: &LocMetrics) -> Self {
+        Self {
+            path,
+            physical: loc.physical,
+            logical: loc.logical,
+            statements: loc.statements,
+            comments: loc.comments,
+            blank: loc.blank,
+            comment_density: loc.comment_density(),
         }
     }
 }
@@ -168,7 +291,7 @@ impl LocReport {
             .into_iter()
             .map(|(path, files)| {
                 let total = files.iter().fold(
-                    LocMetrics { physical: 0, logical: 0, comments: 0, blank: 0 },
+                    LocMetrics { physical: 0, logical: 0, comments: 0, blank: 0, statements: 0 },
                     |acc, f| acc.add(&f.metrics),
                 );
 
@@ -278,21 +401,98 @@ function hello() {
         assert_eq!(metrics.logical, 0);
     }
 
+    #[test]
+    fn test_multi_line_block_comment_counts_every_line() {
+        let source = "/*\n * Copyright 2024 The Authors\n *\n * Licensed under the MIT License\n */\n\npackage main\n";
+        let metrics = LocMetrics::calculate(source, Language::Go).unwrap();
+
+        assert_eq!(metrics.comments, 5);
+        assert_eq!(metrics.blank, 2);
+        assert_eq!(metrics.logical, 1);
+    }
+
+    #[test]
+    fn test_raw_string_lines_are_code() {
+        let source = "package main\n\nconst usage = `\n// not a comment\n/* nor this\n`\n";
+        let metrics = LocMetrics::calculate(source, Language::Go).unwrap();
+
+        assert_eq!(metrics.comments, 0);
+        assert_eq!(metrics.logical, 5);
+    }
+
+    #[test]
+    fn test_build_tags_are_comments() {
+        let source = "//go:build linux && amd64\n// +build linux,amd64\n\npackage sys\n";
+        let metrics = LocMetrics::calculate(source, Language::Go).unwrap();
+
+        assert_eq!(metrics.comments, 2);
+        assert_eq!(metrics.logical, 1);
+    }
+
+    #[test]
+    fn test_go_statements() {
+        let source = r#"package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	for i := 0; i < 3; i++ {
+		fmt.Println(i,
+			os.Args)
+	}
+	x := 1; x++
+	if x > 1 {
+		return
+	}
+}
+"#;
+        let metrics = LocMetrics::calculate(source, Language::Go).unwrap();
+
+        // package, both imports, the import block, the call, `x := 1`, `x++`, `return`,
+        // and the closing braces of the `for`, the `if` and the function
+        assert_eq!(metrics.statements, 11);
+    }
+
+    #[test]
+    fn test_semicolon_statements() {
+        let source = "fn main() {\n    let xs = [0; 4];\n    for x in xs {\n        print(x);\n    }\n}\n";
+        assert_eq!(LocMetrics::calculate(source, Language::Rust).unwrap().statements, 2);
+
+        let source = "for (int i = 0; i < n; i++) {\n    sum += i;\n}\nreturn sum;\n";
+        assert_eq!(LocMetrics::calculate(source, Language::Java).unwrap().statements, 2);
+    }
+
+    #[test]
+    fn test_comment_density() {
+        let metrics = LocMetrics { physical: 12, logical: 6, comments: 2, blank: 4, statements: 5 };
+        assert_eq!(metrics.comment_density(), 0.25);
+
+        let empty = LocMetrics { physical: 0, logical: 0, comments: 0, blank: 0, statements: 0 };
+        assert_eq!(empty.comment_density(), 0.0);
+
+        let file = FileMetrics::new(PathBuf::from("a.go"), &metrics);
+        assert_eq!((file.physical, file.statements, file.comment_density), (12, 5, 0.25));
+    }
+
     #[test]
     fn test_loc_metrics_add() {
-        let m1 = LocMetrics { physical: 10, logical: 8, comments: 1, blank: 1 };
-        let m2 = LocMetrics { physical: 20, logical: 15, comments: 3, blank: 2 };
+        let m1 = LocMetrics { physical: 10, logical: 8, comments: 1, blank: 1, statements: 8 };
+        let m2 = LocMetrics { physical: 20, logical: 15, comments: 3, blank: 2, statements: 15 };
         let result = m1.add(&m2);
 
         assert_eq!(result.physical, 30);
         assert_eq!(result.logical, 23);
         assert_eq!(result.comments, 4);
         assert_eq!(result.blank, 3);
+        assert_eq!(result.statements, 23);
     }
 
     #[test]
     fn test_rank_by_value_from() {
-        let metrics = LocMetrics { physical: 100, logical: 80, comments: 10, blank: 10 };
+        let metrics = LocMetrics { physical: 100, logical: 80, comments: 10, blank: 10, statements: 80 };
 
         assert_eq!(RankBy::Physical.value_from(&metrics), 100);
         assert_eq!(RankBy::Logical.value_from(&metrics), 80);
@@ -305,11 +505,11 @@ function hello() {
         let files = vec![
             FileLocReport {
                 path: PathBuf::from("test1.rs"),
-                metrics: LocMetrics { physical: 100, logical: 80, comments: 10, blank: 10 },
+                metrics: LocMetrics { physical: 100, logical: 80, comments: 10, blank: 10, statements: 80 },
             },
             FileLocReport {
                 path: PathBuf::from("test2.rs"),
-                metrics: LocMetrics { physical: 50, logical: 40, comments: 5, blank: 5 },
+                metrics: LocMetrics { physical: 50, logical: 40, comments: 5, blank: 5, statements: 40 },
             },
         ];
 
@@ -330,15 +530,15 @@ function hello() {
         let files = vec![
             FileLocReport {
                 path: PathBuf::from("src/main.rs"),
-                metrics: LocMetrics { physical: 100, logical: 80, comments: 10, blank: 10 },
+                metrics: LocMetrics { physical: 100, logical: 80, comments: 10, blank: 10, statements: 80 },
             },
             FileLocReport {
                 path: PathBuf::from("src/lib.rs"),
-                metrics: LocMetrics { physical: 50, logical: 40, comments: 5, blank: 5 },
+                metrics: LocMetrics { physical: 50, logical: 40, comments: 5, blank: 5, statements: 40 },
             },
             FileLocReport {
                 path: PathBuf::from("tests/test.rs"),
-                metrics: LocMetrics { physical: 30, logical: 25, comments: 3, blank: 2 },
+                metrics: LocMetrics { physical: 30, logical: 25, comments: 3, blank: 2, statements: 25 },
             },
         ];
 
@@ -361,7 +561,7 @@ function hello() {
     fn test_loc_report_to_json() {
         let files = vec![FileLocReport {
             path: PathBuf::from("test.rs"),
-            metrics: LocMetrics { physical: 10, logical: 8, comments: 1, blank: 1 },
+            metrics: LocMetrics { physical: 10, logical: 8, comments: 1, blank: 1, statements: 8 },
         }];
 
         let report = LocReport::new(files, RankBy::Logical, false);
@@ -0,0 +1,105 @@
+use crate::complexity::{CyclomaticMetrics, HalsteadMetrics, LocMetrics};
+use crate::tokenizer::Language;
+use crate::{MccabreError, Result};
+use std::fs;
+use std::path::Path;
+
+/// Calculate the maintainability index for source code, normalized to 0-100
+///
+/// Uses the classic formula with the 0-100 rescaling popularized by Visual Studio:
+///
+/// `MI = max(0, (171 - 5.2 * ln(V) - 0.23 * G - 16.2 * ln(LOC)) * 100 / 171)`
+///
+/// where `V` is the Halstead volume, `G` the file's cyclomatic complexity, and `LOC` the
+/// logical lines of code (lines with code on them, excluding blank and comment-only lines).
+/// Higher is better; values below 20 are generally considered hard to maintain.
+pub fn maintainability_index(source: &str, language: Language) -> Result<f64> {
+    let halstead = HalsteadMetrics::calculate(source, language)?;
+    let cyclomatic = CyclomaticMetrics::calculate(source, language)?;
+    let loc = LocMetrics::calculate(source, language)?;
+
+    Ok(maintainability_from_metrics(&halstead, &cyclomatic, &loc))
+}
+
+/// Maintainability index from already computed metrics
+pub fn maintainability_from_metrics(
+    halstead: &HalsteadMetrics, cyclomatic: &CyclomaticMetrics, loc: &LocMetrics,
+) -> f64 {
+    // ln(0) is undefined: an empty file has nothing to maintain and scores close to 100
+    let volume = halstead.volume().max(1.0);
+    let lines = loc.logical.max(1) as f64;
+    let complexity = cyclomatic.file_complexity as f64;
+
+    let raw = 171.0 - 5.2 * volume.ln() - 0.23 * complexity - 16.2 * lines.ln();
+    (raw * 100.0 / 171.0).clamp(0.0, 100.0)
+}
+
+/// Read a file and calculate its maintainability index
+pub fn compute_maintainability(path: &Path) -> Result<f64> {
+    let language = Language::from_path(path)?;
+    let source =
+        fs::read_to_string(path).map_err(|e| MccabreError::FileRead { path: path.to_path_buf(), source: e })?;
+
+    maintainability_index(&source, language)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_simple_code_scores_high() {
+        let mi = maintainability_index("fn add(a: i32, b: i32) -> i32 {\n    a + b\n}\n", Language::Rust).unwrap();
+        assert!(mi > 70.0, "got {mi}");
+        assert!(mi <= 100.0);
+    }
+
+    #[test]
+    fn test_empty_source() {
+        let mi = maintainability_index("", Language::Rust).unwrap();
+        assert!(mi > 99.0 && mi <= 100.0, "got {mi}");
+    }
+
+    #[test]
+    fn test_complex_code_scores_lower() {
+        let simple = "function f(x) {\n    return x + 1;\n}\n";
+        let mut complex = String::from("function g(x) {\n");
+        for i in 0..40 {
+            complex.push_str(&format!(
+                "    if (x > {i} && x < {}) {{ x = x * {i} + {}; }}\n",
+                i * 2,
+                i + 3
+            ));
+        }
+        complex.push_str("    return x;\n}\n");
+
+        let simple_mi = maintainability_index(simple, Language::JavaScript).unwrap();
+        let complex_mi = maintainability_index(&complex, Language::JavaScript).unwrap();
+        assert!(complex_mi < simple_mi);
+    }
+
+    #[test]
+    fn test_negative_values_clamped() {
+        let halstead = HalsteadMetrics {
+            distinct_operators: 200,
+            distinct_operands: 5000,
+            total_operators: 400_000,
+            total_operands: 400_000,
+        };
+        let cyclomatic = CyclomaticMetrics { file_complexity: 2000, functions: vec![] };
+        let loc = LocMetrics { physical: 60_000, logical: 50_000, comments: 5_000, blank: 5_000, statements: 50_000 };
+
+        assert_eq!(maintainability_from_metrics(&halstead, &cyclomatic, &loc), 0.0);
+    }
+
+    #[test]
+    fn test_compute_maintainability_from_file() {
+        let temp_dir = tempfile::TempDir::new().unwrap();
+        let path = temp_dir.path().join("main.go");
+        std::fs::write(&path, "package main\n\nfunc main() {\n\tprintln(1)\n}\n").unwrap();
+
+        let from_file = compute_maintainability(&path).unwrap();
+        let from_source = maintainability_index(&std::fs::read_to_string(&path).unwrap(), Language::Go).unwrap();
+        assert_eq!(from_file, from_source);
+    }
+}
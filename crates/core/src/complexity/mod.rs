@@ -1,5 +1,18 @@
+pub mod cognitive;
 pub mod cyclomatic;
+pub mod halstead;
 pub mod loc;
+pub mod maintainability;
+pub mod nesting;
+pub mod parameters;
 
-pub use cyclomatic::{CyclomaticMetrics, FunctionComplexity, Severity};
-pub use loc::LocMetrics;
+pub use cognitive::cognitive_complexity;
+pub use cyclomatic::{
+    CyclomaticMetrics, DecisionPoint, FunctionComplexity, FunctionTokens, Severity, SeverityBands, analyze_file,
+    function_tokens,
+};
+pub use halstead::HalsteadMetrics;
+pub use loc::{FileMetrics, LocMetrics, physical_lines};
+pub use maintainability::{compute_maintainability, maintainability_from_metrics, maintainability_index};
+pub use nesting::max_nesting_depth;
+pub use parameters::parameter_names;
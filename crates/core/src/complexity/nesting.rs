@@ -0,0 +1,233 @@
+use crate::tokenizer::{Language, Token, TokenType};
+
+/// Calculate the deepest nesting of block statements in a single function body
+///
+/// The tokens start at the function's opening brace, which is depth 0. Each block opened by
+/// `if`/`else`, a loop, `switch`/`match`/`select`, `try`/`catch`/`finally`, or a nested function
+/// or closure body adds one level. `case` clauses do not add a level: statements in a case sit
+/// one level inside their `switch` or `select`, and so do Rust match arms, even with braces.
+/// Plain `{ ... }` blocks and composite literals are not counted.
+///
+/// Unlike [`cognitive_complexity`](crate::complexity::cognitive_complexity), the tokens should
+/// include nested functions, since their bodies deepen the enclosing function.
+pub fn max_nesting_depth(tokens: &[&Token], language: Language) -> usize {
+    let mut depth = 0;
+    let mut max_depth = 0;
+    // Each open brace records whether it raised the depth and the paren depth outside it
+    let mut scopes: Vec<(bool, usize)> = Vec::new();
+    let mut paren_depth = 0usize;
+    let mut pending_control = false;
+    let mut pending_function = false;
+
+    for (i, token) in tokens.iter().enumerate() {
+        match &token.token_type {
+            TokenType::If
+            | TokenType::Else
+            | TokenType::ElseIf
+            | TokenType::While
+            | TokenType::For
+            | TokenType::Loop
+            | TokenType::Match
+            | TokenType::Switch
+            | TokenType::Catch => pending_control = true,
+            TokenType::Identifier(word) if matches!(word.as_str(), "select" | "try" | "finally" | "do") => {
+                pending_control = true;
+            }
+            TokenType::Identifier(word) if matches!(word.as_str(), "fn" | "func" | "function") => {
+                pending_function = true;
+            }
+            TokenType::Operator(op)
+                if op == "=>" && matches!(language, Language::JavaScript | Language::TypeScript) =>
+            {
+                pending_function = next_is_brace(tokens, i);
+            }
+            TokenType::Operator(op) if op == "|" && language == Language::Rust => {
+                pending_function |= closure_has_block(tokens, i);
+            }
+            TokenType::LogicalOr if language == Language::Rust => pending_function |= next_is_brace(tokens, i),
+            TokenType::LeftParen | TokenType::LeftBracket => paren_depth += 1,
+            TokenType::RightParen | TokenType::RightBracket => paren_depth = paren_depth.saturating_sub(1),
+            TokenType::LeftBrace => {
+                // Closures are often passed as arguments, so only control headers need paren depth 0
+                let raises = pending_function || (pending_control && paren_depth == 0);
+                if raises {
+                    depth += 1;
+                    max_depth = max_depth.max(depth);
+                    pending_control = false;
+                    pending_function = false;
+                }
+                scopes.push((raises, paren_depth));
+                paren_depth = 0;
+            }
+            TokenType::RightBrace => {
+                if let Some((raised, outer_depth)) = scopes.pop() {
+                    if raised {
+                        depth -= 1;
+                    }
+                    paren_depth = outer_depth;
+                }
+            }
+            TokenType::Semicolon => {
+                // Go uses semicolons inside `for` and `if` headers
+                if paren_depth == 0 && language != Language::Go {
+                    pending_control = false;
+                    pending_function = false;
+                }
+            }
+            _ => {}
+        }
+    }
+
+    max_depth
+}
+
+fn next_is_brace(tokens: &[&Token], i: usize) -> bool {
+    tokens.get(i + 1).is_some_and(|t| t.token_type == TokenType::LeftBrace)
+}
+
+/// Whether the Rust `|` at `i` opens closure parameters followed by a block body
+fn closure_has_block(tokens: &[&Token], i: usize) -> bool {
+    let opens = i == 0
+        || matches!(
+            &tokens[i - 1].token_type,
+            TokenType::LeftParen | TokenType::LeftBrace | TokenType::Comma | TokenType::Operator(_)
+        )
+        || matches!(&tokens[i - 1].token_type, TokenType::Identifier(word) if word == "move");
+    if !opens {
+        return false;
+    }
+
+    let Some(close) = tokens[i + 1..]
+        .iter()
+        .position(|t| matches!(&t.token_type, TokenType::Operator(op) if op == "|"))
+    else {
+        return false;
+    };
+
+    match tokens.get(i + close + 2).map(|t| &t.token_type) {
+        Some(TokenType::LeftBrace) => true,
+        Some(TokenType::Operator(op)) => op == "->",
+        _ => false,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::complexity::CyclomaticMetrics;
+
+    fn nesting(source: &str, language: Language) -> usize {
+        CyclomaticMetrics::calculate(source, language).unwrap().functions[0].max_nesting
+    }
+
+    #[test]
+    fn test_flat_function() {
+        assert_eq!(
+            nesting(
+                "fn flat(x: i32) -> i32 {\n    let y = x + 1;\n    y\n}\n",
+                Language::Rust
+            ),
+            0
+        );
+    }
+
+    #[test]
+    fn test_nested_blocks() {
+        let source = r#"
+fn deep(items: &[i32]) {
+    for item in items {
+        if *item > 0 {
+            while ready() {
+                step();
+            }
+        } else {
+            skip();
+        }
+    }
+    if done() {
+        finish();
+    }
+}
+"#;
+        assert_eq!(nesting(source, Language::Rust), 3);
+    }
+
+    #[test]
+    fn test_plain_blocks_and_literals_do_not_count() {
+        let source = r#"
+fn build() -> Point {
+    let p = Point { x: 1, y: 2 };
+    {
+        let scoped = p;
+    }
+    p
+}
+"#;
+        assert_eq!(nesting(source, Language::Rust), 0);
+    }
+
+    #[test]
+    fn test_switch_and_select_cases_add_no_level() {
+        let source = r#"
+func route(kind int, ch chan int) {
+	switch kind {
+	case 1:
+		if ready() {
+			run()
+		}
+	default:
+		stop()
+	}
+	select {
+	case v := <-ch:
+		use(v)
+	}
+}
+"#;
+        assert_eq!(nesting(source, Language::Go), 2);
+    }
+
+    #[test]
+    fn test_match_arm_blocks_add_no_level() {
+        let source = r#"
+fn handle(cmd: Command) {
+    match cmd {
+        Command::Run => {
+            run();
+        }
+        Command::Stop => stop(),
+    }
+}
+"#;
+        assert_eq!(nesting(source, Language::Rust), 1);
+    }
+
+    #[test]
+    fn test_go_for_header_with_semicolons() {
+        let source = "func count(n int) {\n\tfor i := 0; i < n; i++ {\n\t\tif i > 2 {\n\t\t\tbreak\n\t\t}\n\t}\n}\n";
+        assert_eq!(nesting(source, Language::Go), 2);
+    }
+
+    #[test]
+    fn test_function_literal_bodies_add_a_level() {
+        let go = r#"
+func spawn(jobs []int) {
+	for _, job := range jobs {
+		go func(j int) {
+			if j > 0 {
+				work(j)
+			}
+		}(job)
+	}
+}
+"#;
+        assert_eq!(nesting(go, Language::Go), 3);
+
+        let rust = "fn apply(items: Vec<i32>) {\n    items.iter().for_each(|x| {\n        if *x > 0 {\n            use_it(x);\n        }\n    });\n}\n";
+        assert_eq!(nesting(rust, Language::Rust), 2);
+
+        let js =
+            "function load(items) {\n  items.forEach((item) => {\n    if (item) {\n      use(item);\n    }\n  });\n}\n";
+        assert_eq!(nesting(js, Language::JavaScript), 2);
+    }
+}
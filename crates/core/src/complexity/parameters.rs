@@ -0,0 +1,238 @@
+use crate::tokenizer::{Language, Token, TokenType};
+
+/// Names of a function's parameters, one per parameter, from its header tokens
+///
+/// Go parameters declared together, as in `(a, b int)`, are listed one by one, and a variadic
+/// `...T` counts as one. Unnamed Go parameters, as in `func(int, string)`, are listed by their
+/// type. A Go method's receiver and Rust's `self` are not parameters.
+pub fn parameter_names(header: &[&Token], language: Language) -> Vec<String> {
+    let Some(list) = parameter_list(header, language) else {
+        return Vec::new();
+    };
+    let parts = split_parameters(list, language);
+
+    if language != Language::Go {
+        return parts.iter().filter_map(|part| declared_name(part)).collect();
+    }
+    if parts.iter().any(|part| is_named(part)) {
+        parts.iter().map(|part| part[0].text.clone()).collect()
+    } else {
+        parts.iter().map(|part| source_text(part)).collect()
+    }
+}
+
+/// Tokens between the delimiters of the parameter list in a function header
+fn parameter_list<'a>(header: &'a [&'a Token], language: Language) -> Option<&'a [&'a Token]> {
+    let first = header.first()?;
+    match &first.token_type {
+        // A Rust closure: `|a, b|`, or `||` without parameters
+        TokenType::LogicalOr => Some(&[]),
+        TokenType::Operator(op) if op.starts_with('|') => {
+            let close = (1..header.len()).find(|&i| header[i].text == "|")?;
+            Some(&header[1..close])
+        }
+        // A JavaScript arrow function with one bare parameter: `x => {`
+        TokenType::Identifier(word) if !matches!(word.as_str(), "fn" | "func" | "function") => Some(&header[..1]),
+        TokenType::LeftParen => Some(&header[1..closing(header, 0)?]),
+        _ => {
+            let mut open = 1;
+            if language == Language::Go && header.get(open)?.token_type == TokenType::LeftParen {
+                let close = closing(header, open)?;
+                let method = header
+                    .get(close + 1)
+                    .is_some_and(|t| matches!(t.token_type, TokenType::Identifier(_)))
+                    && header
+                        .get(close + 2)
+                        .is_some_and(|t| t.token_type == TokenType::LeftParen);
+                if method {
+                    open = close + 1;
+                }
+            }
+
+            let mut angles = 0usize;
+            let open = (open..header.len()).find(|&i| {
+                let token = header[i];
+                if language != Language::Go && is_angle(token) {
+                    for c in token.text.chars() {
+                        match c {
+                            '<' => angles += 1,
+                            _ => angles = angles.saturating_sub(1),
+                        }
+                    }
+                }
+                angles == 0 && token.token_type == TokenType::LeftParen
+            })?;
+            Some(&header[open + 1..closing(header, open)?])
+        }
+    }
+}
+
+/// Index of the `)` closing the `(` at `open`
+pub(crate) fn closing(tokens: &[&Token], open: usize) -> Option<usize> {
+    let mut depth = 0usize;
+
+    for (offset, token) in tokens[open..].iter().enumerate() {
+        match token.token_type {
+            TokenType::LeftParen => depth += 1,
+            TokenType::RightParen => {
+                depth -= 1;
+                if depth == 0 {
+                    return Some(open + offset);
+                }
+            }
+            _ => {}
+        }
+    }
+
+    None
+}
+
+/// Whether an operator is made of angle brackets only, as generic arguments such as `<K, V>` are
+fn is_angle(token: &Token) -> bool {
+    matches!(&token.token_type, TokenType::Operator(op) if op.chars().all(|c| c == '<' || c == '>'))
+}
+
+/// Split a parameter list at its top-level commas, dropping a trailing comma
+fn split_parameters<'a>(tokens: &'a [&'a Token], language: Language) -> Vec<&'a [&'a Token]> {
+    let mut parts = Vec::new();
+    let (mut depth, mut from) = (0usize, 0);
+
+    for (i, token) in tokens.iter().enumerate() {
+        match token.token_type {
+            TokenType::LeftBrace | TokenType::LeftParen | TokenType::LeftBracket => depth += 1,
+            TokenType::RightBrace | TokenType::RightParen | TokenType::RightBracket => depth = depth.saturating_sub(1),
+            TokenType::Operator(_) if language != Language::Go && is_angle(token) => {
+                for c in token.text.chars() {
+                    match c {
+                        '<' => depth += 1,
+                        _ => depth = depth.saturating_sub(1),
+                    }
+                }
+            }
+            TokenType::Comma if depth == 0 => {
+                parts.push(&tokens[from..i]);
+                from = i + 1;
+            }
+            _ => {}
+        }
+    }
+    parts.push(&tokens[from..]);
+
+    parts.retain(|part| !part.is_empty());
+    parts
+}
+
+/// Whether a Go parameter starts with its name: `a int` or `opts ...Option`, but not the
+/// unnamed `pkg.Type`, `[]int`, or `func(int) error`
+fn is_named(part: &[&Token]) -> bool {
+    let starts_with_name = matches!(&part[0].token_type, TokenType::Identifier(word) if !Language::Go.is_keyword(word));
+    let qualified_type = part.get(1).is_some_and(|t| t.text == ".") && part.get(2).is_some_and(|t| t.text != ".");
+
+    starts_with_name && part.len() > 1 && !qualified_type
+}
+
+/// Name of a Rust, JavaScript, or TypeScript parameter: the pattern before its type or default
+fn declared_name(part: &[&Token]) -> Option<String> {
+    let end = part
+        .iter()
+        .position(|t| t.token_type == TokenType::Unknown(':') || t.text == "=")
+        .unwrap_or(part.len());
+    let name = source_text(&part[..end]);
+    let name = name
+        .trim_start_matches("...")
+        .trim_start_matches("mut ")
+        .trim_end_matches('?')
+        .trim();
+
+    let receiver = matches!(name, "self" | "&self" | "&mut self" | "this");
+    (!receiver && !name.is_empty()).then(|| name.to_string())
+}
+
+/// Tokens joined with a space only where the source had whitespace between them
+fn source_text(tokens: &[&Token]) -> String {
+    let mut text = String::new();
+
+    for (i, token) in tokens.iter().enumerate() {
+        if i > 0 && tokens[i - 1].end_offset < token.offset {
+            text.push(' ');
+        }
+        text.push_str(&token.text);
+    }
+
+    text
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::complexity::function_tokens;
+    use crate::tokenizer::Tokenizer;
+
+    fn parameters(source: &str, language: Language) -> Vec<(String, Vec<String>)> {
+        let tokens = Tokenizer::new(source, language).tokenize().unwrap();
+        function_tokens(&tokens, language)
+            .iter()
+            .map(|f| (f.name.clone(), parameter_names(&f.header, language)))
+            .collect()
+    }
+
+    fn names(list: &[&str]) -> Vec<String> {
+        list.iter().map(|name| name.to_string()).collect()
+    }
+
+    #[test]
+    fn test_go_grouped_variadic_and_unnamed_parameters() {
+        let source = r#"
+func (s *Server) Handle(w http.ResponseWriter, r *http.Request) {}
+func copyN(dst, src []byte, n int, opts ...Option) (written int, err error) {}
+func Map[T, U any](items []T, f func(T) U) []U {}
+func none() {}
+var handler = func(context.Context, *pkg.Request, ...string) error { return nil }
+func trailing(
+	a int,
+	b string,
+) {}
+"#;
+        assert_eq!(
+            parameters(source, Language::Go),
+            vec![
+                ("(*Server).Handle".to_string(), names(&["w", "r"])),
+                ("copyN".to_string(), names(&["dst", "src", "n", "opts"])),
+                ("Map".to_string(), names(&["items", "f"])),
+                ("none".to_string(), vec![]),
+                (
+                    "glob$func1".to_string(),
+                    names(&["context.Context", "*pkg.Request", "...string"])
+                ),
+                ("trailing".to_string(), names(&["a", "b"])),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_rust_and_javascript_parameters() {
+        let source = r#"
+fn merge<K, V>(&mut self, left: HashMap<K, V>, mut right: Vec<(K, V)>) {}
+fn apply() {
+    let f = |a, b: i32| { a + b };
+}
+"#;
+        assert_eq!(
+            parameters(source, Language::Rust),
+            vec![
+                ("merge".to_string(), names(&["left", "right"])),
+                ("apply".to_string(), vec![]),
+                ("f".to_string(), names(&["a", "b"])),
+            ]
+        );
+
+        let source = "function send(to, body = '', ...rest) {}\nconst log = msg => {};\n";
+        assert_eq!(
+            parameters(source, Language::JavaScript),
+            vec![
+                ("send".to_string(), names(&["to", "body", "rest"])),
+                ("log".to_string(), names(&["msg"])),
+            ]
+        );
+    }
+}
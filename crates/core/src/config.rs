@@ -1,8 +1,23 @@
+use crate::cloner::{CloneStrategy, DEFAULT_MIN_NODES, DEFAULT_MIN_STATEMENTS, Granularity};
+use crate::complexity::SeverityBands;
 use crate::error::{MccabreError, Result};
+use crate::rules::LengthLimits;
+use crate::tokenizer::NormalizeMode;
+use globset::Glob;
 use serde::{Deserialize, Serialize};
+use std::fmt;
 use std::fs;
 use std::path::Path;
 
+/// File names searched in the working directory when no config path is given, in order
+pub const DEFAULT_CONFIG_FILES: &[&str] = &[
+    "mccabre.toml",
+    ".mccabre.toml",
+    ".mccabre.yaml",
+    ".mccabre.yml",
+    ".mccabre/config.toml",
+];
+
 /// Configuration for mccabre analysis
 #[derive(Debug, Clone, Serialize, Deserialize, Default)]
 pub struct Config {
@@ -17,6 +32,10 @@ pub struct Config {
     /// File filtering settings
     #[serde(default)]
     pub files: FileConfig,
+
+    /// Report output settings
+    #[serde(default)]
+    pub output: OutputConfig,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -28,6 +47,53 @@ pub struct ComplexityConfig {
     /// Threshold for error level (default: 20)
     #[serde(default = "default_error_threshold")]
     pub error_threshold: usize,
+
+    /// Deepest block nesting allowed before a function is flagged (default: 4)
+    #[serde(default = "default_max_nesting")]
+    pub max_nesting: usize,
+
+    /// Longest function body allowed, in lines, before it is flagged (default: unlimited)
+    #[serde(default)]
+    pub max_function_lines: Option<usize>,
+
+    /// Most statements allowed in a function body before it is flagged (default: unlimited)
+    #[serde(default)]
+    pub max_function_statements: Option<usize>,
+
+    /// Most parameters a function may take before it is flagged (default: unlimited)
+    #[serde(default)]
+    pub max_parameters: Option<usize>,
+
+    /// Lowest complexity of the moderate, high, and very high severity tiers
+    #[serde(default, alias = "severityBands")]
+    pub severity_bands: SeverityBands,
+
+    /// Thresholds for paths matching a glob, in place of the ones above (default: none)
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub overrides: Vec<PathOverride>,
+}
+
+/// Complexity thresholds for the files under one glob pattern
+///
+/// Unset values fall back to the `[complexity]` settings.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct PathOverride {
+    /// Glob matched against file paths, such as `internal/parser/**`
+    pub path: String,
+
+    #[serde(
+        default,
+        alias = "max_complexity",
+        alias = "maxComplexity",
+        alias = "warningThreshold"
+    )]
+    pub warning_threshold: Option<usize>,
+
+    #[serde(default, alias = "errorThreshold")]
+    pub error_threshold: Option<usize>,
+
+    #[serde(default, alias = "maxNesting")]
+    pub max_nesting: Option<usize>,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -36,9 +102,61 @@ pub struct CloneConfig {
     #[serde(default = "default_min_tokens")]
     pub min_tokens: usize,
 
+    /// Fewest instances a clone group needs to be reported (default: 2)
+    #[serde(default = "default_min_instances")]
+    pub min_instances: usize,
+
     /// Whether to enable clone detection (default: true)
     #[serde(default = "default_true")]
     pub enabled: bool,
+
+    /// Token normalization: "exact" or "renamed" (default: exact)
+    #[serde(default)]
+    pub normalize: NormalizeMode,
+
+    /// Maximum number of mismatched tokens tolerated inside a clone (default: 0)
+    #[serde(default)]
+    pub max_gap: usize,
+
+    /// Matching strategy: "token" or "ast" (default: token)
+    #[serde(default)]
+    pub strategy: CloneStrategy,
+
+    /// Minimum subtree size for the AST strategy (default: 40)
+    #[serde(default = "default_min_nodes")]
+    pub min_nodes: usize,
+
+    /// Units of token-strategy windows: "token" or "statement" (default: token)
+    #[serde(default)]
+    pub granularity: Granularity,
+
+    /// Minimum run of statements for statement granularity (default: 5)
+    #[serde(default = "default_min_statements")]
+    pub min_statements: usize,
+
+    /// Report clone groups nested inside a larger group (default: false)
+    #[serde(default)]
+    pub keep_overlaps: bool,
+
+    /// Leave `_test.go` files out of clone detection; they are still measured (default: false)
+    #[serde(default)]
+    pub skip_tests: bool,
+
+    /// Group repeated `if err != nil { ... }` blocks, which are too short to be clones (default: false)
+    #[serde(default)]
+    pub report_errcheck: bool,
+
+    /// Report function pairs whose bodies are at least this similar, above 0 and at most 1 (default: off)
+    #[serde(default)]
+    pub similarity_threshold: Option<f64>,
+
+    /// Group copied top-level `const`, `var`, and `type` blocks, each reported whole (default: false)
+    #[serde(default)]
+    pub report_declarations: bool,
+
+    /// Group functions whose bodies match, ignoring their signatures (default: false)
+    #[serde(default)]
+    pub report_function_clones: bool,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -46,23 +164,168 @@ pub struct FileConfig {
     /// Whether to respect .gitignore (default: true)
     #[serde(default = "default_true")]
     pub respect_gitignore: bool,
+
+    /// Skip files with a `// Code generated ... DO NOT EDIT.` header (default: true)
+    #[serde(default = "default_true")]
+    pub skip_generated: bool,
+
+    /// Skip files under `vendor/` directories (default: true)
+    #[serde(default = "default_true")]
+    pub skip_vendor: bool,
+
+    /// Glob patterns for paths to exclude (default: none)
+    #[serde(default)]
+    pub exclude: Vec<String>,
+
+    /// Go build tags to satisfy in addition to the default build context (default: none)
+    #[serde(default)]
+    pub tags: Vec<String>,
+
+    /// Build as with `CGO_ENABLED=0`, skipping files that `import "C"` (default: false)
+    #[serde(default)]
+    pub skip_cgo: bool,
+}
+
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct OutputConfig {
+    /// Report format: "text", "json", "sarif", "html", "github", or "junit" (default: text)
+    #[serde(default)]
+    pub format: OutputFormat,
+}
+
+/// Report format selectable from the config file
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum OutputFormat {
+    #[default]
+    Text,
+    Json,
+    Sarif,
+    Html,
+    Github,
+    Junit,
+    Csv,
+    Markdown,
+}
+
+impl fmt::Display for OutputFormat {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            OutputFormat::Text => write!(f, "text"),
+            OutputFormat::Json => write!(f, "json"),
+            OutputFormat::Sarif => write!(f, "sarif"),
+            OutputFormat::Html => write!(f, "html"),
+            OutputFormat::Github => write!(f, "github"),
+            OutputFormat::Junit => write!(f, "junit"),
+            OutputFormat::Csv => write!(f, "csv"),
+            OutputFormat::Markdown => write!(f, "markdown"),
+        }
+    }
 }
 
 impl Default for ComplexityConfig {
     fn default() -> Self {
-        Self { warning_threshold: default_warning_threshold(), error_threshold: default_error_threshold() }
+        Self {
+            warning_threshold: default_warning_threshold(),
+            error_threshold: default_error_threshold(),
+            max_nesting: default_max_nesting(),
+            max_function_lines: None,
+            max_function_statements: None,
+            max_parameters: None,
+            severity_bands: SeverityBands::default(),
+            overrides: Vec::new(),
+        }
+    }
+}
+
+impl ComplexityConfig {
+    /// Function length limits checked by the `function-too-long` rule
+    pub fn length_limits(&self) -> LengthLimits {
+        LengthLimits::new(self.max_function_lines, self.max_function_statements)
+    }
+
+    /// Thresholds that apply to `path`, with the most specific matching override applied
+    ///
+    /// See [`resolve_override`] for how the override is chosen. The result has no overrides of
+    /// its own.
+    pub fn for_path(&self, path: &Path) -> ComplexityConfig {
+        let mut thresholds = ComplexityConfig { overrides: Vec::new(), ..self.clone() };
+
+        if let Some(entry) = resolve_override(&self.overrides, path) {
+            thresholds.warning_threshold = entry.warning_threshold.unwrap_or(self.warning_threshold);
+            thresholds.error_threshold = entry.error_threshold.unwrap_or(self.error_threshold);
+            thresholds.max_nesting = entry.max_nesting.unwrap_or(self.max_nesting);
+        }
+
+        thresholds
+    }
+}
+
+impl PathOverride {
+    /// Number of characters in the pattern that are not glob syntax
+    ///
+    /// `internal/parser/**` is more specific than `internal/**`, which is more specific than `**`.
+    pub fn specificity(&self) -> usize {
+        self.path.chars().filter(|c| !"*?[]{}!,".contains(*c)).count()
+    }
+
+    /// Whether the pattern matches `path` or any trailing part of it
+    ///
+    /// Trailing parts start after a `/`, so `internal/parser/**` matches `./internal/parser/lex.go`
+    /// and `/home/me/project/internal/parser/lex.go` alike. An invalid pattern matches nothing.
+    pub fn matches(&self, path: &Path) -> bool {
+        let Ok(glob) = Glob::new(&self.path) else { return false };
+        let matcher = glob.compile_matcher();
+        let path = path.to_string_lossy().replace('\\', "/");
+        let path = path.trim_start_matches("./");
+
+        matcher.is_match(path) || path.match_indices('/').any(|(i, _)| matcher.is_match(&path[i + 1..]))
     }
 }
 
+/// The override that applies to `path`: of those whose pattern matches, the most specific
+///
+/// Specificity is [`PathOverride::specificity`]. When two matching patterns are equally specific,
+/// the one listed last wins, as a later setting overrides an earlier one.
+pub fn resolve_override<'a>(overrides: &'a [PathOverride], path: &Path) -> Option<&'a PathOverride> {
+    overrides
+        .iter()
+        .filter(|entry| entry.matches(path))
+        .max_by_key(|entry| entry.specificity())
+}
+
 impl Default for CloneConfig {
     fn default() -> Self {
-        Self { min_tokens: default_min_tokens(), enabled: default_true() }
+        Self {
+            min_tokens: default_min_tokens(),
+            min_instances: default_min_instances(),
+            enabled: default_true(),
+            normalize: NormalizeMode::default(),
+            max_gap: 0,
+            strategy: CloneStrategy::default(),
+            min_nodes: default_min_nodes(),
+            granularity: Granularity::default(),
+            min_statements: default_min_statements(),
+            keep_overlaps: false,
+            skip_tests: false,
+            report_errcheck: false,
+            similarity_threshold: None,
+            report_declarations: false,
+            report_function_clones: false,
+        }
     }
 }
 
 impl Default for FileConfig {
     fn default() -> Self {
-        Self { respect_gitignore: default_true() }
+        Self {
+            respect_gitignore: default_true(),
+            skip_generated: default_true(),
+            skip_vendor: default_true(),
+            exclude: Vec::new(),
+            tags: Vec::new(),
+            skip_cgo: false,
+        }
     }
 }
 
@@ -74,29 +337,82 @@ fn default_error_threshold() -> usize {
     20
 }
 
+fn default_max_nesting() -> usize {
+    4
+}
+
 fn default_min_tokens() -> usize {
     30
 }
 
+fn default_min_instances() -> usize {
+    2
+}
+
+fn default_min_nodes() -> usize {
+    DEFAULT_MIN_NODES
+}
+
+fn default_min_statements() -> usize {
+    DEFAULT_MIN_STATEMENTS
+}
+
 fn default_true() -> bool {
     true
 }
 
 impl Config {
-    /// Load configuration from a TOML file
+    /// Load configuration from an explicit path, or from the default locations when `None`
+    pub fn load<P: AsRef<Path>>(path: Option<P>) -> Result<Self> {
+        match path {
+            Some(path) => Self::from_file(path),
+            None => Self::load_default(),
+        }
+    }
+
+    /// Load configuration from a TOML or YAML file, chosen by extension
     pub fn from_file<P: AsRef<Path>>(path: P) -> Result<Self> {
-        let content = fs::read_to_string(path.as_ref())
-            .map_err(|e| MccabreError::FileRead { path: path.as_ref().to_path_buf(), source: e })?;
+        let path = path.as_ref();
+        let content =
+            fs::read_to_string(path).map_err(|e| MccabreError::FileRead { path: path.to_path_buf(), source: e })?;
+
+        let config: Self = if is_yaml(path) {
+            serde_yaml::from_str(&content)
+                .map_err(|e| MccabreError::InvalidConfig(format!("{}: {e}", path.display())))?
+        } else {
+            toml::from_str(&content).map_err(|e| MccabreError::InvalidConfig(format!("{}: {e}", path.display())))?
+        };
+
+        config
+            .complexity
+            .severity_bands
+            .validate()
+            .map_err(|e| MccabreError::InvalidConfig(format!("{}: {e}", path.display())))?;
+        for entry in &config.complexity.overrides {
+            Glob::new(&entry.path).map_err(|e| {
+                MccabreError::InvalidConfig(format!(
+                    "{}: invalid override path '{}': {e}",
+                    path.display(),
+                    entry.path
+                ))
+            })?;
+        }
+        if let Some(threshold) = config.clones.similarity_threshold
+            && !(threshold > 0.0 && threshold <= 1.0)
+        {
+            return Err(MccabreError::InvalidConfig(format!(
+                "{}: similarity_threshold must be above 0 and at most 1, got {threshold}",
+                path.display()
+            )));
+        }
 
-        toml::from_str(&content).map_err(|e| MccabreError::InvalidConfig(e.to_string()))
+        Ok(config)
     }
 
     /// Try to load configuration from default locations
-    /// Looks for: mccabre.toml, .mccabre.toml, .mccabre/config.toml
+    /// Looks for the files in [`DEFAULT_CONFIG_FILES`] and uses the first one found
     pub fn load_default() -> Result<Self> {
-        let candidates = vec!["mccabre.toml", ".mccabre.toml", ".mccabre/config.toml"];
-
-        for path in candidates {
+        for path in DEFAULT_CONFIG_FILES {
             if Path::new(path).exists() {
                 return Self::from_file(path);
             }
@@ -105,9 +421,13 @@ impl Config {
         Ok(Self::default())
     }
 
-    /// Save configuration to a TOML file
+    /// Save configuration to a TOML or YAML file, chosen by extension
     pub fn save<P: AsRef<Path>>(&self, path: P) -> Result<()> {
-        let content = toml::to_string_pretty(self).map_err(|e| MccabreError::InvalidConfig(e.to_string()))?;
+        let content = if is_yaml(path.as_ref()) {
+            serde_yaml::to_string(self).map_err(|e| MccabreError::InvalidConfig(e.to_string()))?
+        } else {
+            toml::to_string_pretty(self).map_err(|e| MccabreError::InvalidConfig(e.to_string()))?
+        };
 
         fs::write(path.as_ref(), content)
             .map_err(|e| MccabreError::FileRead { path: path.as_ref().to_path_buf(), source: e })?;
@@ -133,6 +453,69 @@ impl Config {
 
         self
     }
+
+    /// Settings that are valid but likely to hide findings or to flag everything
+    ///
+    /// Each warning names the setting and what it does to a run, such as a warning threshold of
+    /// 0, which puts every function above it so `--fail-on complexity` fails on any code, or
+    /// disabled clone detection, under which `--max-clones` can never fail.
+    pub fn warnings(&self) -> Vec<String> {
+        let complexity = &self.complexity;
+        let mut warnings = Vec::new();
+
+        if complexity.warning_threshold == 0 {
+            warnings.push(
+                "complexity.warning_threshold is 0: every function is reported, and --fail-on complexity fails on any function"
+                    .to_string(),
+            );
+        }
+        if complexity.error_threshold < complexity.warning_threshold {
+            warnings.push(format!(
+                "complexity.error_threshold ({}) is below warning_threshold ({}): functions turn errors before warnings",
+                complexity.error_threshold, complexity.warning_threshold
+            ));
+        }
+        if complexity.max_nesting == 0 {
+            warnings.push("complexity.max_nesting is 0: every function with a block is flagged".to_string());
+        }
+        for (name, limit) in [
+            ("max_function_lines", complexity.max_function_lines),
+            ("max_function_statements", complexity.max_function_statements),
+            ("max_parameters", complexity.max_parameters),
+        ] {
+            if limit == Some(0) {
+                warnings.push(format!("complexity.{name} is 0: every function is flagged"));
+            }
+        }
+        for entry in &complexity.overrides {
+            if entry.warning_threshold == Some(0) {
+                warnings.push(format!(
+                    "the override for '{}' sets warning_threshold to 0: every function under it is reported",
+                    entry.path
+                ));
+            }
+        }
+
+        if !self.clones.enabled {
+            warnings.push("clones.enabled is false: no clones are reported, and --max-clones never fails".to_string());
+        } else if self.clones.min_tokens == 0 {
+            warnings.push("clones.min_tokens is 0: every repeated token is reported as a clone".to_string());
+        }
+
+        for pattern in &self.files.exclude {
+            if matches!(pattern.as_str(), "*" | "**" | "**/*") {
+                warnings.push(format!(
+                    "files.exclude has '{pattern}', which leaves no files to analyze"
+                ));
+            }
+        }
+
+        warnings
+    }
+}
+
+fn is_yaml(path: &Path) -> bool {
+    path.extension().is_some_and(|ext| ext == "yaml" || ext == "yml")
 }
 
 #[cfg(test)]
@@ -140,16 +523,44 @@ mod tests {
     use super::*;
     use tempfile::TempDir;
 
+    #[test]
+    fn test_warnings_for_settings_that_hide_or_flag_everything() {
+        assert!(Config::default().warnings().is_empty());
+
+        let mut config = Config::default();
+        config.complexity.warning_threshold = 0;
+        config.complexity.max_parameters = Some(0);
+        config.clones.enabled = false;
+        config.files.exclude.push("**".to_string());
+
+        let warnings = config.warnings();
+        assert_eq!(warnings.len(), 4);
+        assert!(warnings[0].starts_with("complexity.warning_threshold is 0"));
+        assert_eq!(warnings[1], "complexity.max_parameters is 0: every function is flagged");
+        assert!(warnings[2].ends_with("--max-clones never fails"));
+        assert_eq!(warnings[3], "files.exclude has '**', which leaves no files to analyze");
+    }
+
     #[test]
     fn test_default_config() {
         let config = Config::default();
         assert_eq!(config.complexity.warning_threshold, 10);
         assert_eq!(config.complexity.error_threshold, 20);
+        assert_eq!(config.complexity.max_nesting, 4);
         assert_eq!(config.clones.min_tokens, 30);
         assert!(config.clones.enabled);
+        assert_eq!(config.clones.normalize, NormalizeMode::Exact);
         assert!(config.files.respect_gitignore);
     }
 
+    #[test]
+    fn test_normalize_from_toml() {
+        let config: Config = toml::from_str("[clones]\nnormalize = \"renamed\"\n").unwrap();
+        assert_eq!(config.clones.normalize, NormalizeMode::Renamed);
+        assert_eq!(config.clones.min_tokens, 30);
+        assert_eq!(config.clones.max_gap, 0);
+    }
+
     #[test]
     fn test_save_and_load() {
         let temp_dir = TempDir::new().unwrap();
@@ -163,6 +574,153 @@ mod tests {
         assert_eq!(loaded.clones.min_tokens, config.clones.min_tokens);
     }
 
+    #[test]
+    fn test_load_yaml() {
+        let temp_dir = TempDir::new().unwrap();
+        let config_path = temp_dir.path().join(".mccabre.yaml");
+        fs::write(
+            &config_path,
+            r#"
+complexity:
+  warning_threshold: 8
+  max_nesting: 3
+clones:
+  normalize: renamed
+  min_tokens: 40
+files:
+  exclude:
+    - "*.pb.go"
+    - testdata/**
+output:
+  format: sarif
+"#,
+        )
+        .unwrap();
+
+        let config = Config::load(Some(&config_path)).unwrap();
+        assert_eq!(config.complexity.warning_threshold, 8);
+        assert_eq!(config.complexity.error_threshold, 20);
+        assert_eq!(config.complexity.max_nesting, 3);
+        assert_eq!(config.clones.normalize, NormalizeMode::Renamed);
+        assert_eq!(config.clones.min_tokens, 40);
+        assert_eq!(config.files.exclude, vec!["*.pb.go", "testdata/**"]);
+        assert_eq!(config.output.format, OutputFormat::Sarif);
+    }
+
+    #[test]
+    fn test_yaml_round_trip() {
+        let temp_dir = TempDir::new().unwrap();
+        let config_path = temp_dir.path().join("mccabre.yml");
+
+        let mut config = Config::default();
+        config.output.format = OutputFormat::Json;
+        config.files.exclude.push("vendor/**".to_string());
+        config.save(&config_path).unwrap();
+
+        let loaded = Config::from_file(&config_path).unwrap();
+        assert_eq!(loaded.output.format, OutputFormat::Json);
+        assert_eq!(loaded.files.exclude, vec!["vendor/**"]);
+    }
+
+    #[test]
+    fn test_invalid_config_names_the_file() {
+        let temp_dir = TempDir::new().unwrap();
+        let config_path = temp_dir.path().join("mccabre.toml");
+        fs::write(&config_path, "[output]\nformat = \"pdf\"\n").unwrap();
+
+        let err = Config::from_file(&config_path).unwrap_err();
+        assert!(matches!(err, MccabreError::InvalidConfig(_)));
+        assert!(err.to_string().contains("mccabre.toml"));
+    }
+
+    #[test]
+    fn test_severity_bands() {
+        let temp_dir = TempDir::new().unwrap();
+        let config_path = temp_dir.path().join("mccabre.toml");
+        fs::write(&config_path, "[complexity.severityBands]\nmedium = 6\nhigh = 15\n").unwrap();
+
+        let bands = Config::from_file(&config_path).unwrap().complexity.severity_bands;
+        assert_eq!(bands, SeverityBands { moderate: 6, high: 15, very_high: 51 });
+
+        fs::write(&config_path, "[complexity.severity_bands]\nhigh = 60\n").unwrap();
+        let err = Config::from_file(&config_path).unwrap_err();
+        assert!(err.to_string().contains("severity bands must increase"));
+    }
+
+    #[test]
+    fn test_resolve_override_precedence() {
+        let entry = |path: &str, threshold: usize| PathOverride {
+            path: path.to_string(),
+            warning_threshold: Some(threshold),
+            ..PathOverride::default()
+        };
+        let overrides = vec![
+            entry("internal/**", 15),
+            entry("internal/parser/**", 25),
+            entry("**/*_test.go", 30),
+            entry("internal/*/**", 20),
+        ];
+        let resolved = |path: &str| resolve_override(&overrides, Path::new(path)).map(|o| o.path.as_str());
+
+        assert_eq!(resolved("internal/parser/lex.go"), Some("internal/parser/**"));
+        assert_eq!(resolved("./internal/parser/lex_test.go"), Some("internal/parser/**"));
+        assert_eq!(
+            resolved("/src/project/internal/parser/lex.go"),
+            Some("internal/parser/**")
+        );
+        assert_eq!(resolved("internal/handlers/user.go"), Some("internal/*/**"));
+        assert_eq!(resolved("internal/util.go"), Some("internal/**"));
+        assert_eq!(resolved("cmd/main_test.go"), Some("**/*_test.go"));
+        assert_eq!(resolved("cmd/main.go"), None);
+
+        let tied = vec![entry("a/*.go", 1), entry("a/?.go", 2)];
+        assert_eq!(
+            resolve_override(&tied, Path::new("a/b.go")).unwrap().warning_threshold,
+            Some(2)
+        );
+    }
+
+    #[test]
+    fn test_overrides_apply_per_path() {
+        let temp_dir = TempDir::new().unwrap();
+        let config_path = temp_dir.path().join(".mccabre.yaml");
+        fs::write(
+            &config_path,
+            r#"
+complexity:
+  warning_threshold: 15
+  max_nesting: 3
+  overrides:
+    - path: "internal/parser/**"
+      maxComplexity: 25
+      max_nesting: 5
+    - path: "internal/handlers/**"
+      warning_threshold: 8
+"#,
+        )
+        .unwrap();
+
+        let complexity = Config::from_file(&config_path).unwrap().complexity;
+        let parser = complexity.for_path(Path::new("internal/parser/expr.go"));
+        assert_eq!(
+            (parser.warning_threshold, parser.error_threshold, parser.max_nesting),
+            (25, 20, 5)
+        );
+        assert!(parser.overrides.is_empty());
+        let handlers = complexity.for_path(Path::new("internal/handlers/user.go"));
+        assert_eq!((handlers.warning_threshold, handlers.max_nesting), (8, 3));
+        assert_eq!(complexity.for_path(Path::new("main.go")).warning_threshold, 15);
+
+        let toml_path = temp_dir.path().join("mccabre.toml");
+        fs::write(
+            &toml_path,
+            "[[complexity.overrides]]\npath = \"src/[oops\"\nwarning_threshold = 5\n",
+        )
+        .unwrap();
+        let err = Config::from_file(&toml_path).unwrap_err();
+        assert!(err.to_string().contains("invalid override path 'src/[oops'"));
+    }
+
     #[test]
     fn test_merge_with_cli() {
         let mut config = Config::default();
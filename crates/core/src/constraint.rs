@@ -0,0 +1,474 @@
+use crate::tokenizer::{Language, TokenType, Tokenizer};
+use std::collections::BTreeSet;
+use std::path::Path;
+
+/// Operating systems known to the Go toolchain, as used in `_GOOS.go` file name suffixes
+const KNOWN_OS: &[&str] = &[
+    "aix",
+    "android",
+    "darwin",
+    "dragonfly",
+    "freebsd",
+    "hurd",
+    "illumos",
+    "ios",
+    "js",
+    "linux",
+    "nacl",
+    "netbsd",
+    "openbsd",
+    "plan9",
+    "solaris",
+    "wasip1",
+    "windows",
+    "zos",
+];
+
+/// Operating systems that satisfy the `unix` constraint
+const UNIX_OS: &[&str] = &[
+    "aix",
+    "android",
+    "darwin",
+    "dragonfly",
+    "freebsd",
+    "hurd",
+    "illumos",
+    "ios",
+    "linux",
+    "netbsd",
+    "openbsd",
+    "solaris",
+];
+
+/// Architectures known to the Go toolchain, as used in `_GOARCH.go` file name suffixes
+const KNOWN_ARCH: &[&str] = &[
+    "386",
+    "amd64",
+    "amd64p32",
+    "arm",
+    "armbe",
+    "arm64",
+    "arm64be",
+    "loong64",
+    "mips",
+    "mipsle",
+    "mips64",
+    "mips64le",
+    "mips64p32",
+    "mips64p32le",
+    "ppc",
+    "ppc64",
+    "ppc64le",
+    "riscv",
+    "riscv64",
+    "s390",
+    "s390x",
+    "sparc",
+    "sparc64",
+    "wasm",
+];
+
+/// The set of Go build constraints a file must satisfy to be analyzed
+///
+/// Mirrors `go/build`'s default context: the target `GOOS` and `GOARCH` (from the environment,
+/// or the host), `unix` on Unix systems, the `gc` compiler, `cgo` unless `CGO_ENABLED=0`, every
+/// `go1.N` release tag, and any extra tags passed with [`BuildContext::with_tags`].
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct BuildContext {
+    goos: String,
+    goarch: String,
+    cgo: bool,
+    tags: BTreeSet<String>,
+}
+
+impl Default for BuildContext {
+    fn default() -> Self {
+        let goos = std::env::var("GOOS").unwrap_or_else(|_| host_os().to_string());
+        let goarch = std::env::var("GOARCH").unwrap_or_else(|_| host_arch().to_string());
+        let cgo = std::env::var("CGO_ENABLED").map_or(true, |value| value != "0");
+
+        Self { goos, goarch, cgo, tags: BTreeSet::new() }
+    }
+}
+
+impl BuildContext {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Build for a specific target instead of the host
+    pub fn with_target(mut self, goos: &str, goarch: &str) -> Self {
+        self.goos = goos.to_string();
+        self.goarch = goarch.to_string();
+        self
+    }
+
+    /// Enable or disable the `cgo` constraint
+    pub fn with_cgo(mut self, cgo: bool) -> Self {
+        self.cgo = cgo;
+        self
+    }
+
+    /// Satisfy these build tags in addition to the default ones, as with `go build -tags`
+    pub fn with_tags<S: AsRef<str>>(mut self, tags: &[S]) -> Self {
+        self.tags.extend(
+            tags.iter()
+                .map(|tag| tag.as_ref().trim().to_string())
+                .filter(|tag| !tag.is_empty()),
+        );
+        self
+    }
+
+    /// Target operating system, as `GOOS`
+    pub fn goos(&self) -> &str {
+        &self.goos
+    }
+
+    /// Target architecture, as `GOARCH`
+    pub fn goarch(&self) -> &str {
+        &self.goarch
+    }
+
+    /// Whether the `cgo` constraint holds
+    pub fn cgo(&self) -> bool {
+        self.cgo
+    }
+
+    /// Extra tags passed with [`BuildContext::with_tags`], sorted
+    pub fn tags(&self) -> impl Iterator<Item = &str> {
+        self.tags.iter().map(String::as_str)
+    }
+
+    /// Whether a Go file belongs in this build
+    ///
+    /// Checks the `_GOOS`, `_GOARCH`, and `_GOOS_GOARCH` file name suffixes, then the
+    /// `//go:build` line in the file header, falling back to legacy `// +build` lines when it
+    /// has none. Files whose name starts with `_` or `.` are ignored, as by the go tool, and so
+    /// are cgo files, which `import "C"`, when `cgo` is disabled. A constraint that cannot be
+    /// parsed does not exclude the file.
+    pub fn matches(&self, path: &Path, content: &str) -> bool {
+        self.matches_file_name(path) && self.matches_header(content) && (self.cgo || !imports_c(content))
+    }
+
+    /// Whether a single build tag is satisfied
+    pub fn has_tag(&self, tag: &str) -> bool {
+        tag == self.goos
+            || tag == self.goarch
+            || (tag == "linux" && self.goos == "android")
+            || (tag == "solaris" && self.goos == "illumos")
+            || (tag == "darwin" && self.goos == "ios")
+            || (tag == "unix" && UNIX_OS.contains(&self.goos.as_str()))
+            || tag == "gc"
+            || (tag == "cgo" && self.cgo)
+            || is_release_tag(tag)
+            || self.tags.contains(tag)
+    }
+
+    fn matches_file_name(&self, path: &Path) -> bool {
+        let Some(name) = path.file_name().and_then(|name| name.to_str()) else { return true };
+        if name.starts_with('_') || name.starts_with('.') {
+            return false;
+        }
+
+        let stem = name.split_once('.').map_or(name, |(stem, _)| stem);
+        let stem = stem.strip_suffix("_test").unwrap_or(stem);
+        let parts: Vec<&str> = stem.split('_').skip(1).collect();
+
+        match parts.as_slice() {
+            [.., os, arch] if KNOWN_OS.contains(os) && KNOWN_ARCH.contains(arch) => {
+                self.has_tag(os) && self.has_tag(arch)
+            }
+            [.., last] if KNOWN_OS.contains(last) || KNOWN_ARCH.contains(last) => self.has_tag(last),
+            _ => true,
+        }
+    }
+
+    fn matches_header(&self, content: &str) -> bool {
+        let mut go_build = None;
+        let mut plus_build = Vec::new();
+        let mut in_block = false;
+
+        for line in content.lines() {
+            let line = line.trim();
+            if in_block {
+                in_block = !line.contains("*/");
+                continue;
+            }
+
+            if let Some(comment) = line.strip_prefix("//") {
+                if let Some(expr) = comment.strip_prefix("go:build") {
+                    go_build.get_or_insert(expr.trim().to_string());
+                } else if let Some(expr) = comment.trim_start().strip_prefix("+build") {
+                    plus_build.push(expr.trim().to_string());
+                }
+            } else if line.starts_with("/*") {
+                in_block = !line.contains("*/");
+            } else if !line.is_empty() {
+                break;
+            }
+        }
+
+        match go_build {
+            Some(expr) => Expr::parse(&expr).is_none_or(|expr| expr.eval(&|tag| self.has_tag(tag))),
+            None => plus_build.iter().all(|line| self.matches_plus_build(line)),
+        }
+    }
+
+    /// `// +build a,b c`: space-separated options, any of which may hold; commas join terms
+    fn matches_plus_build(&self, line: &str) -> bool {
+        line.split_whitespace().any(|option| {
+            option.split(',').all(|term| match term.strip_prefix('!') {
+                Some(tag) => !self.has_tag(tag),
+                None => self.has_tag(term),
+            })
+        })
+    }
+}
+
+/// `go1.N` tags, all of which are satisfied by a current toolchain
+/// Whether Go source imports the `C` pseudo-package, which makes it a cgo file
+pub fn imports_c(content: &str) -> bool {
+    if !content.contains("\"C\"") {
+        return false;
+    }
+    let Ok(tokens) = Tokenizer::new(content, Language::Go).tokenize() else {
+        return false;
+    };
+    let mut code = tokens.iter().filter(|t| t.token_type.is_significant()).peekable();
+
+    while let Some(token) = code.next() {
+        match token.text.as_str() {
+            "import" => {
+                if code.peek().is_some_and(|t| t.token_type == TokenType::LeftParen) {
+                    for token in code.by_ref() {
+                        match &token.token_type {
+                            TokenType::RightParen => break,
+                            TokenType::Literal(text) if text == "\"C\"" => return true,
+                            _ => {}
+                        }
+                    }
+                } else if code.next().is_some_and(|t| t.text == "\"C\"") {
+                    return true;
+                }
+            }
+            // Imports come before every other declaration
+            "func" | "type" | "var" | "const" => return false,
+            _ => {}
+        }
+    }
+
+    false
+}
+
+fn is_release_tag(tag: &str) -> bool {
+    tag.strip_prefix("go1.")
+        .is_some_and(|minor| !minor.is_empty() && minor.bytes().all(|b| b.is_ascii_digit()))
+}
+
+fn host_os() -> &'static str {
+    match std::env::consts::OS {
+        "macos" => "darwin",
+        os => os,
+    }
+}
+
+fn host_arch() -> &'static str {
+    match std::env::consts::ARCH {
+        "x86" => "386",
+        "x86_64" => "amd64",
+        "aarch64" => "arm64",
+        "loongarch64" => "loong64",
+        "powerpc" => "ppc",
+        "powerpc64" => "ppc64",
+        "wasm32" => "wasm",
+        arch => arch,
+    }
+}
+
+/// A parsed `//go:build` expression
+#[derive(Debug, Clone, PartialEq, Eq)]
+enum Expr {
+    Tag(String),
+    Not(Box<Expr>),
+    And(Box<Expr>, Box<Expr>),
+    Or(Box<Expr>, Box<Expr>),
+}
+
+impl Expr {
+    /// Parse `linux && (amd64 || arm64) && !integration`, or `None` if it is malformed
+    fn parse(text: &str) -> Option<Self> {
+        let tokens = lex(text)?;
+        let mut parser = Parser { tokens: &tokens, pos: 0 };
+        let expr = parser.or()?;
+        (parser.pos == tokens.len()).then_some(expr)
+    }
+
+    fn eval(&self, has_tag: &dyn Fn(&str) -> bool) -> bool {
+        match self {
+            Expr::Tag(tag) => has_tag(tag),
+            Expr::Not(inner) => !inner.eval(has_tag),
+            Expr::And(a, b) => a.eval(has_tag) && b.eval(has_tag),
+            Expr::Or(a, b) => a.eval(has_tag) || b.eval(has_tag),
+        }
+    }
+}
+
+fn lex(text: &str) -> Option<Vec<String>> {
+    let mut tokens = Vec::new();
+    let mut chars = text.chars().peekable();
+
+    while let Some(c) = chars.next() {
+        match c {
+            ' ' | '\t' => {}
+            '(' | ')' | '!' => tokens.push(c.to_string()),
+            '&' | '|' => {
+                if chars.next() != Some(c) {
+                    return None;
+                }
+                tokens.push(format!("{c}{c}"));
+            }
+            c if c.is_alphanumeric() || c == '_' || c == '.' => {
+                let mut tag = c.to_string();
+                while let Some(&next) = chars.peek() {
+                    if !(next.is_alphanumeric() || next == '_' || next == '.') {
+                        break;
+                    }
+                    tag.push(next);
+                    chars.next();
+                }
+                tokens.push(tag);
+            }
+            _ => return None,
+        }
+    }
+
+    Some(tokens)
+}
+
+struct Parser<'a> {
+    tokens: &'a [String],
+    pos: usize,
+}
+
+impl Parser<'_> {
+    fn peek(&self) -> Option<&str> {
+        self.tokens.get(self.pos).map(String::as_str)
+    }
+
+    fn or(&mut self) -> Option<Expr> {
+        let mut expr = self.and()?;
+        while self.peek() == Some("||") {
+            self.pos += 1;
+            expr = Expr::Or(Box::new(expr), Box::new(self.and()?));
+        }
+        Some(expr)
+    }
+
+    fn and(&mut self) -> Option<Expr> {
+        let mut expr = self.not()?;
+        while self.peek() == Some("&&") {
+            self.pos += 1;
+            expr = Expr::And(Box::new(expr), Box::new(self.not()?));
+        }
+        Some(expr)
+    }
+
+    fn not(&mut self) -> Option<Expr> {
+        let token = self.peek()?.to_string();
+        self.pos += 1;
+
+        match token.as_str() {
+            "!" => Some(Expr::Not(Box::new(self.not()?))),
+            "(" => {
+                let expr = self.or()?;
+                (self.peek() == Some(")")).then(|| {
+                    self.pos += 1;
+                    expr
+                })
+            }
+            ")" | "&&" | "||" => None,
+            _ => Some(Expr::Tag(token)),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn linux() -> BuildContext {
+        BuildContext::new().with_target("linux", "amd64").with_cgo(true)
+    }
+
+    #[test]
+    fn test_go_build_expressions() {
+        let context = linux().with_tags(&["integration"]);
+        let matches = |expr: &str| context.matches(Path::new("a.go"), &format!("//go:build {expr}\n\npackage a\n"));
+
+        assert!(matches("linux"));
+        assert!(matches("unix && !windows"));
+        assert!(matches("(darwin || linux) && amd64"));
+        assert!(matches("integration && go1.21"));
+        assert!(!matches("windows"));
+        assert!(!matches("linux && !cgo"));
+        assert!(!matches("e2e"));
+        assert!(!linux().matches(Path::new("a.go"), "//go:build integration\n\npackage a\n"));
+    }
+
+    #[test]
+    fn test_legacy_plus_build_lines() {
+        let context = linux();
+
+        assert!(context.matches(Path::new("a.go"), "// +build darwin linux,amd64\n\npackage a\n"));
+        assert!(!context.matches(Path::new("a.go"), "// +build linux\n// +build 386\n\npackage a\n"));
+        // `//go:build` wins over `// +build`
+        assert!(context.matches(Path::new("a.go"), "//go:build linux\n// +build windows\n\npackage a\n"));
+    }
+
+    #[test]
+    fn test_constraints_stop_at_package_clause() {
+        let source = "// Copyright 2024\n\n/* license\n   text */\npackage a\n\n//go:build windows\n";
+
+        assert!(linux().matches(Path::new("a.go"), source));
+        assert!(!linux().matches(Path::new("a.go"), "/* header */\n//go:build windows\npackage a\n"));
+    }
+
+    #[test]
+    fn test_malformed_constraint_keeps_file() {
+        assert!(linux().matches(Path::new("a.go"), "//go:build linux &&\n\npackage a\n"));
+        assert!(linux().matches(Path::new("a.go"), "//go:build (linux\n\npackage a\n"));
+    }
+
+    #[test]
+    fn test_cgo_files_need_cgo() {
+        let single = "package a\n\n// #include <stdio.h>\nimport \"C\"\n\nfunc f() {}\n";
+        let grouped = "package a\n\nimport (\n\t\"fmt\"\n\t\"C\"\n)\n";
+        let string = "package a\n\nimport \"fmt\"\n\nvar s = \"C\"\n";
+        let no_cgo = linux().with_cgo(false);
+
+        assert!(linux().matches(Path::new("a.go"), single));
+        assert!(!no_cgo.matches(Path::new("a.go"), single));
+        assert!(!no_cgo.matches(Path::new("a.go"), grouped));
+        assert!(no_cgo.matches(Path::new("a.go"), string));
+    }
+
+    #[test]
+    fn test_file_name_suffixes() {
+        let context = linux();
+        let matches = |name: &str| context.matches(Path::new(name), "package a\n");
+
+        assert!(matches("net_linux.go"));
+        assert!(matches("net_linux_amd64_test.go"));
+        assert!(matches("net.go"));
+        assert!(matches("linux.go"));
+        assert!(!matches("net_windows.go"));
+        assert!(!matches("net_linux_arm64.go"));
+        assert!(!matches("net_386_test.go"));
+        assert!(!matches("_scratch.go"));
+        assert!(
+            BuildContext::new()
+                .with_target("android", "arm64")
+                .matches(Path::new("net_linux.go"), "")
+        );
+    }
+}
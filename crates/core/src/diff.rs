@@ -0,0 +1,350 @@
+use crate::loader::SourceFile;
+use crate::reporter::Report;
+use crate::{MccabreError, Result};
+use std::collections::BTreeSet;
+use std::fs;
+use std::path::{Path, PathBuf};
+use std::process::Command;
+use std::time::{Duration, SystemTime, UNIX_EPOCH};
+
+/// Files changed since a git revision, used to limit a report to what a branch touched
+///
+/// Paths are kept absolute and canonical, so they match loaded files however the targets
+/// were spelled on the command line.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct ChangedFiles {
+    paths: BTreeSet<PathBuf>,
+}
+
+impl ChangedFiles {
+    /// Files that differ between `rev` and the working tree of the repository holding `dir`
+    ///
+    /// Runs `git diff --name-only --diff-filter=d`, so deleted files are left out and a renamed
+    /// file is listed under its new name. `rev` is passed through as is: `origin/main` compares
+    /// against that commit, `origin/main...` against the merge base. Returns `None` when `dir`
+    /// is not inside a git work tree or git is not installed.
+    pub fn since(rev: &str, dir: &Path) -> Result<Option<Self>> {
+        let Ok(toplevel) = git(dir, &["rev-parse", "--show-toplevel"]) else {
+            return Ok(None);
+        };
+        let root = PathBuf::from(toplevel.trim_end());
+
+        let names = git(dir, &["diff", "--name-only", "-z", "--diff-filter=d", rev, "--"])?;
+        Ok(Some(Self::from_names(
+            &root,
+            names.split('\0').filter(|name| !name.is_empty()),
+        )))
+    }
+
+    /// Files of `files` last modified after `time`, for incremental runs without git
+    ///
+    /// Read from each file's modification time on disk, so files that cannot be stat'ed, such as
+    /// stdin input, are left out.
+    pub fn modified_since(files: &[SourceFile], time: SystemTime) -> Self {
+        let paths = files
+            .iter()
+            .filter(|file| {
+                fs::metadata(&file.path)
+                    .and_then(|meta| meta.modified())
+                    .is_ok_and(|modified| modified > time)
+            })
+            .map(|file| canonical(&file.path))
+            .collect();
+        Self { paths }
+    }
+
+    /// Set built from paths relative to a repository root
+    pub fn from_names<'a>(root: &Path, names: impl IntoIterator<Item = &'a str>) -> Self {
+        let paths = names.into_iter().map(|name| canonical(&root.join(name))).collect();
+        Self { paths }
+    }
+
+    pub fn len(&self) -> usize {
+        self.paths.len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.paths.is_empty()
+    }
+
+    pub fn contains(&self, path: &Path) -> bool {
+        self.paths.contains(&canonical(path))
+    }
+
+    /// Keep only what concerns changed files
+    ///
+    /// File reports and parse errors of unchanged files are dropped. A clone group stays, with
+    /// all of its instances, when any instance is in a changed file, so code copied from or into
+    /// a changed file is reported wherever the other copy lives; error-handling clusters and
+    /// similar function pairs are kept the same way.
+    pub fn filter(&self, report: Report) -> Report {
+        let files = report.files.into_iter().filter(|f| self.contains(&f.path)).collect();
+        let clones = report
+            .clones
+            .into_iter()
+            .filter(|clone| clone.locations.iter().any(|loc| self.contains(&loc.file)))
+            .collect();
+
+        let mut filtered = Report::new(files, clones);
+        filtered.suppressed = report.suppressed;
+        filtered.parse_errors = report
+            .parse_errors
+            .into_iter()
+            .filter(|e| self.contains(&e.file))
+            .collect();
+        filtered.errcheck_clusters = report
+            .errcheck_clusters
+            .into_iter()
+            .filter(|cluster| cluster.instances.iter().any(|loc| self.contains(&loc.file)))
+            .collect();
+        filtered.similar_functions = report
+            .similar_functions
+            .into_iter()
+            .filter(|pair| pair.functions.iter().any(|f| self.contains(&f.location.file)))
+            .collect();
+        filtered.duplicate_declarations = report
+            .duplicate_declarations
+            .into_iter()
+            .filter(|duplicate| duplicate.instances.iter().any(|loc| self.contains(&loc.file)))
+            .collect();
+        filtered.function_clones = report
+            .function_clones
+            .into_iter()
+            .filter(|clone| clone.functions.iter().any(|f| self.contains(&f.location.file)))
+            .collect();
+        filtered
+    }
+}
+
+/// Parse a `--since` value: a UTC date or time such as `2024-01-01` or `2024-01-01T09:30`, or a
+/// duration before `now` such as `24h`, `7d`, or `1h30m`
+///
+/// Durations are written as numbers of `s`, `m`, `h`, `d`, or `w` units.
+pub fn parse_since(value: &str, now: SystemTime) -> std::result::Result<SystemTime, String> {
+    let invalid = || format!("invalid time '{value}': use a date such as 2024-01-01 or a duration such as 24h");
+
+    if value.as_bytes().get(4) == Some(&b'-') {
+        let (date, time) = value.split_once(['T', ' ']).unwrap_or((value, "00:00"));
+        let numbers = |text: &str, sep: char| -> Option<Vec<u64>> { text.split(sep).map(|n| n.parse().ok()).collect() };
+        let (Some(date), Some(time)) = (numbers(date, '-'), numbers(time, ':')) else {
+            return Err(invalid());
+        };
+        let (&[year, month, day], &[hour, minute, ref second @ ..]) = (date.as_slice(), time.as_slice()) else {
+            return Err(invalid());
+        };
+        let second = match second {
+            [] => 0,
+            [second] => *second,
+            _ => return Err(invalid()),
+        };
+        if !(1970..=9999).contains(&year)
+            || !(1..=12).contains(&month)
+            || !(1..=31).contains(&day)
+            || hour > 23
+            || minute > 59
+            || second > 59
+        {
+            return Err(invalid());
+        }
+
+        let days = days_from_civil(year, month, day);
+        return Ok(UNIX_EPOCH + Duration::from_secs(days * 86_400 + hour * 3_600 + minute * 60 + second));
+    }
+
+    if value.is_empty() {
+        return Err(invalid());
+    }
+    let mut total = 0u64;
+    let mut rest = value;
+    while !rest.is_empty() {
+        let digits = rest.find(|c: char| !c.is_ascii_digit()).ok_or_else(invalid)?;
+        let count: u64 = rest[..digits].parse().map_err(|_| invalid())?;
+        let unit = match rest[digits..].chars().next() {
+            Some('s') => 1,
+            Some('m') => 60,
+            Some('h') => 3_600,
+            Some('d') => 86_400,
+            Some('w') => 604_800,
+            _ => return Err(invalid()),
+        };
+        total = count
+            .checked_mul(unit)
+            .and_then(|secs| total.checked_add(secs))
+            .ok_or_else(invalid)?;
+        rest = &rest[digits + 1..];
+    }
+
+    now.checked_sub(Duration::from_secs(total)).ok_or_else(invalid)
+}
+
+/// Days from 1970-01-01 to a date in the proleptic Gregorian calendar
+fn days_from_civil(year: u64, month: u64, day: u64) -> u64 {
+    let year = if month <= 2 { year - 1 } else { year };
+    let era = year / 400;
+    let year_of_era = year % 400;
+    let day_of_year = (153 * ((month + 9) % 12) + 2) / 5 + day - 1;
+    let day_of_era = year_of_era * 365 + year_of_era / 4 - year_of_era / 100 + day_of_year;
+    era * 146_097 + day_of_era - 719_468
+}
+
+/// Standard output of a git command run in `dir`
+fn git(dir: &Path, args: &[&str]) -> Result<String> {
+    let output = Command::new("git").arg("-C").arg(dir).args(args).output()?;
+    if !output.status.success() {
+        let message = String::from_utf8_lossy(&output.stderr);
+        return Err(MccabreError::Git(format!("git {}: {}", args.join(" "), message.trim())));
+    }
+
+    Ok(String::from_utf8_lossy(&output.stdout).into_owned())
+}
+
+fn canonical(path: &Path) -> PathBuf {
+    fs::canonicalize(path).unwrap_or_else(|_| path.to_path_buf())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::{Clone, CloneLocation};
+    use crate::reporter::FileReport;
+    use crate::tokenizer::Language;
+    use tempfile::TempDir;
+
+    fn run(dir: &Path, args: &[&str]) {
+        let status = Command::new("git")
+            .arg("-C")
+            .arg(dir)
+            .args(["-c", "user.name=test", "-c", "user.email=test@example.com"])
+            .args(args)
+            .output()
+            .unwrap()
+            .status;
+        assert!(status.success(), "git {args:?}");
+    }
+
+    #[test]
+    fn test_changed_files_since_revision() {
+        if Command::new("git").arg("--version").output().is_err() {
+            return;
+        }
+        let temp = TempDir::new().unwrap();
+        let dir = temp.path();
+        for name in ["keep.go", "edit.go", "old.go", "gone.go"] {
+            fs::write(dir.join(name), format!("package main // {name}\n")).unwrap();
+        }
+        run(dir, &["init", "-q"]);
+        run(dir, &["add", "."]);
+        run(dir, &["commit", "-q", "-m", "base"]);
+
+        fs::write(dir.join("edit.go"), "package main\n\nfunc f() {}\n").unwrap();
+        run(dir, &["mv", "old.go", "new.go"]);
+        run(dir, &["rm", "-q", "gone.go"]);
+
+        let changed = ChangedFiles::since("HEAD", dir).unwrap().unwrap();
+        assert_eq!(changed.len(), 2);
+        assert!(changed.contains(&dir.join("edit.go")));
+        assert!(changed.contains(&dir.join("new.go")));
+        assert!(!changed.contains(&dir.join("keep.go")));
+
+        assert!(matches!(
+            ChangedFiles::since("no-such-rev", dir),
+            Err(MccabreError::Git(_))
+        ));
+    }
+
+    #[test]
+    fn test_outside_a_repository() {
+        let temp = TempDir::new().unwrap();
+        assert_eq!(ChangedFiles::since("HEAD", temp.path()).unwrap(), None);
+    }
+
+    #[test]
+    fn test_modified_since() {
+        let temp = TempDir::new().unwrap();
+        let files: Vec<SourceFile> = ["old.go", "new.go"]
+            .iter()
+            .map(|name| {
+                let path = temp.path().join(name);
+                fs::write(&path, "package main\n").unwrap();
+                SourceFile::new(path, "package main\n").unwrap()
+            })
+            .collect();
+        let hour_ago = SystemTime::now() - Duration::from_secs(3_600);
+        fs::File::options()
+            .write(true)
+            .open(temp.path().join("old.go"))
+            .unwrap()
+            .set_modified(hour_ago - Duration::from_secs(60))
+            .unwrap();
+
+        let changed = ChangedFiles::modified_since(&files, hour_ago);
+        assert_eq!(changed.len(), 1);
+        assert!(changed.contains(&temp.path().join("new.go")));
+        assert!(ChangedFiles::modified_since(&files, SystemTime::now() + Duration::from_secs(60)).is_empty());
+    }
+
+    #[test]
+    fn test_parse_since() {
+        let now = UNIX_EPOCH + Duration::from_secs(1_700_000_000);
+        let ago = |secs| Ok(now - Duration::from_secs(secs));
+
+        assert_eq!(
+            parse_since("2024-01-01", now),
+            Ok(UNIX_EPOCH + Duration::from_secs(1_704_067_200))
+        );
+        assert_eq!(
+            parse_since("2000-02-29T12:30", now),
+            Ok(UNIX_EPOCH + Duration::from_secs(951_827_400))
+        );
+        assert_eq!(
+            parse_since("1970-01-01 00:00:05", now),
+            Ok(UNIX_EPOCH + Duration::from_secs(5))
+        );
+        assert_eq!(parse_since("24h", now), ago(86_400));
+        assert_eq!(parse_since("1h30m", now), ago(5_400));
+        assert_eq!(parse_since("2w", now), ago(1_209_600));
+
+        for invalid in [
+            "",
+            "24",
+            "h",
+            "3y",
+            "2024-13-01",
+            "2024-01",
+            "2024-01-01T25:00",
+            "yesterday",
+        ] {
+            assert!(parse_since(invalid, now).is_err(), "{invalid}");
+        }
+    }
+
+    #[test]
+    fn test_filter_keeps_clone_partners() {
+        let file = |name: &str| FileReport::from_source(PathBuf::from(name), "fn f() {}", Language::Rust).unwrap();
+        let clone = |id, files: &[&str]| Clone {
+            id,
+            length: 30,
+            locations: files
+                .iter()
+                .map(|f| CloneLocation { file: PathBuf::from(f), ..Default::default() })
+                .collect(),
+            hash: id as u64,
+            group_id: String::new(),
+        };
+        let report = Report::new(
+            vec![file("/repo/a.rs"), file("/repo/b.rs"), file("/repo/c.rs")],
+            vec![
+                clone(1, &["/repo/a.rs", "/repo/c.rs"]),
+                clone(2, &["/repo/b.rs", "/repo/c.rs"]),
+            ],
+        );
+
+        let changed = ChangedFiles::from_names(Path::new("/repo"), ["a.rs"]);
+        let filtered = changed.filter(report);
+        assert_eq!(filtered.files.len(), 1);
+        assert_eq!(filtered.files[0].path, PathBuf::from("/repo/a.rs"));
+        assert_eq!(filtered.clones.len(), 1);
+        assert_eq!(filtered.clones[0].locations[1].file, PathBuf::from("/repo/c.rs"));
+        assert_eq!(filtered.summary.total_files, 1);
+    }
+}
@@ -7,12 +7,36 @@ pub enum MccabreError {
     #[error("Failed to read file {path}: {source}")]
     FileRead { path: PathBuf, source: io::Error },
 
+    #[error("No supported source files match '{0}'")]
+    NoMatch(String),
+
     #[error("Unsupported file type: {0}")]
     UnsupportedFileType(String),
 
     #[error("Invalid configuration: {0}")]
     InvalidConfig(String),
 
+    #[error("Invalid baseline {path}: {message}")]
+    InvalidBaseline { path: PathBuf, message: String },
+
+    #[error("Invalid clone index {path}: {message}")]
+    InvalidIndex { path: PathBuf, message: String },
+
+    #[error("Invalid report {path}: {message}")]
+    InvalidReport { path: PathBuf, message: String },
+
+    #[error("No function named '{0}'")]
+    FunctionNotFound(String),
+
+    #[error("Function '{name}' is defined in several files; pass one of them as PATH:\n  {}", .matches.join("\n  "))]
+    AmbiguousFunction { name: String, matches: Vec<String> },
+
+    #[error("Git failed: {0}")]
+    Git(String),
+
+    #[error("Go failed: {0}")]
+    Go(String),
+
     #[error("Tokenization failed: {0}")]
     TokenizationError(String),
 
@@ -0,0 +1,263 @@
+use crate::complexity::CyclomaticMetrics;
+use crate::loader::{SourceFile, go_package_name};
+use crate::parallel::map_ordered;
+use crate::reporter::Report;
+use crate::{MccabreError, Result};
+use std::path::{Path, PathBuf};
+
+/// One function picked by name, used to narrow a report to it and its clones
+///
+/// Functions are matched by their reported name, such as `(*Server).Handle` for a Go method,
+/// optionally behind the package name as in `api.(*Server).Handle`. A bare method name such as
+/// `Handle` selects the methods of that name on every receiver in the file.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct FunctionFocus {
+    pub name: String,
+    pub file: PathBuf,
+    /// `(start line, end line)` of each matching function
+    pub spans: Vec<(usize, usize)>,
+}
+
+impl FunctionFocus {
+    /// Locate `name` among the functions of `files`
+    ///
+    /// Fails with [`MccabreError::FunctionNotFound`] when no file defines it, and with
+    /// [`MccabreError::AmbiguousFunction`], listing each definition as `file:line`, when
+    /// several files do. Files that cannot be tokenized are skipped.
+    pub fn find(files: &[SourceFile], name: &str, jobs: usize) -> Result<Self> {
+        let per_file = map_ordered(files, jobs, |file| {
+            let Ok(metrics) = CyclomaticMetrics::calculate(&file.content, file.language) else {
+                return Vec::new();
+            };
+            metrics
+                .functions
+                .iter()
+                .filter(|f| selects(name, go_package_name(&file.content), &f.name))
+                .map(|f| (f.line, f.end_line))
+                .collect::<Vec<_>>()
+        });
+
+        let mut found: Vec<(&Path, Vec<(usize, usize)>)> = files
+            .iter()
+            .zip(per_file)
+            .filter(|(_, spans)| !spans.is_empty())
+            .map(|(file, spans)| (file.path.as_path(), spans))
+            .collect();
+
+        match found.len() {
+            0 => Err(MccabreError::FunctionNotFound(name.to_string())),
+            1 => {
+                let (file, spans) = found.remove(0);
+                Ok(Self { name: name.to_string(), file: file.to_path_buf(), spans })
+            }
+            _ => Err(MccabreError::AmbiguousFunction {
+                name: name.to_string(),
+                matches: found
+                    .iter()
+                    .flat_map(|(file, spans)| spans.iter().map(move |(line, _)| format!("{}:{line}", file.display())))
+                    .collect(),
+            }),
+        }
+    }
+
+    /// Whether lines `start..=end` of `path` overlap one of the functions
+    pub fn overlaps(&self, path: &Path, start: usize, end: usize) -> bool {
+        path == self.file && self.spans.iter().any(|&(first, last)| start <= last && first <= end)
+    }
+
+    /// Keep only the function, findings inside it, and clone groups it takes part in
+    ///
+    /// The file report keeps its whole-file metrics but lists only the matching functions. A
+    /// clone group, error-handling cluster, or similar pair stays, with all of its instances,
+    /// when one instance overlaps the function.
+    pub fn filter(&self, report: Report) -> Report {
+        let files = report
+            .files
+            .into_iter()
+            .filter(|file| file.path == self.file)
+            .map(|mut file| {
+                file.cyclomatic.functions.retain(|f| {
+                    selects(&self.name, file.package.as_deref(), &f.name) && self.overlaps(&self.file, f.line, f.line)
+                });
+                file.findings.retain(|f| self.overlaps(&self.file, f.line, f.line));
+                file
+            })
+            .collect();
+        let clones = report
+            .clones
+            .into_iter()
+            .filter(|clone| {
+                clone
+                    .locations
+                    .iter()
+                    .any(|loc| self.overlaps(&loc.file, loc.start_line, loc.end_line))
+            })
+            .collect();
+
+        let mut filtered = Report::new(files, clones);
+        filtered.suppressed = report.suppressed;
+        filtered.parse_errors = report
+            .parse_errors
+            .into_iter()
+            .filter(|e| e.file == self.file)
+            .collect();
+        filtered.errcheck_clusters = report
+            .errcheck_clusters
+            .into_iter()
+            .filter(|cluster| {
+                cluster
+                    .instances
+                    .iter()
+                    .any(|loc| self.overlaps(&loc.file, loc.start_line, loc.end_line))
+            })
+            .collect();
+        filtered.similar_functions = report
+            .similar_functions
+            .into_iter()
+            .filter(|pair| {
+                pair.functions
+                    .iter()
+                    .any(|f| self.overlaps(&f.location.file, f.location.start_line, f.location.end_line))
+            })
+            .collect();
+        filtered.duplicate_declarations = report
+            .duplicate_declarations
+            .into_iter()
+            .filter(|duplicate| {
+                duplicate
+                    .instances
+                    .iter()
+                    .any(|loc| self.overlaps(&loc.file, loc.start_line, loc.end_line))
+            })
+            .collect();
+        filtered.function_clones = report
+            .function_clones
+            .into_iter()
+            .filter(|clone| {
+                clone
+                    .functions
+                    .iter()
+                    .any(|f| self.overlaps(&f.location.file, f.location.start_line, f.location.end_line))
+            })
+            .collect();
+        filtered
+    }
+}
+
+/// Whether `query` names the function reported as `name` in a file of package `package`
+fn selects(query: &str, package: Option<&str>, name: &str) -> bool {
+    let query = package
+        .and_then(|package| query.strip_prefix(package)?.strip_prefix('.'))
+        .filter(|rest| !rest.is_empty())
+        .unwrap_or(query);
+    let method = name.rsplit_once('.').map(|(_, method)| method);
+
+    query == name || method == Some(query)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::Config;
+
+    const PROCESS: &str = r#"package main
+
+func processUserInput(input string) string {
+	if input == "" {
+		return "empty"
+	}
+	for i := 0; i < len(input); i++ {
+		if input[i] == ' ' {
+			continue
+		}
+	}
+	return input
+}
+
+func other(x int) int {
+	if x > 0 {
+		return x
+	}
+	return -x
+}
+"#;
+
+    fn sources() -> Vec<SourceFile> {
+        vec![
+            SourceFile::new("a.go", PROCESS).unwrap(),
+            SourceFile::new("b.go", PROCESS.replace("processUserInput", "processOrder")).unwrap(),
+        ]
+    }
+
+    #[test]
+    fn test_find_reports_missing_and_ambiguous_names() {
+        let files = sources();
+
+        let focus = FunctionFocus::find(&files, "processUserInput", 1).unwrap();
+        assert_eq!(focus.file, PathBuf::from("a.go"));
+        assert_eq!(focus.spans, vec![(3, 13)]);
+
+        assert!(matches!(
+            FunctionFocus::find(&files, "missing", 1),
+            Err(MccabreError::FunctionNotFound(name)) if name == "missing"
+        ));
+
+        let err = FunctionFocus::find(&files, "other", 2).unwrap_err();
+        assert!(matches!(&err, MccabreError::AmbiguousFunction { matches, .. } if matches == &["a.go:15", "b.go:15"]));
+        assert!(err.to_string().ends_with("PATH:\n  a.go:15\n  b.go:15"), "{err}");
+    }
+
+    #[test]
+    fn test_find_selects_methods_by_receiver_and_package() {
+        let source = r#"package api
+
+func (s *Server) Handle() {}
+
+func (c Client) Handle() {}
+
+func Handle() {}
+"#;
+        let files = [SourceFile::new("api.go", source).unwrap()];
+        let lines = |query: &str| {
+            FunctionFocus::find(&files, query, 1)
+                .map(|focus| focus.spans.iter().map(|(line, _)| *line).collect::<Vec<_>>())
+                .unwrap_or_default()
+        };
+
+        assert_eq!(lines("(*Server).Handle"), vec![3]);
+        assert_eq!(lines("api.Client.Handle"), vec![5]);
+        assert_eq!(lines("Handle"), vec![3, 5, 7]);
+        assert_eq!(lines("api.Handle"), vec![3, 5, 7]);
+        assert!(lines("other.Handle").is_empty());
+        assert!(lines("Server.Handle").is_empty());
+    }
+
+    #[test]
+    fn test_filter_keeps_function_and_its_clones() {
+        let files = sources();
+        let mut config = Config::default();
+        config.clones.min_tokens = 15;
+        let report = crate::analyze_sources(&files, &config).unwrap();
+        assert!(!report.clones.is_empty());
+
+        let filtered = FunctionFocus::find(&files, "processUserInput", 1)
+            .unwrap()
+            .filter(report);
+        assert_eq!(filtered.files.len(), 1);
+        let names: Vec<_> = filtered.files[0]
+            .cyclomatic
+            .functions
+            .iter()
+            .map(|f| f.name.as_str())
+            .collect();
+        assert_eq!(names, vec!["processUserInput"]);
+        assert_eq!(filtered.clones.len(), 1);
+        assert_eq!(filtered.clones[0].locations.len(), 2);
+        assert!(
+            filtered.clones[0]
+                .locations
+                .iter()
+                .any(|loc| loc.file == PathBuf::from("b.go"))
+        );
+    }
+}
@@ -35,6 +35,59 @@ impl Highlighter {
 
         output
     }
+
+    /// Highlight code as HTML, one string per source line, with colors inlined as styles
+    pub fn highlight_html_lines(&self, code: &str, file_extension: &str) -> Vec<String> {
+        let syntax = self
+            .syntax_set
+            .find_syntax_by_extension(file_extension)
+            .unwrap_or_else(|| self.syntax_set.find_syntax_plain_text());
+
+        let theme = &self.theme_set.themes["base16-ocean.dark"];
+
+        let mut highlighter = HighlightLines::new(syntax, theme);
+        let mut lines = Vec::new();
+
+        for line in LinesWithEndings::from(code) {
+            let ranges = highlighter.highlight_line(line, &self.syntax_set).unwrap_or_default();
+            let mut html = String::new();
+
+            for (style, text) in ranges {
+                let text = text.trim_end_matches(['\n', '\r']);
+                if text.is_empty() {
+                    continue;
+                }
+
+                let fg = style.foreground;
+                html.push_str(&format!(
+                    "<span style=\"color:#{:02x}{:02x}{:02x}\">{}</span>",
+                    fg.r,
+                    fg.g,
+                    fg.b,
+                    escape_html(text)
+                ));
+            }
+            lines.push(html);
+        }
+
+        lines
+    }
+}
+
+/// Escape text for use in HTML element content and attribute values
+pub fn escape_html(text: &str) -> String {
+    let mut escaped = String::with_capacity(text.len());
+    for c in text.chars() {
+        match c {
+            '&' => escaped.push_str("&amp;"),
+            '<' => escaped.push_str("&lt;"),
+            '>' => escaped.push_str("&gt;"),
+            '"' => escaped.push_str("&quot;"),
+            '\'' => escaped.push_str("&#39;"),
+            _ => escaped.push(c),
+        }
+    }
+    escaped
 }
 
 impl Default for Highlighter {
@@ -181,6 +234,25 @@ mod tests {
         assert!(!highlighted.is_empty());
     }
 
+    #[test]
+    fn test_highlight_html_lines() {
+        let highlighter = Highlighter::new();
+        let lines = highlighter.highlight_html_lines("if a < b {\n    run();\n}\n", "rs");
+
+        assert_eq!(lines.len(), 3);
+        assert!(lines[0].contains("&lt;"));
+        assert!(lines[0].contains("<span style=\"color:#"));
+        assert!(!lines.iter().any(|l| l.contains('\n')));
+    }
+
+    #[test]
+    fn test_escape_html() {
+        assert_eq!(
+            escape_html(r#"<a href="x">&'</a>"#),
+            "&lt;a href=&quot;x&quot;&gt;&amp;&#39;&lt;/a&gt;"
+        );
+    }
+
     #[test]
     fn test_is_grayscale() {
         assert!(is_grayscale(Color { r: 128, g: 128, b: 128, a: 255 }));
@@ -1,12 +1,28 @@
+pub mod analyzer;
+pub mod baseline;
+pub mod cache;
 pub mod cloner;
+pub mod compare;
 pub mod complexity;
 pub mod config;
+pub mod constraint;
 pub mod coverage;
+pub mod diff;
 pub mod error;
+pub mod focus;
 pub mod highlight;
 pub mod loader;
+pub mod logging;
+pub mod parallel;
+pub mod policy;
+pub mod position;
 pub mod reporter;
+pub mod rules;
+pub mod suppress;
+pub mod syntax;
 pub mod tokenizer;
+pub mod watch;
 
+pub use analyzer::{Analyzer, analyze_sources};
 pub use error::{MccabreError, Result};
 pub use highlight::Highlighter;
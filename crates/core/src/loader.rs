@@ -1,9 +1,19 @@
+use crate::config::FileConfig;
+use crate::constraint::BuildContext;
 use crate::error::{MccabreError, Result};
+use crate::parallel::{default_jobs, map_ordered};
 use crate::tokenizer::Language;
+use globset::{Glob, GlobSet, GlobSetBuilder};
 use ignore::WalkBuilder;
+use serde::Deserialize;
+use std::io::{BufRead, BufReader, Read};
 use std::path::{Path, PathBuf};
+use std::process::Command;
 use std::{fs, io};
 
+/// Directory names whose contents are third-party code
+const VENDOR_DIRS: &[&str] = &["vendor"];
+
 /// File entry with source code and metadata
 #[derive(Debug, Clone)]
 pub struct SourceFile {
@@ -12,15 +22,301 @@ pub struct SourceFile {
     pub language: Language,
 }
 
+impl SourceFile {
+    /// Wrap source that is already in memory, picking the language from `path`'s extension
+    pub fn new<P: AsRef<Path>, S: Into<String>>(path: P, content: S) -> Result<Self> {
+        let path = path.as_ref();
+        let language = Language::from_path(path)?;
+
+        Ok(Self { path: path.to_path_buf(), content: content.into(), language })
+    }
+
+    /// Read source from a stream, such as an unsaved editor buffer piped to stdin
+    ///
+    /// `path` is only used for reporting and to pick the language from its extension; nothing
+    /// is read from disk.
+    pub fn from_reader<P: AsRef<Path>, R: Read>(path: P, mut reader: R) -> Result<Self> {
+        let path = path.as_ref();
+        let mut content = String::new();
+        reader
+            .read_to_string(&mut content)
+            .map_err(|e| MccabreError::FileRead { path: path.to_path_buf(), source: e })?;
+
+        Self::new(path, content)
+    }
+}
+
+/// Report whether a file carries the standard generated-code header
+///
+/// Follows the Go convention: a line matching `// Code generated ... DO NOT EDIT.` before
+/// the first line that is neither blank nor a comment. Tools such as `protoc-gen-go`,
+/// `stringer`, and `mockgen` emit it.
+pub fn is_generated(path: &Path) -> Result<bool> {
+    let file = fs::File::open(path).map_err(|e| MccabreError::FileRead { path: path.to_path_buf(), source: e })?;
+
+    for line in BufReader::new(file).lines() {
+        let line = line.map_err(|e| MccabreError::FileRead { path: path.to_path_buf(), source: e })?;
+        match classify_header_line(&line) {
+            HeaderLine::Generated => return Ok(true),
+            HeaderLine::Comment => continue,
+            HeaderLine::Code => return Ok(false),
+        }
+    }
+
+    Ok(false)
+}
+
+/// Report whether a path names a Go test file (`*_test.go`)
+pub fn is_test_file(path: &Path) -> bool {
+    path.file_name()
+        .and_then(|name| name.to_str())
+        .is_some_and(|name| name.ends_with("_test.go"))
+}
+
+/// Same check as [`is_generated`] on source that is already in memory
+pub fn is_generated_source(content: &str) -> bool {
+    for line in content.lines() {
+        match classify_header_line(line) {
+            HeaderLine::Generated => return true,
+            HeaderLine::Comment => continue,
+            HeaderLine::Code => return false,
+        }
+    }
+
+    false
+}
+
+/// Name in the `package` clause of Go source, after any comments above it
+///
+/// Returns `None` when the first code in the file is not a package clause.
+pub fn go_package_name(content: &str) -> Option<&str> {
+    let mut in_block = false;
+
+    for line in content.lines() {
+        let mut line = line.trim();
+        if in_block {
+            let Some((_, rest)) = line.split_once("*/") else { continue };
+            in_block = false;
+            line = rest.trim();
+        }
+        if let Some(comment) = line.strip_prefix("/*") {
+            match comment.split_once("*/") {
+                Some((_, rest)) => line = rest.trim(),
+                None => {
+                    in_block = true;
+                    continue;
+                }
+            }
+        }
+        if line.is_empty() || line.starts_with("//") {
+            continue;
+        }
+
+        let name = line.strip_prefix("package")?;
+        if !name.starts_with(char::is_whitespace) {
+            return None;
+        }
+        let name = name.trim_start();
+        let end = name
+            .find(|c: char| !(c.is_alphanumeric() || c == '_'))
+            .unwrap_or(name.len());
+        return (end > 0).then(|| &name[..end]);
+    }
+
+    None
+}
+
+enum HeaderLine {
+    Generated,
+    Comment,
+    Code,
+}
+
+fn classify_header_line(line: &str) -> HeaderLine {
+    let line = line.trim_end();
+
+    if line.starts_with("// Code generated ") && line.ends_with(" DO NOT EDIT.") {
+        HeaderLine::Generated
+    } else if line.trim().is_empty() || line.trim_start().starts_with("//") {
+        HeaderLine::Comment
+    } else {
+        HeaderLine::Code
+    }
+}
+
+/// Suffix that selects a directory and everything below it, as in `go build ./...`
+pub const RECURSIVE_SUFFIX: &str = "...";
+
+/// Turn a command-line target into a file or directory on disk
+///
+/// Accepts files, directories, Go-style `dir/...` wildcards, and Go import paths inside the
+/// module declared by the nearest `go.mod` above the working directory, such as
+/// `example.com/app/internal/...`. Directories are always walked recursively, so `./...` and `.`
+/// select the same files.
+pub fn resolve_target<P: AsRef<Path>>(target: P) -> Result<PathBuf> {
+    let target = target.as_ref();
+    let text = target.to_string_lossy();
+    let (base, recursive) = match text.strip_suffix(RECURSIVE_SUFFIX) {
+        Some(rest) => (rest.trim_end_matches('/'), true),
+        None => (text.as_ref(), false),
+    };
+    let base = if base.is_empty() { "." } else { base };
+
+    let path = PathBuf::from(base);
+    let resolved = if path.exists() {
+        Some(path)
+    } else {
+        std::env::current_dir()
+            .ok()
+            .and_then(|cwd| resolve_import_path(base, &cwd))
+    };
+
+    match resolved {
+        Some(path) if !recursive || path.is_dir() => Ok(path),
+        _ => Err(MccabreError::NoMatch(text.into_owned())),
+    }
+}
+
+/// Directory of a Go import path in the module around `cwd`, relative to `cwd` when inside it
+fn resolve_import_path(import: &str, cwd: &Path) -> Option<PathBuf> {
+    if import.starts_with('.') || Path::new(import).is_absolute() {
+        return None;
+    }
+
+    let root = cwd.ancestors().find(|dir| dir.join("go.mod").is_file())?;
+    let module = fs::read_to_string(root.join("go.mod")).ok()?.lines().find_map(|line| {
+        let name = line.trim().strip_prefix("module")?;
+        name.starts_with(char::is_whitespace)
+            .then(|| name.trim().trim_matches('"').to_string())
+    })?;
+
+    let rest = import.strip_prefix(module.as_str())?;
+    if !rest.is_empty() && !rest.starts_with('/') {
+        return None;
+    }
+
+    let dir = root.join(rest.trim_start_matches('/'));
+    if !dir.exists() {
+        return None;
+    }
+
+    match dir.strip_prefix(cwd) {
+        Ok(relative) if relative.as_os_str().is_empty() => Some(PathBuf::from(".")),
+        Ok(relative) => Some(relative.to_path_buf()),
+        Err(_) => Some(dir),
+    }
+}
+
+/// Directory of a Go module version in the module cache, such as `example.com/foo@v1.2.3`
+///
+/// Runs `go mod download -json`, which fetches the module unless it is already cached, from a
+/// temporary directory so no `go.mod` or `go.sum` around the working directory is touched. Any
+/// version query Go accepts works, including `@latest`. The directory is read-only, as Go
+/// leaves it. Fails with [`MccabreError::Go`] when Go is not installed or the download fails.
+pub fn download_module(module: &str) -> Result<PathBuf> {
+    let output = Command::new("go")
+        .args(["mod", "download", "-json", module])
+        .current_dir(std::env::temp_dir())
+        .env("GO111MODULE", "on")
+        .output()
+        .map_err(|e| MccabreError::Go(format!("go mod download {module}: {e}")))?;
+
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    match (parse_download(&stdout), output.status.success()) {
+        (Ok(dir), true) => Ok(dir),
+        (Err(message), _) => Err(MccabreError::Go(format!("go mod download {module}: {message}"))),
+        (Ok(_), false) => {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            Err(MccabreError::Go(format!("go mod download {module}: {}", stderr.trim())))
+        }
+    }
+}
+
+/// `Dir` of the object printed by `go mod download -json`, or its `Error`
+fn parse_download(stdout: &str) -> std::result::Result<PathBuf, String> {
+    #[derive(Deserialize)]
+    #[serde(rename_all = "PascalCase")]
+    struct Download {
+        dir: Option<PathBuf>,
+        error: Option<String>,
+    }
+
+    let download: Download = serde_json::from_str(stdout).map_err(|e| e.to_string())?;
+    match download {
+        Download { error: Some(error), .. } => Err(error),
+        Download { dir: Some(dir), .. } => Ok(dir),
+        Download { .. } => Err("no module directory in the output".to_string()),
+    }
+}
+
+/// Settings of the installed Go toolchain that decide which files a build takes in
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct GoEnv {
+    /// Toolchain version, such as `go1.24.1`
+    pub version: String,
+    pub goos: String,
+    pub goarch: String,
+    pub cgo: bool,
+}
+
+/// What `go env` reports for the toolchain on `PATH`, including settings saved with `go env -w`
+///
+/// Fails with [`MccabreError::Go`] when Go is not installed.
+pub fn go_env() -> Result<GoEnv> {
+    let output = Command::new("go")
+        .args(["env", "-json", "GOVERSION", "GOOS", "GOARCH", "CGO_ENABLED"])
+        .output()
+        .map_err(|e| MccabreError::Go(format!("go env: {e}")))?;
+    if !output.status.success() {
+        let stderr = String::from_utf8_lossy(&output.stderr);
+        return Err(MccabreError::Go(format!("go env: {}", stderr.trim())));
+    }
+
+    parse_go_env(&String::from_utf8_lossy(&output.stdout))
+        .map_err(|message| MccabreError::Go(format!("go env: {message}")))
+}
+
+/// The object printed by `go env -json`
+fn parse_go_env(stdout: &str) -> std::result::Result<GoEnv, String> {
+    #[derive(Deserialize)]
+    #[serde(rename_all = "SCREAMING_SNAKE_CASE")]
+    struct Env {
+        goversion: String,
+        goos: String,
+        goarch: String,
+        cgo_enabled: String,
+    }
+
+    let env: Env = serde_json::from_str(stdout).map_err(|e| e.to_string())?;
+    Ok(GoEnv { version: env.goversion, goos: env.goos, goarch: env.goarch, cgo: env.cgo_enabled == "1" })
+}
+
 /// File loader that respects .gitignore and supports various input types
 pub struct FileLoader {
     /// Whether to respect .gitignore files
     respect_gitignore: bool,
+    /// Whether to skip files with a generated-code header
+    skip_generated: bool,
+    /// Whether to skip files under `vendor/` directories
+    skip_vendor: bool,
+    /// Paths matching these globs are skipped
+    exclude: GlobSet,
+    /// Go files excluded by these build constraints are skipped
+    build: BuildContext,
+    /// Worker threads used to read files
+    jobs: usize,
 }
 
 impl Default for FileLoader {
     fn default() -> Self {
-        Self { respect_gitignore: true }
+        Self {
+            respect_gitignore: true,
+            skip_generated: true,
+            skip_vendor: true,
+            exclude: GlobSet::empty(),
+            build: BuildContext::default(),
+            jobs: default_jobs(),
+        }
     }
 }
 
@@ -29,12 +325,76 @@ impl FileLoader {
         Self::default()
     }
 
+    /// Build a loader from the `[files]` configuration section
+    pub fn from_config(config: &FileConfig) -> Result<Self> {
+        let mut build = BuildContext::new().with_tags(&config.tags);
+        if config.skip_cgo {
+            build = build.with_cgo(false);
+        }
+
+        Self::new()
+            .with_gitignore(config.respect_gitignore)
+            .with_skip_generated(config.skip_generated)
+            .with_skip_vendor(config.skip_vendor)
+            .with_build_context(build)
+            .with_excludes(&config.exclude)
+    }
+
     /// Enable or disable gitignore awareness
     pub fn with_gitignore(mut self, respect: bool) -> Self {
         self.respect_gitignore = respect;
         self
     }
 
+    /// Skip files whose header marks them as generated (see [`is_generated`])
+    pub fn with_skip_generated(mut self, skip: bool) -> Self {
+        self.skip_generated = skip;
+        self
+    }
+
+    /// Skip files under `vendor/` directories
+    pub fn with_skip_vendor(mut self, skip: bool) -> Self {
+        self.skip_vendor = skip;
+        self
+    }
+
+    /// Skip Go files that the build context excludes (see [`BuildContext::matches`])
+    ///
+    /// Files named explicitly are always loaded; only files found by walking a directory are
+    /// checked.
+    pub fn with_build_context(mut self, build: BuildContext) -> Self {
+        self.build = build;
+        self
+    }
+
+    /// Skip paths matching any of the glob patterns
+    ///
+    /// Patterns are matched against paths relative to the directory being walked, so
+    /// `*.pb.go` and `internal/mocks/**` both work. `*` also matches `/`.
+    pub fn with_excludes<S: AsRef<str>>(mut self, patterns: &[S]) -> Result<Self> {
+        let mut builder = GlobSetBuilder::new();
+
+        for pattern in patterns {
+            let pattern = pattern.as_ref();
+            let glob = Glob::new(pattern)
+                .map_err(|e| MccabreError::InvalidConfig(format!("invalid exclude pattern '{pattern}': {e}")))?;
+            builder.add(glob);
+        }
+
+        self.exclude = builder
+            .build()
+            .map_err(|e| MccabreError::InvalidConfig(format!("invalid exclude patterns: {e}")))?;
+        Ok(self)
+    }
+
+    /// Read files on `jobs` worker threads (default: available CPUs)
+    ///
+    /// Files are returned in walk order whatever the worker count.
+    pub fn with_jobs(mut self, jobs: usize) -> Self {
+        self.jobs = jobs.max(1);
+        self
+    }
+
     /// Load files from a path (file, directory, or list)
     pub fn load<P: AsRef<Path>>(&self, path: P) -> Result<Vec<SourceFile>> {
         let path = path.as_ref();
@@ -67,6 +427,28 @@ impl FileLoader {
         Ok(files)
     }
 
+    /// Load command-line targets: files, directories, `dir/...` wildcards, or Go import paths
+    ///
+    /// Each target must select at least one supported file. Results are sorted by path without
+    /// duplicates, as with [`FileLoader::load_multiple`].
+    pub fn load_targets<P: AsRef<Path>>(&self, targets: &[P]) -> Result<Vec<SourceFile>> {
+        let mut files = Vec::new();
+
+        for target in targets {
+            let target = target.as_ref();
+            let mut loaded = self.load(resolve_target(target)?)?;
+            if loaded.is_empty() {
+                return Err(MccabreError::NoMatch(target.display().to_string()));
+            }
+            files.append(&mut loaded);
+        }
+
+        files.sort_by(|a, b| a.path.cmp(&b.path));
+        files.dedup_by(|a, b| a.path == b.path);
+
+        Ok(files)
+    }
+
     /// Load a single file
     fn load_file(&self, path: &Path) -> Result<SourceFile> {
         let language = Language::from_path(path)?;
@@ -76,25 +458,86 @@ impl FileLoader {
         Ok(SourceFile { path: path.to_path_buf(), content, language })
     }
 
-    /// Load all supported files from a directory
-    fn load_directory(&self, dir: &Path) -> Result<Vec<SourceFile>> {
-        let mut files = Vec::new();
+    /// Paths of the supported source files under a path, without reading them
+    ///
+    /// Applies the same gitignore, vendor, and exclude filters as [`FileLoader::load`]. Generated
+    /// files and build constraints are only checked against content, so those files are still
+    /// listed.
+    pub fn paths<P: AsRef<Path>>(&self, path: P) -> Result<Vec<PathBuf>> {
+        let path = path.as_ref();
+
+        if path.is_dir() {
+            let mut paths = self.walk(path)?;
+            paths.retain(|p| Language::from_path(p).is_ok());
+            Ok(paths)
+        } else if path.is_file() {
+            Ok(vec![path.to_path_buf()])
+        } else {
+            Err(MccabreError::FileRead {
+                path: path.to_path_buf(),
+                source: std::io::Error::new(std::io::ErrorKind::NotFound, "Path is neither a file nor a directory"),
+            })
+        }
+    }
+
+    /// Every file under a directory that passes the walk filters
+    fn walk(&self, dir: &Path) -> Result<Vec<PathBuf>> {
+        let root = dir.to_path_buf();
+        let exclude = self.exclude.clone();
+        let skip_vendor = self.skip_vendor;
 
         let walker = WalkBuilder::new(dir)
             .standard_filters(self.respect_gitignore)
             .hidden(false)
             .parents(true)
+            .filter_entry(move |entry| {
+                let relative = entry.path().strip_prefix(&root).unwrap_or(entry.path());
+                let is_vendor = skip_vendor
+                    && entry.file_type().is_some_and(|t| t.is_dir())
+                    && entry
+                        .path()
+                        .file_name()
+                        .is_some_and(|name| VENDOR_DIRS.iter().any(|v| name == *v));
+
+                !is_vendor && !exclude.is_match(relative)
+            })
             .build();
 
+        // Symlinked directories are not followed. A symlinked file is loaded under its own path
+        // unless it points back into the tree, where the file is found anyway.
+        let canonical_root = fs::canonicalize(dir).unwrap_or_else(|_| dir.to_path_buf());
+        let mut paths = Vec::new();
         for entry in walker {
-            let entry = entry.map_err(|e| MccabreError::Io(io::Error::other(e.to_string())))?;
-            let path = entry.path();
-
-            if !path.is_file() {
+            let entry = match entry {
+                Ok(entry) => entry,
+                // Entries that vanished or cannot be read below the root, such as a directory
+                // without read permission, are skipped rather than ending the walk
+                Err(e) if e.depth().is_some_and(|depth| depth > 0) && is_unreadable(&e) => continue,
+                Err(e) => return Err(MccabreError::Io(io::Error::other(e.to_string()))),
+            };
+            if !entry.path().is_file() {
+                continue;
+            }
+            if entry.path_is_symlink()
+                && fs::canonicalize(entry.path()).is_ok_and(|target| target.starts_with(&canonical_root))
+            {
                 continue;
             }
+            paths.push(entry.path().to_path_buf());
+        }
+
+        Ok(paths)
+    }
+
+    /// Load all supported files from a directory
+    fn load_directory(&self, dir: &Path) -> Result<Vec<SourceFile>> {
+        let mut files = Vec::new();
+        let paths = self.walk(dir)?;
 
-            match self.load_file(path) {
+        for loaded in map_ordered(&paths, self.jobs, |path| self.load_file(path)) {
+            match loaded {
+                Ok(file) if self.skip_generated && is_generated_source(&file.content) => continue,
+                Ok(file) if file.language == Language::Go && !self.build.matches(&file.path, &file.content) => continue,
                 Ok(file) => files.push(file),
                 Err(MccabreError::UnsupportedFileType(_)) => continue,
                 Err(e) => return Err(e),
@@ -105,6 +548,12 @@ impl FileLoader {
     }
 }
 
+fn is_unreadable(error: &ignore::Error) -> bool {
+    error
+        .io_error()
+        .is_some_and(|e| matches!(e.kind(), io::ErrorKind::PermissionDenied | io::ErrorKind::NotFound))
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -127,6 +576,20 @@ mod tests {
         Ok(())
     }
 
+    #[test]
+    fn test_source_from_reader() -> Result<()> {
+        let file = SourceFile::from_reader("unsaved/main.go", "package main\n".as_bytes())?;
+
+        assert_eq!(file.path, PathBuf::from("unsaved/main.go"));
+        assert_eq!(file.language, Language::Go);
+        assert_eq!(file.content, "package main\n");
+        assert!(matches!(
+            SourceFile::from_reader("notes.txt", "".as_bytes()),
+            Err(MccabreError::UnsupportedFileType(_))
+        ));
+        Ok(())
+    }
+
     #[test]
     fn test_load_directory() -> Result<()> {
         let temp_dir = TempDir::new().unwrap();
@@ -147,6 +610,98 @@ mod tests {
         Ok(())
     }
 
+    #[test]
+    fn test_paths_lists_supported_files_without_reading() -> Result<()> {
+        let temp_dir = TempDir::new().unwrap();
+        fs::create_dir_all(temp_dir.path().join("vendor")).unwrap();
+        fs::write(temp_dir.path().join("vendor").join("dep.go"), "package dep\n").unwrap();
+        fs::write(temp_dir.path().join("main.go"), "package main\n").unwrap();
+        fs::write(temp_dir.path().join("notes.txt"), "Not code").unwrap();
+
+        let paths = FileLoader::new().paths(temp_dir.path())?;
+
+        assert_eq!(paths, vec![temp_dir.path().join("main.go")]);
+        Ok(())
+    }
+
+    #[test]
+    fn test_resolve_recursive_targets() -> Result<()> {
+        let temp_dir = TempDir::new().unwrap();
+        let pkg = temp_dir.path().join("pkg");
+        fs::create_dir_all(&pkg).unwrap();
+
+        assert_eq!(resolve_target(format!("{}/...", pkg.display()))?, pkg);
+        assert_eq!(resolve_target("./...")?, PathBuf::from("."));
+        assert_eq!(resolve_target("...")?, PathBuf::from("."));
+        assert!(matches!(
+            resolve_target(temp_dir.path().join("missing/...")),
+            Err(MccabreError::NoMatch(_))
+        ));
+
+        let file = pkg.join("main.go");
+        fs::write(&file, "package main\n").unwrap();
+        assert_eq!(resolve_target(&file)?, file);
+        assert!(matches!(
+            resolve_target(format!("{}/...", file.display())),
+            Err(MccabreError::NoMatch(_))
+        ));
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_resolve_import_path() {
+        let temp_dir = TempDir::new().unwrap();
+        let root = temp_dir.path();
+        fs::create_dir_all(root.join("internal/store")).unwrap();
+        fs::write(
+            root.join("go.mod"),
+            "// app module\nmodule example.com/app\n\ngo 1.22\n",
+        )
+        .unwrap();
+        let cmd = root.join("cmd");
+        fs::create_dir_all(&cmd).unwrap();
+
+        assert_eq!(
+            resolve_import_path("example.com/app/internal/store", root),
+            Some(PathBuf::from("internal/store"))
+        );
+        assert_eq!(resolve_import_path("example.com/app", root), Some(PathBuf::from(".")));
+        assert_eq!(
+            resolve_import_path("example.com/app/internal", &cmd),
+            Some(root.join("internal"))
+        );
+        assert_eq!(resolve_import_path("example.com/application", root), None);
+        assert_eq!(resolve_import_path("example.com/app/missing", root), None);
+        assert_eq!(resolve_import_path("./internal", root), None);
+    }
+
+    #[test]
+    fn test_load_targets() -> Result<()> {
+        let temp_dir = TempDir::new().unwrap();
+        let api = temp_dir.path().join("api");
+        let docs = temp_dir.path().join("docs");
+        fs::create_dir_all(&api).unwrap();
+        fs::create_dir_all(&docs).unwrap();
+        fs::write(api.join("server.go"), "package api\n").unwrap();
+        fs::write(docs.join("index.md"), "# Docs\n").unwrap();
+        fs::write(temp_dir.path().join("main.go"), "package main\n").unwrap();
+
+        let loader = FileLoader::new();
+        let files = loader.load_targets(&[format!("{}/...", temp_dir.path().display())])?;
+        assert_eq!(files.len(), 2);
+
+        let files = loader.load_targets(&[api.join("..."), temp_dir.path().join("main.go"), api.clone()])?;
+        assert_eq!(files.len(), 2);
+
+        assert!(matches!(
+            loader.load_targets(&[docs.join("...")]),
+            Err(MccabreError::NoMatch(target)) if target.ends_with("docs/...")
+        ));
+
+        Ok(())
+    }
+
     #[test]
     fn test_gitignore_respected() -> Result<()> {
         let temp_dir = TempDir::new().unwrap();
@@ -200,4 +755,230 @@ mod tests {
 
         Ok(())
     }
+
+    const GENERATED_GO: &str =
+        "// Code generated by protoc-gen-go. DO NOT EDIT.\n// source: user.proto\n\npackage user\n";
+
+    #[test]
+    fn test_parallel_load_matches_serial() -> Result<()> {
+        let temp_dir = TempDir::new().unwrap();
+        for i in 0..20 {
+            fs::write(temp_dir.path().join(format!("file{i}.rs")), format!("fn f{i}() {{}}")).unwrap();
+        }
+
+        let serial = FileLoader::new().with_jobs(1).load(temp_dir.path())?;
+        let parallel = FileLoader::new().with_jobs(8).load(temp_dir.path())?;
+
+        let paths = |files: &[SourceFile]| files.iter().map(|f| f.path.clone()).collect::<Vec<_>>();
+        assert_eq!(serial.len(), 20);
+        assert_eq!(paths(&parallel), paths(&serial));
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_is_test_file() {
+        assert!(is_test_file(Path::new("pkg/server_test.go")));
+        assert!(is_test_file(Path::new("_test.go")));
+        assert!(!is_test_file(Path::new("pkg/test.go")));
+        assert!(!is_test_file(Path::new("pkg/server_test.go.orig")));
+        assert!(!is_test_file(Path::new("src/server_test.rs")));
+    }
+
+    #[test]
+    fn test_go_package_name() {
+        assert_eq!(go_package_name("package foo\n"), Some("foo"));
+        assert_eq!(
+            go_package_name("// Package foo_test\n\n/* license\n text */\npackage foo_test // tests\n"),
+            Some("foo_test")
+        );
+        assert_eq!(go_package_name("/* header */ package main\n"), Some("main"));
+        assert_eq!(go_package_name("func main() {}\npackage foo\n"), None);
+        assert_eq!(go_package_name("packagefoo\n"), None);
+    }
+
+    #[test]
+    fn test_is_generated() {
+        let temp_dir = TempDir::new().unwrap();
+        let generated = temp_dir.path().join("user.pb.go");
+        let licensed = temp_dir.path().join("mock.go");
+        let handwritten = temp_dir.path().join("user.go");
+        let late = temp_dir.path().join("late.go");
+
+        fs::write(&generated, GENERATED_GO).unwrap();
+        fs::write(
+            &licensed,
+            "// Copyright 2024\n\n// Code generated by MockGen. DO NOT EDIT.\npackage mock\n",
+        )
+        .unwrap();
+        fs::write(&handwritten, "// Package user handles users.\npackage user\n").unwrap();
+        fs::write(&late, "package user\n\n// Code generated by hand. DO NOT EDIT.\n").unwrap();
+
+        assert!(is_generated(&generated).unwrap());
+        assert!(is_generated(&licensed).unwrap());
+        assert!(!is_generated(&handwritten).unwrap());
+        assert!(!is_generated(&late).unwrap());
+        assert!(is_generated(&temp_dir.path().join("missing.go")).is_err());
+    }
+
+    #[test]
+    fn test_generated_files_skipped() -> Result<()> {
+        let temp_dir = TempDir::new().unwrap();
+        fs::write(temp_dir.path().join("user.pb.go"), GENERATED_GO).unwrap();
+        fs::write(temp_dir.path().join("user.go"), "package user\n").unwrap();
+
+        let files = FileLoader::new().load(temp_dir.path())?;
+        assert_eq!(files.len(), 1);
+        assert!(files[0].path.ends_with("user.go"));
+
+        let files = FileLoader::new().with_skip_generated(false).load(temp_dir.path())?;
+        assert_eq!(files.len(), 2);
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_vendor_skipped() -> Result<()> {
+        let temp_dir = TempDir::new().unwrap();
+        let vendor = temp_dir.path().join("vendor").join("github.com").join("lib");
+        fs::create_dir_all(&vendor).unwrap();
+        fs::write(vendor.join("lib.go"), "package lib\n").unwrap();
+        fs::write(temp_dir.path().join("main.go"), "package main\n").unwrap();
+
+        let files = FileLoader::new().load(temp_dir.path())?;
+        assert_eq!(files.len(), 1);
+        assert!(files[0].path.ends_with("main.go"));
+
+        let files = FileLoader::new().with_skip_vendor(false).load(temp_dir.path())?;
+        assert_eq!(files.len(), 2);
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_exclude_globs() -> Result<()> {
+        let temp_dir = TempDir::new().unwrap();
+        let mocks = temp_dir.path().join("internal").join("mocks");
+        fs::create_dir_all(&mocks).unwrap();
+        fs::write(mocks.join("store.go"), "package mocks\n").unwrap();
+        fs::write(temp_dir.path().join("api.pb.go"), "package api\n").unwrap();
+        fs::write(temp_dir.path().join("api.go"), "package api\n").unwrap();
+
+        let files = FileLoader::new()
+            .with_excludes(&["*.pb.go", "internal/mocks/**"])?
+            .load(temp_dir.path())?;
+        assert_eq!(files.len(), 1);
+        assert!(files[0].path.ends_with("api.go"));
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_invalid_exclude_glob() {
+        let result = FileLoader::new().with_excludes(&["src/[unclosed"]);
+        assert!(matches!(result, Err(MccabreError::InvalidConfig(_))));
+    }
+
+    #[test]
+    fn test_from_config() -> Result<()> {
+        let temp_dir = TempDir::new().unwrap();
+        fs::write(temp_dir.path().join("keep.rs"), "fn keep() {}").unwrap();
+        fs::write(temp_dir.path().join("drop.rs"), "fn drop() {}").unwrap();
+
+        let config = FileConfig { exclude: vec!["drop.rs".to_string()], ..FileConfig::default() };
+        let files = FileLoader::from_config(&config)?.load(temp_dir.path())?;
+
+        assert_eq!(files.len(), 1);
+        assert!(files[0].path.ends_with("keep.rs"));
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_build_constraints() -> Result<()> {
+        let temp_dir = TempDir::new().unwrap();
+        fs::write(temp_dir.path().join("main.go"), "package main\n").unwrap();
+        fs::write(temp_dir.path().join("sys_linux.go"), "package main\n").unwrap();
+        fs::write(temp_dir.path().join("sys_windows.go"), "package main\n").unwrap();
+        let integration = temp_dir.path().join("db_test.go");
+        fs::write(&integration, "//go:build integration\n\npackage main\n").unwrap();
+
+        let names = |loader: FileLoader| -> Result<Vec<String>> {
+            Ok(loader
+                .load(temp_dir.path())?
+                .iter()
+                .map(|f| f.path.file_name().unwrap().to_string_lossy().into_owned())
+                .collect())
+        };
+        let linux = BuildContext::new().with_target("linux", "amd64");
+
+        let mut default = names(FileLoader::new().with_build_context(linux.clone()))?;
+        default.sort();
+        assert_eq!(default, vec!["main.go", "sys_linux.go"]);
+
+        let mut tagged = names(FileLoader::new().with_build_context(linux.with_tags(&["integration"])))?;
+        tagged.sort();
+        assert_eq!(tagged, vec!["db_test.go", "main.go", "sys_linux.go"]);
+
+        // Files named explicitly are loaded whatever their constraints
+        assert_eq!(FileLoader::new().load(&integration)?.len(), 1);
+        Ok(())
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_symlinks_and_read_only_directories() -> Result<()> {
+        use std::os::unix::fs::{PermissionsExt, symlink};
+
+        let outside = TempDir::new().unwrap();
+        fs::write(outside.path().join("shared.go"), "package shared\n").unwrap();
+        let temp_dir = TempDir::new().unwrap();
+        let module = temp_dir.path().join("example.com").join("foo@v1.2.3");
+        fs::create_dir_all(&module).unwrap();
+        fs::write(module.join("foo.go"), "package foo\n").unwrap();
+        symlink(module.join("foo.go"), module.join("alias.go")).unwrap();
+        symlink(outside.path().join("shared.go"), module.join("shared.go")).unwrap();
+        symlink(module.join("missing.go"), module.join("broken.go")).unwrap();
+        symlink(outside.path(), module.join("linked")).unwrap();
+        symlink(&module, module.join("loop")).unwrap();
+        fs::set_permissions(&module, fs::Permissions::from_mode(0o555)).unwrap();
+
+        let loaded = FileLoader::new().load(temp_dir.path());
+        fs::set_permissions(&module, fs::Permissions::from_mode(0o755)).unwrap();
+
+        let names: Vec<_> = loaded?
+            .iter()
+            .map(|f| f.path.strip_prefix(&module).unwrap().to_path_buf())
+            .collect();
+        assert_eq!(names, vec![PathBuf::from("foo.go"), PathBuf::from("shared.go")]);
+        Ok(())
+    }
+
+    #[test]
+    fn test_parse_module_download() {
+        let cached = r#"{"Path": "example.com/foo", "Version": "v1.2.3", "Dir": "/go/pkg/mod/example.com/foo@v1.2.3"}"#;
+        assert_eq!(
+            parse_download(cached),
+            Ok(PathBuf::from("/go/pkg/mod/example.com/foo@v1.2.3"))
+        );
+
+        let failed = r#"{"Path": "example.com/foo", "Version": "v9.9.9", "Error": "unknown revision v9.9.9"}"#;
+        assert_eq!(parse_download(failed), Err("unknown revision v9.9.9".to_string()));
+        assert!(parse_download("go: not a module").is_err());
+    }
+
+    #[test]
+    fn test_parse_go_env() {
+        let env = r#"{"CGO_ENABLED": "0", "GOARCH": "arm64", "GOOS": "darwin", "GOVERSION": "go1.24.1"}"#;
+        assert_eq!(
+            parse_go_env(env),
+            Ok(GoEnv {
+                version: "go1.24.1".to_string(),
+                goos: "darwin".to_string(),
+                goarch: "arm64".to_string(),
+                cgo: false
+            })
+        );
+        assert!(parse_go_env("{}").is_err());
+    }
 }
@@ -0,0 +1,83 @@
+use std::fmt::{self, Display, Write};
+use std::sync::Arc;
+
+/// Receiver of debug records describing what analysis did, for `--debug`
+///
+/// Each record is a message and a list of fields, rendered as `msg="..." key=value` text in the
+/// layout of Go's `log/slog` text handler. Nothing is logged unless a logger is set, and logging
+/// never changes results.
+#[derive(Clone)]
+pub struct Logger(Arc<LogFn>);
+
+/// Log callback: one rendered record
+type LogFn = dyn Fn(&str) + Send + Sync;
+
+impl Logger {
+    pub fn new(log: impl Fn(&str) + Send + Sync + 'static) -> Self {
+        Self(Arc::new(log))
+    }
+
+    /// Log `message` with its fields
+    pub fn debug(&self, message: &str, fields: &[(&str, &dyn Display)]) {
+        (self.0)(&record(message, fields));
+    }
+}
+
+impl fmt::Debug for Logger {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str("Logger")
+    }
+}
+
+/// Render a record as `msg=... key=value ...`, quoting values that hold spaces, quotes, or `=`
+pub fn record(message: &str, fields: &[(&str, &dyn Display)]) -> String {
+    let mut line = format!("msg={}", quote(message));
+    for (key, value) in fields {
+        let _ = write!(line, " {key}={}", quote(&value.to_string()));
+    }
+    line
+}
+
+fn quote(value: &str) -> String {
+    if value.is_empty() || value.chars().any(|c| c.is_whitespace() || c == '"' || c == '=') {
+        format!("{value:?}")
+    } else {
+        value.to_string()
+    }
+}
+
+/// Log through `logger` when one is set
+pub(crate) fn debug(logger: Option<&Logger>, message: &str, fields: &[(&str, &dyn Display)]) {
+    if let Some(logger) = logger {
+        logger.debug(message, fields);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::sync::Mutex;
+
+    #[test]
+    fn test_record_quotes_values_like_slog() {
+        assert_eq!(
+            record("tokenized file", &[("file", &"pkg/a b.go"), ("tokens", &120)]),
+            r#"msg="tokenized file" file="pkg/a b.go" tokens=120"#
+        );
+        assert_eq!(record("done", &[("stage", &"")]), r#"msg=done stage="""#);
+    }
+
+    #[test]
+    fn test_logger_receives_rendered_records() {
+        let seen = Arc::new(Mutex::new(Vec::new()));
+        let logger = Logger::new({
+            let seen = Arc::clone(&seen);
+            move |line| seen.lock().unwrap().push(line.to_string())
+        });
+
+        debug(Some(&logger), "window index", &[("windows", &4)]);
+        debug(None, "ignored", &[]);
+
+        assert_eq!(*seen.lock().unwrap(), vec!["msg=\"window index\" windows=4"]);
+    }
+}
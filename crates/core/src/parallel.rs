@@ -0,0 +1,143 @@
+use std::sync::Arc;
+use std::sync::atomic::{AtomicUsize, Ordering};
+use std::thread;
+
+/// Worker count used when none is requested: the number of available CPUs
+pub fn default_jobs() -> usize {
+    thread::available_parallelism().map(|n| n.get()).unwrap_or(1)
+}
+
+/// Apply `f` to every item on up to `jobs` worker threads
+///
+/// Workers pull the next unclaimed item until none are left, so uneven file sizes balance out.
+/// Results are returned in input order, making the output independent of the worker count.
+pub fn map_ordered<T, R, F>(items: &[T], jobs: usize, f: F) -> Vec<R>
+where
+    T: Sync,
+    R: Send,
+    F: Fn(&T) -> R + Sync,
+{
+    let jobs = jobs.clamp(1, items.len().max(1));
+    if jobs == 1 {
+        return items.iter().map(f).collect();
+    }
+
+    let next = AtomicUsize::new(0);
+    let mut results: Vec<Option<R>> = (0..items.len()).map(|_| None).collect();
+
+    thread::scope(|scope| {
+        let workers: Vec<_> = (0..jobs)
+            .map(|_| {
+                scope.spawn(|| {
+                    let mut done = Vec::new();
+                    loop {
+                        let idx = next.fetch_add(1, Ordering::Relaxed);
+                        if idx >= items.len() {
+                            break done;
+                        }
+                        done.push((idx, f(&items[idx])));
+                    }
+                })
+            })
+            .collect();
+
+        for worker in workers {
+            for (idx, result) in worker.join().expect("worker thread panicked") {
+                results[idx] = Some(result);
+            }
+        }
+    });
+
+    results
+        .into_iter()
+        .map(|r| r.expect("every item is processed"))
+        .collect()
+}
+
+/// Callback told how far a long-running phase has got
+///
+/// It is called from worker threads with the phase name, the number of items finished so far,
+/// and the phase total, once with zero when the phase starts and then after every item.
+#[derive(Clone)]
+pub struct Progress(Arc<ProgressFn>);
+
+/// Progress callback: phase name, items finished, phase total
+type ProgressFn = dyn Fn(&str, usize, usize) + Send + Sync;
+
+impl Progress {
+    pub fn new(report: impl Fn(&str, usize, usize) + Send + Sync + 'static) -> Self {
+        Self(Arc::new(report))
+    }
+
+    /// Start counting a phase of `total` items
+    pub fn phase(&self, name: &'static str, total: usize) -> Phase<'_> {
+        (self.0)(name, 0, total);
+        Phase { progress: self, name, total, done: AtomicUsize::new(0) }
+    }
+}
+
+/// Finished-item counter for one phase of a [`Progress`]
+pub struct Phase<'a> {
+    progress: &'a Progress,
+    name: &'static str,
+    total: usize,
+    done: AtomicUsize,
+}
+
+impl Phase<'_> {
+    /// Record one finished item
+    pub fn tick(&self) {
+        let done = self.done.fetch_add(1, Ordering::Relaxed) + 1;
+        (self.progress.0)(self.name, done, self.total);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_results_keep_input_order() {
+        let items: Vec<usize> = (0..500).collect();
+        let serial = map_ordered(&items, 1, |n| n * n);
+
+        for jobs in [2, 3, 8, 64] {
+            assert_eq!(map_ordered(&items, jobs, |n| n * n), serial);
+        }
+    }
+
+    #[test]
+    fn test_empty_and_zero_jobs() {
+        let empty: Vec<usize> = Vec::new();
+        assert!(map_ordered(&empty, 4, |n| *n).is_empty());
+        assert_eq!(map_ordered(&[1, 2, 3], 0, |n| n + 1), vec![2, 3, 4]);
+    }
+
+    #[test]
+    fn test_progress_counts_every_item() {
+        use std::sync::Mutex;
+
+        let seen = Arc::new(Mutex::new(Vec::new()));
+        let progress = Progress::new({
+            let seen = Arc::clone(&seen);
+            move |phase, done, total| seen.lock().unwrap().push((phase.to_string(), done, total))
+        });
+
+        let items: Vec<usize> = (0..40).collect();
+        let phase = progress.phase("Squaring", items.len());
+        map_ordered(&items, 4, |n| {
+            phase.tick();
+            n * n
+        });
+
+        let mut seen = seen.lock().unwrap().clone();
+        seen.sort();
+        let expected: Vec<_> = (0..=40).map(|done| ("Squaring".to_string(), done, 40)).collect();
+        assert_eq!(seen, expected);
+    }
+
+    #[test]
+    fn test_default_jobs_is_positive() {
+        assert!(default_jobs() >= 1);
+    }
+}
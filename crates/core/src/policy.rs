@@ -0,0 +1,528 @@
+use crate::complexity::Severity;
+use crate::config::{PathOverride, resolve_override};
+use crate::reporter::Report;
+use crate::rules::LengthLimits;
+use std::fmt;
+use std::path::Path;
+
+/// Limits that make an analysis run fail
+///
+/// Unset limits are never checked, so the default policy accepts every report.
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct FailurePolicy {
+    /// Highest cyclomatic complexity allowed for a single function
+    pub max_complexity: Option<usize>,
+    /// Highest number of clone groups allowed
+    pub max_clones: Option<usize>,
+    /// Highest share of lines inside clone instances allowed, in percent
+    pub max_duplication: Option<f64>,
+    /// Deepest block nesting allowed for a single function
+    pub max_nesting: Option<usize>,
+    /// Longest function bodies allowed
+    pub length_limits: LengthLimits,
+    /// Most parameters allowed for a single function
+    pub max_parameters: Option<usize>,
+    /// Most `return` statements allowed in a single function
+    pub max_returns: Option<usize>,
+    /// Lowest function severity that fails the run
+    pub min_severity: Option<Severity>,
+    /// Fail when any file could not be parsed
+    pub fail_on_parse_error: bool,
+    /// Per-path replacements for `max_complexity` and `max_nesting`, from their
+    /// `warning_threshold` and `max_nesting`
+    pub overrides: Vec<PathOverride>,
+}
+
+/// A limit exceeded by a report
+#[derive(Debug, Clone, PartialEq)]
+pub enum Violation {
+    /// Functions above the complexity limit, with the highest score found
+    Complexity {
+        functions: usize,
+        worst: usize,
+        limit: usize,
+    },
+    /// Clone groups beyond the allowed count
+    Clones { groups: usize, limit: usize },
+    /// Lines inside clone instances above the allowed percentage of all lines
+    Duplication { percent: f64, limit: f64 },
+    /// Functions nested deeper than the limit, with the deepest nesting found
+    Nesting {
+        functions: usize,
+        worst: usize,
+        limit: usize,
+    },
+    /// Functions with more lines or statements than the length limits allow
+    Length { functions: usize },
+    /// Functions taking more parameters than the limit, with the most found
+    Parameters {
+        functions: usize,
+        worst: usize,
+        limit: usize,
+    },
+    /// Functions with more `return` statements than the limit, with the most found
+    Returns {
+        functions: usize,
+        worst: usize,
+        limit: usize,
+    },
+    /// Functions rated at or above the failing severity, with the highest rating found
+    Severity {
+        functions: usize,
+        worst: Severity,
+        limit: Severity,
+    },
+    /// Files left out of the analysis because they could not be parsed
+    ParseErrors { files: usize },
+}
+
+impl FailurePolicy {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    pub fn with_max_complexity(mut self, limit: usize) -> Self {
+        self.max_complexity = Some(limit);
+        self
+    }
+
+    pub fn with_max_clones(mut self, limit: usize) -> Self {
+        self.max_clones = Some(limit);
+        self
+    }
+
+    /// Fail when more than `percent` of all lines fall inside clone instances
+    pub fn with_max_duplication(mut self, percent: f64) -> Self {
+        self.max_duplication = Some(percent);
+        self
+    }
+
+    pub fn with_max_nesting(mut self, limit: usize) -> Self {
+        self.max_nesting = Some(limit);
+        self
+    }
+
+    pub fn with_length_limits(mut self, limits: LengthLimits) -> Self {
+        self.length_limits = limits;
+        self
+    }
+
+    pub fn with_max_parameters(mut self, limit: usize) -> Self {
+        self.max_parameters = Some(limit);
+        self
+    }
+
+    pub fn with_max_returns(mut self, limit: usize) -> Self {
+        self.max_returns = Some(limit);
+        self
+    }
+
+    pub fn with_min_severity(mut self, severity: Severity) -> Self {
+        self.min_severity = Some(severity);
+        self
+    }
+
+    pub fn with_fail_on_parse_error(mut self, fail: bool) -> Self {
+        self.fail_on_parse_error = fail;
+        self
+    }
+
+    /// Check files matching an override against its limits instead
+    ///
+    /// The most specific matching override applies, as chosen by [`resolve_override`]; its unset
+    /// values keep the policy's own limits. Limits the policy does not check stay unchecked.
+    pub fn with_overrides(mut self, overrides: Vec<PathOverride>) -> Self {
+        self.overrides = overrides;
+        self
+    }
+
+    /// Check a report against the configured limits
+    pub fn check(&self, report: &Report) -> Vec<Violation> {
+        let mut violations = Vec::new();
+
+        if let Some(limit) = self.max_complexity {
+            let over: Vec<usize> = report
+                .files
+                .iter()
+                .flat_map(|f| {
+                    let limit = self
+                        .override_for(f.path.as_path(), |o| o.warning_threshold)
+                        .unwrap_or(limit);
+                    f.cyclomatic
+                        .functions
+                        .iter()
+                        .filter(move |func| func.cyclomatic > limit)
+                })
+                .map(|func| func.cyclomatic)
+                .collect();
+
+            if let Some(&worst) = over.iter().max() {
+                violations.push(Violation::Complexity { functions: over.len(), worst, limit });
+            }
+        }
+
+        if let Some(limit) = self.max_clones
+            && report.clones.len() > limit
+        {
+            violations.push(Violation::Clones { groups: report.clones.len(), limit });
+        }
+
+        let percent = report.summary.duplication_ratio * 100.0;
+        if let Some(limit) = self.max_duplication
+            && percent > limit
+        {
+            violations.push(Violation::Duplication { percent, limit });
+        }
+
+        if let Some(limit) = self.max_nesting {
+            let over: Vec<usize> = report
+                .files
+                .iter()
+                .flat_map(|f| {
+                    let limit = self.override_for(f.path.as_path(), |o| o.max_nesting).unwrap_or(limit);
+                    f.cyclomatic
+                        .functions
+                        .iter()
+                        .filter(move |func| func.max_nesting > limit)
+                })
+                .map(|func| func.max_nesting)
+                .collect();
+
+            if let Some(&worst) = over.iter().max() {
+                violations.push(Violation::Nesting { functions: over.len(), worst, limit });
+            }
+        }
+
+        if !self.length_limits.is_empty() {
+            let functions = report
+                .files
+                .iter()
+                .flat_map(|f| &f.cyclomatic.functions)
+                .filter(|func| self.length_limits.exceeded_by(func))
+                .count();
+
+            if functions > 0 {
+                violations.push(Violation::Length { functions });
+            }
+        }
+
+        if let Some(limit) = self.max_parameters {
+            let over: Vec<usize> = report
+                .files
+                .iter()
+                .flat_map(|f| &f.cyclomatic.functions)
+                .map(|func| func.parameters.len())
+                .filter(|&count| count > limit)
+                .collect();
+
+            if let Some(&worst) = over.iter().max() {
+                violations.push(Violation::Parameters { functions: over.len(), worst, limit });
+            }
+        }
+
+        if let Some(limit) = self.max_returns {
+            let over: Vec<usize> = report
+                .files
+                .iter()
+                .flat_map(|f| &f.cyclomatic.functions)
+                .map(|func| func.returns)
+                .filter(|&count| count > limit)
+                .collect();
+
+            if let Some(&worst) = over.iter().max() {
+                violations.push(Violation::Returns { functions: over.len(), worst, limit });
+            }
+        }
+
+        if let Some(limit) = self.min_severity {
+            let over: Vec<Severity> = report
+                .files
+                .iter()
+                .flat_map(|f| &f.cyclomatic.functions)
+                .map(|func| func.severity)
+                .filter(|&s| s >= limit)
+                .collect();
+
+            if let Some(&worst) = over.iter().max() {
+                violations.push(Violation::Severity { functions: over.len(), worst, limit });
+            }
+        }
+
+        if self.fail_on_parse_error && !report.parse_errors.is_empty() {
+            violations.push(Violation::ParseErrors { files: report.parse_errors.len() });
+        }
+
+        violations
+    }
+
+    fn override_for(&self, path: &Path, value: fn(&PathOverride) -> Option<usize>) -> Option<usize> {
+        resolve_override(&self.overrides, path).and_then(value)
+    }
+}
+
+impl fmt::Display for Violation {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Violation::Complexity { functions, worst, limit } => {
+                write!(f, "{functions} function(s) exceed complexity {limit} (highest {worst})")
+            }
+            Violation::Clones { groups, limit } => write!(f, "{groups} clone group(s) found (limit {limit})"),
+            Violation::Duplication { percent, limit } => {
+                write!(f, "{percent:.2}% of lines duplicated (limit {limit}%)")
+            }
+            Violation::Nesting { functions, worst, limit } => {
+                write!(
+                    f,
+                    "{functions} function(s) nested deeper than {limit} (deepest {worst})"
+                )
+            }
+            Violation::Length { functions } => write!(f, "{functions} function(s) exceed the length limits"),
+            Violation::Parameters { functions, worst, limit } => {
+                write!(
+                    f,
+                    "{functions} function(s) take more than {limit} parameters (most {worst})"
+                )
+            }
+            Violation::Returns { functions, worst, limit } => {
+                write!(
+                    f,
+                    "{functions} function(s) have more than {limit} returns (most {worst})"
+                )
+            }
+            Violation::Severity { functions, worst, limit } => {
+                write!(f, "{functions} function(s) rated {limit} or above (highest {worst})")
+            }
+            Violation::ParseErrors { files } => write!(f, "{files} file(s) could not be parsed"),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::Clone;
+    use crate::complexity::{CyclomaticMetrics, FunctionComplexity, HalsteadMetrics, LocMetrics, SeverityBands};
+    use crate::reporter::FileReport;
+    use crate::syntax::ParseError;
+    use std::path::PathBuf;
+
+    fn report(complexities: &[usize], clones: usize) -> Report {
+        let functions = complexities
+            .iter()
+            .enumerate()
+            .map(|(i, &cyclomatic)| FunctionComplexity {
+                name: format!("f{i}"),
+                cyclomatic,
+                cognitive: 0,
+                max_nesting: 0,
+                line: i + 1,
+                halstead: HalsteadMetrics::default(),
+                severity: SeverityBands::default().classify(cyclomatic),
+                ..Default::default()
+            })
+            .collect();
+        let files = vec![FileReport {
+            path: PathBuf::from("a.rs"),
+            loc: LocMetrics { physical: 10, logical: 8, comments: 1, blank: 1, statements: 8 },
+            cyclomatic: CyclomaticMetrics { file_complexity: complexities.iter().sum(), functions },
+            maintainability_index: 50.0,
+            findings: Vec::new(),
+            package: None,
+        }];
+        let clones = (1..=clones)
+            .map(|id| Clone { id, length: 30, locations: vec![], hash: id as u64, group_id: String::new() })
+            .collect();
+
+        Report::new(files, clones)
+    }
+
+    #[test]
+    fn test_default_policy_passes() {
+        assert!(FailurePolicy::new().check(&report(&[50, 40], 10)).is_empty());
+    }
+
+    #[test]
+    fn test_max_complexity() {
+        let policy = FailurePolicy::new().with_max_complexity(10);
+
+        assert!(policy.check(&report(&[3, 10], 0)).is_empty());
+        assert_eq!(
+            policy.check(&report(&[3, 12, 25], 0)),
+            vec![Violation::Complexity { functions: 2, worst: 25, limit: 10 }]
+        );
+    }
+
+    #[test]
+    fn test_max_clones() {
+        let policy = FailurePolicy::new().with_max_clones(2);
+
+        assert!(policy.check(&report(&[], 2)).is_empty());
+        assert_eq!(
+            policy.check(&report(&[], 3)),
+            vec![Violation::Clones { groups: 3, limit: 2 }]
+        );
+    }
+
+    #[test]
+    fn test_max_duplication() {
+        let mut report = report(&[], 0);
+        report.summary.duplicated_lines = 4;
+        report.summary.duplication_ratio = 0.04;
+
+        assert!(FailurePolicy::new().with_max_duplication(4.0).check(&report).is_empty());
+        let violations = FailurePolicy::new().with_max_duplication(3.0).check(&report);
+        assert_eq!(violations, vec![Violation::Duplication { percent: 4.0, limit: 3.0 }]);
+        assert_eq!(violations[0].to_string(), "4.00% of lines duplicated (limit 3%)");
+    }
+
+    #[test]
+    fn test_max_nesting() {
+        let mut nested = report(&[3, 4, 5], 0);
+        for func in &mut nested.files[0].cyclomatic.functions {
+            func.max_nesting = func.cyclomatic;
+        }
+        let policy = FailurePolicy::new().with_max_nesting(3);
+
+        assert_eq!(
+            policy.check(&nested),
+            vec![Violation::Nesting { functions: 2, worst: 5, limit: 3 }]
+        );
+        assert!(FailurePolicy::new().with_max_nesting(5).check(&nested).is_empty());
+    }
+
+    #[test]
+    fn test_overrides_replace_limits_per_path() {
+        let mut nested = report(&[12, 30], 0);
+        nested.files[0].cyclomatic.functions[1].max_nesting = 5;
+        let parser = |warning_threshold, max_nesting| PathOverride {
+            path: "*.rs".to_string(),
+            warning_threshold,
+            max_nesting,
+            ..PathOverride::default()
+        };
+        let policy = FailurePolicy::new().with_max_complexity(10).with_max_nesting(3);
+
+        assert!(
+            policy
+                .clone()
+                .with_overrides(vec![parser(Some(30), Some(5))])
+                .check(&nested)
+                .is_empty()
+        );
+        assert_eq!(
+            policy.with_overrides(vec![parser(Some(20), None)]).check(&nested),
+            vec![
+                Violation::Complexity { functions: 1, worst: 30, limit: 10 },
+                Violation::Nesting { functions: 1, worst: 5, limit: 3 },
+            ]
+        );
+    }
+
+    #[test]
+    fn test_length_limits() {
+        let mut long = report(&[1, 1, 1], 0);
+        for (i, func) in long.files[0].cyclomatic.functions.iter_mut().enumerate() {
+            (func.lines, func.statements) = (20 * (i + 1), 5 * (i + 1));
+        }
+
+        let policy = FailurePolicy::new().with_length_limits(LengthLimits::new(Some(30), Some(10)));
+        assert_eq!(policy.check(&long), vec![Violation::Length { functions: 2 }]);
+        assert_eq!(
+            policy.check(&long)[0].to_string(),
+            "2 function(s) exceed the length limits"
+        );
+
+        let policy = FailurePolicy::new().with_length_limits(LengthLimits::new(None, Some(15)));
+        assert!(policy.check(&long).is_empty());
+    }
+
+    #[test]
+    fn test_max_parameters() {
+        let mut report = report(&[1, 1, 1], 0);
+        for (i, func) in report.files[0].cyclomatic.functions.iter_mut().enumerate() {
+            func.parameters = (0..3 * i).map(|n| format!("p{n}")).collect();
+        }
+
+        let policy = FailurePolicy::new().with_max_parameters(4);
+        assert_eq!(
+            policy.check(&report),
+            vec![Violation::Parameters { functions: 1, worst: 6, limit: 4 }]
+        );
+        assert_eq!(
+            policy.check(&report)[0].to_string(),
+            "1 function(s) take more than 4 parameters (most 6)"
+        );
+        assert!(FailurePolicy::new().with_max_parameters(6).check(&report).is_empty());
+    }
+
+    #[test]
+    fn test_max_returns() {
+        let mut report = report(&[1, 1, 1], 0);
+        for (i, func) in report.files[0].cyclomatic.functions.iter_mut().enumerate() {
+            func.returns = 2 * i;
+        }
+
+        let policy = FailurePolicy::new().with_max_returns(1);
+        assert_eq!(
+            policy.check(&report),
+            vec![Violation::Returns { functions: 2, worst: 4, limit: 1 }]
+        );
+        assert_eq!(
+            policy.check(&report)[0].to_string(),
+            "2 function(s) have more than 1 returns (most 4)"
+        );
+        assert!(FailurePolicy::new().with_max_returns(4).check(&report).is_empty());
+    }
+
+    #[test]
+    fn test_min_severity() {
+        let mut report = report(&[3, 12, 25, 60], 0);
+        let policy = FailurePolicy::new().with_min_severity(Severity::High);
+
+        assert_eq!(
+            policy.check(&report),
+            vec![Violation::Severity { functions: 2, worst: Severity::VeryHigh, limit: Severity::High }]
+        );
+
+        report.classify(&SeverityBands { moderate: 20, high: 50, very_high: 100 });
+        assert_eq!(
+            policy.check(&report),
+            vec![Violation::Severity { functions: 1, worst: Severity::High, limit: Severity::High }]
+        );
+        assert_eq!(
+            policy.check(&report)[0].to_string(),
+            "1 function(s) rated high or above (highest high)"
+        );
+    }
+
+    #[test]
+    fn test_parse_errors() {
+        let mut report = report(&[3], 0);
+        report.parse_errors.push(ParseError {
+            file: PathBuf::from("bad.go"),
+            line: 3,
+            column: 9,
+            message: "newline in string".to_string(),
+        });
+
+        assert!(FailurePolicy::new().check(&report).is_empty());
+        let violations = FailurePolicy::new().with_fail_on_parse_error(true).check(&report);
+        assert_eq!(violations, vec![Violation::ParseErrors { files: 1 }]);
+        assert_eq!(violations[0].to_string(), "1 file(s) could not be parsed");
+    }
+
+    #[test]
+    fn test_violation_messages() {
+        let complexity = Violation::Complexity { functions: 2, worst: 25, limit: 10 };
+        assert_eq!(
+            complexity.to_string(),
+            "2 function(s) exceed complexity 10 (highest 25)"
+        );
+
+        let clones = Violation::Clones { groups: 3, limit: 0 };
+        assert_eq!(clones.to_string(), "3 clone group(s) found (limit 0)");
+
+        let nesting = Violation::Nesting { functions: 1, worst: 6, limit: 4 };
+        assert_eq!(nesting.to_string(), "1 function(s) nested deeper than 4 (deepest 6)");
+    }
+}
@@ -0,0 +1,336 @@
+use crate::Result;
+use crate::cloner::CloneLocation;
+use crate::complexity::{CyclomaticMetrics, LocMetrics};
+use crate::loader::SourceFile;
+use crate::reporter::{FileReport, Report};
+use crate::tokenizer::{Language, TokenType, Tokenizer};
+use std::collections::HashMap;
+use std::mem;
+use std::path::{Path, PathBuf};
+
+/// File and line that a line of analyzed source stands for
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Position {
+    pub file: PathBuf,
+    pub line: usize,
+}
+
+/// A `//line` or `/*line */` directive, with its file already resolved
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct LineDirective {
+    /// First line of the analyzed file the directive applies to
+    pub from_line: usize,
+    /// File the directive names, or the one named before it for `//line :N`
+    pub file: PathBuf,
+    /// Line that `from_line` stands for
+    pub line: usize,
+}
+
+/// Line directives of a set of Go files, through which reported positions are resolved
+///
+/// Generators such as `goyacc`, `go tool cgo`, and templating tools write directives that
+/// point back at the source they generated code from:
+///
+/// ```text
+/// //line parser.y:42
+/// ```
+///
+/// The line after a `//line` comment, which must start at the beginning of a line, stands for
+/// line 42 of `parser.y`; a `/*line parser.y:42*/` comment gives the position of the text right
+/// after it. A trailing `:col` is accepted and ignored, a relative file name is relative to the
+/// directory of the file holding the directive, and `//line :42` keeps the current file. Like
+/// `token.FileSet.Position` in Go, this maps each position once, after analysis, so every
+/// report format sees the same file and line.
+#[derive(Debug, Clone, Default)]
+pub struct Positions {
+    directives: HashMap<PathBuf, Vec<LineDirective>>,
+}
+
+impl Positions {
+    /// Collect the line directives of every Go file
+    pub fn from_files(files: &[SourceFile]) -> Result<Self> {
+        let mut directives = HashMap::new();
+
+        for file in files.iter().filter(|f| f.language == Language::Go) {
+            let found = parse_line_directives(&file.path, &file.content)?;
+            if !found.is_empty() {
+                directives.insert(file.path.clone(), found);
+            }
+        }
+
+        Ok(Self { directives })
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.directives.is_empty()
+    }
+
+    /// Where line `line` of the analyzed file `path` stands for
+    pub fn position(&self, path: &Path, line: usize) -> Position {
+        let directive = self
+            .directives
+            .get(path)
+            .and_then(|found| found.iter().rev().find(|d| d.from_line <= line));
+
+        match directive {
+            Some(d) => Position { file: d.file.clone(), line: d.line + (line - d.from_line) },
+            None => Position { file: path.to_path_buf(), line },
+        }
+    }
+
+    /// Rewrite every position in a report to the one its line directives give
+    ///
+    /// Clone instances and the other located groups take the file and lines their first line
+    /// stands for; an instance whose last line stands for another file is cut to its first line.
+    /// Functions and findings move to the report of the file they stand for, which is added with
+    /// no lines counted when that file was not analyzed itself. Line counts and file complexity
+    /// stay with the analyzed file. Columns and byte offsets are left as they are.
+    pub fn resolve(&self, mut report: Report) -> Report {
+        if self.is_empty() {
+            return report;
+        }
+
+        let clone_locations = report.clones.iter_mut().flat_map(|c| &mut c.locations);
+        let errcheck_locations = report.errcheck_clusters.iter_mut().flat_map(|c| &mut c.instances);
+        let declaration_locations = report.duplicate_declarations.iter_mut().flat_map(|d| &mut d.instances);
+        let similar_locations = report
+            .similar_functions
+            .iter_mut()
+            .flat_map(|p| &mut p.functions)
+            .map(|f| &mut f.location);
+        let function_locations = report
+            .function_clones
+            .iter_mut()
+            .flat_map(|c| &mut c.functions)
+            .map(|f| &mut f.location);
+        for location in clone_locations
+            .chain(errcheck_locations)
+            .chain(declaration_locations)
+            .chain(similar_locations)
+            .chain(function_locations)
+        {
+            self.resolve_location(location);
+        }
+
+        let mut moved: Vec<FileReport> = Vec::new();
+        for file in &mut report.files {
+            if !self.directives.contains_key(&file.path) {
+                continue;
+            }
+
+            for mut func in mem::take(&mut file.cyclomatic.functions) {
+                let (start, end) = (
+                    self.position(&file.path, func.line),
+                    self.position(&file.path, func.end_line),
+                );
+                func.line = start.line;
+                func.end_line = if end.file == start.file { end.line } else { start.line };
+                let target =
+                    if start.file == file.path { &mut *file } else { report_for(&mut moved, file, start.file) };
+                target.cyclomatic.functions.push(func);
+            }
+            for mut finding in mem::take(&mut file.findings) {
+                let position = self.position(&file.path, finding.line);
+                finding.line = position.line;
+                let target =
+                    if position.file == file.path { &mut *file } else { report_for(&mut moved, file, position.file) };
+                target.findings.push(finding);
+            }
+        }
+
+        for file in moved {
+            match report.files.iter_mut().find(|f| f.path == file.path) {
+                Some(existing) => {
+                    existing.cyclomatic.functions.extend(file.cyclomatic.functions);
+                    existing.findings.extend(file.findings);
+                }
+                None => report.files.push(file),
+            }
+        }
+        for file in &mut report.files {
+            file.cyclomatic.functions.sort_by_key(|f| f.line);
+            file.findings.sort_by_key(|f| f.line);
+        }
+
+        report
+    }
+
+    fn resolve_location(&self, location: &mut CloneLocation) {
+        let start = self.position(&location.file, location.start_line);
+        let end = self.position(&location.file, location.end_line);
+        location.end_line = if end.file == start.file && end.line >= start.line { end.line } else { start.line };
+        location.start_line = start.line;
+        location.file = start.file;
+    }
+}
+
+/// Report collecting what moves from `source` to `path`, added to `moved` on first use
+fn report_for<'a>(moved: &'a mut Vec<FileReport>, source: &FileReport, path: PathBuf) -> &'a mut FileReport {
+    let index = match moved.iter().position(|f| f.path == path) {
+        Some(index) => index,
+        None => {
+            moved.push(FileReport {
+                path,
+                loc: LocMetrics { physical: 0, logical: 0, comments: 0, blank: 0, statements: 0 },
+                cyclomatic: CyclomaticMetrics { file_complexity: 0, functions: Vec::new() },
+                maintainability_index: source.maintainability_index,
+                findings: Vec::new(),
+                package: source.package.clone(),
+            });
+            moved.len() - 1
+        }
+    };
+    &mut moved[index]
+}
+
+/// Find the line directives in Go source, in file order
+///
+/// Directives inside string literals are not comments and are not found.
+pub fn parse_line_directives(path: &Path, source: &str) -> Result<Vec<LineDirective>> {
+    if !source.contains("//line ") && !source.contains("/*line ") {
+        return Ok(Vec::new());
+    }
+
+    let tokens = Tokenizer::new(source, Language::Go).tokenize()?;
+    let lines: Vec<&str> = source.lines().collect();
+    let dir = path.parent().unwrap_or(Path::new(""));
+    let mut current = path.to_path_buf();
+    let mut directives = Vec::new();
+
+    for comment in tokens.iter().filter(|t| t.token_type == TokenType::Comment) {
+        let Some(text) = lines.get(comment.line - 1) else { continue };
+        let text: String = text.chars().skip(comment.column - 1).collect();
+
+        let (target, from_line) = if let Some(rest) = text.strip_prefix("//line ") {
+            if comment.column != 1 {
+                continue;
+            }
+            (rest.trim_end(), comment.line + 1)
+        } else if let Some(rest) = text.strip_prefix("/*line ") {
+            let Some((target, _)) = rest.split_once("*/") else { continue };
+            (target, comment.line)
+        } else {
+            continue;
+        };
+
+        let Some((file, line)) = split_target(target) else { continue };
+        if !file.is_empty() {
+            let file = Path::new(file);
+            current = if file.is_absolute() { file.to_path_buf() } else { dir.join(file) };
+        }
+        directives.push(LineDirective { from_line, file: current.clone(), line });
+    }
+
+    Ok(directives)
+}
+
+/// Split `file:line` or `file:line:col` into the file and a line of at least 1
+fn split_target(target: &str) -> Option<(&str, usize)> {
+    let (rest, last) = target.rsplit_once(':')?;
+    let last: usize = last.parse().ok()?;
+
+    let (file, line) = match rest.rsplit_once(':') {
+        Some((file, line)) if line.parse::<usize>().is_ok() => (file, line.parse().ok()?),
+        _ => (rest, last),
+    };
+    (line > 0).then_some((file, line))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::Clone;
+
+    const PARSER: &str = "package parser\n\
+\n\
+//line parser.y:40\n\
+func reduce(n int) int {\n\
+\tif n > 0 {\n\
+\t\treturn n\n\
+\t}\n\
+\treturn 0\n\
+}\n\
+\n\
+//line yaccpar:1\n\
+func parse() int { return 1 }\n\
+var s = `\n\
+//line nowhere.go:9\n\
+`\n\
+/*line :70:5*/ func lex() int { return 2 }\n";
+
+    #[test]
+    fn test_parse_and_resolve_positions() {
+        let directives = parse_line_directives(Path::new("gen/y.go"), PARSER).unwrap();
+        assert_eq!(
+            directives,
+            vec![
+                LineDirective { from_line: 4, file: PathBuf::from("gen/parser.y"), line: 40 },
+                LineDirective { from_line: 12, file: PathBuf::from("gen/yaccpar"), line: 1 },
+                LineDirective { from_line: 16, file: PathBuf::from("gen/yaccpar"), line: 70 },
+            ]
+        );
+
+        let file = SourceFile::new("gen/y.go", PARSER).unwrap();
+        let positions = Positions::from_files(&[file]).unwrap();
+        let at = |line| {
+            let p = positions.position(Path::new("gen/y.go"), line);
+            (p.file.display().to_string(), p.line)
+        };
+        assert_eq!(at(2), ("gen/y.go".to_string(), 2));
+        assert_eq!(at(6), ("gen/parser.y".to_string(), 42));
+        assert_eq!(at(14), ("gen/yaccpar".to_string(), 3));
+        assert_eq!(at(16), ("gen/yaccpar".to_string(), 70));
+        assert_eq!(at(3), ("gen/y.go".to_string(), 3));
+    }
+
+    #[test]
+    fn test_report_moves_to_original_files() {
+        let file = SourceFile::new("gen/y.go", PARSER).unwrap();
+        let positions = Positions::from_files(std::slice::from_ref(&file)).unwrap();
+        let report = FileReport::from_source(file.path.clone(), &file.content, file.language).unwrap();
+        let clone = Clone {
+            id: 1,
+            length: 20,
+            locations: vec![
+                CloneLocation { file: PathBuf::from("gen/y.go"), start_line: 5, end_line: 7, ..Default::default() },
+                CloneLocation { file: PathBuf::from("gen/y.go"), start_line: 8, end_line: 12, ..Default::default() },
+            ],
+            hash: 0,
+            group_id: String::new(),
+        };
+
+        let report = positions.resolve(Report::new(vec![report], vec![clone]));
+        let functions: Vec<(String, &str, usize)> = report
+            .files
+            .iter()
+            .flat_map(|f| {
+                f.cyclomatic
+                    .functions
+                    .iter()
+                    .map(|func| (f.path.display().to_string(), func.name.as_str(), func.line))
+            })
+            .collect();
+        assert_eq!(
+            functions,
+            vec![
+                ("gen/parser.y".to_string(), "reduce", 40),
+                ("gen/yaccpar".to_string(), "parse", 1),
+                ("gen/yaccpar".to_string(), "lex", 70),
+            ]
+        );
+        assert_eq!(report.files[0].loc.physical, 17);
+
+        let locations: Vec<(String, usize, usize)> = report.clones[0]
+            .locations
+            .iter()
+            .map(|l| (l.file.display().to_string(), l.start_line, l.end_line))
+            .collect();
+        assert_eq!(
+            locations,
+            vec![
+                ("gen/parser.y".to_string(), 41, 43),
+                ("gen/parser.y".to_string(), 44, 44)
+            ]
+        );
+    }
+}
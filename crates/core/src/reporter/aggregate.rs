@@ -0,0 +1,294 @@
+use crate::config::ComplexityConfig;
+use crate::reporter::Report;
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, BTreeSet};
+use std::path::{Path, PathBuf};
+
+/// Totals for a set of files
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Rollup {
+    pub files: usize,
+    pub functions: usize,
+    /// Mean cyclomatic complexity per function
+    pub avg_complexity: f64,
+    /// Highest cyclomatic complexity of any function
+    pub max_complexity: usize,
+    /// Functions above the warning threshold
+    pub functions_over_threshold: usize,
+    /// Clone groups with at least one instance in these files
+    pub clone_groups: usize,
+    /// Lines inside at least one clone instance, each counted once
+    pub duplicated_lines: usize,
+}
+
+/// Rollup for the files of one package: a directory, split by Go package name
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct PackageRollup {
+    pub package: PathBuf,
+    /// Go package name when known; a directory can hold both `foo` and `foo_test`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub name: Option<String>,
+    #[serde(flatten)]
+    pub rollup: Rollup,
+}
+
+/// Per-package and repository-wide rollups of a [`Report`]
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Aggregate {
+    /// One entry per directory and package name, sorted by path then name
+    pub packages: Vec<PackageRollup>,
+    pub total: Rollup,
+}
+
+/// Running sums for one rollup
+#[derive(Default)]
+struct Tally {
+    files: BTreeSet<PathBuf>,
+    complexities: Vec<usize>,
+    over_threshold: usize,
+    clone_groups: BTreeSet<usize>,
+    duplicated_lines: usize,
+}
+
+impl Tally {
+    fn rollup(&self) -> Rollup {
+        let functions = self.complexities.len();
+        let avg_complexity =
+            if functions > 0 { self.complexities.iter().sum::<usize>() as f64 / functions as f64 } else { 0.0 };
+
+        Rollup {
+            files: self.files.len(),
+            functions,
+            avg_complexity,
+            max_complexity: self.complexities.iter().max().copied().unwrap_or(0),
+            functions_over_threshold: self.over_threshold,
+            clone_groups: self.clone_groups.len(),
+            duplicated_lines: self.duplicated_lines,
+        }
+    }
+}
+
+impl Report {
+    /// Roll complexity and duplication up per package and for the whole report
+    ///
+    /// A package is the directory of a file together with its Go package name, so the
+    /// external tests of a `foo_test` package are rolled up apart from `foo`. Clone groups are
+    /// still detected across both and count toward each package they touch.
+    ///
+    /// Duplicated lines are those of [`Report::duplication`], the union of all clone instance
+    /// ranges in each file, so a line covered by several overlapping clone groups counts once
+    /// and the total matches `summary.duplicated_lines`.
+    pub fn aggregate(&self, thresholds: &ComplexityConfig) -> Aggregate {
+        let names: BTreeMap<&Path, &str> = self
+            .files
+            .iter()
+            .filter_map(|file| Some((file.path.as_path(), file.package.as_deref()?)))
+            .collect();
+        let key = |path: &Path| (package_of(path), names.get(path).map(|name| name.to_string()));
+
+        let mut packages: BTreeMap<(PathBuf, Option<String>), Tally> = BTreeMap::new();
+        let mut total = Tally::default();
+
+        for file in &self.files {
+            let package = packages.entry(key(&file.path)).or_default();
+            let limit = thresholds.for_path(&file.path).warning_threshold;
+            for tally in [&mut *package, &mut total] {
+                tally.files.insert(file.path.clone());
+                for func in &file.cyclomatic.functions {
+                    tally.complexities.push(func.cyclomatic);
+                    if func.cyclomatic > limit {
+                        tally.over_threshold += 1;
+                    }
+                }
+            }
+        }
+
+        // Instances in files outside the report, such as unchanged files under `--diff`, count
+        // toward the group but toward no package
+        for (idx, clone) in self.clones.iter().enumerate() {
+            total.clone_groups.insert(idx);
+            for loc in &clone.locations {
+                if let Some(package) = packages.get_mut(&key(&loc.file)) {
+                    package.clone_groups.insert(idx);
+                }
+            }
+        }
+
+        for (file, duplication) in self.duplication() {
+            packages.entry(key(file)).or_default().duplicated_lines += duplication.lines;
+            total.duplicated_lines += duplication.lines;
+        }
+
+        Aggregate {
+            packages: packages
+                .into_iter()
+                .map(|((package, name), tally)| PackageRollup { package, name, rollup: tally.rollup() })
+                .collect(),
+            total: total.rollup(),
+        }
+    }
+
+    pub fn to_aggregate_json(&self, thresholds: &ComplexityConfig) -> serde_json::Result<String> {
+        serde_json::to_string_pretty(&self.aggregate(thresholds))
+    }
+}
+
+/// Directory holding a file, `.` for files at the top level
+fn package_of(path: &Path) -> PathBuf {
+    match path.parent() {
+        Some(parent) if !parent.as_os_str().is_empty() => parent.to_path_buf(),
+        _ => PathBuf::from("."),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::{Clone, CloneLocation};
+    use crate::complexity::{CyclomaticMetrics, FunctionComplexity, HalsteadMetrics, LocMetrics};
+    use crate::reporter::FileReport;
+
+    fn file(path: &str, complexities: &[usize]) -> FileReport {
+        FileReport {
+            path: PathBuf::from(path),
+            loc: LocMetrics { physical: 40, logical: 30, comments: 5, blank: 5, statements: 25 },
+            cyclomatic: CyclomaticMetrics {
+                file_complexity: complexities.iter().sum(),
+                functions: complexities
+                    .iter()
+                    .enumerate()
+                    .map(|(idx, &cyclomatic)| FunctionComplexity {
+                        name: format!("f{idx}"),
+                        cyclomatic,
+                        cognitive: 0,
+                        max_nesting: 0,
+                        line: idx * 10 + 1,
+                        halstead: HalsteadMetrics::default(),
+                        ..Default::default()
+                    })
+                    .collect(),
+            },
+            maintainability_index: 60.0,
+            findings: Vec::new(),
+            package: None,
+        }
+    }
+
+    fn clone(locations: &[(&str, usize, usize)]) -> Clone {
+        Clone {
+            id: 1,
+            length: 40,
+            locations: locations
+                .iter()
+                .map(|(file, start, end)| CloneLocation {
+                    file: PathBuf::from(file),
+                    start_line: *start,
+                    end_line: *end,
+                    gap_tokens: 0,
+                    ..Default::default()
+                })
+                .collect(),
+            hash: 7,
+            group_id: String::new(),
+        }
+    }
+
+    fn report() -> Report {
+        Report::new(
+            vec![
+                file("pkg/a/one.go", &[2, 12]),
+                file("pkg/a/two.go", &[4]),
+                file("pkg/b/three.go", &[25, 1]),
+                file("main.go", &[1]),
+            ],
+            vec![
+                clone(&[("pkg/a/one.go", 10, 20), ("pkg/b/three.go", 1, 11)]),
+                clone(&[("pkg/a/one.go", 15, 25), ("pkg/a/two.go", 5, 15)]),
+            ],
+        )
+    }
+
+    #[test]
+    fn test_package_rollups() {
+        let aggregate = report().aggregate(&ComplexityConfig::default());
+        let packages: Vec<&str> = aggregate.packages.iter().map(|p| p.package.to_str().unwrap()).collect();
+        assert_eq!(packages, vec![".", "pkg/a", "pkg/b"]);
+
+        let a = &aggregate.packages[1].rollup;
+        assert_eq!(
+            *a,
+            Rollup {
+                files: 2,
+                functions: 3,
+                avg_complexity: 6.0,
+                max_complexity: 12,
+                functions_over_threshold: 1,
+                clone_groups: 2,
+                // one.go 10-25 once despite the overlapping groups, plus two.go 5-15
+                duplicated_lines: 16 + 11,
+            }
+        );
+        assert_eq!(aggregate.packages[0].rollup.clone_groups, 0);
+    }
+
+    #[test]
+    fn test_total_rollup() {
+        let total = report().aggregate(&ComplexityConfig::default()).total;
+
+        assert_eq!(total.files, 4);
+        assert_eq!(total.functions, 6);
+        assert_eq!(total.avg_complexity, 45.0 / 6.0);
+        assert_eq!(total.max_complexity, 25);
+        assert_eq!(total.functions_over_threshold, 2);
+        assert_eq!(total.clone_groups, 2);
+        assert_eq!(total.duplicated_lines, 16 + 11 + 11);
+    }
+
+    #[test]
+    fn test_external_test_package_rolls_up_separately() {
+        use crate::{analyzer::Analyzer, config::Config, loader::FileLoader};
+
+        let dir = Path::new(env!("CARGO_MANIFEST_DIR")).join("testdata/multipkg");
+        let files = FileLoader::new().load(&dir).unwrap();
+        let mut config = Config::default();
+        config.clones.min_tokens = 20;
+        let report = Analyzer::new(config.clone()).with_jobs(1).analyze(&files).unwrap();
+        let aggregate = report.aggregate(&config.complexity);
+
+        let packages: Vec<(Option<&str>, usize, usize)> = aggregate
+            .packages
+            .iter()
+            .map(|p| (p.name.as_deref(), p.rollup.files, p.rollup.functions))
+            .collect();
+        // The in-package test file belongs to `shapes`, the external one to `shapes_test`
+        assert_eq!(packages, vec![(Some("shapes"), 2, 3), (Some("shapes_test"), 1, 2)]);
+        assert!(aggregate.packages[0].package.ends_with("multipkg"));
+
+        // The copy of Scale in the external test package is still found
+        let crosses = |clone: &Clone| {
+            let names: BTreeSet<_> = clone.locations.iter().filter_map(|l| l.file.file_name()).collect();
+            names.contains(std::ffi::OsStr::new("shapes.go")) && names.contains(std::ffi::OsStr::new("shapes_test.go"))
+        };
+        assert!(report.clones.iter().any(crosses));
+        assert!(aggregate.packages.iter().all(|p| p.rollup.clone_groups > 0));
+    }
+
+    #[test]
+    fn test_clones_reaching_files_outside_the_report() {
+        // As under `--diff`, where only pkg/a/one.go is left in the report
+        let report = Report::new(
+            vec![file("pkg/a/one.go", &[2])],
+            vec![clone(&[("pkg/a/one.go", 1, 35), ("other.go", 1, 35)])],
+        );
+        let aggregate = report.aggregate(&ComplexityConfig::default());
+
+        let packages: Vec<&str> = aggregate.packages.iter().map(|p| p.package.to_str().unwrap()).collect();
+        assert_eq!(packages, vec!["pkg/a"]);
+        assert_eq!(aggregate.packages[0].rollup.duplicated_lines, 35);
+        assert_eq!(aggregate.total.duplicated_lines, report.summary.duplicated_lines);
+        assert_eq!(aggregate.total.clone_groups, 1);
+    }
+}
@@ -0,0 +1,157 @@
+use crate::reporter::Report;
+use std::path::Path;
+
+/// Header row of the complexity table
+pub const COMPLEXITY_HEADER: &[&str] = &["file", "function", "startLine", "cyclomatic", "cognitive", "nesting"];
+/// Header row of the clone table
+pub const CLONES_HEADER: &[&str] = &["groupId", "file", "startLine", "endLine", "tokens"];
+
+impl Report {
+    /// One row per function, in report order, under a [`COMPLEXITY_HEADER`] row
+    pub fn to_complexity_csv(&self) -> String {
+        let mut csv = row(COMPLEXITY_HEADER);
+
+        for file in &self.files {
+            let path = display_path(&file.path);
+            for func in &file.cyclomatic.functions {
+                csv.push_str(&row(&[
+                    &path,
+                    &func.name,
+                    &func.line.to_string(),
+                    &func.cyclomatic.to_string(),
+                    &func.cognitive.to_string(),
+                    &func.max_nesting.to_string(),
+                ]));
+            }
+        }
+
+        csv
+    }
+
+    /// One row per clone instance under a [`CLONES_HEADER`] row
+    ///
+    /// `tokens` counts the tokens the instance spans, its gap tokens included.
+    pub fn to_clones_csv(&self) -> String {
+        let mut csv = row(CLONES_HEADER);
+
+        for clone in &self.clones {
+            for loc in &clone.locations {
+                csv.push_str(&row(&[
+                    &clone.id.to_string(),
+                    &display_path(&loc.file),
+                    &loc.start_line.to_string(),
+                    &loc.end_line.to_string(),
+                    &(clone.length + loc.gap_tokens).to_string(),
+                ]));
+            }
+        }
+
+        csv
+    }
+
+    /// The complexity table, a blank line, then the clone table
+    ///
+    /// Only tables with rows are included; an empty report yields the complexity header alone.
+    pub fn to_csv(&self) -> String {
+        let has_functions = self.files.iter().any(|f| !f.cyclomatic.functions.is_empty());
+
+        match (has_functions, self.clones.is_empty()) {
+            (_, true) => self.to_complexity_csv(),
+            (false, false) => self.to_clones_csv(),
+            (true, false) => format!("{}\r\n{}", self.to_complexity_csv(), self.to_clones_csv()),
+        }
+    }
+}
+
+/// A CSV record terminated by CRLF, as in RFC 4180
+fn row(fields: &[&str]) -> String {
+    let mut line = fields.iter().map(|field| escape(field)).collect::<Vec<_>>().join(",");
+    line.push_str("\r\n");
+    line
+}
+
+/// Quote a field containing a comma, quote, or line break, doubling any quotes inside it
+fn escape(field: &str) -> String {
+    if field.contains([',', '"', '\n', '\r']) {
+        format!("\"{}\"", field.replace('"', "\"\""))
+    } else {
+        field.to_string()
+    }
+}
+
+fn display_path(path: &Path) -> String {
+    path.to_string_lossy().replace('\\', "/")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::{Clone, CloneLocation};
+    use crate::complexity::{CyclomaticMetrics, FunctionComplexity, LocMetrics};
+    use crate::reporter::FileReport;
+    use std::path::PathBuf;
+
+    fn report(clones: Vec<Clone>) -> Report {
+        let function = |name: &str, cyclomatic, line| FunctionComplexity {
+            name: name.to_string(),
+            cyclomatic,
+            cognitive: 2,
+            max_nesting: 1,
+            line,
+            ..Default::default()
+        };
+        Report::new(
+            vec![FileReport {
+                path: PathBuf::from("pkg/a,b.go"),
+                loc: LocMetrics { physical: 40, logical: 30, comments: 5, blank: 5, statements: 25 },
+                cyclomatic: CyclomaticMetrics {
+                    file_complexity: 5,
+                    functions: vec![function("Run", 4, 3), function("(*Server).\"odd\"", 1, 20)],
+                },
+                maintainability_index: 60.0,
+                findings: Vec::new(),
+                package: None,
+            }],
+            clones,
+        )
+    }
+
+    #[test]
+    fn test_complexity_rows_are_escaped() {
+        assert_eq!(
+            report(vec![]).to_csv(),
+            "file,function,startLine,cyclomatic,cognitive,nesting\r\n\
+             \"pkg/a,b.go\",Run,3,4,2,1\r\n\
+             \"pkg/a,b.go\",\"(*Server).\"\"odd\"\"\",20,1,2,1\r\n"
+        );
+    }
+
+    #[test]
+    fn test_clone_section() {
+        let location = |file: &str, start_line, end_line, gap_tokens| CloneLocation {
+            file: PathBuf::from(file),
+            start_line,
+            end_line,
+            gap_tokens,
+            ..Default::default()
+        };
+        let clone = Clone {
+            id: 1,
+            length: 40,
+            locations: vec![location("a.go", 3, 12, 0), location("b.go", 8, 18, 2)],
+            hash: 7,
+            group_id: String::new(),
+        };
+
+        let csv = report(vec![clone.clone()]).to_csv();
+        let (complexity, clones) = csv.split_once("\r\n\r\n").unwrap();
+        assert_eq!(complexity.lines().count(), 3);
+        assert_eq!(
+            clones,
+            "groupId,file,startLine,endLine,tokens\r\n1,a.go,3,12,40\r\n1,b.go,8,18,42\r\n"
+        );
+
+        let clones_only = Report::new(vec![], vec![clone]).to_csv();
+        assert!(clones_only.starts_with("groupId,"));
+    }
+}
@@ -0,0 +1,169 @@
+use crate::cloner::Clone;
+use crate::config::ComplexityConfig;
+use crate::reporter::Report;
+use std::path::Path;
+
+impl Report {
+    /// Format findings as GitHub Actions workflow commands
+    ///
+    /// Each line is a `::warning` annotation, which Actions shows in the job log and on the
+    /// matching line of the pull request's files view. Functions above the warning threshold get
+    /// one annotation; every instance of a clone group gets its own, naming the other instances.
+    pub fn to_github_annotations(&self, thresholds: &ComplexityConfig) -> String {
+        let mut output = String::new();
+
+        for file in &self.files {
+            let thresholds = thresholds.for_path(&file.path);
+            for func in &file.cyclomatic.functions {
+                if func.cyclomatic <= thresholds.warning_threshold {
+                    continue;
+                }
+
+                output.push_str(&annotation(
+                    &file.path,
+                    func.line,
+                    func.line,
+                    "Cyclomatic complexity",
+                    &format!(
+                        "Function '{}' has cyclomatic complexity {} (threshold {})",
+                        func.name, func.cyclomatic, thresholds.warning_threshold
+                    ),
+                ));
+            }
+        }
+
+        for clone in &self.clones {
+            push_clone_annotations(&mut output, clone);
+        }
+
+        output
+    }
+}
+
+fn push_clone_annotations(output: &mut String, clone: &Clone) {
+    for (idx, loc) in clone.locations.iter().enumerate() {
+        let others: Vec<String> = clone
+            .locations
+            .iter()
+            .enumerate()
+            .filter(|(other, _)| *other != idx)
+            .map(|(_, partner)| format!("{}:{}", display_path(&partner.file), partner.start_line))
+            .collect();
+
+        output.push_str(&annotation(
+            &loc.file,
+            loc.start_line,
+            loc.end_line,
+            "Duplicated code",
+            &format!(
+                "Clone group #{}: {} tokens also found at {}",
+                clone.id,
+                clone.length,
+                others.join(", ")
+            ),
+        ));
+    }
+}
+
+fn annotation(path: &Path, line: usize, end_line: usize, title: &str, message: &str) -> String {
+    format!(
+        "::warning file={},line={line},endLine={end_line},title={}::{}\n",
+        escape_property(&display_path(path)),
+        escape_property(title),
+        escape_data(message)
+    )
+}
+
+fn display_path(path: &Path) -> String {
+    path.to_string_lossy().replace('\\', "/")
+}
+
+/// Escape a workflow command message
+fn escape_data(value: &str) -> String {
+    value.replace('%', "%25").replace('\r', "%0D").replace('\n', "%0A")
+}
+
+/// Escape a workflow command property, which also may not contain `:` or `,`
+fn escape_property(value: &str) -> String {
+    escape_data(value).replace(':', "%3A").replace(',', "%2C")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::CloneLocation;
+    use crate::complexity::{CyclomaticMetrics, FunctionComplexity, HalsteadMetrics, LocMetrics};
+    use crate::reporter::FileReport;
+    use std::path::PathBuf;
+
+    fn function(name: &str, cyclomatic: usize, line: usize) -> FunctionComplexity {
+        FunctionComplexity {
+            name: name.to_string(),
+            cyclomatic,
+            cognitive: 0,
+            max_nesting: 0,
+            line,
+            halstead: HalsteadMetrics::default(),
+            ..Default::default()
+        }
+    }
+
+    fn report() -> Report {
+        Report::new(
+            vec![FileReport {
+                path: PathBuf::from("pkg/handler.go"),
+                loc: LocMetrics { physical: 40, logical: 30, comments: 5, blank: 5, statements: 25 },
+                cyclomatic: CyclomaticMetrics {
+                    file_complexity: 14,
+                    functions: vec![function("Handle", 12, 8), function("ok", 2, 30)],
+                },
+                maintainability_index: 60.0,
+                findings: Vec::new(),
+                package: None,
+            }],
+            vec![Clone {
+                id: 1,
+                length: 42,
+                locations: vec![
+                    CloneLocation {
+                        file: PathBuf::from("a.go"),
+                        start_line: 3,
+                        end_line: 9,
+                        gap_tokens: 0,
+                        ..Default::default()
+                    },
+                    CloneLocation {
+                        file: PathBuf::from("b.go"),
+                        start_line: 10,
+                        end_line: 16,
+                        gap_tokens: 0,
+                        ..Default::default()
+                    },
+                ],
+                hash: 7,
+                group_id: String::new(),
+            }],
+        )
+    }
+
+    #[test]
+    fn test_annotations() {
+        let output = report().to_github_annotations(&ComplexityConfig::default());
+        let lines: Vec<&str> = output.lines().collect();
+
+        assert_eq!(
+            lines,
+            vec![
+                "::warning file=pkg/handler.go,line=8,endLine=8,title=Cyclomatic complexity::Function 'Handle' has cyclomatic complexity 12 (threshold 10)",
+                "::warning file=a.go,line=3,endLine=9,title=Duplicated code::Clone group #1: 42 tokens also found at b.go:10",
+                "::warning file=b.go,line=10,endLine=16,title=Duplicated code::Clone group #1: 42 tokens also found at a.go:3",
+            ]
+        );
+    }
+
+    #[test]
+    fn test_escaping() {
+        assert_eq!(escape_data("100% done\nnext"), "100%25 done%0Anext");
+        assert_eq!(escape_property("a,b:c"), "a%2Cb%3Ac");
+    }
+}
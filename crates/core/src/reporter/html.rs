@@ -0,0 +1,339 @@
+use crate::cloner::{Clone, CloneLocation};
+use crate::config::ComplexityConfig;
+use crate::highlight::{Highlighter, escape_html};
+use crate::loader::SourceFile;
+use crate::reporter::Report;
+use std::collections::HashMap;
+use std::fmt::Write;
+use std::path::Path;
+
+/// Lines of surrounding source shown above and below each clone instance
+const CONTEXT_LINES: usize = 3;
+
+const STYLE: &str = r#"
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2rem; color: #1f2328; }
+h1 { margin-bottom: 0.25rem; }
+h2 { border-bottom: 1px solid #d0d7de; padding-bottom: 0.3rem; margin-top: 2.5rem; }
+.summary { display: flex; flex-wrap: wrap; gap: 1rem; margin: 1rem 0; }
+.summary div { border: 1px solid #d0d7de; border-radius: 6px; padding: 0.5rem 1rem; }
+.summary strong { display: block; font-size: 1.4rem; }
+table.hotspots { border-collapse: collapse; width: 100%; }
+table.hotspots th, table.hotspots td { border: 1px solid #d0d7de; padding: 0.3rem 0.6rem; text-align: left; }
+table.hotspots th { background: #f6f8fa; cursor: pointer; user-select: none; }
+table.hotspots th::after { content: " \2195"; color: #8c959f; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+tr.warning td.cyclomatic { background: #fff8c5; }
+tr.error td.cyclomatic { background: #ffebe9; font-weight: bold; }
+.group h3 { margin-bottom: 0.5rem; }
+.instances { display: flex; gap: 1rem; overflow-x: auto; }
+.instance { flex: 1 0 28rem; min-width: 0; border: 1px solid #d0d7de; border-radius: 6px; overflow: hidden; }
+.instance .title { background: #f6f8fa; padding: 0.4rem 0.6rem; font-family: monospace; border-bottom: 1px solid #d0d7de; }
+table.code { border-collapse: collapse; width: 100%; background: #2b303b; font: 12px/1.5 "SFMono-Regular", Consolas, monospace; }
+table.code td { padding: 0 0.6rem; white-space: pre; }
+table.code td.ln { color: #65737e; text-align: right; user-select: none; width: 1%; }
+table.code tr.context { opacity: 0.45; }
+table.code tr.match { background: #3b4252; }
+table.code tr.match td.ln { color: #ebcb8b; border-left: 3px solid #ebcb8b; }
+.empty { color: #57606a; }
+"#;
+
+const SCRIPT: &str = r#"
+document.querySelectorAll("table.hotspots th").forEach(function (th, col) {
+  th.addEventListener("click", function () {
+    var body = th.closest("table").tBodies[0];
+    var asc = th.dataset.dir !== "asc";
+    th.dataset.dir = asc ? "asc" : "desc";
+    var rows = Array.prototype.slice.call(body.rows);
+    rows.sort(function (a, b) {
+      var x = a.cells[col].dataset.sort || a.cells[col].textContent;
+      var y = b.cells[col].dataset.sort || b.cells[col].textContent;
+      var nx = parseFloat(x), ny = parseFloat(y);
+      var cmp = isNaN(nx) || isNaN(ny) ? x.localeCompare(y) : nx - ny;
+      return asc ? cmp : -cmp;
+    });
+    rows.forEach(function (row) { body.appendChild(row); });
+  });
+});
+"#;
+
+impl Report {
+    /// Render a self-contained HTML page
+    ///
+    /// Functions above the warning threshold are listed in a sortable table. Each clone group
+    /// shows its instances side by side, with the matched lines highlighted against a few lines
+    /// of surrounding context. `files` supplies the source text; instances whose file is missing
+    /// are listed without code.
+    pub fn to_html(&self, files: &[SourceFile], thresholds: &ComplexityConfig) -> String {
+        let sources: HashMap<&Path, &SourceFile> = files.iter().map(|f| (f.path.as_path(), f)).collect();
+        let highlighter = Highlighter::new();
+        let mut html = String::new();
+
+        html.push_str("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n");
+        html.push_str("<title>Mccabre Report</title>\n");
+        let _ = writeln!(html, "<style>{STYLE}</style>");
+        html.push_str("</head>\n<body>\n<h1>Mccabre Report</h1>\n");
+
+        self.write_summary(&mut html);
+        self.write_hotspots(&mut html, thresholds);
+
+        html.push_str("<h2>Clone Groups</h2>\n");
+        if self.clones.is_empty() {
+            html.push_str("<p class=\"empty\">No clones detected.</p>\n");
+        }
+        for clone in &self.clones {
+            write_clone(&mut html, clone, &sources, &highlighter);
+        }
+
+        let _ = writeln!(html, "<script>{SCRIPT}</script>");
+        html.push_str("</body>\n</html>\n");
+        html
+    }
+
+    fn write_summary(&self, html: &mut String) {
+        let summary = &self.summary;
+        html.push_str("<div class=\"summary\">\n");
+        for (label, value) in [
+            ("Files", summary.total_files.to_string()),
+            ("Logical LOC", summary.total_logical_loc.to_string()),
+            ("Average complexity", format!("{:.2}", summary.avg_complexity)),
+            ("Maximum complexity", summary.max_complexity.to_string()),
+            ("Clone groups", summary.total_clones.to_string()),
+            ("Duplication", format!("{:.1}%", summary.duplication_ratio * 100.0)),
+        ] {
+            let _ = writeln!(html, "<div><strong>{value}</strong>{label}</div>");
+        }
+        html.push_str("</div>\n");
+    }
+
+    fn write_hotspots(&self, html: &mut String, thresholds: &ComplexityConfig) {
+        let mut hotspots: Vec<_> = self
+            .files
+            .iter()
+            .flat_map(|file| {
+                let limits = thresholds.for_path(&file.path);
+                file.cyclomatic
+                    .functions
+                    .iter()
+                    .filter(move |func| func.cyclomatic > limits.warning_threshold)
+                    .map(move |func| (file, func, limits.error_threshold))
+            })
+            .collect();
+        hotspots.sort_by(|(a_file, a, _), (b_file, b, _)| {
+            b.cyclomatic
+                .cmp(&a.cyclomatic)
+                .then_with(|| (&a_file.path, a.line).cmp(&(&b_file.path, b.line)))
+        });
+
+        let overridden = if thresholds.overrides.is_empty() { "" } else { ", or the threshold set for their path" };
+        let _ = writeln!(
+            html,
+            "<h2>Complexity Hotspots</h2>\n<p>Functions with cyclomatic complexity above {}{overridden}.</p>",
+            thresholds.warning_threshold
+        );
+        if hotspots.is_empty() {
+            html.push_str("<p class=\"empty\">No functions above the threshold.</p>\n");
+            return;
+        }
+
+        html.push_str("<table class=\"hotspots\">\n<thead><tr>");
+        html.push_str(
+            "<th>File</th><th>Function</th><th>Line</th><th>Cyclomatic</th><th>Cognitive</th><th>Nesting</th>",
+        );
+        html.push_str("</tr></thead>\n<tbody>\n");
+        for (file, func, error_threshold) in hotspots {
+            let level = if func.cyclomatic > error_threshold { "error" } else { "warning" };
+            let _ = writeln!(
+                html,
+                "<tr class=\"{level}\"><td>{}</td><td>{}</td><td class=\"num\">{}</td>\
+                 <td class=\"num cyclomatic\">{}</td><td class=\"num\">{}</td><td class=\"num\">{}</td></tr>",
+                escape_html(&file.path.display().to_string()),
+                escape_html(&func.name),
+                func.line,
+                func.cyclomatic,
+                func.cognitive,
+                func.max_nesting
+            );
+        }
+        html.push_str("</tbody>\n</table>\n");
+    }
+}
+
+fn write_clone(html: &mut String, clone: &Clone, sources: &HashMap<&Path, &SourceFile>, highlighter: &Highlighter) {
+    let _ = writeln!(
+        html,
+        "<section class=\"group\" id=\"clone-{}\">\n<h3>Clone Group #{} &middot; {} tokens, {} instances</h3>",
+        clone.id,
+        clone.id,
+        clone.length,
+        clone.locations.len()
+    );
+    html.push_str("<div class=\"instances\">\n");
+
+    for loc in &clone.locations {
+        let gap_note = if loc.gap_tokens > 0 { format!(" ({} tokens differ)", loc.gap_tokens) } else { String::new() };
+        let _ = writeln!(
+            html,
+            "<div class=\"instance\">\n<div class=\"title\">{}:{}-{}{}</div>",
+            escape_html(&loc.file.display().to_string()),
+            loc.start_line,
+            loc.end_line,
+            gap_note
+        );
+
+        if let Some(source) = sources.get(loc.file.as_path()) {
+            write_code(html, loc, source, highlighter);
+        }
+        html.push_str("</div>\n");
+    }
+
+    html.push_str("</div>\n</section>\n");
+}
+
+/// Render the instance's lines plus surrounding context as a numbered code table
+fn write_code(html: &mut String, loc: &CloneLocation, source: &SourceFile, highlighter: &Highlighter) {
+    let first = loc.start_line.saturating_sub(CONTEXT_LINES).max(1);
+    let last = loc.end_line + CONTEXT_LINES;
+    let excerpt: Vec<&str> = source.content.lines().skip(first - 1).take(last - first + 1).collect();
+
+    let extension = source.path.extension().and_then(|e| e.to_str()).unwrap_or("txt");
+    let highlighted = highlighter.highlight_html_lines(&excerpt.join("\n"), extension);
+
+    html.push_str("<table class=\"code\">\n");
+    for (offset, line) in highlighted.iter().enumerate() {
+        let number = first + offset;
+        let class = if (loc.start_line..=loc.end_line).contains(&number) { "match" } else { "context" };
+        let _ = writeln!(
+            html,
+            "<tr class=\"{class}\"><td class=\"ln\">{number}</td><td>{line}</td></tr>"
+        );
+    }
+    html.push_str("</table>\n");
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::complexity::{CyclomaticMetrics, FunctionComplexity, HalsteadMetrics, LocMetrics};
+    use crate::reporter::FileReport;
+    use crate::tokenizer::Language;
+    use std::path::PathBuf;
+
+    fn source(path: &str, lines: usize) -> SourceFile {
+        let content = (1..=lines)
+            .map(|n| format!("let line{n} = a < b;"))
+            .collect::<Vec<_>>()
+            .join("\n");
+        SourceFile { path: PathBuf::from(path), content, language: Language::Rust }
+    }
+
+    fn function(name: &str, cyclomatic: usize, line: usize) -> FunctionComplexity {
+        FunctionComplexity {
+            name: name.to_string(),
+            cyclomatic,
+            cognitive: 1,
+            max_nesting: 0,
+            line,
+            halstead: HalsteadMetrics::default(),
+            ..Default::default()
+        }
+    }
+
+    fn sample_report() -> Report {
+        let files = vec![FileReport {
+            path: PathBuf::from("src/a.rs"),
+            loc: LocMetrics { physical: 20, logical: 20, comments: 0, blank: 0, statements: 20 },
+            cyclomatic: CyclomaticMetrics {
+                file_complexity: 40,
+                functions: vec![
+                    function("small", 2, 1),
+                    function("busy<T>", 12, 5),
+                    function("tangled", 25, 9),
+                ],
+            },
+            maintainability_index: 40.0,
+            findings: Vec::new(),
+            package: None,
+        }];
+        let clones = vec![Clone {
+            id: 1,
+            length: 30,
+            locations: vec![
+                CloneLocation {
+                    file: PathBuf::from("src/a.rs"),
+                    start_line: 5,
+                    end_line: 8,
+                    gap_tokens: 0,
+                    ..Default::default()
+                },
+                CloneLocation {
+                    file: PathBuf::from("src/b.rs"),
+                    start_line: 2,
+                    end_line: 5,
+                    gap_tokens: 3,
+                    ..Default::default()
+                },
+            ],
+            hash: 0,
+            group_id: String::new(),
+        }];
+
+        Report::new(files, clones)
+    }
+
+    fn render() -> String {
+        sample_report().to_html(
+            &[source("src/a.rs", 20), source("src/b.rs", 6)],
+            &ComplexityConfig::default(),
+        )
+    }
+
+    #[test]
+    fn test_page_is_self_contained() {
+        let html = render();
+
+        assert!(html.starts_with("<!DOCTYPE html>"));
+        assert!(html.contains("<style>"));
+        assert!(html.contains("<script>"));
+        assert!(!html.contains("<link"));
+        assert!(!html.contains("src=\""));
+    }
+
+    #[test]
+    fn test_hotspots_table() {
+        let html = render();
+
+        assert!(html.contains("<table class=\"hotspots\">"));
+        assert!(html.contains("busy&lt;T&gt;"));
+        assert!(!html.contains("<td>small</td>"));
+
+        let tangled = html.find("<td>tangled</td>").unwrap();
+        let busy = html.find("<td>busy&lt;T&gt;</td>").unwrap();
+        assert!(tangled < busy);
+        assert!(html.contains("<tr class=\"error\"><td>src/a.rs</td><td>tangled</td>"));
+    }
+
+    #[test]
+    fn test_clone_instances_with_context() {
+        let html = render();
+
+        assert!(html.contains("Clone Group #1 &middot; 30 tokens, 2 instances"));
+        assert!(html.contains("src/b.rs:2-5 (3 tokens differ)"));
+        assert_eq!(html.matches("<table class=\"code\">").count(), 2);
+
+        // a.rs shows lines 2-11: three context lines on each side of 5-8
+        assert!(html.contains("<tr class=\"context\"><td class=\"ln\">2</td>"));
+        assert!(html.contains("<tr class=\"match\"><td class=\"ln\">5</td>"));
+        assert!(html.contains("<tr class=\"match\"><td class=\"ln\">8</td>"));
+        assert!(html.contains("<tr class=\"context\"><td class=\"ln\">11</td>"));
+        assert!(!html.contains("<td class=\"ln\">12</td>"));
+        assert!(html.contains("a &lt; b"));
+    }
+
+    #[test]
+    fn test_empty_report() {
+        let html = Report::new(vec![], vec![]).to_html(&[], &ComplexityConfig::default());
+
+        assert!(html.contains("No clones detected."));
+        assert!(html.contains("No functions above the threshold."));
+    }
+}
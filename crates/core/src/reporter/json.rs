@@ -0,0 +1,678 @@
+use crate::cloner::{
+    Clone, CloneLocation, DuplicateDeclaration, ErrcheckCluster, FunctionClone, SimilarFunction, SimilarPair,
+};
+use crate::complexity::{FunctionComplexity, HalsteadMetrics, Severity};
+use crate::reporter::{Report, SortOrder};
+use crate::syntax::ParseError;
+use serde::{Deserialize, Serialize};
+use std::path::{Path, PathBuf};
+
+/// Version of the JSON document layout, bumped on incompatible changes
+pub const SCHEMA_VERSION: &str = "1";
+
+/// Stable JSON document for scripting
+///
+/// Unlike the raw [`Report`] serialization, field names are camelCase and every array is
+/// sorted by file and then start line, so two runs over the same tree produce identical output.
+/// [`JsonReport::from_report_sorted`] orders the `complexity` array by a [`SortOrder`] instead.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JsonReport {
+    pub schema_version: String,
+    pub complexity: Vec<JsonFunction>,
+    pub clones: Vec<JsonCloneGroup>,
+    pub files: Vec<JsonFile>,
+    pub findings: Vec<JsonFinding>,
+    /// Files left out of the analysis, sorted by file
+    pub parse_errors: Vec<ParseError>,
+    /// Repeated error-handling blocks, largest cluster first; left out unless requested
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub errcheck_clusters: Vec<JsonErrcheckCluster>,
+    /// Function pairs with similar bodies, highest score first; left out unless requested
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub similar_functions: Vec<JsonSimilarPair>,
+    /// Copied top-level declaration blocks, longest first; left out unless requested
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub duplicate_declarations: Vec<JsonDuplicateDeclaration>,
+    /// Functions with matching bodies, longest first; left out unless requested
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub function_clones: Vec<JsonFunctionClone>,
+    pub summary: JsonSummary,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JsonFunction {
+    pub file: PathBuf,
+    pub function: String,
+    pub line: usize,
+    pub column: usize,
+    pub end_line: usize,
+    pub end_column: usize,
+    pub cyclomatic: usize,
+    pub cognitive: usize,
+    pub max_nesting: usize,
+    pub lines: usize,
+    pub statements: usize,
+    #[serde(default)]
+    pub returns: usize,
+    pub severity: Severity,
+    pub halstead: JsonHalstead,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JsonHalstead {
+    pub distinct_operators: usize,
+    pub distinct_operands: usize,
+    pub total_operators: usize,
+    pub total_operands: usize,
+    pub volume: f64,
+    pub difficulty: f64,
+    pub effort: f64,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JsonCloneGroup {
+    /// Group number, matching `Clone Group #N` in text output
+    pub id: usize,
+    /// Hash of the group's normalized tokens, the same in every run and on every machine
+    #[serde(default)]
+    pub group_id: String,
+    /// Rolling hash of the first token window, which only tells groups of one run apart; use
+    /// `group_id` to follow a group across runs
+    #[serde(alias = "fingerprint")]
+    pub window_hash: String,
+    /// Matched tokens shared by every instance
+    pub token_count: usize,
+    pub instances: Vec<JsonCloneInstance>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JsonCloneInstance {
+    pub file: PathBuf,
+    pub start_line: usize,
+    pub end_line: usize,
+    pub start_column: usize,
+    pub end_column: usize,
+    /// Byte offset of the first character
+    pub start_offset: usize,
+    /// Byte offset just past the last character
+    pub end_offset: usize,
+    /// Tokens spanned by this instance, including mismatched ones
+    pub token_count: usize,
+    /// Tokens inside the span that did not match the other instances
+    pub gap_tokens: usize,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JsonErrcheckCluster {
+    pub id: usize,
+    /// Normalized block shared by every instance
+    pub shape: String,
+    pub instances: Vec<JsonSpan>,
+}
+
+/// Region of one file, positioned as a clone instance is
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JsonSpan {
+    pub file: PathBuf,
+    pub start_line: usize,
+    pub end_line: usize,
+    pub start_column: usize,
+    pub end_column: usize,
+    pub start_offset: usize,
+    pub end_offset: usize,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JsonDuplicateDeclaration {
+    pub id: usize,
+    /// `const`, `var`, or `type`
+    pub keyword: String,
+    pub specs: usize,
+    /// Each block from its keyword to the closing parenthesis
+    pub instances: Vec<JsonSpan>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JsonFunctionClone {
+    pub id: usize,
+    /// Whether the bodies match verbatim rather than after renaming identifiers and literals
+    pub exact: bool,
+    pub tokens: usize,
+    pub functions: Vec<JsonSimilarFunction>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JsonSimilarPair {
+    pub id: usize,
+    /// Jaccard similarity of the two bodies, rounded to 3 decimals
+    pub score: f64,
+    pub functions: Vec<JsonSimilarFunction>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JsonSimilarFunction {
+    pub file: PathBuf,
+    pub function: String,
+    pub start_line: usize,
+    pub end_line: usize,
+    pub start_column: usize,
+    pub end_column: usize,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JsonFile {
+    pub file: PathBuf,
+    pub cyclomatic: usize,
+    pub maintainability_index: f64,
+    pub physical_loc: usize,
+    pub logical_loc: usize,
+    pub comment_lines: usize,
+    pub blank_lines: usize,
+    pub statements: usize,
+    pub comment_density: f64,
+    pub duplicated_lines: usize,
+    pub duplication_ratio: f64,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JsonFinding {
+    pub rule: String,
+    pub file: PathBuf,
+    pub function: String,
+    pub line: usize,
+    pub column: usize,
+    pub end_column: usize,
+    pub message: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub suggestion: Option<String>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JsonSummary {
+    pub total_files: usize,
+    pub total_physical_loc: usize,
+    pub total_logical_loc: usize,
+    pub avg_complexity: f64,
+    pub max_complexity: usize,
+    pub high_complexity_files: usize,
+    pub total_clones: usize,
+    pub suppressed_complexity: usize,
+    #[serde(default)]
+    pub suppressed_findings: usize,
+    pub suppressed_clones: usize,
+    pub duplicated_lines: usize,
+    pub duplication_ratio: f64,
+}
+
+impl JsonReport {
+    /// Build the document with functions ordered by file and line
+    pub fn from_report(report: &Report) -> Self {
+        Self::from_report_sorted(report, SortOrder::File)
+    }
+
+    /// Build the document with functions in the given order
+    pub fn from_report_sorted(report: &Report, order: SortOrder) -> Self {
+        let mut functions: Vec<(&Path, &FunctionComplexity)> = report
+            .files
+            .iter()
+            .flat_map(|file| file.cyclomatic.functions.iter().map(|func| (file.path.as_path(), func)))
+            .collect();
+        functions.sort_by(|a, b| order.compare(*a, *b));
+
+        let complexity: Vec<JsonFunction> = functions
+            .into_iter()
+            .map(|(file, func)| JsonFunction {
+                file: file.to_path_buf(),
+                function: func.name.clone(),
+                line: func.line,
+                column: func.column,
+                end_line: func.end_line,
+                end_column: func.end_column,
+                cyclomatic: func.cyclomatic,
+                cognitive: func.cognitive,
+                max_nesting: func.max_nesting,
+                lines: func.lines,
+                statements: func.statements,
+                returns: func.returns,
+                severity: func.severity,
+                halstead: JsonHalstead::from_metrics(&func.halstead),
+            })
+            .collect();
+
+        let mut clones: Vec<JsonCloneGroup> = report.clones.iter().map(JsonCloneGroup::from_clone).collect();
+        clones.sort_by(|a, b| a.sort_key().cmp(&b.sort_key()));
+
+        let duplication = report.duplication();
+        let mut files: Vec<JsonFile> = report
+            .files
+            .iter()
+            .map(|file| JsonFile {
+                file: file.path.clone(),
+                cyclomatic: file.cyclomatic.file_complexity,
+                maintainability_index: (file.maintainability_index * 100.0).round() / 100.0,
+                physical_loc: file.loc.physical,
+                logical_loc: file.loc.logical,
+                comment_lines: file.loc.comments,
+                blank_lines: file.loc.blank,
+                statements: file.loc.statements,
+                comment_density: (file.loc.comment_density() * 1000.0).round() / 1000.0,
+                duplicated_lines: duplication[file.path.as_path()].lines,
+                duplication_ratio: (duplication[file.path.as_path()].ratio * 1000.0).round() / 1000.0,
+            })
+            .collect();
+        files.sort_by(|a, b| a.file.cmp(&b.file));
+
+        let mut findings: Vec<JsonFinding> = report
+            .files
+            .iter()
+            .flat_map(|file| {
+                file.findings.iter().map(|finding| JsonFinding {
+                    rule: finding.rule.clone(),
+                    file: file.path.clone(),
+                    function: finding.function.clone(),
+                    line: finding.line,
+                    column: finding.column,
+                    end_column: finding.end_column,
+                    message: finding.message.clone(),
+                    suggestion: finding.suggestion.clone(),
+                })
+            })
+            .collect();
+        findings.sort_by(|a, b| (&a.file, a.line, &a.rule).cmp(&(&b.file, b.line, &b.rule)));
+
+        let mut parse_errors = report.parse_errors.clone();
+        parse_errors.sort_by(|a, b| a.file.cmp(&b.file));
+
+        let errcheck_clusters = report
+            .errcheck_clusters
+            .iter()
+            .map(JsonErrcheckCluster::from_cluster)
+            .collect();
+
+        let similar_functions = report
+            .similar_functions
+            .iter()
+            .map(JsonSimilarPair::from_pair)
+            .collect();
+
+        let duplicate_declarations = report
+            .duplicate_declarations
+            .iter()
+            .map(JsonDuplicateDeclaration::from_duplicate)
+            .collect();
+
+        let function_clones = report
+            .function_clones
+            .iter()
+            .map(JsonFunctionClone::from_clone)
+            .collect();
+
+        let summary = JsonSummary {
+            total_files: report.summary.total_files,
+            total_physical_loc: report.summary.total_physical_loc,
+            total_logical_loc: report.summary.total_logical_loc,
+            avg_complexity: report.summary.avg_complexity,
+            max_complexity: report.summary.max_complexity,
+            high_complexity_files: report.summary.high_complexity_files,
+            total_clones: report.summary.total_clones,
+            suppressed_complexity: report.suppressed.complexity,
+            suppressed_findings: report.suppressed.findings,
+            suppressed_clones: report.suppressed.clones,
+            duplicated_lines: report.summary.duplicated_lines,
+            duplication_ratio: (report.summary.duplication_ratio * 1000.0).round() / 1000.0,
+        };
+
+        Self {
+            schema_version: SCHEMA_VERSION.to_string(),
+            complexity,
+            clones,
+            files,
+            findings,
+            parse_errors,
+            errcheck_clusters,
+            similar_functions,
+            duplicate_declarations,
+            function_clones,
+            summary,
+        }
+    }
+
+    pub fn to_json(&self) -> serde_json::Result<String> {
+        serde_json::to_string_pretty(self)
+    }
+}
+
+impl JsonHalstead {
+    fn from_metrics(metrics: &HalsteadMetrics) -> Self {
+        let round = |value: f64| (value * 100.0).round() / 100.0;
+        Self {
+            distinct_operators: metrics.distinct_operators,
+            distinct_operands: metrics.distinct_operands,
+            total_operators: metrics.total_operators,
+            total_operands: metrics.total_operands,
+            volume: round(metrics.volume()),
+            difficulty: round(metrics.difficulty()),
+            effort: round(metrics.effort()),
+        }
+    }
+}
+
+impl JsonCloneGroup {
+    fn from_clone(clone: &Clone) -> Self {
+        let mut instances: Vec<JsonCloneInstance> = clone
+            .locations
+            .iter()
+            .map(|loc| JsonCloneInstance {
+                file: loc.file.clone(),
+                start_line: loc.start_line,
+                end_line: loc.end_line,
+                start_column: loc.start_column,
+                end_column: loc.end_column,
+                start_offset: loc.start_offset,
+                end_offset: loc.end_offset,
+                token_count: clone.length + loc.gap_tokens,
+                gap_tokens: loc.gap_tokens,
+            })
+            .collect();
+        instances.sort_by(|a, b| (&a.file, a.start_line, a.end_line).cmp(&(&b.file, b.start_line, b.end_line)));
+
+        Self {
+            id: clone.id,
+            group_id: clone.group_id.clone(),
+            window_hash: clone.window_hash(),
+            token_count: clone.length,
+            instances,
+        }
+    }
+
+    fn sort_key(&self) -> (Option<(&PathBuf, usize, usize)>, usize, usize) {
+        let first = self.instances.first().map(|i| (&i.file, i.start_line, i.end_line));
+        (first, self.token_count, self.id)
+    }
+}
+
+impl JsonSpan {
+    fn from_location(loc: &CloneLocation) -> Self {
+        Self {
+            file: loc.file.clone(),
+            start_line: loc.start_line,
+            end_line: loc.end_line,
+            start_column: loc.start_column,
+            end_column: loc.end_column,
+            start_offset: loc.start_offset,
+            end_offset: loc.end_offset,
+        }
+    }
+}
+
+impl JsonErrcheckCluster {
+    fn from_cluster(cluster: &ErrcheckCluster) -> Self {
+        Self {
+            id: cluster.id,
+            shape: cluster.shape.clone(),
+            instances: cluster.instances.iter().map(JsonSpan::from_location).collect(),
+        }
+    }
+}
+
+impl JsonDuplicateDeclaration {
+    fn from_duplicate(duplicate: &DuplicateDeclaration) -> Self {
+        Self {
+            id: duplicate.id,
+            keyword: duplicate.keyword.clone(),
+            specs: duplicate.specs,
+            instances: duplicate.instances.iter().map(JsonSpan::from_location).collect(),
+        }
+    }
+}
+
+impl JsonFunctionClone {
+    fn from_clone(clone: &FunctionClone) -> Self {
+        Self {
+            id: clone.id,
+            exact: clone.exact,
+            tokens: clone.tokens,
+            functions: clone.functions.iter().map(JsonSimilarFunction::from_function).collect(),
+        }
+    }
+}
+
+impl JsonSimilarPair {
+    fn from_pair(pair: &SimilarPair) -> Self {
+        Self {
+            id: pair.id,
+            score: (pair.score * 1000.0).round() / 1000.0,
+            functions: pair.functions.iter().map(JsonSimilarFunction::from_function).collect(),
+        }
+    }
+}
+
+impl JsonSimilarFunction {
+    fn from_function(f: &SimilarFunction) -> Self {
+        Self {
+            file: f.location.file.clone(),
+            function: f.name.clone(),
+            start_line: f.location.start_line,
+            end_line: f.location.end_line,
+            start_column: f.location.start_column,
+            end_column: f.location.end_column,
+        }
+    }
+}
+
+impl Report {
+    /// Serialize to the stable, versioned JSON document
+    pub fn to_stable_json(&self) -> serde_json::Result<String> {
+        JsonReport::from_report(self).to_json()
+    }
+
+    /// Serialize to the stable JSON document with functions in the given order
+    pub fn to_sorted_json(&self, order: SortOrder) -> serde_json::Result<String> {
+        JsonReport::from_report_sorted(self, order).to_json()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::CloneLocation;
+    use crate::complexity::{CyclomaticMetrics, FunctionComplexity, LocMetrics};
+    use crate::reporter::FileReport;
+    use serde_json::Value;
+
+    fn file_report(path: &str, functions: Vec<(&str, usize)>) -> FileReport {
+        FileReport {
+            path: PathBuf::from(path),
+            loc: LocMetrics { physical: 10, logical: 8, comments: 1, blank: 1, statements: 8 },
+            cyclomatic: CyclomaticMetrics {
+                file_complexity: 3,
+                functions: functions
+                    .into_iter()
+                    .map(|(name, line)| FunctionComplexity {
+                        name: name.to_string(),
+                        cyclomatic: 2,
+                        cognitive: 1,
+                        max_nesting: 0,
+                        line,
+                        halstead: HalsteadMetrics {
+                            distinct_operators: 6,
+                            distinct_operands: 4,
+                            total_operators: 6,
+                            total_operands: 7,
+                        },
+                        ..Default::default()
+                    })
+                    .collect(),
+            },
+            maintainability_index: 75.5,
+            findings: Vec::new(),
+            package: None,
+        }
+    }
+
+    fn clone(id: usize, locations: &[(&str, usize, usize)]) -> Clone {
+        Clone {
+            id,
+            length: 30,
+            locations: locations
+                .iter()
+                .map(|(file, start_line, end_line)| CloneLocation {
+                    file: PathBuf::from(file),
+                    start_line: *start_line,
+                    end_line: *end_line,
+                    gap_tokens: 0,
+                    ..Default::default()
+                })
+                .collect(),
+            hash: 0,
+            group_id: String::new(),
+        }
+    }
+
+    #[test]
+    fn test_schema_fields() {
+        let report = Report::new(
+            vec![file_report("a.rs", vec![("main", 1)])],
+            vec![clone(1, &[("a.rs", 3, 9), ("b.rs", 5, 11)])],
+        );
+        let value: Value = serde_json::from_str(&report.to_stable_json().unwrap()).unwrap();
+
+        assert_eq!(value["schemaVersion"], SCHEMA_VERSION);
+        assert_eq!(value["complexity"][0]["function"], "main");
+        assert_eq!(value["complexity"][0]["cognitive"], 1);
+        assert_eq!(value["complexity"][0]["severity"], "low");
+        assert_eq!(value["complexity"][0]["halstead"]["totalOperands"], 7);
+        assert_eq!(value["complexity"][0]["halstead"]["difficulty"], 5.25);
+
+        let instance = &value["clones"][0]["instances"][1];
+        assert_eq!(instance["file"], "b.rs");
+        assert_eq!(instance["startLine"], 5);
+        assert_eq!(instance["endLine"], 11);
+        assert_eq!(instance["tokenCount"], 30);
+        assert_eq!(value["clones"][0]["windowHash"], "0000000000000000");
+        assert_eq!(value["summary"]["totalClones"], 1);
+        assert_eq!(value["files"][0]["maintainabilityIndex"], 75.5);
+    }
+
+    #[test]
+    fn test_ordering_is_by_file_then_line() {
+        let report = Report::new(
+            vec![
+                file_report("b.rs", vec![("later", 20), ("early", 2)]),
+                file_report("a.rs", vec![("only", 7)]),
+            ],
+            vec![
+                clone(1, &[("c.rs", 40, 50), ("b.rs", 10, 20)]),
+                clone(2, &[("a.rs", 30, 35), ("a.rs", 1, 6)]),
+            ],
+        );
+        let json = JsonReport::from_report(&report);
+
+        let functions: Vec<(&str, usize)> = json
+            .complexity
+            .iter()
+            .map(|f| (f.file.to_str().unwrap(), f.line))
+            .collect();
+        assert_eq!(functions, vec![("a.rs", 7), ("b.rs", 2), ("b.rs", 20)]);
+
+        let groups: Vec<usize> = json.clones.iter().map(|g| g.id).collect();
+        assert_eq!(groups, vec![2, 1]);
+        assert_eq!(json.clones[0].instances[0].start_line, 1);
+        assert_eq!(json.clones[1].instances[0].file, PathBuf::from("b.rs"));
+
+        let files: Vec<&str> = json.files.iter().map(|f| f.file.to_str().unwrap()).collect();
+        assert_eq!(files, vec!["a.rs", "b.rs"]);
+    }
+
+    #[test]
+    fn test_sorted_complexity() {
+        let mut b = file_report("b.rs", vec![("zeta", 20), ("alpha", 2)]);
+        b.cyclomatic.functions[0].cyclomatic = 9;
+        let mut a = file_report("a.rs", vec![("mid", 7)]);
+        a.cyclomatic.functions[0].cognitive = 4;
+        let report = Report::new(vec![b, a], vec![]);
+
+        let order = |sort| {
+            JsonReport::from_report_sorted(&report, sort)
+                .complexity
+                .iter()
+                .map(|f| f.function.clone())
+                .collect::<Vec<_>>()
+        };
+
+        assert_eq!(order(SortOrder::Complexity), vec!["zeta", "mid", "alpha"]);
+        assert_eq!(order(SortOrder::Name), vec!["alpha", "mid", "zeta"]);
+        assert_eq!(order(SortOrder::File), vec!["mid", "alpha", "zeta"]);
+    }
+
+    #[test]
+    fn test_gap_tokens_count_toward_instance_span() {
+        let mut gapped = clone(1, &[("a.rs", 1, 10), ("b.rs", 1, 11)]);
+        gapped.locations[1].gap_tokens = 4;
+
+        let json = JsonReport::from_report(&Report::new(vec![], vec![gapped]));
+        let instances = &json.clones[0].instances;
+
+        assert_eq!(json.clones[0].token_count, 30);
+        assert_eq!((instances[0].token_count, instances[0].gap_tokens), (30, 0));
+        assert_eq!((instances[1].token_count, instances[1].gap_tokens), (34, 4));
+    }
+
+    #[test]
+    fn test_findings_carry_file() {
+        let source = "func clean(s string) string {\n\tout := \"\"\n\tfor _, c := range s {\n\t\tout += string(c)\n\t}\n\treturn out\n}\n";
+        let file = FileReport::from_source(PathBuf::from("clean.go"), source, crate::tokenizer::Language::Go).unwrap();
+        let value = serde_json::to_value(JsonReport::from_report(&Report::new(vec![file], vec![]))).unwrap();
+
+        assert_eq!(value["findings"][0]["rule"], "string-concat-in-loop");
+        assert_eq!(value["findings"][0]["file"], "clean.go");
+        assert_eq!(value["findings"][0]["function"], "clean");
+        assert_eq!(value["findings"][0]["line"], 4);
+    }
+
+    #[test]
+    fn test_parse_errors() {
+        let mut report = Report::new(vec![], vec![]);
+        report.parse_errors.push(ParseError {
+            file: PathBuf::from("bad.go"),
+            line: 3,
+            column: 9,
+            message: "newline in string".to_string(),
+        });
+        let value = serde_json::to_value(JsonReport::from_report(&report)).unwrap();
+
+        assert_eq!(
+            value["parseErrors"],
+            serde_json::json!([{ "file": "bad.go", "line": 3, "column": 9, "message": "newline in string" }])
+        );
+    }
+
+    #[test]
+    fn test_output_is_deterministic() {
+        let build = || {
+            Report::new(
+                vec![file_report("a.rs", vec![("x", 1), ("y", 5)])],
+                vec![
+                    clone(1, &[("a.rs", 1, 4), ("b.rs", 2, 5)]),
+                    clone(2, &[("a.rs", 9, 12), ("b.rs", 9, 12)]),
+                ],
+            )
+        };
+
+        assert_eq!(build().to_stable_json().unwrap(), build().to_stable_json().unwrap());
+    }
+}
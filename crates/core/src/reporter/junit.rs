@@ -0,0 +1,258 @@
+use crate::cloner::Clone;
+use crate::config::ComplexityConfig;
+use crate::highlight::escape_html;
+use crate::reporter::Report;
+use crate::reporter::sarif::{CLONE_RULE_ID, COMPLEXITY_RULE_ID};
+use crate::rules::NESTING;
+use std::fmt::Write;
+use std::path::{Path, PathBuf};
+
+/// One `<testcase>`, failing when `failure` is set
+struct TestCase {
+    name: String,
+    line: usize,
+    failure: Option<Failure>,
+}
+
+struct Failure {
+    kind: &'static str,
+    message: String,
+}
+
+impl Report {
+    /// Format findings as JUnit XML for CI test-report integrations
+    ///
+    /// Each file becomes a `<testsuite>`. Every threshold violation is a `<testcase>` with a
+    /// `<failure>` whose message gives the metric value and the threshold it exceeded: cyclomatic
+    /// complexity above the warning threshold, nesting deeper than `max_nesting`, and one case per
+    /// clone instance. With `include_passing`, functions without violations are emitted as
+    /// passing cases; otherwise passing functions, and files without failures, are left out.
+    pub fn to_junit(&self, thresholds: &ComplexityConfig, include_passing: bool) -> String {
+        let mut suites: Vec<(PathBuf, Vec<TestCase>)> = Vec::new();
+
+        for file in &self.files {
+            let thresholds = thresholds.for_path(&file.path);
+            let mut cases = Vec::new();
+            for func in &file.cyclomatic.functions {
+                let before = cases.len();
+                if func.cyclomatic > thresholds.warning_threshold {
+                    cases.push(TestCase {
+                        name: format!("{} cyclomatic complexity", func.name),
+                        line: func.line,
+                        failure: Some(Failure {
+                            kind: COMPLEXITY_RULE_ID,
+                            message: format!(
+                                "Function '{}' has cyclomatic complexity {} (threshold {})",
+                                func.name, func.cyclomatic, thresholds.warning_threshold
+                            ),
+                        }),
+                    });
+                }
+                if func.max_nesting > thresholds.max_nesting {
+                    cases.push(TestCase {
+                        name: format!("{} nesting depth", func.name),
+                        line: func.line,
+                        failure: Some(Failure {
+                            kind: NESTING,
+                            message: format!(
+                                "Function '{}' has nesting depth {} (threshold {})",
+                                func.name, func.max_nesting, thresholds.max_nesting
+                            ),
+                        }),
+                    });
+                }
+                if include_passing && cases.len() == before {
+                    cases.push(TestCase { name: func.name.clone(), line: func.line, failure: None });
+                }
+            }
+            suites.push((file.path.clone(), cases));
+        }
+
+        for clone in &self.clones {
+            push_clone_cases(&mut suites, clone);
+        }
+
+        if !include_passing {
+            suites.retain(|(_, cases)| !cases.is_empty());
+        }
+
+        let tests: usize = suites.iter().map(|(_, cases)| cases.len()).sum();
+        let failures = suites.iter().map(|(_, cases)| failure_count(cases)).sum::<usize>();
+
+        let mut xml = String::from("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n");
+        let _ = writeln!(
+            xml,
+            r#"<testsuites name="mccabre" tests="{tests}" failures="{failures}">"#
+        );
+
+        for (path, cases) in &suites {
+            let path = display_path(path);
+            let _ = writeln!(
+                xml,
+                r#"  <testsuite name="{}" tests="{}" failures="{}">"#,
+                escape_html(&path),
+                cases.len(),
+                failure_count(cases)
+            );
+            for case in cases {
+                let _ = write!(
+                    xml,
+                    r#"    <testcase name="{}" classname="{}" file="{}" line="{}""#,
+                    escape_html(&case.name),
+                    escape_html(&path),
+                    escape_html(&path),
+                    case.line
+                );
+                match &case.failure {
+                    Some(failure) => {
+                        let _ = writeln!(
+                            xml,
+                            r#"><failure type="{}" message="{}">{}:{}</failure></testcase>"#,
+                            failure.kind,
+                            escape_html(&failure.message),
+                            escape_html(&path),
+                            case.line
+                        );
+                    }
+                    None => xml.push_str("/>\n"),
+                }
+            }
+            xml.push_str("  </testsuite>\n");
+        }
+
+        xml.push_str("</testsuites>\n");
+        xml
+    }
+}
+
+/// Add a failing case for every instance of a clone group to its file's suite
+fn push_clone_cases(suites: &mut Vec<(PathBuf, Vec<TestCase>)>, clone: &Clone) {
+    for (idx, loc) in clone.locations.iter().enumerate() {
+        let others: Vec<String> = clone
+            .locations
+            .iter()
+            .enumerate()
+            .filter(|(other, _)| *other != idx)
+            .map(|(_, partner)| format!("{}:{}", display_path(&partner.file), partner.start_line))
+            .collect();
+
+        let case = TestCase {
+            name: format!("clone #{} lines {}-{}", clone.id, loc.start_line, loc.end_line),
+            line: loc.start_line,
+            failure: Some(Failure {
+                kind: CLONE_RULE_ID,
+                message: format!(
+                    "Clone group #{}: {} tokens also found at {}",
+                    clone.id,
+                    clone.length,
+                    others.join(", ")
+                ),
+            }),
+        };
+
+        match suites.iter_mut().find(|(path, _)| path == &loc.file) {
+            Some((_, cases)) => cases.push(case),
+            None => suites.push((loc.file.clone(), vec![case])),
+        }
+    }
+}
+
+fn failure_count(cases: &[TestCase]) -> usize {
+    cases.iter().filter(|case| case.failure.is_some()).count()
+}
+
+fn display_path(path: &Path) -> String {
+    path.to_string_lossy().replace('\\', "/")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::CloneLocation;
+    use crate::complexity::{CyclomaticMetrics, FunctionComplexity, HalsteadMetrics, LocMetrics};
+    use crate::reporter::FileReport;
+
+    fn function(name: &str, cyclomatic: usize, max_nesting: usize, line: usize) -> FunctionComplexity {
+        FunctionComplexity {
+            name: name.to_string(),
+            cyclomatic,
+            cognitive: 0,
+            max_nesting,
+            line,
+            halstead: HalsteadMetrics::default(),
+            ..Default::default()
+        }
+    }
+
+    fn file(path: &str, functions: Vec<FunctionComplexity>) -> FileReport {
+        FileReport {
+            path: PathBuf::from(path),
+            loc: LocMetrics { physical: 40, logical: 30, comments: 5, blank: 5, statements: 25 },
+            cyclomatic: CyclomaticMetrics { file_complexity: 14, functions },
+            maintainability_index: 60.0,
+            findings: Vec::new(),
+            package: None,
+        }
+    }
+
+    fn report() -> Report {
+        Report::new(
+            vec![
+                file(
+                    "pkg/handler.go",
+                    vec![function("Handle", 12, 6, 8), function("ok", 2, 1, 30)],
+                ),
+                file("pkg/util.go", vec![function("small", 1, 0, 3)]),
+            ],
+            vec![Clone {
+                id: 1,
+                length: 42,
+                locations: vec![
+                    CloneLocation {
+                        file: PathBuf::from("pkg/handler.go"),
+                        start_line: 3,
+                        end_line: 9,
+                        gap_tokens: 0,
+                        ..Default::default()
+                    },
+                    CloneLocation {
+                        file: PathBuf::from("pkg/b<1>.go"),
+                        start_line: 10,
+                        end_line: 16,
+                        gap_tokens: 0,
+                        ..Default::default()
+                    },
+                ],
+                hash: 7,
+                group_id: String::new(),
+            }],
+        )
+    }
+
+    #[test]
+    fn test_failures_only() {
+        let xml = report().to_junit(&ComplexityConfig::default(), false);
+
+        assert!(xml.starts_with("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"));
+        assert!(xml.contains(r#"<testsuites name="mccabre" tests="4" failures="4">"#));
+        assert!(xml.contains(r#"<testsuite name="pkg/handler.go" tests="3" failures="3">"#));
+        assert!(xml.contains(
+            r#"<failure type="complexity" message="Function &#39;Handle&#39; has cyclomatic complexity 12 (threshold 10)">pkg/handler.go:8</failure>"#
+        ));
+        assert!(xml.contains(r#"message="Function &#39;Handle&#39; has nesting depth 6 (threshold 4)""#));
+        assert!(xml.contains(r#"<testsuite name="pkg/b&lt;1&gt;.go" tests="1" failures="1">"#));
+        assert!(xml.contains(r#"message="Clone group #1: 42 tokens also found at pkg/b&lt;1&gt;.go:10""#));
+        assert!(!xml.contains("pkg/util.go"));
+        assert!(xml.ends_with("</testsuites>\n"));
+    }
+
+    #[test]
+    fn test_include_passing() {
+        let xml = report().to_junit(&ComplexityConfig::default(), true);
+
+        assert!(xml.contains(r#"<testsuites name="mccabre" tests="6" failures="4">"#));
+        assert!(xml.contains(r#"<testsuite name="pkg/util.go" tests="1" failures="0">"#));
+        assert!(xml.contains(r#"<testcase name="small" classname="pkg/util.go" file="pkg/util.go" line="3"/>"#));
+        assert!(!xml.contains(r#"<testcase name="Handle""#));
+    }
+}
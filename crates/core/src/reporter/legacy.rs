@@ -1,7 +1,20 @@
-use crate::cloner::Clone;
-use crate::complexity::{CyclomaticMetrics, LocMetrics, Severity};
+use crate::Result;
+use crate::cache::Cache;
+use crate::cloner::{Clone, DuplicateDeclaration, ErrcheckCluster, FunctionClone, SimilarPair};
+use crate::complexity::{
+    CyclomaticMetrics, FileMetrics, FunctionComplexity, HalsteadMetrics, LocMetrics, Severity, SeverityBands,
+    maintainability_from_metrics, physical_lines,
+};
+use crate::loader::{SourceFile, go_package_name};
+use crate::parallel::{Progress, map_ordered};
+use crate::rules::{self, Finding, LengthLimits};
+use crate::suppress::SuppressedCounts;
+use crate::syntax::ParseError;
+use crate::tokenizer::Language;
 use serde::{Deserialize, Serialize};
-use std::path::PathBuf;
+use std::cmp::Ordering;
+use std::collections::{BTreeMap, BTreeSet};
+use std::path::{Path, PathBuf};
 
 /// Complete analysis report for a codebase
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -12,6 +25,24 @@ pub struct Report {
     pub clones: Vec<Clone>,
     /// Summary statistics
     pub summary: Summary,
+    /// Findings dropped by `//mccabre:ignore` directives
+    #[serde(default)]
+    pub suppressed: SuppressedCounts,
+    /// Files left out because they could not be parsed
+    #[serde(default)]
+    pub parse_errors: Vec<ParseError>,
+    /// Repeated error-handling blocks, when requested with `--report-errcheck-clones`
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub errcheck_clusters: Vec<ErrcheckCluster>,
+    /// Function pairs with similar bodies, when requested with `--similarity-threshold`
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub similar_functions: Vec<SimilarPair>,
+    /// Copied top-level declaration blocks, when requested with `--report-decl-clones`
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub duplicate_declarations: Vec<DuplicateDeclaration>,
+    /// Functions with matching bodies, when requested with `--whole-function-clones`
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub function_clones: Vec<FunctionClone>,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -22,6 +53,15 @@ pub struct FileReport {
     pub loc: LocMetrics,
     /// Cyclomatic complexity metrics
     pub cyclomatic: CyclomaticMetrics,
+    /// Maintainability index (0-100, higher is better)
+    #[serde(default)]
+    pub maintainability_index: f64,
+    /// Rule findings, ordered by line
+    #[serde(default)]
+    pub findings: Vec<Finding>,
+    /// Name in the `package` clause of a Go file, such as `foo` or `foo_test`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub package: Option<String>,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -40,12 +80,112 @@ pub struct Summary {
     pub high_complexity_files: usize,
     /// Total number of clone groups
     pub total_clones: usize,
+    /// Physical lines covered by at least one clone instance, each counted once
+    #[serde(default)]
+    pub duplicated_lines: usize,
+    /// Duplicated lines over total physical lines, from 0 to 1
+    #[serde(default)]
+    pub duplication_ratio: f64,
+}
+
+/// Cloned physical lines of one file
+#[derive(Debug, Clone, Copy, Default, PartialEq)]
+pub struct Duplication {
+    /// Lines inside at least one clone instance; a line in several instances counts once
+    pub lines: usize,
+    /// `lines` over the file's physical lines, from 0 to 1
+    pub ratio: f64,
+}
+
+/// Ordering of functions in complexity output
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum SortOrder {
+    /// Highest cyclomatic complexity first, ties broken by cognitive complexity
+    #[default]
+    Complexity,
+    /// Function name, alphabetically
+    Name,
+    /// File path, then line
+    File,
+}
+
+impl SortOrder {
+    /// Compare two functions, each paired with the file it belongs to
+    ///
+    /// Ties fall back to file, line, and name so the order is total.
+    pub fn compare(&self, a: (&Path, &FunctionComplexity), b: (&Path, &FunctionComplexity)) -> Ordering {
+        let ((a_path, a), (b_path, b)) = (a, b);
+        let by_location = || (a_path, a.line, &a.name).cmp(&(b_path, b.line, &b.name));
+
+        match self {
+            Self::Complexity => (b.cyclomatic, b.cognitive)
+                .cmp(&(a.cyclomatic, a.cognitive))
+                .then_with(by_location),
+            Self::Name => a.name.cmp(&b.name).then_with(by_location),
+            Self::File => by_location(),
+        }
+    }
+}
+
+impl FileReport {
+    /// Compute all per-file metrics for a source file
+    pub fn from_source(path: PathBuf, source: &str, language: Language) -> Result<Self> {
+        let loc = LocMetrics::calculate(source, language)?;
+        let cyclomatic = CyclomaticMetrics::calculate(source, language)?;
+        let halstead = HalsteadMetrics::calculate(source, language)?;
+        let maintainability_index = maintainability_from_metrics(&halstead, &cyclomatic, &loc);
+        let findings = rules::check_source(source, language)?;
+        let package = match language {
+            Language::Go => go_package_name(source).map(str::to_string),
+            _ => None,
+        };
+
+        Ok(Self { path, loc, cyclomatic, maintainability_index, findings, package })
+    }
+
+    /// Size and comment metrics of this file
+    pub fn metrics(&self) -> FileMetrics {
+        FileMetrics::new(self.path.clone(), &self.loc)
+    }
+
+    /// Compute per-file metrics for loaded files on `jobs` worker threads, in input order
+    ///
+    /// With a cache, unchanged files are read back instead of being analyzed again. A `progress`
+    /// callback is told about each finished file under the phase name `Analyzing`.
+    pub fn from_files(
+        files: &[SourceFile], jobs: usize, cache: Option<&Cache>, progress: Option<&Progress>,
+    ) -> Result<Vec<Self>> {
+        let phase = progress.map(|p| p.phase("Analyzing", files.len()));
+        map_ordered(files, jobs, |file| {
+            let report = match cache {
+                Some(cache) => cache.file_report(file),
+                None => Self::from_source(file.path.clone(), &file.content, file.language),
+            };
+            if let Some(phase) = &phase {
+                phase.tick();
+            }
+            report
+        })
+        .into_iter()
+        .collect()
+    }
 }
 
 impl Report {
     pub fn new(files: Vec<FileReport>, clones: Vec<Clone>) -> Self {
         let summary = Summary::from_files(&files, &clones);
-        Self { files, clones, summary }
+        Self {
+            files,
+            clones,
+            summary,
+            suppressed: SuppressedCounts::default(),
+            parse_errors: Vec::new(),
+            errcheck_clusters: Vec::new(),
+            similar_functions: Vec::new(),
+            duplicate_declarations: Vec::new(),
+            function_clones: Vec::new(),
+        }
     }
 
     /// Serialize to JSON
@@ -53,6 +193,184 @@ impl Report {
         serde_json::to_string_pretty(self)
     }
 
+    /// Duplicated lines of every analyzed file, keyed by path
+    pub fn duplication(&self) -> BTreeMap<&Path, Duplication> {
+        duplication(physical(&self.files), &self.clones)
+    }
+
+    /// Count duplication against the physical lines of `files`, for a report without file metrics
+    ///
+    /// `mccabre clones` skips complexity analysis, so its report has no [`FileReport`]s to take
+    /// line counts from. This fills in the file, line, and duplication totals of the summary from
+    /// the sources instead, the same way [`Report::new`] does from file reports.
+    pub fn count_lines<'a>(&mut self, files: impl IntoIterator<Item = &'a SourceFile>) {
+        let lines: Vec<(&Path, usize)> = files
+            .into_iter()
+            .map(|f| (f.path.as_path(), physical_lines(&f.content)))
+            .collect();
+        let duplicated_lines = duplication(lines.iter().copied(), &self.clones)
+            .values()
+            .map(|d| d.lines)
+            .sum();
+
+        self.summary.total_files = lines.len();
+        self.summary.total_physical_loc = lines.iter().map(|(_, physical)| physical).sum();
+        self.summary.duplicated_lines = duplicated_lines;
+        self.summary.duplication_ratio = ratio(duplicated_lines, self.summary.total_physical_loc);
+    }
+
+    /// Reorder files and the functions within each file
+    ///
+    /// By complexity, files with the highest file complexity come first; otherwise files are
+    /// ordered by path.
+    pub fn sort(&mut self, order: SortOrder) {
+        for file in &mut self.files {
+            let path = file.path.as_path();
+            file.cyclomatic
+                .functions
+                .sort_by(|a, b| order.compare((path, a), (path, b)));
+        }
+
+        match order {
+            SortOrder::Complexity => self.files.sort_by(|a, b| {
+                b.cyclomatic
+                    .file_complexity
+                    .cmp(&a.cyclomatic.file_complexity)
+                    .then_with(|| a.path.cmp(&b.path))
+            }),
+            SortOrder::Name | SortOrder::File => self.files.sort_by(|a, b| a.path.cmp(&b.path)),
+        }
+    }
+
+    /// Rate every function's cyclomatic complexity against `bands`
+    pub fn classify(&mut self, bands: &SeverityBands) {
+        for func in self.files.iter_mut().flat_map(|f| &mut f.cyclomatic.functions) {
+            func.severity = bands.classify(func.cyclomatic);
+        }
+    }
+
+    /// Add a [`FUNCTION_TOO_LONG`](rules::FUNCTION_TOO_LONG) finding for every function longer than `limits`
+    ///
+    /// Like [`classify`](Self::classify), this runs on finished metrics, so cached reports
+    /// follow whatever limits the current run uses.
+    pub fn check_function_length(&mut self, limits: &LengthLimits) {
+        if limits.is_empty() {
+            return;
+        }
+
+        for file in &mut self.files {
+            let found: Vec<Finding> = file
+                .cyclomatic
+                .functions
+                .iter()
+                .filter_map(|func| rules::detect_long_function(func, limits))
+                .collect();
+            if !found.is_empty() {
+                file.findings.extend(found);
+                file.findings.sort_by_key(|f| f.line);
+            }
+        }
+    }
+
+    /// Add a [`TOO_MANY_PARAMETERS`](rules::TOO_MANY_PARAMETERS) finding for every function taking
+    /// more than `limit` parameters; `None` checks nothing
+    pub fn check_parameters(&mut self, limit: Option<usize>) {
+        let Some(limit) = limit else {
+            return;
+        };
+
+        for file in &mut self.files {
+            let found: Vec<Finding> = file
+                .cyclomatic
+                .functions
+                .iter()
+                .filter_map(|func| rules::detect_too_many_parameters(func, limit))
+                .collect();
+            if !found.is_empty() {
+                file.findings.extend(found);
+                file.findings.sort_by_key(|f| f.line);
+            }
+        }
+    }
+
+    /// Copy of the report listing only functions with cyclomatic complexity of at least `floor`
+    ///
+    /// Meant for display: the summary, files, and clones are kept as they are, so totals still
+    /// describe the whole analysis. A floor of 1 keeps every function.
+    pub fn with_min_complexity(&self, floor: usize) -> Report {
+        let mut report = self.clone();
+        for file in &mut report.files {
+            file.cyclomatic.functions.retain(|func| func.cyclomatic >= floor);
+        }
+        report
+    }
+
+    /// Copy of the report listing only the `n` most complex functions and the first `n` clone groups
+    ///
+    /// Functions are ranked across all files by [`SortOrder::Complexity`] and stay in their
+    /// current order within each file; clone groups keep report order, which puts the groups
+    /// with the most instances first. Like [`Report::with_min_complexity`], this is for display
+    /// and leaves the summary describing the whole analysis.
+    pub fn with_top(&self, n: usize) -> Report {
+        let mut ranked: Vec<(usize, usize)> = self
+            .files
+            .iter()
+            .enumerate()
+            .flat_map(|(f, file)| (0..file.cyclomatic.functions.len()).map(move |i| (f, i)))
+            .collect();
+        let entry = |(f, i): (usize, usize)| (self.files[f].path.as_path(), &self.files[f].cyclomatic.functions[i]);
+        ranked.sort_by(|&a, &b| SortOrder::Complexity.compare(entry(a), entry(b)));
+        let kept: BTreeSet<(usize, usize)> = ranked.into_iter().take(n).collect();
+
+        let mut report = self.clone();
+        for (f, file) in report.files.iter_mut().enumerate() {
+            let mut i = 0;
+            file.cyclomatic.functions.retain(|_| {
+                i += 1;
+                kept.contains(&(f, i - 1))
+            });
+        }
+        report.clones.truncate(n);
+        report
+    }
+
+    /// Drop clone groups nested inside a larger group, returning how many were dropped
+    ///
+    /// Group B is dropped when another group A covers exactly the same set of files and every
+    /// instance of B lies within an instance of A in the same file. This removes the sub-blocks
+    /// of a large duplicated block that would otherwise be reported on their own. Remaining
+    /// groups are renumbered from 1 in their existing order.
+    pub fn dedupe_overlapping(&mut self) -> usize {
+        let file_sets: Vec<BTreeSet<&Path>> = self
+            .clones
+            .iter()
+            .map(|clone| clone.locations.iter().map(|loc| loc.file.as_path()).collect())
+            .collect();
+        let reaches: Vec<Reach> = self.clones.iter().map(Reach::new).collect();
+
+        let nested: Vec<bool> = (0..self.clones.len())
+            .map(|b| {
+                (0..self.clones.len()).any(|a| {
+                    a != b
+                        && file_sets[a] == file_sets[b]
+                        && reaches[a].contains(&self.clones[b])
+                        // Identical spans: keep the first group
+                        && (!reaches[b].contains(&self.clones[a]) || a < b)
+                })
+            })
+            .collect();
+
+        let before = self.clones.len();
+        let mut flags = nested.into_iter();
+        self.clones.retain(|_| !flags.next().unwrap_or(false));
+        for (idx, clone) in self.clones.iter_mut().enumerate() {
+            clone.id = idx + 1;
+        }
+        self.summary.total_clones = self.clones.len();
+
+        before - self.clones.len()
+    }
+
     /// Generate plaintext report
     pub fn to_plaintext(&self) -> String {
         let mut output = String::new();
@@ -87,16 +405,29 @@ impl Report {
             "High complexity files:       {}\n",
             self.summary.high_complexity_files
         ));
+        output.push_str(&format!("Clone groups detected:       {}\n", self.summary.total_clones));
         output.push_str(&format!(
-            "Clone groups detected:       {}\n\n",
-            self.summary.total_clones
+            "Duplicated lines:            {} ({:.1}%)\n",
+            self.summary.duplicated_lines,
+            self.summary.duplication_ratio * 100.0
         ));
+        if self.suppressed.total() > 0 {
+            output.push_str(&format!(
+                "Suppressed findings:         {} ({} complexity, {} rule, {} clone)\n",
+                self.suppressed.total(),
+                self.suppressed.complexity,
+                self.suppressed.findings,
+                self.suppressed.clones
+            ));
+        }
+        output.push('\n');
 
         if !self.files.is_empty() {
             output.push_str("FILE METRICS\n");
             output.push_str(&"-".repeat(80));
             output.push('\n');
 
+            let duplication = self.duplication();
             for file in &self.files {
                 output.push_str(&format!("FILE: {}\n", file.path.display()));
                 output.push_str(&format!(
@@ -109,17 +440,32 @@ impl Report {
                         Severity::VeryHigh => "very high",
                     }
                 ));
+                output.push_str(&format!(
+                    "    Maintainability index:   {:.1}\n",
+                    file.maintainability_index
+                ));
                 output.push_str(&format!("    Physical LOC:            {}\n", file.loc.physical));
                 output.push_str(&format!("    Logical LOC:             {}\n", file.loc.logical));
+                output.push_str(&format!("    Statements:              {}\n", file.loc.statements));
                 output.push_str(&format!("    Comment lines:           {}\n", file.loc.comments));
-                output.push_str(&format!("    Blank lines:             {}\n\n", file.loc.blank));
+                output.push_str(&format!(
+                    "    Comment density:         {:.1}%\n",
+                    file.loc.comment_density() * 100.0
+                ));
+                output.push_str(&format!("    Blank lines:             {}\n", file.loc.blank));
+                let duplicated = duplication[file.path.as_path()];
+                output.push_str(&format!(
+                    "    Duplicated lines:        {} ({:.1}%)\n\n",
+                    duplicated.lines,
+                    duplicated.ratio * 100.0
+                ));
 
                 if !file.cyclomatic.functions.is_empty() {
                     output.push_str("    Functions:\n");
                     for func in &file.cyclomatic.functions {
                         output.push_str(&format!(
-                            "      - {} (line {}): complexity {}\n",
-                            func.name, func.line, func.complexity
+                            "      - {} (line {}): cyclomatic {} ({}), cognitive {}, nesting {}\n",
+                            func.name, func.line, func.cyclomatic, func.severity, func.cognitive, func.max_nesting
                         ));
                     }
                     output.push('\n');
@@ -134,7 +480,7 @@ impl Report {
 
             for clone in &self.clones {
                 output.push_str(&format!(
-                    "Clone Group #{} (length: {} tokens, {} occurrences)\n",
+                    "Clone Group #{} (length: {} tokens, {} instances)\n",
                     clone.id,
                     clone.length,
                     clone.locations.len()
@@ -159,6 +505,45 @@ impl Report {
     }
 }
 
+/// Instances of a group by file and start line, each with the furthest end line reached by it
+/// or an instance starting before it in the same file
+///
+/// A run of repeated tokens can form thousands of instances in one group, too many to compare
+/// with the instances of every other group one by one.
+struct Reach<'a> {
+    instances: Vec<(&'a Path, usize, usize)>,
+}
+
+impl<'a> Reach<'a> {
+    fn new(clone: &'a Clone) -> Self {
+        let mut instances: Vec<(&Path, usize, usize)> = clone
+            .locations
+            .iter()
+            .map(|loc| (loc.file.as_path(), loc.start_line, loc.end_line))
+            .collect();
+        instances.sort_unstable();
+        for i in 1..instances.len() {
+            if instances[i].0 == instances[i - 1].0 {
+                instances[i].2 = instances[i].2.max(instances[i - 1].2);
+            }
+        }
+        Self { instances }
+    }
+
+    /// Every instance of `inner` lies within one of these instances in the same file
+    fn contains(&self, inner: &Clone) -> bool {
+        inner.locations.iter().all(|loc| {
+            let at = self
+                .instances
+                .partition_point(|&(file, start, _)| (file, start) <= (loc.file.as_path(), loc.start_line));
+            at > 0 && {
+                let (file, _, reach) = self.instances[at - 1];
+                file == loc.file && loc.end_line <= reach
+            }
+        })
+    }
+}
+
 impl Summary {
     fn from_files(files: &[FileReport], clones: &[Clone]) -> Self {
         let total_files = files.len();
@@ -180,6 +565,7 @@ impl Summary {
             .count();
 
         let total_clones = clones.len();
+        let duplicated_lines = duplication(physical(files), clones).values().map(|d| d.lines).sum();
 
         Self {
             total_files,
@@ -189,10 +575,45 @@ impl Summary {
             max_complexity,
             high_complexity_files,
             total_clones,
+            duplicated_lines,
+            duplication_ratio: ratio(duplicated_lines, total_physical_loc),
         }
     }
 }
 
+/// Count the lines of each file that fall inside clone instances
+///
+/// Lines are collected into a set per file, so overlapping instances and lines shared by
+/// several groups count once and the result does not depend on clone order.
+fn duplication<'a>(
+    files: impl IntoIterator<Item = (&'a Path, usize)>, clones: &[Clone],
+) -> BTreeMap<&'a Path, Duplication> {
+    let physical: BTreeMap<&Path, usize> = files.into_iter().collect();
+    let mut lines: BTreeMap<&Path, BTreeSet<usize>> = physical.keys().map(|&path| (path, BTreeSet::new())).collect();
+    for loc in clones.iter().flat_map(|clone| &clone.locations) {
+        if let Some(covered) = lines.get_mut(loc.file.as_path()) {
+            covered.extend(loc.start_line..=loc.end_line);
+        }
+    }
+
+    physical
+        .into_iter()
+        .map(|(path, physical)| {
+            let covered = lines[path].len().min(physical);
+            (path, Duplication { lines: covered, ratio: ratio(covered, physical) })
+        })
+        .collect()
+}
+
+/// Path and physical line count of each file report
+fn physical(files: &[FileReport]) -> impl Iterator<Item = (&Path, usize)> {
+    files.iter().map(|f| (f.path.as_path(), f.loc.physical))
+}
+
+fn ratio(part: usize, whole: usize) -> f64 {
+    if whole == 0 { 0.0 } else { part as f64 / whole as f64 }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -210,13 +631,19 @@ mod tests {
         let files = vec![
             FileReport {
                 path: PathBuf::from("test1.rs"),
-                loc: LocMetrics { physical: 100, logical: 80, comments: 10, blank: 10 },
+                loc: LocMetrics { physical: 100, logical: 80, comments: 10, blank: 10, statements: 80 },
                 cyclomatic: CyclomaticMetrics { file_complexity: 5, functions: vec![] },
+                maintainability_index: 70.0,
+                findings: Vec::new(),
+                package: None,
             },
             FileReport {
                 path: PathBuf::from("test2.rs"),
-                loc: LocMetrics { physical: 50, logical: 40, comments: 5, blank: 5 },
+                loc: LocMetrics { physical: 50, logical: 40, comments: 5, blank: 5, statements: 40 },
                 cyclomatic: CyclomaticMetrics { file_complexity: 15, functions: vec![] },
+                maintainability_index: 50.0,
+                findings: Vec::new(),
+                package: None,
             },
         ];
 
@@ -229,6 +656,225 @@ mod tests {
         assert_eq!(report.summary.max_complexity, 15);
     }
 
+    #[test]
+    fn test_sort() {
+        let function = |name: &str, cyclomatic, line| FunctionComplexity {
+            name: name.to_string(),
+            cyclomatic,
+            cognitive: 0,
+            max_nesting: 0,
+            line,
+            halstead: HalsteadMetrics::default(),
+            ..Default::default()
+        };
+        let file = |path: &str, file_complexity, functions| FileReport {
+            path: PathBuf::from(path),
+            loc: LocMetrics { physical: 10, logical: 8, comments: 1, blank: 1, statements: 8 },
+            cyclomatic: CyclomaticMetrics { file_complexity, functions },
+            maintainability_index: 60.0,
+            findings: Vec::new(),
+            package: None,
+        };
+        let mut report = Report::new(
+            vec![
+                file("a.rs", 4, vec![function("parse", 2, 1), function("emit", 3, 9)]),
+                file("b.rs", 12, vec![function("run", 12, 5)]),
+            ],
+            vec![],
+        );
+
+        report.sort(SortOrder::Complexity);
+        let paths: Vec<_> = report.files.iter().map(|f| f.path.to_str().unwrap()).collect();
+        assert_eq!(paths, vec!["b.rs", "a.rs"]);
+        assert_eq!(report.files[1].cyclomatic.functions[0].name, "emit");
+
+        report.sort(SortOrder::File);
+        let paths: Vec<_> = report.files.iter().map(|f| f.path.to_str().unwrap()).collect();
+        assert_eq!(paths, vec!["a.rs", "b.rs"]);
+        assert_eq!(report.files[0].cyclomatic.functions[0].name, "parse");
+
+        report.sort(SortOrder::Name);
+        assert_eq!(report.files[0].cyclomatic.functions[0].name, "emit");
+    }
+
+    #[test]
+    fn test_with_min_complexity() {
+        let function =
+            |name: &str, cyclomatic| FunctionComplexity { name: name.to_string(), cyclomatic, ..Default::default() };
+        let report = Report::new(
+            vec![FileReport {
+                path: PathBuf::from("a.go"),
+                loc: LocMetrics { physical: 10, logical: 8, comments: 1, blank: 1, statements: 8 },
+                cyclomatic: CyclomaticMetrics {
+                    file_complexity: 10,
+                    functions: vec![function("Name", 1), function("parse", 5), function("run", 7)],
+                },
+                maintainability_index: 60.0,
+                findings: Vec::new(),
+                package: None,
+            }],
+            vec![],
+        );
+
+        let shown = report.with_min_complexity(5);
+        let names: Vec<&str> = shown.files[0]
+            .cyclomatic
+            .functions
+            .iter()
+            .map(|f| f.name.as_str())
+            .collect();
+        assert_eq!(names, vec!["parse", "run"]);
+        assert_eq!(shown.summary.max_complexity, report.summary.max_complexity);
+        assert_eq!(report.files[0].cyclomatic.functions.len(), 3);
+        assert_eq!(report.with_min_complexity(1).files[0].cyclomatic.functions.len(), 3);
+    }
+
+    #[test]
+    fn test_with_top() {
+        let function = |name: &str, line, cyclomatic| FunctionComplexity {
+            name: name.to_string(),
+            line,
+            cyclomatic,
+            ..Default::default()
+        };
+        let file = |path: &str, functions| FileReport {
+            path: PathBuf::from(path),
+            loc: LocMetrics { physical: 10, logical: 8, comments: 1, blank: 1, statements: 8 },
+            cyclomatic: CyclomaticMetrics { file_complexity: 10, functions },
+            maintainability_index: 60.0,
+            findings: Vec::new(),
+            package: None,
+        };
+        let clone = |id| Clone { id, length: 30, locations: Vec::new(), hash: id as u64, group_id: String::new() };
+        let report = Report::new(
+            vec![
+                file(
+                    "a.go",
+                    vec![function("parse", 1, 9), function("emit", 20, 2), function("run", 30, 7)],
+                ),
+                file("b.go", vec![function("load", 1, 8)]),
+            ],
+            vec![clone(1), clone(2), clone(3)],
+        );
+
+        let shown = report.with_top(2);
+        let names: Vec<Vec<&str>> = shown
+            .files
+            .iter()
+            .map(|f| f.cyclomatic.functions.iter().map(|f| f.name.as_str()).collect())
+            .collect();
+        assert_eq!(names, vec![vec!["parse"], vec!["load"]]);
+        let ids: Vec<usize> = shown.clones.iter().map(|c| c.id).collect();
+        assert_eq!(ids, vec![1, 2]);
+        assert_eq!(shown.summary.total_clones, 3);
+        assert_eq!(report.with_top(10).files[0].cyclomatic.functions.len(), 3);
+    }
+
+    #[test]
+    fn test_dedupe_overlapping() {
+        use crate::cloner::CloneLocation;
+
+        let clone = |hash: u64, locations: &[(&str, usize, usize)]| Clone {
+            id: 0,
+            length: 30,
+            locations: locations
+                .iter()
+                .map(|(file, start_line, end_line)| CloneLocation {
+                    file: PathBuf::from(file),
+                    start_line: *start_line,
+                    end_line: *end_line,
+                    gap_tokens: 0,
+                    ..Default::default()
+                })
+                .collect(),
+            hash,
+            group_id: String::new(),
+        };
+        let mut report = Report::new(
+            vec![],
+            vec![
+                clone(1, &[("a.go", 10, 40), ("b.go", 5, 35)]),
+                // Sub-block of the first group
+                clone(2, &[("a.go", 12, 20), ("b.go", 7, 15)]),
+                // Also in c.go, so a different file set
+                clone(3, &[("a.go", 12, 20), ("c.go", 1, 9)]),
+                // Partly outside the first group
+                clone(4, &[("a.go", 30, 50), ("b.go", 25, 45)]),
+            ],
+        );
+
+        assert_eq!(report.dedupe_overlapping(), 1);
+
+        let hashes: Vec<u64> = report.clones.iter().map(|c| c.hash).collect();
+        assert_eq!(hashes, vec![1, 3, 4]);
+        let ids: Vec<usize> = report.clones.iter().map(|c| c.id).collect();
+        assert_eq!(ids, vec![1, 2, 3]);
+        assert_eq!(report.summary.total_clones, 3);
+    }
+
+    #[test]
+    fn test_duplication_counts_each_line_once() {
+        use crate::cloner::CloneLocation;
+
+        let file = |path: &str, physical| FileReport {
+            path: PathBuf::from(path),
+            loc: LocMetrics { physical, logical: physical, comments: 0, blank: 0, statements: 0 },
+            cyclomatic: CyclomaticMetrics { file_complexity: 1, functions: vec![] },
+            maintainability_index: 0.0,
+            findings: Vec::new(),
+            package: None,
+        };
+        let clone = |locations: &[(&str, usize, usize)]| Clone {
+            id: 0,
+            length: 30,
+            locations: locations
+                .iter()
+                .map(|(file, start_line, end_line)| CloneLocation {
+                    file: PathBuf::from(file),
+                    start_line: *start_line,
+                    end_line: *end_line,
+                    ..Default::default()
+                })
+                .collect(),
+            hash: 0,
+            group_id: String::new(),
+        };
+        let clones = vec![
+            clone(&[("a.go", 1, 20), ("b.go", 1, 20)]),
+            // Overlaps the first group in a.go and repeats inside b.go
+            clone(&[("a.go", 11, 30), ("b.go", 41, 60), ("b.go", 51, 70)]),
+            // Partner outside the analyzed files
+            clone(&[("a.go", 91, 100), ("vendor.go", 1, 10)]),
+        ];
+
+        let report = Report::new(
+            vec![file("a.go", 100), file("b.go", 200), file("c.go", 50)],
+            clones.clone(),
+        );
+        let duplication = report.duplication();
+        assert_eq!(duplication[Path::new("a.go")], Duplication { lines: 40, ratio: 0.4 });
+        assert_eq!(duplication[Path::new("b.go")], Duplication { lines: 50, ratio: 0.25 });
+        assert_eq!(duplication[Path::new("c.go")], Duplication::default());
+        assert_eq!(report.summary.duplicated_lines, 90);
+        assert_eq!(report.summary.duplication_ratio, 90.0 / 350.0);
+
+        let reversed = Report::new(report.files.clone(), clones.clone().into_iter().rev().collect());
+        assert_eq!(reversed.summary.duplicated_lines, 90);
+
+        // Without file reports, as `mccabre clones` builds them, lines come from the sources
+        let sources = [
+            SourceFile::new("a.go", "x\n".repeat(99)).unwrap(),
+            SourceFile::new("b.go", "x\n".repeat(199)).unwrap(),
+            SourceFile::new("c.go", "x\n".repeat(49)).unwrap(),
+        ];
+        let mut lines_only = Report::new(Vec::new(), clones);
+        lines_only.count_lines(&sources);
+        assert_eq!(lines_only.summary.total_files, 3);
+        assert_eq!(lines_only.summary.total_physical_loc, 350);
+        assert_eq!(lines_only.summary.duplicated_lines, 90);
+        assert_eq!(lines_only.summary.duplication_ratio, report.summary.duplication_ratio);
+    }
+
     #[test]
     fn test_to_json() {
         let report = Report::new(vec![], vec![]);
@@ -242,11 +888,22 @@ mod tests {
     fn test_to_plaintext() {
         let files = vec![FileReport {
             path: PathBuf::from("test.rs"),
-            loc: LocMetrics { physical: 10, logical: 8, comments: 1, blank: 1 },
+            loc: LocMetrics { physical: 10, logical: 8, comments: 1, blank: 1, statements: 8 },
             cyclomatic: CyclomaticMetrics {
                 file_complexity: 3,
-                functions: vec![FunctionComplexity { name: "test".to_string(), complexity: 3, line: 1 }],
+                functions: vec![FunctionComplexity {
+                    name: "test".to_string(),
+                    cyclomatic: 3,
+                    cognitive: 2,
+                    max_nesting: 0,
+                    line: 1,
+                    halstead: HalsteadMetrics::default(),
+                    ..Default::default()
+                }],
             },
+            maintainability_index: 80.0,
+            findings: Vec::new(),
+            package: None,
         }];
 
         let report = Report::new(files, vec![]);
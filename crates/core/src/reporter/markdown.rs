@@ -0,0 +1,293 @@
+use crate::cloner::{Clone, CloneLocation};
+use crate::complexity::FunctionComplexity;
+use crate::config::ComplexityConfig;
+use crate::loader::SourceFile;
+use crate::reporter::{FileReport, Report};
+use crate::tokenizer::Language;
+use std::collections::HashMap;
+use std::fmt::Write;
+use std::path::Path;
+
+/// Default size limit of a markdown report, under the 65536 characters of a GitHub comment
+pub const MARKDOWN_BUDGET: usize = 60_000;
+
+/// Rows of the complex function table
+const TOP_FUNCTIONS: usize = 10;
+
+/// Lines of a clone group's snippet, the rest being cut
+const SNIPPET_LINES: usize = 40;
+
+impl Report {
+    /// Render a markdown summary for a pull request comment, at most `budget` bytes long
+    ///
+    /// A table of totals comes first, then the functions above the warning threshold, most
+    /// complex first, and a collapsible `<details>` section per clone group with its instances
+    /// and the code of the first one. `files` supplies that code; a group whose file is missing
+    /// is listed without it. Only the first ten functions and forty lines of each snippet are
+    /// shown, and clone groups that would take the report past `budget` are left out; each cut
+    /// is noted as `... N more`.
+    pub fn to_markdown(&self, files: &[SourceFile], thresholds: &ComplexityConfig, budget: usize) -> String {
+        let sources: HashMap<&Path, &SourceFile> = files.iter().map(|f| (f.path.as_path(), f)).collect();
+        let mut markdown = String::from("## Mccabre Report\n\n");
+
+        self.write_totals(&mut markdown);
+        self.write_complex_functions(&mut markdown, thresholds);
+
+        markdown.push_str("### Clone Groups\n\n");
+        if self.clones.is_empty() {
+            markdown.push_str("No clones detected.\n");
+            return markdown;
+        }
+
+        for (shown, clone) in self.clones.iter().enumerate() {
+            let section = clone_section(clone, &sources);
+            let remaining = self.clones.len() - shown;
+            let note = more(remaining, "clone groups");
+            // Keep room for the note about the groups that would not fit after this one
+            let reserve = if remaining > 1 { more(remaining - 1, "clone groups").len() } else { 0 };
+            if markdown.len() + section.len() + reserve > budget {
+                markdown.push_str(&note);
+                break;
+            }
+            markdown.push_str(&section);
+        }
+
+        markdown
+    }
+
+    fn write_totals(&self, markdown: &mut String) {
+        let summary = &self.summary;
+        markdown.push_str(
+            "| Files | Logical LOC | Average complexity | Maximum complexity | Clone groups | Duplication |\n",
+        );
+        markdown.push_str("| ---: | ---: | ---: | ---: | ---: | ---: |\n");
+        let _ = writeln!(
+            markdown,
+            "| {} | {} | {:.2} | {} | {} | {:.1}% |\n",
+            summary.total_files,
+            summary.total_logical_loc,
+            summary.avg_complexity,
+            summary.max_complexity,
+            summary.total_clones,
+            summary.duplication_ratio * 100.0
+        );
+    }
+
+    fn write_complex_functions(&self, markdown: &mut String, thresholds: &ComplexityConfig) {
+        let mut complex: Vec<(&FileReport, &FunctionComplexity)> = self
+            .files
+            .iter()
+            .flat_map(|file| {
+                let limits = thresholds.for_path(&file.path);
+                file.cyclomatic
+                    .functions
+                    .iter()
+                    .filter(move |func| func.cyclomatic > limits.warning_threshold)
+                    .map(move |func| (file, func))
+            })
+            .collect();
+        complex.sort_by(|(a_file, a), (b_file, b)| {
+            b.cyclomatic
+                .cmp(&a.cyclomatic)
+                .then_with(|| (&a_file.path, a.line).cmp(&(&b_file.path, b.line)))
+        });
+
+        markdown.push_str("### Complex Functions\n\n");
+        if complex.is_empty() {
+            let _ = writeln!(
+                markdown,
+                "No functions above complexity {}.\n",
+                thresholds.warning_threshold
+            );
+            return;
+        }
+
+        markdown.push_str("| Function | Location | Cyclomatic | Cognitive | Nesting |\n");
+        markdown.push_str("| --- | --- | ---: | ---: | ---: |\n");
+        for (file, func) in complex.iter().take(TOP_FUNCTIONS) {
+            let _ = writeln!(
+                markdown,
+                "| {} | {} | {} | {} | {} |",
+                code_span(&func.name),
+                code_span(&format!("{}:{}", display_path(&file.path), func.line)),
+                func.cyclomatic,
+                func.cognitive,
+                func.max_nesting
+            );
+        }
+        if complex.len() > TOP_FUNCTIONS {
+            markdown.push('\n');
+            markdown.push_str(&more(complex.len() - TOP_FUNCTIONS, "functions"));
+        }
+        markdown.push('\n');
+    }
+}
+
+/// A clone group as a `<details>` block: its instances, then the code of the first one
+fn clone_section(clone: &Clone, sources: &HashMap<&Path, &SourceFile>) -> String {
+    let mut section = String::new();
+    let _ = writeln!(
+        section,
+        "<details>\n<summary>Clone group #{}: {} instances, {} tokens</summary>\n",
+        clone.id,
+        clone.locations.len(),
+        clone.length
+    );
+
+    for loc in &clone.locations {
+        let _ = writeln!(
+            section,
+            "- {}",
+            code_span(&format!(
+                "{}:{}-{}",
+                display_path(&loc.file),
+                loc.start_line,
+                loc.end_line
+            ))
+        );
+    }
+    section.push('\n');
+
+    if let Some(first) = clone.locations.first()
+        && let Some(source) = sources.get(first.file.as_path())
+    {
+        section.push_str(&snippet(first, source));
+    }
+    section.push_str("</details>\n\n");
+    section
+}
+
+/// The lines of an instance in a fenced code block
+fn snippet(loc: &CloneLocation, source: &SourceFile) -> String {
+    let lines: Vec<&str> = source
+        .content
+        .lines()
+        .skip(loc.start_line.saturating_sub(1))
+        .take(loc.end_line + 1 - loc.start_line.max(1))
+        .collect();
+    let mut code = lines[..lines.len().min(SNIPPET_LINES)].join("\n");
+    if lines.len() > SNIPPET_LINES {
+        let _ = write!(code, "\n// ... {} more lines", lines.len() - SNIPPET_LINES);
+    }
+
+    // A fence longer than any backtick run in the code, so it cannot be closed early
+    let longest = code.split(|c| c != '`').map(str::len).max().unwrap_or(0);
+    let fence = "`".repeat(longest.max(2) + 1);
+    format!("{fence}{}\n{code}\n{fence}\n\n", fence_language(source.language))
+}
+
+fn fence_language(language: Language) -> &'static str {
+    match language {
+        Language::Go => "go",
+        Language::Rust => "rust",
+        Language::JavaScript => "javascript",
+        Language::TypeScript => "typescript",
+        Language::Java => "java",
+        Language::Cpp => "cpp",
+    }
+}
+
+/// The note for items cut from the report
+fn more(count: usize, items: &str) -> String {
+    format!("*... {count} more {items}*\n")
+}
+
+/// Text as inline code, in a table cell; backticks and pipes cannot be escaped inside code, so
+/// a name holding them is written as plain escaped text
+fn code_span(text: &str) -> String {
+    if text.contains('`') || text.contains('|') {
+        text.replace('\\', "\\\\").replace('|', "\\|").replace('`', "\\`")
+    } else {
+        format!("`{text}`")
+    }
+}
+
+fn display_path(path: &Path) -> String {
+    path.to_string_lossy().replace('\\', "/")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::CloneLocation;
+    use crate::complexity::{CyclomaticMetrics, LocMetrics};
+    use std::path::PathBuf;
+
+    fn file_report(path: &str, scores: &[(&str, usize)]) -> FileReport {
+        FileReport {
+            path: PathBuf::from(path),
+            loc: LocMetrics { physical: 40, logical: 30, comments: 5, blank: 5, statements: 20 },
+            cyclomatic: CyclomaticMetrics {
+                file_complexity: scores.iter().map(|(_, score)| score).sum(),
+                functions: scores
+                    .iter()
+                    .enumerate()
+                    .map(|(i, (name, cyclomatic))| FunctionComplexity {
+                        name: name.to_string(),
+                        cyclomatic: *cyclomatic,
+                        line: i * 10 + 1,
+                        ..Default::default()
+                    })
+                    .collect(),
+            },
+            maintainability_index: 70.0,
+            findings: Vec::new(),
+            package: None,
+        }
+    }
+
+    fn clone(id: usize, file: &str, start_line: usize, end_line: usize) -> Clone {
+        let location = |start_line, end_line| CloneLocation {
+            file: PathBuf::from(file),
+            start_line,
+            end_line,
+            ..Default::default()
+        };
+        Clone {
+            id,
+            length: 30,
+            locations: vec![location(start_line, end_line), location(start_line + 20, end_line + 20)],
+            hash: 0,
+            group_id: String::new(),
+        }
+    }
+
+    #[test]
+    fn test_totals_functions_and_clone_sections() {
+        let source = SourceFile::new("a.go", "package a\n\nfunc f() {\n\tx := \"`\"\n}\n").unwrap();
+        let report = Report::new(
+            vec![file_report("a.go", &[("low", 2), ("(*T).High", 25), ("mid", 12)])],
+            vec![clone(1, "a.go", 3, 5)],
+        );
+
+        let markdown = report.to_markdown(&[source], &ComplexityConfig::default(), MARKDOWN_BUDGET);
+        assert!(markdown.starts_with("## Mccabre Report\n\n| Files |"));
+        assert!(markdown.contains("| 1 | 30 | 39.00 | 39 | 1 |"));
+        assert!(markdown.contains("| `(*T).High` | `a.go:11` | 25 | 0 | 0 |\n| `mid` | `a.go:21` | 12 | 0 | 0 |\n\n"));
+        assert!(!markdown.contains("`low`"));
+        assert!(
+            markdown.contains(
+                "<summary>Clone group #1: 2 instances, 30 tokens</summary>\n\n- `a.go:3-5`\n- `a.go:23-25`\n"
+            )
+        );
+        assert!(markdown.contains("```go\nfunc f() {\n\tx := \"`\"\n}\n```\n\n</details>\n"));
+    }
+
+    #[test]
+    fn test_budget_cuts_clone_groups() {
+        let names: Vec<String> = (0..15).map(|i| format!("f{i}")).collect();
+        let scores: Vec<(&str, usize)> = names.iter().map(|name| (name.as_str(), 30)).collect();
+        let clones: Vec<Clone> = (1..=40).map(|id| clone(id, "a.go", id, id + 2)).collect();
+        let report = Report::new(vec![file_report("a.go", &scores)], clones);
+
+        let full = report.to_markdown(&[], &ComplexityConfig::default(), usize::MAX);
+        assert!(full.contains("*... 5 more functions*"));
+        assert_eq!(full.matches("<details>").count(), 40);
+
+        let markdown = report.to_markdown(&[], &ComplexityConfig::default(), 2_000);
+        assert!(markdown.len() <= 2_000);
+        let shown = markdown.matches("<details>").count();
+        assert!(shown > 0 && shown < 40);
+        assert!(markdown.ends_with(&format!("*... {} more clone groups*\n", 40 - shown)));
+    }
+}
@@ -1,9 +1,26 @@
+pub mod aggregate;
 pub mod coverage_detailed;
 pub mod coverage_jsonl;
 pub mod coverage_term;
+pub mod csv;
+pub mod github;
+pub mod html;
+pub mod json;
+pub mod junit;
 pub mod legacy;
+pub mod markdown;
+pub mod sarif;
+pub mod writer;
 
+pub use aggregate::{Aggregate, PackageRollup, Rollup};
 pub use coverage_detailed::{report_detailed_file_view, report_directory_view};
 pub use coverage_jsonl::JsonlReporter;
 pub use coverage_term::{format_file_coverage, report_coverage};
-pub use legacy::{FileReport, Report, Summary};
+pub use json::{JsonReport, SCHEMA_VERSION};
+pub use legacy::{Duplication, FileReport, Report, SortOrder, Summary};
+pub use markdown::MARKDOWN_BUDGET;
+pub use sarif::SarifLog;
+pub use writer::{
+    CsvReporter, GithubReporter, HtmlReporter, JsonReporter, JunitReporter, MarkdownReporter, Reporter, SarifReporter,
+    TextReporter,
+};
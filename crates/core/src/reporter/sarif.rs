@@ -0,0 +1,373 @@
+use crate::cloner::{Clone, CloneLocation};
+use crate::config::ComplexityConfig;
+use crate::reporter::Report;
+use serde::Serialize;
+use std::path::Path;
+
+const SARIF_SCHEMA: &str = "https://json.schemastore.org/sarif-2.1.0.json";
+const SARIF_VERSION: &str = "2.1.0";
+
+/// Rule id for duplicated code blocks
+pub const CLONE_RULE_ID: &str = "clone";
+/// Rule id for functions above the complexity threshold
+pub const COMPLEXITY_RULE_ID: &str = "complexity";
+
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SarifLog {
+    #[serde(rename = "$schema")]
+    pub schema: &'static str,
+    pub version: &'static str,
+    pub runs: Vec<SarifRun>,
+}
+
+#[derive(Debug, Serialize)]
+pub struct SarifRun {
+    pub tool: SarifTool,
+    pub results: Vec<SarifResult>,
+}
+
+#[derive(Debug, Serialize)]
+pub struct SarifTool {
+    pub driver: SarifDriver,
+}
+
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SarifDriver {
+    pub name: &'static str,
+    pub version: &'static str,
+    pub information_uri: &'static str,
+    pub rules: Vec<SarifRule>,
+}
+
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SarifRule {
+    pub id: &'static str,
+    pub name: &'static str,
+    pub short_description: SarifMessage,
+}
+
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SarifResult {
+    pub rule_id: &'static str,
+    pub level: &'static str,
+    pub message: SarifMessage,
+    pub locations: Vec<SarifLocation>,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub related_locations: Vec<SarifLocation>,
+}
+
+#[derive(Debug, Serialize)]
+pub struct SarifMessage {
+    pub text: String,
+}
+
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SarifLocation {
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub id: Option<usize>,
+    pub physical_location: SarifPhysicalLocation,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub message: Option<SarifMessage>,
+}
+
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SarifPhysicalLocation {
+    pub artifact_location: SarifArtifactLocation,
+    pub region: SarifRegion,
+}
+
+#[derive(Debug, Serialize)]
+pub struct SarifArtifactLocation {
+    pub uri: String,
+}
+
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SarifRegion {
+    pub start_line: usize,
+    pub end_line: usize,
+    /// Omitted when unknown, since SARIF columns are 1-based
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub start_column: Option<usize>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub end_column: Option<usize>,
+}
+
+impl SarifLog {
+    /// Build a SARIF 2.1.0 log from an analysis report
+    ///
+    /// Every clone instance becomes its own result, with the other instances of the group
+    /// attached as related locations. Functions above the warning threshold are reported
+    /// under the complexity rule, as errors once they pass the error threshold.
+    pub fn from_report(report: &Report, thresholds: &ComplexityConfig) -> Self {
+        let mut results = Vec::new();
+
+        for file in &report.files {
+            let thresholds = thresholds.for_path(&file.path);
+            for func in &file.cyclomatic.functions {
+                if func.cyclomatic <= thresholds.warning_threshold {
+                    continue;
+                }
+
+                results.push(SarifResult {
+                    rule_id: COMPLEXITY_RULE_ID,
+                    level: if func.cyclomatic > thresholds.error_threshold { "error" } else { "warning" },
+                    message: SarifMessage {
+                        text: format!(
+                            "Function '{}' has cyclomatic complexity {} (threshold {})",
+                            func.name, func.cyclomatic, thresholds.warning_threshold
+                        ),
+                    },
+                    locations: vec![SarifLocation::new(
+                        &file.path,
+                        SarifRegion::new(func.line, func.column, func.line, 0),
+                    )],
+                    related_locations: Vec::new(),
+                });
+            }
+        }
+
+        for clone in &report.clones {
+            results.extend(clone_results(clone));
+        }
+
+        let rules = vec![
+            SarifRule {
+                id: CLONE_RULE_ID,
+                name: "DuplicatedCode",
+                short_description: SarifMessage { text: "Duplicated block of code".to_string() },
+            },
+            SarifRule {
+                id: COMPLEXITY_RULE_ID,
+                name: "CyclomaticComplexity",
+                short_description: SarifMessage {
+                    text: "Function exceeds cyclomatic complexity threshold".to_string(),
+                },
+            },
+        ];
+
+        Self {
+            schema: SARIF_SCHEMA,
+            version: SARIF_VERSION,
+            runs: vec![SarifRun {
+                tool: SarifTool {
+                    driver: SarifDriver {
+                        name: "mccabre",
+                        version: env!("CARGO_PKG_VERSION"),
+                        information_uri: "https://github.com/desertthunder/mccabre",
+                        rules,
+                    },
+                },
+                results,
+            }],
+        }
+    }
+}
+
+fn clone_results(clone: &Clone) -> Vec<SarifResult> {
+    clone
+        .locations
+        .iter()
+        .enumerate()
+        .map(|(idx, loc)| {
+            let related_locations = clone
+                .locations
+                .iter()
+                .enumerate()
+                .filter(|(other, _)| *other != idx)
+                .map(|(other, partner)| SarifLocation {
+                    id: Some(other + 1),
+                    message: Some(SarifMessage { text: "Duplicate of this block".to_string() }),
+                    ..SarifLocation::from_clone_location(partner)
+                })
+                .collect();
+
+            SarifResult {
+                rule_id: CLONE_RULE_ID,
+                level: "warning",
+                message: SarifMessage {
+                    text: format!(
+                        "Clone group #{}: {} tokens duplicated in {} other location(s)",
+                        clone.id,
+                        clone.length,
+                        clone.locations.len() - 1
+                    ),
+                },
+                locations: vec![SarifLocation::from_clone_location(loc)],
+                related_locations,
+            }
+        })
+        .collect()
+}
+
+impl SarifLocation {
+    fn new(path: &Path, region: SarifRegion) -> Self {
+        Self {
+            id: None,
+            physical_location: SarifPhysicalLocation {
+                artifact_location: SarifArtifactLocation { uri: path.to_string_lossy().replace('\\', "/") },
+                region,
+            },
+            message: None,
+        }
+    }
+
+    fn from_clone_location(loc: &CloneLocation) -> Self {
+        Self::new(
+            &loc.file,
+            SarifRegion::new(loc.start_line, loc.start_column, loc.end_line, loc.end_column),
+        )
+    }
+}
+
+impl SarifRegion {
+    /// Region with columns, where a column of 0 means unknown
+    fn new(start_line: usize, start_column: usize, end_line: usize, end_column: usize) -> Self {
+        let column = |c: usize| (c > 0).then_some(c);
+        Self { start_line, end_line, start_column: column(start_column), end_column: column(end_column) }
+    }
+}
+
+impl Report {
+    /// Serialize to SARIF 2.1.0
+    pub fn to_sarif(&self, thresholds: &ComplexityConfig) -> serde_json::Result<String> {
+        serde_json::to_string_pretty(&SarifLog::from_report(self, thresholds))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::complexity::{CyclomaticMetrics, FunctionComplexity, HalsteadMetrics, LocMetrics};
+    use crate::reporter::FileReport;
+    use serde_json::Value;
+    use std::path::PathBuf;
+
+    fn location(file: &str, start_line: usize, end_line: usize) -> CloneLocation {
+        CloneLocation { file: PathBuf::from(file), start_line, end_line, gap_tokens: 0, ..Default::default() }
+    }
+
+    fn function(name: &str, cyclomatic: usize, line: usize) -> FunctionComplexity {
+        FunctionComplexity {
+            name: name.to_string(),
+            cyclomatic,
+            cognitive: 0,
+            max_nesting: 0,
+            line,
+            halstead: HalsteadMetrics::default(),
+            ..Default::default()
+        }
+    }
+
+    fn sample_report() -> Report {
+        let files = vec![FileReport {
+            path: PathBuf::from("src/lib.rs"),
+            loc: LocMetrics { physical: 100, logical: 80, comments: 10, blank: 10, statements: 80 },
+            cyclomatic: CyclomaticMetrics {
+                file_complexity: 40,
+                functions: vec![
+                    function("simple", 2, 1),
+                    function("busy", 12, 10),
+                    function("tangled", 25, 40),
+                ],
+            },
+            maintainability_index: 40.0,
+            findings: Vec::new(),
+            package: None,
+        }];
+        let clones = vec![Clone {
+            id: 1,
+            length: 42,
+            locations: vec![location("src/a.rs", 3, 12), location("src/b.rs", 20, 29)],
+            hash: 0,
+            group_id: String::new(),
+        }];
+
+        Report::new(files, clones)
+    }
+
+    fn sarif(report: &Report) -> Value {
+        serde_json::from_str(&report.to_sarif(&ComplexityConfig::default()).unwrap()).unwrap()
+    }
+
+    #[test]
+    fn test_sarif_envelope() {
+        let log = sarif(&sample_report());
+
+        assert_eq!(log["version"], "2.1.0");
+        assert_eq!(log["runs"][0]["tool"]["driver"]["name"], "mccabre");
+
+        let rules: Vec<&str> = log["runs"][0]["tool"]["driver"]["rules"]
+            .as_array()
+            .unwrap()
+            .iter()
+            .map(|r| r["id"].as_str().unwrap())
+            .collect();
+        assert_eq!(rules, vec!["clone", "complexity"]);
+    }
+
+    #[test]
+    fn test_complexity_results_use_thresholds() {
+        let log = sarif(&sample_report());
+        let results: Vec<&Value> = log["runs"][0]["results"]
+            .as_array()
+            .unwrap()
+            .iter()
+            .filter(|r| r["ruleId"] == "complexity")
+            .collect();
+
+        assert_eq!(results.len(), 2);
+        assert_eq!(results[0]["level"], "warning");
+        assert_eq!(results[1]["level"], "error");
+
+        let region = &results[1]["locations"][0]["physicalLocation"]["region"];
+        assert_eq!(region["startLine"], 40);
+        assert_eq!(
+            results[1]["locations"][0]["physicalLocation"]["artifactLocation"]["uri"],
+            "src/lib.rs"
+        );
+    }
+
+    #[test]
+    fn test_clone_instances_link_partners() {
+        let log = sarif(&sample_report());
+        let results: Vec<&Value> = log["runs"][0]["results"]
+            .as_array()
+            .unwrap()
+            .iter()
+            .filter(|r| r["ruleId"] == "clone")
+            .collect();
+
+        assert_eq!(results.len(), 2);
+
+        let first = &results[0];
+        assert_eq!(
+            first["locations"][0]["physicalLocation"]["artifactLocation"]["uri"],
+            "src/a.rs"
+        );
+        assert_eq!(first["locations"][0]["physicalLocation"]["region"]["endLine"], 12);
+
+        let related = first["relatedLocations"].as_array().unwrap();
+        assert_eq!(related.len(), 1);
+        assert_eq!(related[0]["physicalLocation"]["artifactLocation"]["uri"], "src/b.rs");
+        assert_eq!(related[0]["physicalLocation"]["region"]["startLine"], 20);
+
+        let second = &results[1];
+        assert_eq!(
+            second["relatedLocations"][0]["physicalLocation"]["artifactLocation"]["uri"],
+            "src/a.rs"
+        );
+    }
+
+    #[test]
+    fn test_empty_report() {
+        let log = sarif(&Report::new(vec![], vec![]));
+        assert!(log["runs"][0]["results"].as_array().unwrap().is_empty());
+    }
+}
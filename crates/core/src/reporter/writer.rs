@@ -0,0 +1,259 @@
+use crate::Result;
+use crate::config::ComplexityConfig;
+use crate::loader::SourceFile;
+use crate::reporter::{JsonReport, MARKDOWN_BUDGET, Report, SortOrder};
+use std::io::{self, Write};
+
+/// An output format that renders a finished [`Report`] into any writer
+///
+/// Implementations only write: they never print, exit, or decide whether the run failed, so a
+/// host application can render into a file, a buffer, or a socket and pick formats at runtime
+/// through `Box<dyn Reporter>`. Output that is not empty always ends with a newline.
+pub trait Reporter {
+    fn report(&self, w: &mut dyn Write, report: &Report) -> Result<()>;
+
+    /// Render into a string, mainly for tests and small reports
+    fn render(&self, report: &Report) -> Result<String> {
+        let mut buffer = Vec::new();
+        self.report(&mut buffer, report)?;
+        Ok(String::from_utf8_lossy(&buffer).into_owned())
+    }
+}
+
+/// Plain text without colors, as [`Report::to_plaintext`]
+#[derive(Debug, Clone, Copy, Default)]
+pub struct TextReporter;
+
+/// The stable, versioned JSON document, with functions in `order`
+#[derive(Debug, Clone, Copy, Default)]
+pub struct JsonReporter {
+    pub order: SortOrder,
+}
+
+/// SARIF 2.1.0, with functions above the warning threshold as results
+#[derive(Debug, Clone, Default)]
+pub struct SarifReporter {
+    pub thresholds: ComplexityConfig,
+}
+
+/// GitHub Actions workflow commands, one annotation per finding
+#[derive(Debug, Clone, Default)]
+pub struct GithubReporter {
+    pub thresholds: ComplexityConfig,
+}
+
+/// JUnit XML; passing functions are listed as test cases when `include_passing` is set
+#[derive(Debug, Clone, Default)]
+pub struct JunitReporter {
+    pub thresholds: ComplexityConfig,
+    pub include_passing: bool,
+}
+
+/// RFC 4180 CSV tables of functions and clone instances
+#[derive(Debug, Clone, Copy, Default)]
+pub struct CsvReporter;
+
+/// A self-contained HTML page; clone snippets are read from `files`
+#[derive(Debug, Clone)]
+pub struct HtmlReporter<'a> {
+    pub files: &'a [SourceFile],
+    pub thresholds: ComplexityConfig,
+}
+
+/// Markdown for pull request comments, at most `budget` bytes; clone snippets are read from `files`
+#[derive(Debug, Clone)]
+pub struct MarkdownReporter<'a> {
+    pub files: &'a [SourceFile],
+    pub thresholds: ComplexityConfig,
+    pub budget: usize,
+}
+
+impl JsonReporter {
+    pub fn new(order: SortOrder) -> Self {
+        Self { order }
+    }
+}
+
+impl SarifReporter {
+    pub fn new(thresholds: ComplexityConfig) -> Self {
+        Self { thresholds }
+    }
+}
+
+impl GithubReporter {
+    pub fn new(thresholds: ComplexityConfig) -> Self {
+        Self { thresholds }
+    }
+}
+
+impl JunitReporter {
+    pub fn new(thresholds: ComplexityConfig, include_passing: bool) -> Self {
+        Self { thresholds, include_passing }
+    }
+}
+
+impl<'a> HtmlReporter<'a> {
+    pub fn new(files: &'a [SourceFile], thresholds: ComplexityConfig) -> Self {
+        Self { files, thresholds }
+    }
+}
+
+impl<'a> MarkdownReporter<'a> {
+    pub fn new(files: &'a [SourceFile], thresholds: ComplexityConfig) -> Self {
+        Self { files, thresholds, budget: MARKDOWN_BUDGET }
+    }
+
+    /// Cut clone groups that would take the report past `budget` bytes
+    pub fn with_budget(mut self, budget: usize) -> Self {
+        self.budget = budget;
+        self
+    }
+}
+
+impl Reporter for TextReporter {
+    fn report(&self, w: &mut dyn Write, report: &Report) -> Result<()> {
+        write_document(w, &report.to_plaintext())
+    }
+}
+
+impl Reporter for JsonReporter {
+    fn report(&self, w: &mut dyn Write, report: &Report) -> Result<()> {
+        let json = JsonReport::from_report_sorted(report, self.order)
+            .to_json()
+            .map_err(io::Error::from)?;
+        write_document(w, &json)
+    }
+}
+
+impl Reporter for SarifReporter {
+    fn report(&self, w: &mut dyn Write, report: &Report) -> Result<()> {
+        let sarif = report.to_sarif(&self.thresholds).map_err(io::Error::from)?;
+        write_document(w, &sarif)
+    }
+}
+
+impl Reporter for GithubReporter {
+    fn report(&self, w: &mut dyn Write, report: &Report) -> Result<()> {
+        write_document(w, &report.to_github_annotations(&self.thresholds))
+    }
+}
+
+impl Reporter for JunitReporter {
+    fn report(&self, w: &mut dyn Write, report: &Report) -> Result<()> {
+        write_document(w, &report.to_junit(&self.thresholds, self.include_passing))
+    }
+}
+
+impl Reporter for CsvReporter {
+    fn report(&self, w: &mut dyn Write, report: &Report) -> Result<()> {
+        write_document(w, &report.to_csv())
+    }
+}
+
+impl Reporter for HtmlReporter<'_> {
+    fn report(&self, w: &mut dyn Write, report: &Report) -> Result<()> {
+        write_document(w, &report.to_html(self.files, &self.thresholds))
+    }
+}
+
+impl Reporter for MarkdownReporter<'_> {
+    fn report(&self, w: &mut dyn Write, report: &Report) -> Result<()> {
+        write_document(w, &report.to_markdown(self.files, &self.thresholds, self.budget))
+    }
+}
+
+/// Write a rendered document, adding the final newline if it lacks one
+///
+/// An empty document, such as GitHub annotations for a clean report, is written as nothing.
+fn write_document(w: &mut dyn Write, document: &str) -> Result<()> {
+    w.write_all(document.as_bytes())?;
+    if !document.is_empty() && !document.ends_with('\n') {
+        w.write_all(b"\n")?;
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::complexity::{CyclomaticMetrics, FunctionComplexity, LocMetrics};
+    use crate::reporter::FileReport;
+    use std::path::PathBuf;
+
+    fn report() -> Report {
+        let function = |name: &str, cyclomatic| FunctionComplexity {
+            name: name.to_string(),
+            cyclomatic,
+            line: cyclomatic,
+            ..Default::default()
+        };
+        Report::new(
+            vec![FileReport {
+                path: PathBuf::from("main.go"),
+                loc: LocMetrics { physical: 40, logical: 30, comments: 5, blank: 5, statements: 25 },
+                cyclomatic: CyclomaticMetrics {
+                    file_complexity: 16,
+                    functions: vec![function("small", 2), function("large", 14)],
+                },
+                maintainability_index: 60.0,
+                findings: Vec::new(),
+                package: None,
+            }],
+            Vec::new(),
+        )
+    }
+
+    #[test]
+    fn test_reporters_are_interchangeable() {
+        let reporters: Vec<Box<dyn Reporter>> = vec![
+            Box::new(TextReporter),
+            Box::new(JsonReporter::default()),
+            Box::new(SarifReporter::default()),
+            Box::new(JunitReporter::new(ComplexityConfig::default(), true)),
+            Box::new(CsvReporter),
+        ];
+
+        for reporter in &reporters {
+            let output = reporter.render(&report()).unwrap();
+            assert!(output.ends_with('\n'));
+            assert!(output.contains("large"), "{output}");
+        }
+    }
+
+    #[test]
+    fn test_json_reporter_matches_document() {
+        let report = report();
+        let mut buffer = Vec::new();
+        JsonReporter::new(SortOrder::Name).report(&mut buffer, &report).unwrap();
+
+        let written = String::from_utf8(buffer).unwrap();
+        assert_eq!(
+            written,
+            format!("{}\n", report.to_sorted_json(SortOrder::Name).unwrap())
+        );
+
+        let first = written.find("\"large\"").unwrap();
+        assert!(first < written.find("\"small\"").unwrap());
+    }
+
+    #[test]
+    fn test_github_reporter_writes_nothing_for_clean_report() {
+        let clean = Report::new(Vec::new(), Vec::new());
+        assert_eq!(GithubReporter::default().render(&clean).unwrap(), "");
+    }
+
+    #[test]
+    fn test_write_errors_are_returned() {
+        struct Closed;
+        impl Write for Closed {
+            fn write(&mut self, _: &[u8]) -> io::Result<usize> {
+                Err(io::Error::new(io::ErrorKind::BrokenPipe, "closed"))
+            }
+            fn flush(&mut self) -> io::Result<()> {
+                Ok(())
+            }
+        }
+
+        assert!(TextReporter.report(&mut Closed, &report()).is_err());
+    }
+}
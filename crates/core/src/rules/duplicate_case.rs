@@ -0,0 +1,338 @@
+use crate::complexity::FunctionTokens;
+use crate::rules::{DUPLICATE_CASE_BODY, Finding, matching_brace};
+use crate::tokenizer::{Language, NormalizeMode, Token, TokenType};
+use std::collections::BTreeMap;
+use std::ptr;
+
+/// Shortest body, in tokens, reported when clauses only match after renaming
+///
+/// Short bodies such as `return 1` and `return 2` are the point of a switch, not duplication.
+pub const MIN_RENAMED_TOKENS: usize = 8;
+
+/// One `case` or `default` clause of a switch
+struct Clause<'a> {
+    /// Tokens from `case`/`default` up to, not including, the `:`
+    label: &'a [&'a Token],
+    body: &'a [&'a Token],
+}
+
+/// Flag clauses of one `switch` (expression or type switch) whose bodies are the same
+///
+/// Bodies that are token-for-token identical are always reported; bodies equal once identifiers
+/// and literals are normalized (type-2) are reported when at least [`MIN_RENAMED_TOKENS`] long.
+/// Empty clauses and clauses ending in `fallthrough` are skipped. Each finding names the labels
+/// of every clause in the group, so the branches to collapse are easy to find.
+pub fn detect_duplicate_case_bodies(function: &FunctionTokens) -> Vec<Finding> {
+    // Switches are found in `body` so each one belongs to its innermost function, but clauses
+    // are compared over `full_body` so function literals inside them are compared too
+    let tokens = &function.full_body;
+    let mut findings = Vec::new();
+
+    for switch in function.body.iter().filter(|t| t.token_type == TokenType::Switch) {
+        let Some(start) = tokens.iter().position(|t| ptr::eq(*t, *switch)) else { continue };
+        let Some(open) = (start + 1..tokens.len()).find(|&i| tokens[i].token_type == TokenType::LeftBrace) else {
+            continue;
+        };
+        let Some(close) = matching_brace(tokens, open) else { continue };
+
+        let clauses = clauses(&tokens[open + 1..close]);
+        findings.extend(duplicate_groups(function, &clauses));
+    }
+
+    findings.sort_by_key(|f| f.line);
+    findings
+}
+
+/// Split the tokens between a switch's braces into its clauses
+fn clauses<'a>(tokens: &'a [&'a Token]) -> Vec<Clause<'a>> {
+    let mut starts = Vec::new();
+    let mut depth = 0usize;
+
+    for (i, token) in tokens.iter().enumerate() {
+        match token.token_type {
+            TokenType::LeftBrace | TokenType::LeftParen | TokenType::LeftBracket => depth += 1,
+            TokenType::RightBrace | TokenType::RightParen | TokenType::RightBracket => depth = depth.saturating_sub(1),
+            TokenType::Case | TokenType::Default if depth == 0 => starts.push(i),
+            _ => {}
+        }
+    }
+
+    starts
+        .iter()
+        .enumerate()
+        .filter_map(|(n, &start)| {
+            let end = starts.get(n + 1).copied().unwrap_or(tokens.len());
+            let colon = label_end(tokens, start, end)?;
+            Some(Clause { label: &tokens[start..colon], body: &tokens[colon + 1..end] })
+        })
+        .collect()
+}
+
+/// Index of the `:` ending the label that starts at `start`
+fn label_end(tokens: &[&Token], start: usize, end: usize) -> Option<usize> {
+    let mut depth = 0usize;
+
+    (start..end).find(|&i| match tokens[i].token_type {
+        TokenType::LeftBrace | TokenType::LeftParen | TokenType::LeftBracket => {
+            depth += 1;
+            false
+        }
+        TokenType::RightBrace | TokenType::RightParen | TokenType::RightBracket => {
+            depth = depth.saturating_sub(1);
+            false
+        }
+        TokenType::Unknown(':') => depth == 0,
+        _ => false,
+    })
+}
+
+fn duplicate_groups(function: &FunctionTokens, clauses: &[Clause]) -> Vec<Finding> {
+    let mut renamed: BTreeMap<Vec<String>, Vec<&Clause>> = BTreeMap::new();
+    for clause in clauses {
+        let skip = clause.body.is_empty()
+            || clause
+                .body
+                .last()
+                .is_some_and(|t| matches!(&t.token_type, TokenType::Identifier(word) if word == "fallthrough"));
+        if !skip {
+            renamed.entry(renamed_key(clause.body)).or_default().push(clause);
+        }
+    }
+
+    let mut findings = Vec::new();
+    for group in renamed.into_values().filter(|group| group.len() > 1) {
+        let mut exact: BTreeMap<Vec<&str>, Vec<&Clause>> = BTreeMap::new();
+        for clause in &group {
+            exact.entry(exact_key(clause.body)).or_default().push(clause);
+        }
+
+        if exact.len() == 1 {
+            findings.push(finding(function, &group, false));
+        } else if group[0].body.len() >= MIN_RENAMED_TOKENS {
+            findings.push(finding(function, &group, true));
+        } else {
+            findings.extend(
+                exact
+                    .into_values()
+                    .filter(|clauses| clauses.len() > 1)
+                    .map(|clauses| finding(function, &clauses, false)),
+            );
+        }
+    }
+
+    findings
+}
+
+fn finding(function: &FunctionTokens, group: &[&Clause], renamed: bool) -> Finding {
+    let mut group = group.to_vec();
+    group.sort_by_key(|clause| clause.label[0].offset);
+
+    let labels: Vec<String> = group.iter().map(|clause| source_text(clause.label)).collect();
+    let listed: Vec<String> = group
+        .iter()
+        .zip(&labels)
+        .map(|(clause, label)| format!("{label} (line {})", clause.label[0].line))
+        .collect();
+
+    let (message, suggestion) = if renamed {
+        (
+            format!("Case bodies differ only in names and literals: {}", listed.join(", ")),
+            "Move the shared body into a function that takes the differing values as arguments".to_string(),
+        )
+    } else if labels.iter().any(|label| label == "default") {
+        (
+            format!("Case bodies are identical: {}", listed.join(", ")),
+            "Remove the other clauses; the default clause already handles them".to_string(),
+        )
+    } else {
+        let values: Vec<&str> = labels
+            .iter()
+            .map(|label| label.strip_prefix("case").unwrap_or(label).trim())
+            .collect();
+        (
+            format!("Case bodies are identical: {}", listed.join(", ")),
+            format!("Merge them into one clause: case {}:", values.join(", ")),
+        )
+    };
+
+    let first = group[0].label[0];
+    Finding {
+        rule: DUPLICATE_CASE_BODY.to_string(),
+        function: function.name.clone(),
+        line: first.line,
+        column: first.column,
+        end_column: first.end_column,
+        message,
+        suggestion: Some(suggestion),
+    }
+}
+
+/// Tokens joined with a space only where the source had whitespace between them
+fn source_text(tokens: &[&Token]) -> String {
+    let mut text = String::new();
+
+    for (i, token) in tokens.iter().enumerate() {
+        if i > 0 && tokens[i - 1].end_offset < token.offset {
+            text.push(' ');
+        }
+        text.push_str(&token.text);
+    }
+
+    text
+}
+
+fn exact_key<'a>(body: &[&'a Token]) -> Vec<&'a str> {
+    body.iter().map(|t| t.text.as_str()).collect()
+}
+
+fn renamed_key(body: &[&Token]) -> Vec<String> {
+    body.iter()
+        .map(|t| match &t.token_type {
+            TokenType::Identifier(word) if !Language::Go.is_keyword(word) => NormalizeMode::IDENT.to_string(),
+            TokenType::Literal(_) => NormalizeMode::LIT.to_string(),
+            _ => t.text.clone(),
+        })
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::complexity::function_tokens;
+    use crate::tokenizer::Tokenizer;
+
+    fn findings(source: &str) -> Vec<Finding> {
+        let tokens = Tokenizer::new(source, Language::Go).tokenize().unwrap();
+        function_tokens(&tokens, Language::Go)
+            .iter()
+            .flat_map(detect_duplicate_case_bodies)
+            .collect()
+    }
+
+    #[test]
+    fn test_identical_case_bodies() {
+        let source = r#"
+func kind(ext string) string {
+	switch ext {
+	case ".go":
+		log.Println("source")
+		return "code"
+	case ".md":
+		return "docs"
+	case ".rs", ".c":
+		log.Println("source")
+		return "code"
+	default:
+		return ""
+	}
+}
+"#;
+        let found = findings(source);
+
+        assert_eq!(found.len(), 1);
+        assert_eq!(found[0].rule, DUPLICATE_CASE_BODY);
+        assert_eq!(
+            (found[0].function.as_str(), found[0].line, found[0].column),
+            ("kind", 4, 2)
+        );
+        assert_eq!(
+            found[0].message,
+            r#"Case bodies are identical: case ".go" (line 4), case ".rs", ".c" (line 9)"#
+        );
+        assert_eq!(
+            found[0].suggestion.as_deref(),
+            Some(r#"Merge them into one clause: case ".go", ".rs", ".c":"#)
+        );
+    }
+
+    #[test]
+    fn test_type_switch_and_default() {
+        let source = r#"
+func describe(v any) string {
+	switch v.(type) {
+	case int:
+		return fmt.Sprint(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+"#;
+        let found = findings(source);
+
+        assert_eq!(found.len(), 1);
+        assert!(found[0].message.ends_with("case int (line 4), default (line 6)"));
+        assert!(
+            found[0]
+                .suggestion
+                .as_deref()
+                .unwrap()
+                .starts_with("Remove the other clauses")
+        );
+    }
+
+    #[test]
+    fn test_renamed_bodies_need_minimum_length() {
+        let source = r#"
+func handle(op string, a, b int) int {
+	switch op {
+	case "add":
+		result := a * 2
+		log.Printf("add %d", result)
+		return result
+	case "sub":
+		result := b * 3
+		log.Printf("sub %d", result)
+		return result
+	case "one":
+		return 1
+	case "two":
+		return 2
+	}
+	return 0
+}
+"#;
+        let found = findings(source);
+
+        assert_eq!(found.len(), 1);
+        assert!(
+            found[0]
+                .message
+                .starts_with("Case bodies differ only in names and literals")
+        );
+        assert!(found[0].message.contains(r#"case "add" (line 4), case "sub" (line 8)"#));
+    }
+
+    #[test]
+    fn test_ignores_empty_fallthrough_and_nested_switches() {
+        let source = r#"
+func route(a, b int) {
+	switch a {
+	case 1:
+	case 2:
+	case 3:
+		log.Print("x")
+		fallthrough
+	case 4:
+		log.Print("x")
+		fallthrough
+	case 5:
+		switch b {
+		case 1:
+			run()
+		case 2:
+			stop()
+		}
+	case 6:
+		go func() {
+			run()
+		}()
+	case 7:
+		go func() {
+			stop(b)
+		}()
+	}
+}
+"#;
+        assert!(findings(source).is_empty());
+    }
+}
@@ -0,0 +1,125 @@
+use crate::complexity::FunctionComplexity;
+use crate::rules::{FUNCTION_TOO_LONG, Finding, function_end_column};
+
+/// Longest function bodies allowed; unset limits are never checked
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct LengthLimits {
+    /// Physical lines from the body's opening brace to its closing brace
+    pub max_lines: Option<usize>,
+    /// Statements in the body, those of nested functions and closures included
+    pub max_statements: Option<usize>,
+}
+
+impl LengthLimits {
+    pub fn new(max_lines: Option<usize>, max_statements: Option<usize>) -> Self {
+        Self { max_lines, max_statements }
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.max_lines.is_none() && self.max_statements.is_none()
+    }
+
+    /// Whether the function is longer than either limit
+    pub fn exceeded_by(&self, function: &FunctionComplexity) -> bool {
+        self.max_lines.is_some_and(|limit| function.lines > limit)
+            || self.max_statements.is_some_and(|limit| function.statements > limit)
+    }
+}
+
+/// Flag a function whose body has more lines or statements than `limits` allow
+///
+/// Works from the computed metrics rather than tokens, so limits can change without
+/// re-analyzing; the message names every exceeded limit with the actual count.
+pub fn detect_long_function(function: &FunctionComplexity, limits: &LengthLimits) -> Option<Finding> {
+    let mut over = Vec::new();
+    if let Some(limit) = limits.max_lines.filter(|&limit| function.lines > limit) {
+        over.push(format!("{} lines (limit {limit})", function.lines));
+    }
+    if let Some(limit) = limits.max_statements.filter(|&limit| function.statements > limit) {
+        over.push(format!("{} statements (limit {limit})", function.statements));
+    }
+    if over.is_empty() {
+        return None;
+    }
+
+    Some(Finding {
+        rule: FUNCTION_TOO_LONG.to_string(),
+        function: function.name.clone(),
+        line: function.line,
+        column: function.column,
+        end_column: function_end_column(function),
+        message: format!("Function body has {}", over.join(" and ")),
+        suggestion: Some("Split it into smaller functions".to_string()),
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::complexity::CyclomaticMetrics;
+    use crate::tokenizer::Language;
+
+    const SOURCE: &str = r#"
+func short(a, b int) int { return a + b }
+
+func long(items []string) int {
+	count := 0
+	for _, item := range items {
+		if item == "" {
+			continue
+		}
+		count++
+	}
+	log := func() {
+		fmt.Println(count)
+	}
+	log()
+	return count
+}
+"#;
+
+    fn functions() -> Vec<FunctionComplexity> {
+        CyclomaticMetrics::calculate(SOURCE, Language::Go).unwrap().functions
+    }
+
+    fn by_name<'a>(functions: &'a [FunctionComplexity], name: &str) -> &'a FunctionComplexity {
+        functions.iter().find(|f| f.name == name).unwrap()
+    }
+
+    #[test]
+    fn test_line_and_statement_counts() {
+        let functions = functions();
+
+        let short = by_name(&functions, "short");
+        assert_eq!((short.lines, short.statements), (1, 1));
+
+        let long = by_name(&functions, "long");
+        assert_eq!((long.lines, long.statements), (14, 9));
+
+        let literal = by_name(&functions, "long$func1");
+        assert_eq!((literal.lines, literal.statements), (3, 1));
+    }
+
+    #[test]
+    fn test_findings_report_count_and_limit() {
+        let functions = functions();
+        let long = by_name(&functions, "long");
+
+        let limits = LengthLimits::new(Some(10), None);
+        let finding = detect_long_function(long, &limits).unwrap();
+        assert_eq!(finding.rule, FUNCTION_TOO_LONG);
+        assert_eq!((finding.line, finding.column, finding.end_column), (4, 1, 0));
+        assert_eq!(finding.message, "Function body has 14 lines (limit 10)");
+
+        let limits = LengthLimits::new(Some(10), Some(5));
+        assert_eq!(
+            detect_long_function(long, &limits).unwrap().message,
+            "Function body has 14 lines (limit 10) and 9 statements (limit 5)"
+        );
+
+        assert!(detect_long_function(long, &LengthLimits::new(Some(14), Some(9))).is_none());
+        assert!(detect_long_function(long, &LengthLimits::default()).is_none());
+        let short = detect_long_function(by_name(&functions, "short"), &LengthLimits::new(Some(0), None)).unwrap();
+        assert_eq!((short.line, short.column, short.end_column), (2, 1, 42));
+    }
+}
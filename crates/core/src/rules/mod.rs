@@ -0,0 +1,368 @@
+pub mod duplicate_case;
+pub mod function_length;
+pub mod parameters;
+pub mod string_concat;
+pub mod struct_literal;
+pub mod test_case;
+pub mod unreachable;
+
+use crate::Result;
+use crate::complexity::{FunctionComplexity, function_tokens};
+use crate::config::{CloneConfig, ComplexityConfig, OutputFormat};
+use crate::reporter::sarif::{CLONE_RULE_ID, COMPLEXITY_RULE_ID};
+use crate::tokenizer::{Language, Token, TokenType, Tokenizer};
+use serde::{Deserialize, Serialize};
+
+pub use duplicate_case::detect_duplicate_case_bodies;
+pub use function_length::{LengthLimits, detect_long_function};
+pub use parameters::detect_too_many_parameters;
+pub use string_concat::detect_string_concat_in_loop;
+pub use struct_literal::detect_duplicate_struct_literals;
+pub use test_case::detect_duplicate_test_cases;
+pub use unreachable::detect_unreachable_code;
+
+/// Rule id for strings built with `+=` or `x = x + y` inside a loop
+pub const STRING_CONCAT_IN_LOOP: &str = "string-concat-in-loop";
+/// Rule id for clauses of one `switch` whose bodies are the same
+pub const DUPLICATE_CASE_BODY: &str = "duplicate-case-body";
+/// Rule id for functions whose body has more lines or statements than configured
+pub const FUNCTION_TOO_LONG: &str = "function-too-long";
+/// Rule id for functions that take more parameters than configured
+pub const TOO_MANY_PARAMETERS: &str = "too-many-parameters";
+/// Rule id for keyed struct literals written out the same way more than once in a file
+pub const DUPLICATE_STRUCT_LITERAL: &str = "duplicate-struct-literal";
+/// Rule id for elements of a test's table of cases that are written out alike
+pub const DUPLICATE_TEST_CASE: &str = "duplicate-test-case";
+/// Rule id for statements after a `return`, `panic`, or other statement that leaves the block
+pub const UNREACHABLE_CODE: &str = "unreachable-code";
+/// Rule id for functions nested deeper than `max_nesting`, as JUnit output names it
+pub const NESTING: &str = "nesting";
+/// Rule id for functions with more `return` statements than `--max-returns` allows
+pub const RETURNS: &str = "returns";
+/// Rule id for repeated `if err != nil { ... }` blocks grouped by shape
+pub const ERRCHECK_CLUSTER: &str = "errcheck-cluster";
+/// Rule id for function pairs whose bodies are at least `similarity_threshold` alike
+pub const SIMILAR_FUNCTIONS: &str = "similar-functions";
+/// Rule id for top-level `const`, `var`, and `type` blocks written out more than once
+pub const DUPLICATE_DECLARATION: &str = "duplicate-declaration";
+/// Rule id for functions whose bodies match, whatever their signatures
+pub const FUNCTION_CLONE: &str = "function-clone";
+
+/// What one check reports, for tools that generate config templates or documentation
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct RuleInfo {
+    /// Id used in findings, SARIF results, and JUnit failures
+    pub id: String,
+    pub description: String,
+    /// Config keys that set the rule's thresholds, as `section.key`
+    pub config_keys: Vec<String>,
+    /// Threshold used when the config does not set one; `None` when the rule has no threshold
+    /// or is off until one is set
+    pub default_threshold: Option<usize>,
+    /// Output formats the rule's results appear in
+    pub formats: Vec<OutputFormat>,
+}
+
+/// Every check Mccabre runs, metrics thresholds and clones first, then the rules in this module
+pub fn catalog() -> Vec<RuleInfo> {
+    use OutputFormat::*;
+
+    let complexity = ComplexityConfig::default();
+    let clones = CloneConfig::default();
+    let rule =
+        |id: &str, description: &str, config_keys: &[&str], default_threshold, formats: &[OutputFormat]| RuleInfo {
+            id: id.to_string(),
+            description: description.to_string(),
+            config_keys: config_keys.iter().map(|key| key.to_string()).collect(),
+            default_threshold,
+            formats: formats.to_vec(),
+        };
+
+    vec![
+        rule(
+            COMPLEXITY_RULE_ID,
+            "Function cyclomatic complexity above the warning threshold",
+            &["complexity.warning_threshold"],
+            Some(complexity.warning_threshold),
+            &[Text, Json, Sarif, Html, Github, Junit, Csv, Markdown],
+        ),
+        rule(
+            NESTING,
+            "Function blocks nested deeper than the nesting limit",
+            &["complexity.max_nesting"],
+            Some(complexity.max_nesting),
+            &[Text, Json, Junit, Csv],
+        ),
+        rule(
+            CLONE_RULE_ID,
+            "Duplicated token sequences of at least the minimum length",
+            &["clones.min_tokens"],
+            Some(clones.min_tokens),
+            &[Text, Json, Sarif, Html, Github, Junit, Csv, Markdown],
+        ),
+        rule(
+            ERRCHECK_CLUSTER,
+            "Go error checks of the same shape repeated too often to be worth a clone group",
+            &["clones.report_errcheck"],
+            None,
+            &[Text, Json],
+        ),
+        rule(
+            SIMILAR_FUNCTIONS,
+            "Function pairs whose bodies share at least the similarity threshold of their token n-grams",
+            &["clones.similarity_threshold"],
+            None,
+            &[Text, Json],
+        ),
+        rule(
+            DUPLICATE_DECLARATION,
+            "Top-level Go const, var, or type block written out more than once",
+            &["clones.report_declarations"],
+            None,
+            &[Text, Json],
+        ),
+        rule(
+            FUNCTION_CLONE,
+            "Functions with the same body of at least the minimum length, whatever their signatures",
+            &["clones.report_function_clones", "clones.min_tokens"],
+            None,
+            &[Text, Json],
+        ),
+        rule(
+            FUNCTION_TOO_LONG,
+            "Function body with more lines or statements than configured",
+            &["complexity.max_function_lines", "complexity.max_function_statements"],
+            complexity.max_function_lines,
+            &[Text, Json],
+        ),
+        rule(
+            TOO_MANY_PARAMETERS,
+            "Function that takes more parameters than configured, grouped ones counted singly",
+            &["complexity.max_parameters"],
+            complexity.max_parameters,
+            &[Text, Json],
+        ),
+        rule(
+            RETURNS,
+            "Function with more return statements than --max-returns allows",
+            &[],
+            None,
+            &[Text, Json],
+        ),
+        rule(
+            STRING_CONCAT_IN_LOOP,
+            "Go string built with += or x = x + y inside a loop",
+            &[],
+            None,
+            &[Text, Json],
+        ),
+        rule(
+            DUPLICATE_CASE_BODY,
+            "Clauses of one switch whose bodies are the same",
+            &[],
+            None,
+            &[Text, Json],
+        ),
+        rule(
+            DUPLICATE_STRUCT_LITERAL,
+            "Keyed struct literal written out the same way more than once in a file",
+            &[],
+            None,
+            &[Text, Json],
+        ),
+        rule(
+            DUPLICATE_TEST_CASE,
+            "Case in a Go test's table of cases written out the same way more than once",
+            &[],
+            None,
+            &[Text, Json],
+        ),
+        rule(
+            UNREACHABLE_CODE,
+            "Go statement after a return, panic, os.Exit, or branch that leaves its block",
+            &[],
+            None,
+            &[Text, Json],
+        ),
+    ]
+}
+
+/// A problem reported by a rule at one line of a function
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Finding {
+    /// Rule id, such as [`STRING_CONCAT_IN_LOOP`]
+    pub rule: String,
+    /// Function the finding is in
+    pub function: String,
+    pub line: usize,
+    /// Column of the flagged token, in Unicode scalar values (1-based)
+    #[serde(default)]
+    pub column: usize,
+    /// Column just past the flagged token, or 0 when the flagged code runs past `line`
+    #[serde(default)]
+    pub end_column: usize,
+    pub message: String,
+    /// How to fix it, if the rule has a standard remedy
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub suggestion: Option<String>,
+}
+
+/// End column of a finding that flags all of `function`, which is known only when the
+/// function fits on its first line
+fn function_end_column(function: &FunctionComplexity) -> usize {
+    if function.end_line == function.line { function.end_column } else { 0 }
+}
+
+/// Run every rule that applies to the language over each function of a source file
+///
+/// Findings are ordered by line.
+pub fn check_source(source: &str, language: Language) -> Result<Vec<Finding>> {
+    if language != Language::Go {
+        return Ok(Vec::new());
+    }
+
+    let tokens = Tokenizer::new(source, language).tokenize()?;
+    let functions = function_tokens(&tokens, language);
+    let mut findings: Vec<Finding> = functions
+        .iter()
+        .flat_map(|function| {
+            let mut findings = detect_string_concat_in_loop(function);
+            findings.extend(detect_duplicate_case_bodies(function));
+            findings.extend(detect_duplicate_test_cases(function));
+            findings.extend(detect_unreachable_code(function));
+            findings
+        })
+        .collect();
+    findings.extend(detect_duplicate_struct_literals(&functions));
+    findings.sort_by_key(|f| f.line);
+
+    Ok(findings)
+}
+
+/// Index of the `}` closing the `{` at `open`
+pub(crate) fn matching_brace(tokens: &[&Token], open: usize) -> Option<usize> {
+    let mut depth = 0usize;
+
+    for (offset, token) in tokens[open..].iter().enumerate() {
+        match token.token_type {
+            TokenType::LeftBrace => depth += 1,
+            TokenType::RightBrace => {
+                depth -= 1;
+                if depth == 0 {
+                    return Some(open + offset);
+                }
+            }
+            _ => {}
+        }
+    }
+
+    None
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::analyzer::Analyzer;
+    use crate::config::Config;
+    use crate::loader::SourceFile;
+    use crate::reporter::JsonReport;
+    use crate::reporter::sarif::SarifLog;
+    use std::collections::HashSet;
+
+    #[test]
+    fn test_catalog_lists_every_rule_once() {
+        let rules = catalog();
+        let ids: HashSet<&str> = rules.iter().map(|rule| rule.id.as_str()).collect();
+
+        assert_eq!(ids.len(), rules.len());
+        for id in [
+            STRING_CONCAT_IN_LOOP,
+            DUPLICATE_CASE_BODY,
+            FUNCTION_TOO_LONG,
+            TOO_MANY_PARAMETERS,
+            DUPLICATE_STRUCT_LITERAL,
+            DUPLICATE_TEST_CASE,
+            UNREACHABLE_CODE,
+        ] {
+            assert!(ids.contains(id), "{id} missing");
+        }
+
+        let complexity = rules.iter().find(|rule| rule.id == COMPLEXITY_RULE_ID).unwrap();
+        assert_eq!(complexity.default_threshold, Some(10));
+        assert!(complexity.formats.contains(&OutputFormat::Sarif));
+        let length = rules.iter().find(|rule| rule.id == FUNCTION_TOO_LONG).unwrap();
+        let json = serde_json::to_value(length).unwrap();
+        assert_eq!(json["id"], FUNCTION_TOO_LONG);
+        assert_eq!(json["defaultThreshold"], serde_json::Value::Null);
+        assert_eq!(json["formats"], serde_json::json!(["text", "json"]));
+    }
+
+    #[test]
+    fn test_catalog_covers_reported_rule_ids() {
+        let source = r#"package p
+
+func build(prefix string, items []string) string {
+	out := prefix
+	for _, item := range items {
+		if item != "" {
+			if len(item) > 1 {
+				out += item
+			}
+		}
+	}
+	switch prefix {
+	case "a":
+		out = strings.ToUpper(out)
+	case "b":
+		out = strings.ToUpper(out)
+	}
+	return out
+	out = ""
+}
+"#;
+        let files = [
+            SourceFile::new("a.go", source).unwrap(),
+            SourceFile::new("b.go", source).unwrap(),
+        ];
+        let mut config = Config::default();
+        config.complexity.warning_threshold = 1;
+        config.complexity.max_nesting = 1;
+        config.complexity.max_function_lines = Some(1);
+        config.complexity.max_parameters = Some(1);
+        config.clones.min_tokens = 20;
+        let report = Analyzer::new(config.clone()).with_jobs(1).analyze(&files).unwrap();
+
+        let sarif = serde_json::to_value(SarifLog::from_report(&report, &config.complexity)).unwrap();
+        let json = serde_json::to_value(JsonReport::from_report(&report)).unwrap();
+        let junit = report.to_junit(&config.complexity, false);
+
+        let mut emitted: HashSet<String> = HashSet::new();
+        for result in sarif["runs"][0]["results"].as_array().unwrap() {
+            emitted.insert(result["ruleId"].as_str().unwrap().to_string());
+        }
+        for rule in sarif["runs"][0]["tool"]["driver"]["rules"].as_array().unwrap() {
+            emitted.insert(rule["id"].as_str().unwrap().to_string());
+        }
+        for finding in json["findings"].as_array().unwrap() {
+            emitted.insert(finding["rule"].as_str().unwrap().to_string());
+        }
+        for failure in junit.split("<failure type=\"").skip(1) {
+            emitted.insert(failure.split('"').next().unwrap().to_string());
+        }
+
+        let ids: HashSet<String> = catalog().into_iter().map(|rule| rule.id).collect();
+        for id in [
+            COMPLEXITY_RULE_ID,
+            NESTING,
+            CLONE_RULE_ID,
+            FUNCTION_TOO_LONG,
+            STRING_CONCAT_IN_LOOP,
+        ] {
+            assert!(emitted.contains(id), "{id} not emitted");
+        }
+        for id in &emitted {
+            assert!(ids.contains(id), "{id} missing from the catalog");
+        }
+    }
+}
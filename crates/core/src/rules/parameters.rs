@@ -0,0 +1,53 @@
+use crate::complexity::FunctionComplexity;
+use crate::rules::{Finding, TOO_MANY_PARAMETERS, function_end_column};
+
+/// Flag a function that takes more than `limit` parameters
+///
+/// Works from the computed metrics, as [`detect_long_function`](crate::rules::detect_long_function)
+/// does; the message gives the count and the limit, then names every parameter.
+pub fn detect_too_many_parameters(function: &FunctionComplexity, limit: usize) -> Option<Finding> {
+    let count = function.parameters.len();
+    if count <= limit {
+        return None;
+    }
+
+    Some(Finding {
+        rule: TOO_MANY_PARAMETERS.to_string(),
+        function: function.name.clone(),
+        line: function.line,
+        column: function.column,
+        end_column: function_end_column(function),
+        message: format!(
+            "Function takes {count} parameters (limit {limit}): {}",
+            function.parameters.join(", ")
+        ),
+        suggestion: Some("Group related parameters into a struct".to_string()),
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::complexity::CyclomaticMetrics;
+    use crate::tokenizer::Language;
+
+    #[test]
+    fn test_grouped_parameters_count_one_by_one() {
+        let source = r#"
+func connect(host, port string, timeout, retries int, opts ...Option) error {
+	return nil
+}
+"#;
+        let functions = CyclomaticMetrics::calculate(source, Language::Go).unwrap().functions;
+
+        let finding = detect_too_many_parameters(&functions[0], 4).unwrap();
+        assert_eq!(finding.rule, TOO_MANY_PARAMETERS);
+        assert_eq!((finding.function.as_str(), finding.line), ("connect", 2));
+        assert_eq!((finding.column, finding.end_column), (1, 0));
+        assert_eq!(
+            finding.message,
+            "Function takes 5 parameters (limit 4): host, port, timeout, retries, opts"
+        );
+        assert!(detect_too_many_parameters(&functions[0], 5).is_none());
+    }
+}
@@ -0,0 +1,319 @@
+use crate::complexity::FunctionTokens;
+use crate::rules::{Finding, STRING_CONCAT_IN_LOOP, matching_brace};
+use crate::tokenizer::{Token, TokenType};
+use std::collections::HashSet;
+
+/// Standard library calls that return a string, used to type `x := pkg.Call(...)`
+const STRING_CALLS: &[(&str, &str)] = &[
+    ("fmt", "Sprint"),
+    ("fmt", "Sprintf"),
+    ("fmt", "Sprintln"),
+    ("strconv", "FormatInt"),
+    ("strconv", "Itoa"),
+    ("strconv", "Quote"),
+    ("strings", "Join"),
+    ("strings", "Repeat"),
+    ("strings", "Replace"),
+    ("strings", "ReplaceAll"),
+    ("strings", "ToLower"),
+    ("strings", "ToUpper"),
+    ("strings", "Trim"),
+    ("strings", "TrimSpace"),
+];
+
+/// Flag Go strings built by repeated concatenation inside a `for` or `range` body
+///
+/// Matches statements of the form `x += y` and `x = x + y` where `x` is known to be a string:
+/// a `string` parameter or named result, a `var x string`, or a variable assigned a string
+/// literal, a `string(...)` conversion, or a string-returning standard library call such as
+/// `fmt.Sprintf`. Each such statement copies the whole string, so the loop is quadratic.
+///
+/// Names declared with `const` never match, and constant expressions such as `"a" + "b"` are
+/// folded by the compiler, so neither is reported. Concatenation inside a function literal
+/// belongs to that literal, not to the loop around it.
+pub fn detect_string_concat_in_loop(function: &FunctionTokens) -> Vec<Finding> {
+    let strings = string_variables(function);
+    if strings.is_empty() {
+        return Vec::new();
+    }
+
+    let body = &function.body;
+    let loops = loop_bodies(body);
+    let mut findings = Vec::new();
+
+    for i in 0..body.len() {
+        if !loops.iter().any(|&(open, close)| open < i && i < close) || !starts_statement(body, i) {
+            continue;
+        }
+        let TokenType::Identifier(name) = &body[i].token_type else { continue };
+        if !strings.contains(name.as_str()) || !is_concatenation(body, i, name) {
+            continue;
+        }
+
+        findings.push(Finding {
+            rule: STRING_CONCAT_IN_LOOP.to_string(),
+            function: function.name.clone(),
+            line: body[i].line,
+            column: body[i].column,
+            end_column: body[i].end_column,
+            message: format!("String '{name}' is concatenated inside a loop"),
+            suggestion: Some("Build the string with a strings.Builder and call String() after the loop".to_string()),
+        });
+    }
+
+    findings
+}
+
+/// `x += ...` or `x = x + ...` starting at `i`
+fn is_concatenation(tokens: &[&Token], i: usize, name: &str) -> bool {
+    match tokens.get(i + 1).map(|t| &t.token_type) {
+        Some(TokenType::Operator(op)) if op == "+=" => true,
+        Some(TokenType::Operator(op)) if op == "=" => {
+            matches!(tokens.get(i + 2).map(|t| &t.token_type), Some(TokenType::Identifier(id)) if id == name)
+                && matches!(tokens.get(i + 3).map(|t| &t.token_type), Some(TokenType::Operator(op)) if op == "+")
+        }
+        _ => false,
+    }
+}
+
+/// Names the function declares or assigns as strings, minus any declared `const`
+fn string_variables<'a>(function: &FunctionTokens<'a>) -> HashSet<&'a str> {
+    let tokens: Vec<&Token> = function.header.iter().chain(&function.body).copied().collect();
+    let mut strings = HashSet::new();
+    let mut constants = HashSet::new();
+
+    for (i, token) in tokens.iter().enumerate() {
+        let TokenType::Identifier(word) = &token.token_type else { continue };
+
+        match word.as_str() {
+            // Parameters and named results: `(a, b string, n int)`
+            "string" if is_parameter_type(&tokens, i) => {
+                let mut k = i - 1;
+                strings.insert(identifier(tokens[k]).unwrap_or_default());
+                while k >= 3
+                    && tokens[k - 1].token_type == TokenType::Comma
+                    && matches!(tokens[k - 3].token_type, TokenType::Comma | TokenType::LeftParen)
+                    && let Some(name) = identifier(tokens[k - 2])
+                {
+                    strings.insert(name);
+                    k -= 2;
+                }
+            }
+            "var" | "const" => {
+                let mut names = Vec::new();
+                let mut k = i + 1;
+                while let Some(name) = tokens.get(k).and_then(|t| identifier(t)) {
+                    names.push(name);
+                    if tokens.get(k + 1).is_some_and(|t| t.token_type == TokenType::Comma) {
+                        k += 2;
+                    } else {
+                        k += 1;
+                        break;
+                    }
+                }
+
+                if word == "const" {
+                    constants.extend(names);
+                } else if tokens.get(k).and_then(|t| identifier(t)) == Some("string")
+                    || (is_assign(tokens.get(k)) && starts_string(&tokens, k + 1))
+                {
+                    strings.extend(names);
+                }
+            }
+            name => {
+                // `x := "..."` and `x = "..."`
+                let assign = match tokens.get(i + 1).map(|t| &t.token_type) {
+                    Some(TokenType::Unknown(':')) if is_assign(tokens.get(i + 2)) => Some(i + 3),
+                    _ if is_assign(tokens.get(i + 1)) => Some(i + 2),
+                    _ => None,
+                };
+                if let Some(rhs) = assign
+                    && starts_string(&tokens, rhs)
+                {
+                    strings.insert(name);
+                }
+            }
+        }
+    }
+
+    strings.retain(|name| !name.is_empty() && !constants.contains(name));
+    strings
+}
+
+/// `string` after a parameter name and before `,` or `)`
+fn is_parameter_type(tokens: &[&Token], i: usize) -> bool {
+    i > 0
+        && identifier(tokens[i - 1]).is_some()
+        && tokens
+            .get(i + 1)
+            .is_some_and(|t| matches!(t.token_type, TokenType::Comma | TokenType::RightParen))
+}
+
+fn is_assign(token: Option<&&Token>) -> bool {
+    token.is_some_and(|t| matches!(&t.token_type, TokenType::Operator(op) if op == "="))
+}
+
+/// Whether the expression at `i` starts with a value that is certainly a string
+fn starts_string(tokens: &[&Token], i: usize) -> bool {
+    let Some(token) = tokens.get(i) else { return false };
+
+    match &token.token_type {
+        TokenType::Literal(text) => text.starts_with('"'),
+        // Raw string literals are not lexed as literals
+        TokenType::Unknown('`') => true,
+        TokenType::Identifier(word) if word == "string" => {
+            tokens.get(i + 1).is_some_and(|t| t.token_type == TokenType::LeftParen)
+        }
+        TokenType::Identifier(package) => {
+            tokens
+                .get(i + 1)
+                .is_some_and(|t| t.token_type == TokenType::Unknown('.'))
+                && tokens
+                    .get(i + 2)
+                    .and_then(|t| identifier(t))
+                    .is_some_and(|call| STRING_CALLS.contains(&(package.as_str(), call)))
+        }
+        _ => false,
+    }
+}
+
+/// Brace ranges of every `for` body, nested loops included
+fn loop_bodies(tokens: &[&Token]) -> Vec<(usize, usize)> {
+    let mut bodies = Vec::new();
+
+    for (i, token) in tokens.iter().enumerate() {
+        if token.token_type != TokenType::For {
+            continue;
+        }
+
+        let mut depth = 0usize;
+        let mut j = i + 1;
+        while j < tokens.len() {
+            match tokens[j].token_type {
+                TokenType::LeftParen | TokenType::LeftBracket => depth += 1,
+                TokenType::RightParen | TokenType::RightBracket => depth = depth.saturating_sub(1),
+                TokenType::LeftBrace if depth == 0 => {
+                    let Some(close) = matching_brace(tokens, j) else { break };
+                    if is_composite_literal(tokens, j) {
+                        j = close;
+                    } else {
+                        bodies.push((j, close));
+                        break;
+                    }
+                }
+                _ => {}
+            }
+            j += 1;
+        }
+    }
+
+    bodies
+}
+
+/// A `{` opening a literal such as `[]string{...}` or `map[string]int{...}` in a loop header
+fn is_composite_literal(tokens: &[&Token], open: usize) -> bool {
+    open >= 2 && identifier(tokens[open - 1]).is_some() && tokens[open - 2].token_type == TokenType::RightBracket
+}
+
+/// Token `i` is the first token of a statement
+fn starts_statement(tokens: &[&Token], i: usize) -> bool {
+    i == 0
+        || matches!(
+            tokens[i - 1].token_type,
+            TokenType::LeftBrace | TokenType::RightBrace | TokenType::Semicolon
+        )
+        || tokens[i - 1].line < tokens[i].line
+}
+
+fn identifier(token: &Token) -> Option<&str> {
+    match &token.token_type {
+        TokenType::Identifier(word) => Some(word.as_str()),
+        _ => None,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::complexity::function_tokens;
+    use crate::tokenizer::{Language, Tokenizer};
+
+    fn findings(source: &str) -> Vec<(String, usize)> {
+        let tokens = Tokenizer::new(source, Language::Go).tokenize().unwrap();
+        function_tokens(&tokens, Language::Go)
+            .iter()
+            .flat_map(detect_string_concat_in_loop)
+            .map(|f| (f.function, f.line))
+            .collect()
+    }
+
+    #[test]
+    fn test_flags_concatenation_in_range_loop() {
+        let source = r#"
+func clean(lower string) string {
+	cleaned := ""
+	for _, char := range lower {
+		if char != ' ' {
+			cleaned += string(char)
+		}
+	}
+	return cleaned
+}
+"#;
+        assert_eq!(findings(source), vec![("clean".to_string(), 6)]);
+    }
+
+    #[test]
+    fn test_flags_self_assignment_and_parameters() {
+        let source = r#"
+func join(prefix, sep string, parts []string) (out string) {
+	for i := 0; i < len(parts); i++ {
+		prefix = prefix + parts[i]
+		out += sep
+	}
+	var line string
+	for _, p := range []string{"a", "b"} {
+		line = line + p
+	}
+	return prefix + out + line
+}
+"#;
+        let lines: Vec<usize> = findings(source).into_iter().map(|(_, line)| line).collect();
+        assert_eq!(lines, vec![4, 5, 9]);
+    }
+
+    #[test]
+    fn test_ignores_numbers_constants_and_code_outside_loops() {
+        let source = r#"
+func total(values []int) (int, string) {
+	const sep = "-"
+	sum := 0
+	label := "total"
+	label += sep
+	for _, v := range values {
+		sum += v
+		title := "a" + "b"
+		label = sep + title
+	}
+	return sum, label
+}
+"#;
+        assert!(findings(source).is_empty());
+    }
+
+    #[test]
+    fn test_function_literal_is_its_own_function() {
+        let source = r#"
+func outer(names []string) {
+	for _, n := range names {
+		go func() {
+			msg := fmt.Sprintf("hi %s", n)
+			msg += "!"
+			send(msg)
+		}()
+	}
+}
+"#;
+        assert!(findings(source).is_empty());
+    }
+}
@@ -0,0 +1,293 @@
+use crate::complexity::FunctionTokens;
+use crate::rules::{DUPLICATE_STRUCT_LITERAL, Finding, matching_brace};
+use crate::tokenizer::{Language, Token, TokenType};
+use std::collections::{BTreeMap, HashSet};
+use std::ptr;
+
+/// Field values that leave a field at its zero value
+const ZERO_VALUES: &[&str] = &["0", "0.0", "\"\"", "``", "nil", "false"];
+
+/// Field names with the text of their values, sorted by field name
+type Fields = Vec<(String, String)>;
+
+/// One keyed composite literal such as `&pkg.Config{Name: "x", Retries: 3}`
+struct Literal<'a> {
+    /// Function the literal is written in
+    function: &'a str,
+    /// First token of the type name
+    start: &'a Token,
+    /// Byte offsets from the type name to the closing brace
+    span: (usize, usize),
+    type_name: String,
+    fields: Fields,
+}
+
+/// Flag keyed struct literals that are written out the same way more than once in a file
+///
+/// Literals match when they have the same type and assign the same value to each of the same
+/// fields, whatever the order of the fields and the whitespace between tokens, so they are
+/// found even where the token-based clone detector sees different sequences. Literals with a
+/// single field, and literals whose fields are all zero values, are too cheap to be worth a
+/// constructor and are skipped. A repeated literal nested in another repeated literal is only
+/// reported as part of the outer one.
+///
+/// `functions` are the functions of one file, from [`function_tokens`](crate::complexity::function_tokens);
+/// each group is reported once, at its first literal.
+pub fn detect_duplicate_struct_literals(functions: &[FunctionTokens]) -> Vec<Finding> {
+    let mut groups: BTreeMap<(String, Fields), Vec<Literal>> = BTreeMap::new();
+    for literal in functions.iter().flat_map(literals) {
+        groups
+            .entry((literal.type_name.clone(), literal.fields.clone()))
+            .or_default()
+            .push(literal);
+    }
+
+    let groups: Vec<Vec<Literal>> = groups.into_values().filter(|group| group.len() > 1).collect();
+    let spans: Vec<(usize, usize)> = groups.iter().flatten().map(|literal| literal.span).collect();
+    let nested = |literal: &Literal| {
+        spans
+            .iter()
+            .any(|&(start, end)| start <= literal.span.0 && literal.span.1 <= end && (start, end) != literal.span)
+    };
+
+    let mut findings: Vec<Finding> = groups
+        .into_iter()
+        .filter(|group| !group.iter().all(nested))
+        .map(|mut group| {
+            group.sort_by_key(|literal| literal.span.0);
+            finding(&group)
+        })
+        .collect();
+    findings.sort_by_key(|f| f.line);
+    findings
+}
+
+/// Keyed literals of at least two fields written in the function itself
+///
+/// Literals are found in `body`, so each one belongs to its innermost function, but read from
+/// `full_body` so field values may hold function literals.
+fn literals<'a>(function: &'a FunctionTokens<'a>) -> Vec<Literal<'a>> {
+    let tokens = &function.full_body;
+    let own: HashSet<*const Token> = function.body.iter().map(|t| ptr::from_ref(*t)).collect();
+    let mut found = Vec::new();
+
+    for open in 1..tokens.len() {
+        if tokens[open].token_type != TokenType::LeftBrace || !own.contains(&ptr::from_ref(tokens[open])) {
+            continue;
+        }
+        let Some(start) = type_start(tokens, open) else { continue };
+        let Some(close) = matching_brace(tokens, open) else { continue };
+        let Some(mut fields) = fields(&tokens[open + 1..close]) else { continue };
+        if fields.len() < 2 || fields.iter().all(|(_, value)| ZERO_VALUES.contains(&value.as_str())) {
+            continue;
+        }
+        fields.sort();
+
+        found.push(Literal {
+            function: &function.name,
+            start: tokens[start],
+            span: (tokens[start].offset, tokens[close].end_offset),
+            type_name: tokens[start..open].iter().map(|t| t.text.as_str()).collect(),
+            fields,
+        });
+    }
+
+    found
+}
+
+/// Index of the first token of a type name `T` or `pkg.T` that ends just before `open`
+///
+/// A name after `)` is a function's result type and a name after `]` is the element type of a
+/// slice or map literal, so neither starts a struct literal.
+fn type_start(tokens: &[&Token], open: usize) -> Option<usize> {
+    let is_name =
+        |i: usize| matches!(&tokens[i].token_type, TokenType::Identifier(word) if !Language::Go.is_keyword(word));
+    let mut start = open.checked_sub(1).filter(|&i| is_name(i))?;
+    if start >= 2 && tokens[start - 1].text == "." && is_name(start - 2) {
+        start -= 2;
+    }
+
+    let after_type = start
+        .checked_sub(1)
+        .is_some_and(|i| matches!(tokens[i].token_type, TokenType::RightParen | TokenType::RightBracket));
+    (!after_type).then_some(start)
+}
+
+/// Split a literal's contents into `Name: value` fields, or `None` if any element is not keyed
+fn fields(tokens: &[&Token]) -> Option<Fields> {
+    let mut elements: Vec<&[&Token]> = Vec::new();
+    let (mut depth, mut from) = (0usize, 0);
+    for (i, token) in tokens.iter().enumerate() {
+        match token.token_type {
+            TokenType::LeftBrace | TokenType::LeftParen | TokenType::LeftBracket => depth += 1,
+            TokenType::RightBrace | TokenType::RightParen | TokenType::RightBracket => depth = depth.saturating_sub(1),
+            TokenType::Comma if depth == 0 => {
+                elements.push(&tokens[from..i]);
+                from = i + 1;
+            }
+            _ => {}
+        }
+    }
+    if from < tokens.len() {
+        elements.push(&tokens[from..]);
+    }
+
+    elements
+        .into_iter()
+        .map(|element| match element {
+            [name, colon, value @ ..]
+                if !value.is_empty()
+                    && colon.token_type == TokenType::Unknown(':')
+                    && matches!(&name.token_type, TokenType::Identifier(word) if !Language::Go.is_keyword(word)) =>
+            {
+                Some((
+                    name.text.clone(),
+                    value.iter().map(|t| t.text.as_str()).collect::<Vec<_>>().join(" "),
+                ))
+            }
+            _ => None,
+        })
+        .collect()
+}
+
+fn finding(group: &[Literal]) -> Finding {
+    let first = &group[0];
+    let names: Vec<&str> = first.fields.iter().map(|(name, _)| name.as_str()).collect();
+    let lines: Vec<String> = group.iter().map(|literal| literal.start.line.to_string()).collect();
+    let bare = first.type_name.rsplit('.').next().unwrap_or(&first.type_name);
+
+    let mut constructor = String::from("new");
+    let mut chars = bare.chars();
+    if let Some(initial) = chars.next() {
+        constructor.extend(initial.to_uppercase());
+        constructor.push_str(chars.as_str());
+    }
+
+    Finding {
+        rule: DUPLICATE_STRUCT_LITERAL.to_string(),
+        function: first.function.to_string(),
+        line: first.start.line,
+        column: first.start.column,
+        end_column: first.start.end_column,
+        message: format!(
+            "{}{{{}}} literal is repeated {} times, at lines {}",
+            first.type_name,
+            names.join(", "),
+            group.len(),
+            lines.join(", ")
+        ),
+        suggestion: Some(format!(
+            "Build it in one constructor, such as {constructor}, and call that instead"
+        )),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::complexity::function_tokens;
+    use crate::tokenizer::Tokenizer;
+
+    fn findings(source: &str) -> Vec<Finding> {
+        let tokens = Tokenizer::new(source, Language::Go).tokenize().unwrap();
+        detect_duplicate_struct_literals(&function_tokens(&tokens, Language::Go))
+    }
+
+    #[test]
+    fn test_repeated_literal_in_any_field_order() {
+        let source = r#"
+func primary() *http.Client {
+	return &http.Client{Timeout: 5 * time.Second, Transport: transport}
+}
+
+func fallback() *http.Client {
+	c := &http.Client{
+		Transport: transport,
+		Timeout:   5 * time.Second,
+	}
+	return c
+}
+
+func slow() *http.Client {
+	return &http.Client{Timeout: 30 * time.Second, Transport: transport}
+}
+"#;
+        let found = findings(source);
+
+        assert_eq!(found.len(), 1);
+        assert_eq!(found[0].rule, DUPLICATE_STRUCT_LITERAL);
+        assert_eq!(
+            (found[0].function.as_str(), found[0].line, found[0].column),
+            ("primary", 3, 10)
+        );
+        assert_eq!(
+            found[0].message,
+            "http.Client{Timeout, Transport} literal is repeated 2 times, at lines 3, 7"
+        );
+        assert_eq!(
+            found[0].suggestion.as_deref(),
+            Some("Build it in one constructor, such as newClient, and call that instead")
+        );
+    }
+
+    #[test]
+    fn test_ignores_single_field_zero_value_and_unkeyed_literals() {
+        let source = r#"
+func build() {
+	a := Point{X: 1}
+	b := Point{X: 1}
+	c := Point{X: 0, Y: 0}
+	d := Point{X: 0, Y: 0}
+	e := Point{1, 2}
+	f := Point{1, 2}
+	g := map[string]int{a: 1, b: 2}
+	h := map[string]int{a: 1, b: 2}
+	for _, p := range points {
+		loop: run(p)
+	}
+}
+"#;
+        assert!(findings(source).is_empty());
+    }
+
+    #[test]
+    fn test_reports_outer_literal_and_closures_once() {
+        let source = r#"
+func servers() []Server {
+	one := Server{Addr: ":80", Limits: Limits{Max: 10, Burst: 2}}
+	two := Server{Addr: ":80", Limits: Limits{Max: 10, Burst: 2}}
+	three := Server{Addr: ":81", Limits: Limits{Max: 10, Burst: 2}}
+	start := func() {
+		serve(Options{Name: "a", Debug: true})
+	}
+	return []Server{one, two, three, Options{Name: "a", Debug: true}}
+}
+"#;
+        let found = findings(source);
+        let messages: Vec<(&str, usize, &str)> = found
+            .iter()
+            .map(|f| (f.function.as_str(), f.line, f.message.as_str()))
+            .collect();
+
+        assert_eq!(
+            messages,
+            vec![
+                (
+                    "servers",
+                    3,
+                    "Limits{Burst, Max} literal is repeated 3 times, at lines 3, 4, 5"
+                ),
+                (
+                    "servers",
+                    3,
+                    "Server{Addr, Limits} literal is repeated 2 times, at lines 3, 4"
+                ),
+                (
+                    "servers$func1",
+                    7,
+                    "Options{Debug, Name} literal is repeated 2 times, at lines 7, 9"
+                ),
+            ]
+        );
+    }
+}
@@ -0,0 +1,335 @@
+use crate::complexity::FunctionTokens;
+use crate::rules::{DUPLICATE_TEST_CASE, Finding, matching_brace};
+use crate::tokenizer::{Language, Token, TokenType};
+use std::collections::{BTreeMap, HashSet};
+use std::ptr;
+
+/// Name prefixes of the Go test, benchmark, and fuzz functions checked
+const TEST_PREFIXES: &[&str] = &["Test", "Benchmark", "Fuzz"];
+
+/// Fields whose string value names a case, compared case-insensitively
+const NAME_FIELDS: &[&str] = &["name", "desc", "description"];
+
+/// One element of a table of test cases
+struct Case<'a> {
+    /// Position in the table, from 0 as Go indexes it
+    index: usize,
+    tokens: &'a [&'a Token],
+}
+
+/// Flag elements of a test's table of cases that are written out the same way more than once
+///
+/// A table is a slice or array literal in a function named `Test...`, `Benchmark...`, or
+/// `Fuzz...` whose elements are anonymous structs, or of a type whose name contains `case` or
+/// `test` such as `testCase`. Elements match when their tokens are identical, comments, layout,
+/// and trailing commas aside, so a row pasted twice is found however short it is. Each group is
+/// reported at its first element, with the index of every copy and, when the cases have a
+/// `name` field, its value. Tables nested in a table are not checked on their own.
+pub fn detect_duplicate_test_cases(function: &FunctionTokens) -> Vec<Finding> {
+    let bare = function.name.rsplit('.').next().unwrap_or(&function.name);
+    if !TEST_PREFIXES.iter().any(|prefix| bare.starts_with(prefix)) {
+        return Vec::new();
+    }
+
+    // Tables are found in `body`, so each one belongs to its innermost function, but read from
+    // `full_body` so cases may hold function literals
+    let tokens = &function.full_body;
+    let own: HashSet<*const Token> = function.body.iter().map(|t| ptr::from_ref(*t)).collect();
+    let mut findings = Vec::new();
+    let mut i = 0;
+
+    while i < tokens.len() {
+        if tokens[i].token_type == TokenType::LeftBracket
+            && own.contains(&ptr::from_ref(tokens[i]))
+            && let Some((open, close)) = table(tokens, i)
+        {
+            findings.extend(duplicate_groups(function, &cases(&tokens[open + 1..close])));
+            i = close;
+        }
+        i += 1;
+    }
+
+    findings
+}
+
+/// Braces of the slice or array literal of test cases whose `[` is at `start`
+fn table(tokens: &[&Token], start: usize) -> Option<(usize, usize)> {
+    let bracket = (start + 1..tokens.len()).find(|&i| {
+        matches!(
+            tokens[i].token_type,
+            TokenType::RightBracket | TokenType::LeftBracket | TokenType::LeftBrace | TokenType::LeftParen
+        )
+    })?;
+    if tokens[bracket].token_type != TokenType::RightBracket {
+        return None;
+    }
+    // `x[i]` indexes a value; only `[]T` and `[N]T` start a literal
+    let indexes = bracket > start + 1
+        && start.checked_sub(1).is_some_and(|i| {
+            matches!(&tokens[i].token_type,
+                TokenType::Identifier(word) if !Language::Go.is_keyword(word))
+                || matches!(tokens[i].token_type, TokenType::RightParen | TokenType::RightBracket)
+        });
+    if indexes {
+        return None;
+    }
+
+    let mut open = bracket + 1;
+    match &tokens.get(open)?.token_type {
+        TokenType::Identifier(word) if word == "struct" => {
+            let fields = open + 1;
+            if tokens.get(fields)?.token_type != TokenType::LeftBrace {
+                return None;
+            }
+            open = matching_brace(tokens, fields)? + 1;
+        }
+        _ => {
+            if tokens[open].text == "*" {
+                open += 1;
+            }
+            let start = open;
+            while tokens
+                .get(open)
+                .is_some_and(|t| matches!(t.token_type, TokenType::Identifier(_)) || t.text == ".")
+            {
+                open += 1;
+            }
+            let name = tokens[start..open]
+                .iter()
+                .map(|t| t.text.as_str())
+                .collect::<String>()
+                .to_lowercase();
+            if !(name.contains("case") || name.contains("test")) {
+                return None;
+            }
+        }
+    }
+
+    if tokens.get(open)?.token_type != TokenType::LeftBrace {
+        return None;
+    }
+    Some((open, matching_brace(tokens, open)?))
+}
+
+/// Split a table's contents into its composite literal elements
+fn cases<'a>(tokens: &'a [&'a Token]) -> Vec<Case<'a>> {
+    let mut elements: Vec<&[&Token]> = Vec::new();
+    let (mut depth, mut from) = (0usize, 0);
+    for (i, token) in tokens.iter().enumerate() {
+        match token.token_type {
+            TokenType::LeftBrace | TokenType::LeftParen | TokenType::LeftBracket => depth += 1,
+            TokenType::RightBrace | TokenType::RightParen | TokenType::RightBracket => depth = depth.saturating_sub(1),
+            TokenType::Comma if depth == 0 => {
+                elements.push(&tokens[from..i]);
+                from = i + 1;
+            }
+            _ => {}
+        }
+    }
+    if from < tokens.len() {
+        elements.push(&tokens[from..]);
+    }
+
+    elements
+        .into_iter()
+        .enumerate()
+        .filter(|(_, element)| element.last().is_some_and(|t| t.token_type == TokenType::RightBrace))
+        .map(|(index, tokens)| Case { index, tokens })
+        .collect()
+}
+
+fn duplicate_groups(function: &FunctionTokens, cases: &[Case]) -> Vec<Finding> {
+    let mut groups: BTreeMap<Vec<&str>, Vec<&Case>> = BTreeMap::new();
+    for case in cases {
+        groups.entry(key(case.tokens)).or_default().push(case);
+    }
+
+    let mut findings: Vec<Finding> = groups
+        .into_values()
+        .filter(|group| group.len() > 1)
+        .map(|group| finding(function, &group))
+        .collect();
+    findings.sort_by_key(|f| f.line);
+    findings
+}
+
+fn finding(function: &FunctionTokens, group: &[&Case]) -> Finding {
+    let listed: Vec<String> = group
+        .iter()
+        .map(|case| format!("index {} (line {})", case.index, case.tokens[0].line))
+        .collect();
+    let message = match case_name(group[0].tokens) {
+        Some(name) => format!(
+            "Test case {name} is written out {} times: {}",
+            group.len(),
+            listed.join(", ")
+        ),
+        None => format!("Test cases are identical: {}", listed.join(", ")),
+    };
+
+    let first = group[0].tokens[0];
+    Finding {
+        rule: DUPLICATE_TEST_CASE.to_string(),
+        function: function.name.clone(),
+        line: first.line,
+        column: first.column,
+        end_column: first.end_column,
+        message,
+        suggestion: Some("Delete the copies, or change them to cover the case they were meant to".to_string()),
+    }
+}
+
+/// Token texts without the trailing commas that gofmt adds when a literal spans several lines
+fn key<'a>(tokens: &[&'a Token]) -> Vec<&'a str> {
+    tokens
+        .iter()
+        .enumerate()
+        .filter(|&(i, t)| {
+            t.token_type != TokenType::Comma
+                || !tokens.get(i + 1).is_some_and(|next| {
+                    matches!(
+                        next.token_type,
+                        TokenType::RightBrace | TokenType::RightParen | TokenType::RightBracket
+                    )
+                })
+        })
+        .map(|(_, t)| t.text.as_str())
+        .collect()
+}
+
+/// Value of the case's `name` field, as written, when it is a string literal
+fn case_name(tokens: &[&Token]) -> Option<String> {
+    let mut depth = 0usize;
+
+    for (i, token) in tokens.iter().enumerate() {
+        match &token.token_type {
+            TokenType::LeftBrace | TokenType::LeftParen | TokenType::LeftBracket => depth += 1,
+            TokenType::RightBrace | TokenType::RightParen | TokenType::RightBracket => depth = depth.saturating_sub(1),
+            TokenType::Identifier(word)
+                if depth == 1 && NAME_FIELDS.iter().any(|field| field.eq_ignore_ascii_case(word)) =>
+            {
+                if let [colon, value, ..] = &tokens[i + 1..]
+                    && colon.token_type == TokenType::Unknown(':')
+                    && matches!(value.token_type, TokenType::Literal(_))
+                    && value.text.starts_with(['"', '`'])
+                {
+                    return Some(value.text.clone());
+                }
+            }
+            _ => {}
+        }
+    }
+
+    None
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::complexity::function_tokens;
+    use crate::tokenizer::Tokenizer;
+
+    fn findings(source: &str) -> Vec<Finding> {
+        let tokens = Tokenizer::new(source, Language::Go).tokenize().unwrap();
+        function_tokens(&tokens, Language::Go)
+            .iter()
+            .flat_map(detect_duplicate_test_cases)
+            .collect()
+    }
+
+    #[test]
+    fn test_repeated_rows_with_their_indexes() {
+        let source = r#"
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{name: "empty", input: "", want: 0},
+		{name: "one", input: "1", want: 1},
+		{
+			name:  "empty",
+			input: "", // pasted twice
+			want:  0,
+		},
+		{"two", "2", 2},
+		{"two", "2", 2},
+		{name: "one", input: "01", want: 1},
+	}
+	for _, tt := range tests {
+		check(t, tt.input, tt.want)
+	}
+}
+"#;
+        let found = findings(source);
+        let messages: Vec<(&str, usize, usize, &str)> = found
+            .iter()
+            .map(|f| (f.function.as_str(), f.line, f.column, f.message.as_str()))
+            .collect();
+
+        assert_eq!(
+            messages,
+            vec![
+                (
+                    "TestParse",
+                    8,
+                    3,
+                    r#"Test case "empty" is written out 2 times: index 0 (line 8), index 2 (line 10)"#
+                ),
+                (
+                    "TestParse",
+                    15,
+                    3,
+                    "Test cases are identical: index 3 (line 15), index 4 (line 16)"
+                ),
+            ]
+        );
+        assert!(found.iter().all(|f| f.rule == DUPLICATE_TEST_CASE));
+    }
+
+    #[test]
+    fn test_named_case_types_and_suite_methods() {
+        let source = r#"
+func (s *Suite) TestRoutes() {
+	for _, tc := range []*routeCase{
+		&routeCase{path: "/", handler: func() { s.ok() }},
+		&routeCase{path: "/", handler: func() { s.ok() }},
+	} {
+		s.run(tc)
+	}
+}
+"#;
+        let found = findings(source);
+
+        assert_eq!(found.len(), 1);
+        assert_eq!(found[0].function, "(*Suite).TestRoutes");
+        assert_eq!(
+            found[0].message,
+            "Test cases are identical: index 0 (line 4), index 1 (line 5)"
+        );
+    }
+
+    #[test]
+    fn test_ignores_other_functions_data_and_nested_tables() {
+        let source = r#"
+func fixtures() []Point {
+	return []struct{ X, Y int }{{1, 2}, {1, 2}}
+}
+
+func TestArea(t *testing.T) {
+	want := []Point{{1, 2}, {1, 2}}
+	cases := [2]struct {
+		in   []Point
+		area int
+	}{
+		{in: []struct{ X int }{{1}, {1}}, area: 1},
+		{in: points[1], area: 2},
+	}
+	_ = cases[0]
+	_ = want
+}
+"#;
+        assert!(findings(source).is_empty());
+    }
+}
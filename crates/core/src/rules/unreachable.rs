@@ -0,0 +1,286 @@
+use crate::complexity::FunctionTokens;
+use crate::complexity::loc::ends_go_statement;
+use crate::complexity::parameters::closing;
+use crate::rules::{Finding, UNREACHABLE_CODE, matching_brace};
+use crate::tokenizer::{Language, Token, TokenType};
+use std::collections::HashSet;
+use std::ptr;
+
+/// Statements that leave the enclosing statement list
+const TERMINATING_KEYWORDS: &[&str] = &["return", "goto", "break", "continue", "fallthrough"];
+
+/// Calls that never return, as a statement of their own
+const TERMINATING_CALLS: &[&str] = &[
+    "panic",
+    "os.Exit",
+    "runtime.Goexit",
+    "log.Fatal",
+    "log.Fatalf",
+    "log.Fatalln",
+    "log.Panic",
+    "log.Panicf",
+    "log.Panicln",
+];
+
+/// Flag the first statement of a block that follows a statement the block cannot continue past
+///
+/// A statement ends the block's flow when it is a `return`, `goto`, `break`, `continue`, or
+/// `fallthrough`, or a call to `panic`, `os.Exit`, `runtime.Goexit`, or `log.Fatal` or
+/// `log.Panic` and their variants. Anything after it in the same list of statements is
+/// unreachable, up to the next label, which a `goto` may jump to. Each clause of a `switch` or
+/// `select` is a list of its own, so a `return` in one case says nothing of the next.
+///
+/// Only those simple statements are recognized: an `if` whose branches all return is not, and a
+/// function literal's body is checked as that function rather than as part of this one.
+pub fn detect_unreachable_code(function: &FunctionTokens) -> Vec<Finding> {
+    let tokens = &function.full_body;
+    let own: HashSet<*const Token> = function.body.iter().map(|t| ptr::from_ref(*t)).collect();
+    let mut findings = Vec::new();
+
+    for open in 0..tokens.len() {
+        if tokens[open].token_type != TokenType::LeftBrace || !own.contains(&ptr::from_ref(tokens[open])) {
+            continue;
+        }
+        let Some(close) = matching_brace(tokens, open) else { continue };
+
+        for statements in statement_lists(&tokens[open + 1..close]) {
+            if let Some(finding) = unreachable_in(function, &statements) {
+                findings.push(finding);
+            }
+        }
+    }
+
+    findings.sort_by_key(|f| f.line);
+    findings
+}
+
+/// First statement after a terminating one in a list, unless a label comes between them
+fn unreachable_in(function: &FunctionTokens, statements: &[&[&Token]]) -> Option<Finding> {
+    let mut terminated: Option<(String, usize)> = None;
+
+    for statement in statements {
+        if is_label(statement) {
+            terminated = None;
+        } else if let Some((what, line)) = &terminated {
+            let first = statement[0];
+            return Some(Finding {
+                rule: UNREACHABLE_CODE.to_string(),
+                function: function.name.clone(),
+                line: first.line,
+                column: first.column,
+                end_column: first.end_column,
+                message: format!("Unreachable code after `{what}` on line {line}"),
+                suggestion: Some(format!("Delete it, or move it above the `{what}`")),
+            });
+        }
+
+        if let Some(what) = terminating(statement) {
+            terminated = Some((what, statement[0].line));
+        }
+    }
+
+    None
+}
+
+/// Split the tokens between a block's braces into statements, one list per path through it:
+/// the whole block, or each clause of a `switch` or `select`, without its `case ...:` label
+fn statement_lists<'a>(tokens: &'a [&'a Token]) -> Vec<Vec<&'a [&'a Token]>> {
+    let mut lists = vec![Vec::new()];
+    let (mut depth, mut from, mut in_header) = (0usize, 0, false);
+    let mut i = 0;
+
+    while i < tokens.len() {
+        let token = tokens[i];
+        if depth == 0 && i > from && tokens[i - 1].line < token.line && ends_go_statement(&tokens[i - 1].token_type) {
+            push_statement(&mut lists, &tokens[from..i]);
+            from = i;
+        }
+
+        match token.token_type {
+            TokenType::LeftBrace => {
+                if depth == 0 {
+                    in_header = false;
+                }
+                depth += 1;
+            }
+            TokenType::LeftParen | TokenType::LeftBracket => depth += 1,
+            TokenType::RightBrace | TokenType::RightParen | TokenType::RightBracket => depth = depth.saturating_sub(1),
+            TokenType::If | TokenType::For | TokenType::Switch if depth == 0 => in_header = true,
+            TokenType::Semicolon if depth == 0 && !in_header => {
+                push_statement(&mut lists, &tokens[from..i]);
+                from = i + 1;
+            }
+            TokenType::Case | TokenType::Default if depth == 0 => {
+                push_statement(&mut lists, &tokens[from..i]);
+                lists.push(Vec::new());
+                i = clause_colon(tokens, i).unwrap_or(tokens.len() - 1);
+                from = i + 1;
+            }
+            _ => {}
+        }
+        i += 1;
+    }
+    push_statement(&mut lists, &tokens[from..]);
+
+    lists
+}
+
+fn push_statement<'a>(lists: &mut [Vec<&'a [&'a Token]>], statement: &'a [&'a Token]) {
+    if !statement.is_empty()
+        && let Some(list) = lists.last_mut()
+    {
+        list.push(statement);
+    }
+}
+
+/// Index of the `:` ending the `case` or `default` label at `start`
+fn clause_colon(tokens: &[&Token], start: usize) -> Option<usize> {
+    let mut depth = 0usize;
+
+    (start..tokens.len()).find(|&i| match tokens[i].token_type {
+        TokenType::LeftBrace | TokenType::LeftParen | TokenType::LeftBracket => {
+            depth += 1;
+            false
+        }
+        TokenType::RightBrace | TokenType::RightParen | TokenType::RightBracket => {
+            depth = depth.saturating_sub(1);
+            false
+        }
+        TokenType::Unknown(':') => depth == 0,
+        _ => false,
+    })
+}
+
+/// Whether a statement starts with a label, as `retry:` does
+fn is_label(statement: &[&Token]) -> bool {
+    matches!(&statement[0].token_type, TokenType::Identifier(word) if !Language::Go.is_keyword(word))
+        && statement
+            .get(1)
+            .is_some_and(|t| t.token_type == TokenType::Unknown(':'))
+}
+
+/// The keyword or function name of a statement that never falls through to the next one
+fn terminating(statement: &[&Token]) -> Option<String> {
+    let first = &statement[0].text;
+    if TERMINATING_KEYWORDS.contains(&first.as_str()) {
+        return Some(first.clone());
+    }
+
+    let open = statement.iter().position(|t| t.token_type == TokenType::LeftParen)?;
+    let name: String = statement[..open].iter().map(|t| t.text.as_str()).collect();
+    let close = closing(statement, open)?;
+
+    (TERMINATING_CALLS.contains(&name.as_str()) && close == statement.len() - 1).then_some(name)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::complexity::function_tokens;
+    use crate::tokenizer::Tokenizer;
+
+    fn findings(source: &str) -> Vec<(String, usize, usize, String)> {
+        let tokens = Tokenizer::new(source, Language::Go).tokenize().unwrap();
+        function_tokens(&tokens, Language::Go)
+            .iter()
+            .flat_map(detect_unreachable_code)
+            .map(|f| (f.function, f.line, f.column, f.message))
+            .collect()
+    }
+
+    #[test]
+    fn test_statements_after_return_and_exit() {
+        let source = r#"
+func load(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+		log.Printf("read %s: %v", path, err)
+	}
+	return data, nil
+	cleanup()
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1); fmt.Println("done")
+	}
+	panic(fmt.Sprintf("unreachable: %d", code))
+	// comments are not statements
+}
+"#;
+        assert_eq!(
+            findings(source),
+            vec![
+                (
+                    "load".to_string(),
+                    6,
+                    3,
+                    "Unreachable code after `return` on line 5".to_string()
+                ),
+                (
+                    "load".to_string(),
+                    9,
+                    2,
+                    "Unreachable code after `return` on line 8".to_string()
+                ),
+                (
+                    "main".to_string(),
+                    15,
+                    15,
+                    "Unreachable code after `os.Exit` on line 15".to_string()
+                ),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_clauses_labels_and_function_literals() {
+        let source = r#"
+func next(ch <-chan int, kind string) int {
+	switch kind {
+	case "a":
+		return 1
+	case "b", "c":
+		panic("bad kind")
+	default:
+		break
+		kind = ""
+	}
+	select {
+	case v := <-ch:
+		return v
+	default:
+	}
+	goto retry
+retry:
+	handler := func() int {
+		return 2
+		return 3
+	}
+	for i := 0; i < 3; i++ {
+		continue
+	}
+	return handler()
+}
+"#;
+        assert_eq!(
+            findings(source),
+            vec![
+                (
+                    "next".to_string(),
+                    10,
+                    3,
+                    "Unreachable code after `break` on line 9".to_string()
+                ),
+                (
+                    "next$func1".to_string(),
+                    21,
+                    3,
+                    "Unreachable code after `return` on line 20".to_string()
+                ),
+            ]
+        );
+    }
+}
@@ -0,0 +1,432 @@
+use crate::Result;
+use crate::cloner::CloneLocation;
+use crate::loader::SourceFile;
+use crate::reporter::Report;
+use crate::tokenizer::{Language, Token, TokenType, Tokenizer};
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+
+/// Comment prefix that starts an ignore directive
+pub const IGNORE_DIRECTIVE: &str = "mccabre:ignore";
+
+/// Kind of finding an ignore directive applies to
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum FindingKind {
+    Clone,
+    Complexity,
+}
+
+/// Number of findings dropped by ignore directives
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct SuppressedCounts {
+    /// Functions removed from the complexity results
+    pub complexity: usize,
+    /// Rule findings removed, such as function-length and parameter findings
+    #[serde(default)]
+    pub findings: usize,
+    /// Clone groups, error-handling clusters, duplicate declarations, and function-body groups
+    /// removed because fewer than two instances remained, and similar pairs removed
+    pub clones: usize,
+}
+
+impl SuppressedCounts {
+    pub fn total(&self) -> usize {
+        self.complexity + self.findings + self.clones
+    }
+}
+
+/// Lines covered by one `//mccabre:ignore` directive
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct IgnoreRegion {
+    pub kinds: Vec<FindingKind>,
+    /// First line of code after the directive
+    pub start_line: usize,
+    /// Line holding the opening brace of the block that starts at `start_line`
+    pub open_line: usize,
+    /// Line holding the matching closing brace
+    pub end_line: usize,
+}
+
+/// Ignore directives found in a set of files
+///
+/// A directive is a line comment placed on the line above a function or block:
+///
+/// ```text
+/// //mccabre:ignore clone table-driven test cases
+/// func TestParse(t *testing.T) {
+/// ```
+///
+/// The word after `mccabre:ignore` lists the kinds to ignore, comma-separated (`clone`,
+/// `complexity`). Without a recognized kind list the directive ignores both; anything after it
+/// is free text. A directive covers the next line of code and the block opened there.
+#[derive(Debug, Clone, Default)]
+pub struct Suppressions {
+    regions: HashMap<PathBuf, Vec<IgnoreRegion>>,
+}
+
+impl Suppressions {
+    /// Collect the directives of every file
+    pub fn from_files(files: &[SourceFile]) -> Result<Self> {
+        let mut regions = HashMap::new();
+
+        for file in files {
+            let found = parse_directives(&file.content, file.language)?;
+            if !found.is_empty() {
+                regions.insert(file.path.clone(), found);
+            }
+        }
+
+        Ok(Self { regions })
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.regions.is_empty()
+    }
+
+    /// Drop suppressed findings and record how many were removed
+    ///
+    /// A function is suppressed when it starts inside a `complexity` directive's header lines,
+    /// and a rule finding when its line is inside a `complexity` directive's block. A clone
+    /// instance is dropped when it lies inside a `clone` directive's block, and the group goes
+    /// away once fewer than two instances remain; error-handling clusters, duplicate
+    /// declarations, and function-body groups are filtered the same way, and a similar pair goes
+    /// when either function is inside a `clone` directive's block. Each removal is counted.
+    pub fn filter(&self, report: Report) -> Report {
+        if self.is_empty() {
+            return report;
+        }
+
+        let mut suppressed = report.suppressed;
+
+        let files = report
+            .files
+            .into_iter()
+            .map(|mut file| {
+                let before = file.cyclomatic.functions.len();
+                file.cyclomatic.functions.retain(|func| {
+                    !self
+                        .matching(&file.path, FindingKind::Complexity)
+                        .any(|r| (r.start_line..=r.open_line).contains(&func.line))
+                });
+                suppressed.complexity += before - file.cyclomatic.functions.len();
+
+                let before = file.findings.len();
+                file.findings.retain(|finding| {
+                    !self
+                        .matching(&file.path, FindingKind::Complexity)
+                        .any(|r| (r.start_line..=r.end_line).contains(&finding.line))
+                });
+                suppressed.findings += before - file.findings.len();
+                file
+            })
+            .collect();
+
+        let clones = report
+            .clones
+            .into_iter()
+            .filter_map(|mut clone| {
+                clone.locations.retain(|loc| !self.suppresses_clone(loc));
+                if clone.locations.len() < 2 {
+                    suppressed.clones += 1;
+                    None
+                } else {
+                    Some(clone)
+                }
+            })
+            .collect();
+
+        let before = report.errcheck_clusters.len();
+        let errcheck_clusters: Vec<_> = report
+            .errcheck_clusters
+            .into_iter()
+            .filter_map(|mut cluster| {
+                cluster.instances.retain(|loc| !self.suppresses_clone(loc));
+                (cluster.instances.len() >= 2).then_some(cluster)
+            })
+            .collect();
+        suppressed.clones += before - errcheck_clusters.len();
+
+        let before = report.duplicate_declarations.len();
+        let duplicate_declarations: Vec<_> = report
+            .duplicate_declarations
+            .into_iter()
+            .filter_map(|mut duplicate| {
+                duplicate.instances.retain(|loc| !self.suppresses_clone(loc));
+                (duplicate.instances.len() >= 2).then_some(duplicate)
+            })
+            .collect();
+        suppressed.clones += before - duplicate_declarations.len();
+
+        let before = report.function_clones.len();
+        let function_clones: Vec<_> = report
+            .function_clones
+            .into_iter()
+            .filter_map(|mut clone| {
+                clone.functions.retain(|f| !self.suppresses_clone(&f.location));
+                (clone.functions.len() >= 2).then_some(clone)
+            })
+            .collect();
+        suppressed.clones += before - function_clones.len();
+
+        let before = report.similar_functions.len();
+        let similar_functions: Vec<_> = report
+            .similar_functions
+            .into_iter()
+            .filter(|pair| !pair.functions.iter().any(|f| self.suppresses_clone(&f.location)))
+            .collect();
+        suppressed.clones += before - similar_functions.len();
+
+        let mut filtered = Report::new(files, clones);
+        filtered.suppressed = suppressed;
+        filtered.parse_errors = report.parse_errors;
+        filtered.errcheck_clusters = errcheck_clusters;
+        filtered.similar_functions = similar_functions;
+        filtered.duplicate_declarations = duplicate_declarations;
+        filtered.function_clones = function_clones;
+        filtered
+    }
+
+    fn suppresses_clone(&self, loc: &CloneLocation) -> bool {
+        self.matching(&loc.file, FindingKind::Clone)
+            .any(|r| r.start_line <= loc.start_line && loc.end_line <= r.end_line)
+    }
+
+    fn matching(&self, path: &Path, kind: FindingKind) -> impl Iterator<Item = &IgnoreRegion> {
+        self.regions
+            .get(path)
+            .into_iter()
+            .flatten()
+            .filter(move |r| r.kinds.contains(&kind))
+    }
+}
+
+/// Find the ignore directives in a source file and the lines each one covers
+pub fn parse_directives(source: &str, language: Language) -> Result<Vec<IgnoreRegion>> {
+    let tokens = Tokenizer::new(source, language).tokenize()?;
+    let lines: Vec<&str> = source.lines().collect();
+    let code: Vec<&Token> = tokens.iter().filter(|t| t.token_type.is_significant()).collect();
+    let mut regions = Vec::new();
+
+    for comment in tokens.iter().filter(|t| t.token_type == TokenType::Comment) {
+        let Some(text) = lines.get(comment.line - 1) else { continue };
+        let text: String = text.chars().skip(comment.column - 1).collect();
+        let Some(kinds) = directive_kinds(&text) else { continue };
+
+        // A trailing directive covers its own line, otherwise the next line of code
+        let Some(start) = code
+            .iter()
+            .position(|t| t.line > comment.line || (t.line == comment.line && t.column < comment.column))
+        else {
+            continue;
+        };
+
+        let start_line = code[start].line;
+        let (open_line, end_line) = block_lines(&code, start).unwrap_or((start_line, start_line));
+        regions.push(IgnoreRegion { kinds, start_line, open_line, end_line });
+    }
+
+    Ok(regions)
+}
+
+/// Kinds named by a `//mccabre:ignore` comment, or `None` if the comment is not a directive
+fn directive_kinds(comment: &str) -> Option<Vec<FindingKind>> {
+    let rest = comment
+        .strip_prefix("//")?
+        .trim_start()
+        .strip_prefix(IGNORE_DIRECTIVE)?;
+    if !rest.is_empty() && !rest.starts_with(char::is_whitespace) {
+        return None;
+    }
+
+    let listed: Option<Vec<FindingKind>> = rest.split_whitespace().next().and_then(|word| {
+        word.split(',')
+            .map(|kind| match kind {
+                "clone" | "clones" => Some(FindingKind::Clone),
+                "complexity" => Some(FindingKind::Complexity),
+                _ => None,
+            })
+            .collect()
+    });
+
+    Some(listed.unwrap_or_else(|| vec![FindingKind::Clone, FindingKind::Complexity]))
+}
+
+/// Lines of the first block opened at or after `start`, unless a statement ends first
+fn block_lines(code: &[&Token], start: usize) -> Option<(usize, usize)> {
+    let mut depth = 0usize;
+    let mut open_line = None;
+
+    for token in &code[start..] {
+        match token.token_type {
+            TokenType::LeftParen | TokenType::LeftBracket if open_line.is_none() => depth += 1,
+            TokenType::RightParen | TokenType::RightBracket if open_line.is_none() => {
+                depth = depth.saturating_sub(1);
+            }
+            TokenType::Semicolon if open_line.is_none() && depth == 0 => return None,
+            TokenType::LeftBrace => {
+                if open_line.is_none() {
+                    open_line = Some(token.line);
+                    depth = 0;
+                }
+                depth += 1;
+            }
+            TokenType::RightBrace if open_line.is_some() => {
+                depth -= 1;
+                if depth == 0 {
+                    return Some((open_line?, token.line));
+                }
+            }
+            TokenType::RightBrace => return None,
+            _ => {}
+        }
+    }
+
+    None
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::cloner::{Clone, CloneLocation, ErrcheckCluster};
+    use crate::reporter::FileReport;
+    use crate::rules::Finding;
+
+    const SOURCE: &str = r#"package cases
+
+//mccabre:ignore clone table-driven cases
+func TestParse(t *testing.T) {
+	cases := []struct{ in string }{
+		{"a"},
+	}
+	run(t, cases)
+}
+
+//mccabre:ignore complexity
+func dispatch(kind int) int {
+	if kind > 0 && kind < 10 {
+		return 1
+	}
+	return 0
+}
+
+func helper() {} //mccabre:ignore
+
+// mccabre:ignored is not a directive
+func other() {}
+"#;
+
+    fn file() -> SourceFile {
+        SourceFile { path: PathBuf::from("cases_test.go"), content: SOURCE.to_string(), language: Language::Go }
+    }
+
+    fn clone(locations: &[(&str, usize, usize)]) -> Clone {
+        Clone {
+            id: 1,
+            length: 40,
+            locations: locations
+                .iter()
+                .map(|(file, start, end)| CloneLocation {
+                    file: PathBuf::from(file),
+                    start_line: *start,
+                    end_line: *end,
+                    gap_tokens: 0,
+                    ..Default::default()
+                })
+                .collect(),
+            hash: 7,
+            group_id: String::new(),
+        }
+    }
+
+    #[test]
+    fn test_parse_directives() {
+        let regions = parse_directives(SOURCE, Language::Go).unwrap();
+
+        assert_eq!(
+            regions,
+            vec![
+                IgnoreRegion { kinds: vec![FindingKind::Clone], start_line: 4, open_line: 4, end_line: 9 },
+                IgnoreRegion { kinds: vec![FindingKind::Complexity], start_line: 12, open_line: 12, end_line: 17 },
+                IgnoreRegion {
+                    kinds: vec![FindingKind::Clone, FindingKind::Complexity],
+                    start_line: 19,
+                    open_line: 19,
+                    end_line: 19,
+                },
+            ]
+        );
+    }
+
+    #[test]
+    fn test_directive_kinds() {
+        assert_eq!(
+            directive_kinds("//mccabre:ignore clone,complexity"),
+            Some(vec![FindingKind::Clone, FindingKind::Complexity])
+        );
+        assert_eq!(
+            directive_kinds("// mccabre:ignore complexity legacy parser"),
+            Some(vec![FindingKind::Complexity])
+        );
+        assert_eq!(
+            directive_kinds("//mccabre:ignore generated table"),
+            Some(vec![FindingKind::Clone, FindingKind::Complexity])
+        );
+        assert_eq!(directive_kinds("//mccabre:ignored"), None);
+        assert_eq!(directive_kinds("/* mccabre:ignore */"), None);
+    }
+
+    #[test]
+    fn test_filter_drops_and_counts_findings() {
+        let files = vec![file()];
+        let suppressions = Suppressions::from_files(&files).unwrap();
+        let mut file_report = FileReport::from_source(files[0].path.clone(), SOURCE, Language::Go).unwrap();
+        file_report.findings = [(5, "TestParse"), (12, "dispatch"), (14, "dispatch")]
+            .into_iter()
+            .map(|(line, function)| Finding {
+                rule: "function-too-long".to_string(),
+                function: function.to_string(),
+                line,
+                column: 1,
+                end_column: 1,
+                message: String::new(),
+                suggestion: None,
+            })
+            .collect();
+        let mut report = Report::new(
+            vec![file_report],
+            vec![
+                clone(&[("cases_test.go", 5, 7), ("other_test.go", 10, 12)]),
+                clone(&[("cases_test.go", 5, 7), ("a_test.go", 1, 3), ("b_test.go", 1, 3)]),
+                clone(&[("cases_test.go", 12, 16), ("other_test.go", 20, 24)]),
+            ],
+        );
+        report.errcheck_clusters = vec![ErrcheckCluster {
+            id: 1,
+            shape: "if err != nil { return err }".to_string(),
+            instances: clone(&[("cases_test.go", 6, 6), ("other_test.go", 3, 3)]).locations,
+        }];
+
+        let filtered = suppressions.filter(report);
+
+        let names: Vec<&str> = filtered.files[0]
+            .cyclomatic
+            .functions
+            .iter()
+            .map(|f| f.name.as_str())
+            .collect();
+        assert_eq!(names, vec!["TestParse", "other"]);
+
+        assert_eq!(filtered.clones.len(), 2);
+        assert_eq!(filtered.clones[0].locations.len(), 2);
+        assert_eq!(filtered.clones[1].locations[0].start_line, 12);
+        assert!(filtered.errcheck_clusters.is_empty());
+        let lines: Vec<usize> = filtered.files[0].findings.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![5]);
+        assert_eq!(
+            filtered.suppressed,
+            SuppressedCounts { complexity: 2, findings: 2, clones: 2 }
+        );
+        assert_eq!(filtered.suppressed.total(), 6);
+    }
+}
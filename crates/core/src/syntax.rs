@@ -0,0 +1,208 @@
+use crate::loader::SourceFile;
+use crate::parallel::map_ordered;
+use crate::tokenizer::{Language, Token, TokenType, Tokenizer};
+use serde::{Deserialize, Serialize};
+use std::borrow::Cow;
+use std::fmt;
+use std::path::PathBuf;
+
+/// A file left out of the analysis because it could not be parsed
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ParseError {
+    pub file: PathBuf,
+    /// 1-based line of the problem
+    pub line: usize,
+    /// 1-based column, counted in Unicode scalar values
+    pub column: usize,
+    pub message: String,
+}
+
+impl ParseError {
+    /// First syntax error in a file, if any
+    ///
+    /// Only Go files are checked. The checks are lexical: unterminated strings, runes, raw
+    /// strings, and block comments, newlines inside interpreted strings, and brackets that are
+    /// unbalanced or closed by the wrong kind. Other languages are analyzed as tokenized.
+    pub fn check(file: &SourceFile) -> Option<Self> {
+        if file.language != Language::Go {
+            return None;
+        }
+
+        let error = |line, column, message: String| Self { file: file.path.clone(), line, column, message };
+        match Tokenizer::new(&file.content, file.language).tokenize() {
+            Ok(tokens) => {
+                first_error(&file.content, &tokens).map(|(token, message)| error(token.line, token.column, message))
+            }
+            Err(e) => Some(error(1, 1, e.to_string())),
+        }
+    }
+}
+
+impl fmt::Display for ParseError {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(
+            f,
+            "{}:{}:{}: {}",
+            self.file.display(),
+            self.line,
+            self.column,
+            self.message
+        )
+    }
+}
+
+/// Split loaded files into those that parse and the errors of those that do not
+///
+/// Files keep their order, and are borrowed rather than copied when every one of them parses.
+pub fn partition(files: &[SourceFile], jobs: usize) -> (Cow<'_, [SourceFile]>, Vec<ParseError>) {
+    let errors: Vec<ParseError> = map_ordered(files, jobs, ParseError::check)
+        .into_iter()
+        .flatten()
+        .collect();
+    if errors.is_empty() {
+        return (Cow::Borrowed(files), errors);
+    }
+
+    let valid = files
+        .iter()
+        .filter(|f| !errors.iter().any(|e| e.file == f.path))
+        .cloned()
+        .collect();
+    (Cow::Owned(valid), errors)
+}
+
+/// The token at fault and a message, for the first error in a Go token stream
+fn first_error<'a>(source: &str, tokens: &'a [Token]) -> Option<(&'a Token, String)> {
+    let mut open: Vec<&Token> = Vec::new();
+
+    for token in tokens {
+        match &token.token_type {
+            TokenType::Literal(text) => {
+                if let Some(message) = literal_error(text) {
+                    return Some((token, message.to_string()));
+                }
+            }
+            TokenType::Comment => {
+                let text = &source[token.offset..token.end_offset];
+                if text.starts_with("/*") && (text.len() < 4 || !text.ends_with("*/")) {
+                    return Some((token, "comment not terminated".to_string()));
+                }
+            }
+            TokenType::LeftBrace | TokenType::LeftParen | TokenType::LeftBracket => open.push(token),
+            TokenType::RightBrace | TokenType::RightParen | TokenType::RightBracket => match open.pop() {
+                Some(opener) if closer(opener) == token.text => {}
+                Some(opener) => {
+                    return Some((token, format!("expected '{}', found '{}'", closer(opener), token.text)));
+                }
+                None => return Some((token, format!("unexpected '{}'", token.text))),
+            },
+            _ => {}
+        }
+    }
+
+    open.pop()
+        .map(|opener| (opener, format!("'{}' is not closed", opener.text)))
+}
+
+fn literal_error(text: &str) -> Option<&'static str> {
+    let (quote, message) = match text.chars().next()? {
+        '`' => return (text.len() < 2 || !text.ends_with('`')).then_some("raw string literal not terminated"),
+        '"' => ('"', "string literal not terminated"),
+        '\'' => ('\'', "rune literal not terminated"),
+        _ => return None,
+    };
+
+    if quote == '"' && text.contains('\n') {
+        return Some("newline in string");
+    }
+
+    // The closing quote must not be escaped: an even run of backslashes before it
+    let body = &text[1..];
+    let closed = body.strip_suffix(quote).is_some_and(|inner| {
+        let backslashes = inner.chars().rev().take_while(|&c| c == '\\').count();
+        backslashes % 2 == 0
+    });
+    (!closed).then_some(message)
+}
+
+fn closer(opener: &Token) -> &'static str {
+    match opener.token_type {
+        TokenType::LeftParen => ")",
+        TokenType::LeftBracket => "]",
+        _ => "}",
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn check(source: &str) -> Option<(usize, usize, String)> {
+        let file = SourceFile::new("main.go", source).unwrap();
+        ParseError::check(&file).map(|e| (e.line, e.column, e.message))
+    }
+
+    #[test]
+    fn test_valid_source() {
+        let source = "package main\n\nfunc main() {\n\ts := `a /* b`\n\tr := '\\''\n\tfmt.Println(s, r, \"q\\\\\", []int{1})\n}\n";
+        assert_eq!(check(source), None);
+    }
+
+    #[test]
+    fn test_lexical_errors() {
+        assert_eq!(
+            check("package main\n\nvar s = \"open\n"),
+            Some((3, 9, "newline in string".to_string()))
+        );
+        assert_eq!(
+            check("package main\n\nvar s = \"open\\\"\n"),
+            Some((3, 9, "newline in string".to_string()))
+        );
+        assert_eq!(
+            check("package main\n\nvar s = `raw"),
+            Some((3, 9, "raw string literal not terminated".to_string()))
+        );
+        assert_eq!(
+            check("package main\n\n/* never closed\nfunc main() {}\n"),
+            Some((3, 1, "comment not terminated".to_string()))
+        );
+    }
+
+    #[test]
+    fn test_bracket_errors() {
+        assert_eq!(
+            check("package main\n\nfunc main() {\n\tf(1]\n}\n"),
+            Some((4, 5, "expected ')', found ']'".to_string()))
+        );
+        assert_eq!(
+            check("package main\n\nfunc main() {\n\tif x {\n}\n"),
+            Some((3, 13, "'{' is not closed".to_string()))
+        );
+        assert_eq!(check("package main\n}\n"), Some((2, 1, "unexpected '}'".to_string())));
+    }
+
+    #[test]
+    fn test_other_languages_are_not_checked() {
+        let file = SourceFile::new("lib.rs", "fn f<'a>(s: &'a str) {").unwrap();
+        assert_eq!(ParseError::check(&file), None);
+    }
+
+    #[test]
+    fn test_partition() {
+        let good = SourceFile::new("a.go", "package a\n").unwrap();
+        let bad = SourceFile::new("b.go", "package b\nfunc f() {\n").unwrap();
+
+        let all_good = [good.clone()];
+        let (valid, errors) = partition(&all_good, 2);
+        assert!(matches!(valid, Cow::Borrowed(_)));
+        assert!(errors.is_empty());
+
+        let mixed = [bad, good];
+        let (valid, errors) = partition(&mixed, 2);
+        assert_eq!(valid.len(), 1);
+        assert_eq!(valid[0].path, PathBuf::from("a.go"));
+        assert_eq!(errors.len(), 1);
+        assert_eq!(errors[0].to_string(), "b.go:2:10: '{' is not closed");
+    }
+}
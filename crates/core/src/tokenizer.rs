@@ -1,4 +1,6 @@
 use crate::error::{MccabreError, Result};
+use serde::{Deserialize, Serialize};
+use std::fmt;
 use std::path::Path;
 
 #[derive(Debug, Clone, Copy, PartialEq, Eq)]
@@ -53,9 +55,113 @@ impl Language {
             | Language::Cpp => ("/*", "*/"),
         }
     }
+
+    /// Get the reserved words of the language
+    ///
+    /// The tokenizer only gives control-flow keywords their own [`TokenType`]; everything else is
+    /// lexed as an identifier, so normalization needs this list to keep keywords verbatim.
+    pub fn keywords(&self) -> &'static [&'static str] {
+        match self {
+            Language::Rust => RUST_KEYWORDS,
+            Language::JavaScript => JAVASCRIPT_KEYWORDS,
+            Language::TypeScript => TYPESCRIPT_KEYWORDS,
+            Language::Go => GO_KEYWORDS,
+            Language::Java => JAVA_KEYWORDS,
+            Language::Cpp => CPP_KEYWORDS,
+        }
+    }
+
+    /// Returns true if the word is a reserved word of the language
+    pub fn is_keyword(&self, word: &str) -> bool {
+        self.keywords().contains(&word)
+    }
+}
+
+#[rustfmt::skip]
+const RUST_KEYWORDS: &[&str] = &[
+    "as", "async", "await", "break", "const", "continue", "crate", "dyn", "else", "enum", "extern", "false", "fn",
+    "for", "if", "impl", "in", "let", "loop", "match", "mod", "move", "mut", "pub", "ref", "return", "self", "Self",
+    "static", "struct", "super", "trait", "true", "type", "unsafe", "use", "where", "while",
+];
+
+#[rustfmt::skip]
+const JAVASCRIPT_KEYWORDS: &[&str] = &[
+    "async", "await", "break", "case", "catch", "class", "const", "continue", "debugger", "default", "delete", "do",
+    "else", "export", "extends", "false", "finally", "for", "function", "if", "import", "in", "instanceof", "let",
+    "new", "null", "of", "return", "super", "switch", "this", "throw", "true", "try", "typeof", "undefined", "var",
+    "void", "while", "with", "yield",
+];
+
+#[rustfmt::skip]
+const TYPESCRIPT_KEYWORDS: &[&str] = &[
+    "abstract", "any", "as", "async", "await", "boolean", "break", "case", "catch", "class", "const", "continue",
+    "debugger", "declare", "default", "delete", "do", "else", "enum", "export", "extends", "false", "finally",
+    "for", "function", "if", "implements", "import", "in", "instanceof", "interface", "let", "namespace", "new",
+    "null", "number", "of", "private", "protected", "public", "readonly", "return", "string", "super", "switch",
+    "this", "throw", "true", "try", "type", "typeof", "undefined", "var", "void", "while", "with", "yield",
+];
+
+#[rustfmt::skip]
+const GO_KEYWORDS: &[&str] = &[
+    "break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough", "false", "for", "func",
+    "go", "goto", "if", "import", "interface", "map", "nil", "package", "range", "return", "select", "struct",
+    "switch", "true", "type", "var",
+];
+
+#[rustfmt::skip]
+const JAVA_KEYWORDS: &[&str] = &[
+    "abstract", "assert", "boolean", "break", "byte", "case", "catch", "char", "class", "const", "continue",
+    "default", "do", "double", "else", "enum", "extends", "false", "final", "finally", "float", "for", "goto", "if",
+    "implements", "import", "instanceof", "int", "interface", "long", "native", "new", "null", "package", "private",
+    "protected", "public", "return", "short", "static", "strictfp", "super", "switch", "synchronized", "this",
+    "throw", "throws", "transient", "true", "try", "var", "void", "volatile", "while",
+];
+
+#[rustfmt::skip]
+const CPP_KEYWORDS: &[&str] = &[
+    "auto", "bool", "break", "case", "catch", "char", "class", "const", "constexpr", "continue", "default",
+    "delete", "do", "double", "else", "enum", "explicit", "extern", "false", "float", "for", "friend", "goto", "if",
+    "inline", "int", "long", "namespace", "new", "nullptr", "operator", "private", "protected", "public", "return",
+    "short", "signed", "sizeof", "static", "struct", "switch", "template", "this", "throw", "true", "try",
+    "typedef", "typename", "union", "unsigned", "using", "virtual", "void", "volatile", "while",
+];
+
+/// Token normalization applied before clone matching
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum NormalizeMode {
+    /// Compare tokens verbatim (type-1 clones)
+    #[default]
+    Exact,
+    /// Collapse identifiers to `IDENT` and numeric/string literals to `LIT` (type-2 clones)
+    Renamed,
+    /// Collapse string, character, and rune literals to `STR`, keeping everything else verbatim
+    ///
+    /// Matches code that differs only in message text, such as translated strings, without
+    /// also matching renamed variables or changed numbers.
+    Strings,
+}
+
+impl NormalizeMode {
+    /// Placeholder text emitted for identifiers in renamed mode
+    pub const IDENT: &'static str = "IDENT";
+    /// Placeholder text emitted for literals in renamed mode
+    pub const LIT: &'static str = "LIT";
+    /// Placeholder text emitted for string and rune literals in strings mode
+    pub const STR: &'static str = "STR";
 }
 
-#[derive(Debug, Clone, PartialEq, Eq)]
+impl fmt::Display for NormalizeMode {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            NormalizeMode::Exact => write!(f, "exact"),
+            NormalizeMode::Renamed => write!(f, "renamed"),
+            NormalizeMode::Strings => write!(f, "strings"),
+        }
+    }
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
 pub enum TokenType {
     If,
     Else,
@@ -119,25 +225,70 @@ impl TokenType {
     }
 }
 
-#[derive(Debug, Clone)]
+#[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct Token {
     pub token_type: TokenType,
+    /// 1-based line of the first character
     pub line: usize,
+    /// 1-based column of the first character, counted in Unicode scalar values
     pub column: usize,
     pub text: String,
+    /// Line of the position just past the last character
+    #[serde(default)]
+    pub end_line: usize,
+    /// Column of the position just past the last character (exclusive end)
+    #[serde(default)]
+    pub end_column: usize,
+    /// 0-based byte offset of the first character
+    #[serde(default)]
+    pub offset: usize,
+    /// Byte offset just past the last character
+    #[serde(default)]
+    pub end_offset: usize,
+}
+
+impl Token {
+    /// Text this token would have under [`NormalizeMode::Renamed`], whatever mode it was lexed in
+    pub(crate) fn renamed_text(&self, language: Language) -> &str {
+        match &self.token_type {
+            TokenType::Identifier(word) if !language.is_keyword(word) => NormalizeMode::IDENT,
+            TokenType::Literal(_) => NormalizeMode::LIT,
+            _ => &self.text,
+        }
+    }
 }
 
 pub struct Tokenizer {
     source: Vec<char>,
     position: usize,
+    /// Byte offset of `position` in the original source
+    offset: usize,
     line: usize,
     column: usize,
-    _language: Language,
+    language: Language,
+    normalize: NormalizeMode,
 }
 
 impl Tokenizer {
     pub fn new(source: &str, language: Language) -> Self {
-        Self { source: source.chars().collect(), position: 0, line: 1, column: 1, _language: language }
+        Self {
+            source: source.chars().collect(),
+            position: 0,
+            offset: 0,
+            line: 1,
+            column: 1,
+            language,
+            normalize: NormalizeMode::Exact,
+        }
+    }
+
+    /// Emit a normalized token stream
+    ///
+    /// Only the `text` of affected tokens is replaced; `token_type`, `line` and `column` keep
+    /// pointing at the original source so positions still map back to it.
+    pub fn with_normalization(mut self, mode: NormalizeMode) -> Self {
+        self.normalize = mode;
+        self
     }
 
     pub fn tokenize(mut self) -> Result<Vec<Token>> {
@@ -153,30 +304,19 @@ impl Tokenizer {
     }
 
     fn next_token(&mut self) -> Result<Option<Token>> {
-        let start_line = self.line;
-        let start_column = self.column;
+        let start = (self.line, self.column, self.offset);
         let start_pos = self.position;
         let ch = self.current()?;
 
         if ch.is_whitespace() {
             if ch == '\n' {
                 self.advance();
-                return Ok(Some(Token {
-                    token_type: TokenType::Newline,
-                    line: start_line,
-                    column: start_column,
-                    text: "\n".to_string(),
-                }));
+                return Ok(Some(self.token(TokenType::Newline, start, "\n".to_string())));
             } else {
                 while !self.is_at_end() && self.current()?.is_whitespace() && self.current()? != '\n' {
                     self.advance();
                 }
-                return Ok(Some(Token {
-                    token_type: TokenType::Whitespace,
-                    line: start_line,
-                    column: start_column,
-                    text: " ".to_string(),
-                }));
+                return Ok(Some(self.token(TokenType::Whitespace, start, " ".to_string())));
             }
         }
 
@@ -185,12 +325,7 @@ impl Tokenizer {
                 while !self.is_at_end() && self.current()? != '\n' {
                     self.advance();
                 }
-                return Ok(Some(Token {
-                    token_type: TokenType::Comment,
-                    line: start_line,
-                    column: start_column,
-                    text: "//".to_string(),
-                }));
+                return Ok(Some(self.token(TokenType::Comment, start, "//".to_string())));
             } else if self.peek() == Some('*') {
                 self.advance();
                 self.advance();
@@ -202,15 +337,33 @@ impl Tokenizer {
                     }
                     self.advance();
                 }
-                return Ok(Some(Token {
-                    token_type: TokenType::Comment,
-                    line: start_line,
-                    column: start_column,
-                    text: "/**/".to_string(),
-                }));
+                return Ok(Some(self.token(TokenType::Comment, start, "/**/".to_string())));
             }
         }
 
+        // Go raw strings and JavaScript template literals span lines and have no escapes, so a
+        // `//` or `/*` inside one is text rather than the start of a comment
+        if ch == '`'
+            && matches!(
+                self.language,
+                Language::Go | Language::JavaScript | Language::TypeScript
+            )
+        {
+            self.advance();
+            while !self.is_at_end() && self.current()? != '`' {
+                self.advance();
+            }
+            if !self.is_at_end() {
+                self.advance();
+            }
+            let text: String = self.source[start_pos..self.position].iter().collect();
+            return Ok(Some(self.token(
+                TokenType::Literal(text.clone()),
+                start,
+                self.normalize_literal(text),
+            )));
+        }
+
         if ch == '"' || ch == '\'' {
             let quote = ch;
             self.advance();
@@ -228,12 +381,11 @@ impl Tokenizer {
                 self.advance();
             }
             let text: String = self.source[start_pos..self.position].iter().collect();
-            return Ok(Some(Token {
-                token_type: TokenType::Literal(text.clone()),
-                line: start_line,
-                column: start_column,
-                text,
-            }));
+            return Ok(Some(self.token(
+                TokenType::Literal(text.clone()),
+                start,
+                self.normalize_literal(text),
+            )));
         }
 
         if ch.is_ascii_digit() {
@@ -243,12 +395,11 @@ impl Tokenizer {
                 self.advance();
             }
             let text: String = self.source[start_pos..self.position].iter().collect();
-            return Ok(Some(Token {
-                token_type: TokenType::Literal(text.clone()),
-                line: start_line,
-                column: start_column,
-                text,
-            }));
+            return Ok(Some(self.token(
+                TokenType::Literal(text.clone()),
+                start,
+                self.normalize_literal(text),
+            )));
         }
 
         if ch.is_alphabetic() || ch == '_' {
@@ -257,7 +408,11 @@ impl Tokenizer {
             }
             let text: String = self.source[start_pos..self.position].iter().collect();
             let token_type = self.classify_keyword(&text);
-            return Ok(Some(Token { token_type, line: start_line, column: start_column, text }));
+            let text = match token_type {
+                TokenType::Identifier(_) => self.normalize_identifier(text),
+                _ => text,
+            };
+            return Ok(Some(self.token(token_type, start, text)));
         }
 
         let token_type = match ch {
@@ -307,8 +462,14 @@ impl Tokenizer {
                 self.advance();
                 TokenType::LogicalOr
             }
+            '~' if self.language == Language::Go => {
+                // Go's approximation element (`~int`) never combines with a neighbouring
+                // operator, so `int|~string` lexes the same as `int | ~string`
+                self.advance();
+                TokenType::Operator("~".to_string())
+            }
             _ => {
-                let op_chars = "+-*/%=<>!&|^~";
+                let op_chars = if self.language == Language::Go { "+-*/%=<>!&|^" } else { "+-*/%=<>!&|^~" };
                 if op_chars.contains(ch) {
                     while !self.is_at_end() && op_chars.contains(self.current()?) {
                         self.advance();
@@ -323,14 +484,34 @@ impl Tokenizer {
         };
 
         let text: String = self.source[start_pos..self.position].iter().collect();
-        Ok(Some(Token { token_type, line: start_line, column: start_column, text }))
+        Ok(Some(self.token(token_type, start, text)))
+    }
+
+    /// Build a token that starts at `start` (line, column, byte offset) and ends here
+    fn token(&self, token_type: TokenType, start: (usize, usize, usize), text: String) -> Token {
+        let (line, column, offset) = start;
+        Token {
+            token_type,
+            line,
+            column,
+            text,
+            end_line: self.line,
+            end_column: self.column,
+            offset,
+            end_offset: self.offset,
+        }
     }
 
     fn classify_keyword(&self, word: &str) -> TokenType {
+        // A keyword of one language is a plain name in another: `match` and `loop` are common
+        // Go identifiers and must not count as decision points there
+        if !self.language.is_keyword(word) {
+            return TokenType::Identifier(word.to_string());
+        }
+
         match word {
             "if" => TokenType::If,
             "else" => TokenType::Else,
-            "elif" => TokenType::ElseIf,
             "while" => TokenType::While,
             "for" => TokenType::For,
             "loop" => TokenType::Loop,
@@ -343,6 +524,21 @@ impl Tokenizer {
         }
     }
 
+    fn normalize_identifier(&self, text: String) -> String {
+        match self.normalize {
+            NormalizeMode::Renamed if !self.language.is_keyword(&text) => NormalizeMode::IDENT.to_string(),
+            _ => text,
+        }
+    }
+
+    fn normalize_literal(&self, text: String) -> String {
+        match self.normalize {
+            NormalizeMode::Renamed => NormalizeMode::LIT.to_string(),
+            NormalizeMode::Strings if text.starts_with(['"', '\'', '`']) => NormalizeMode::STR.to_string(),
+            NormalizeMode::Strings | NormalizeMode::Exact => text,
+        }
+    }
+
     fn current(&self) -> Result<char> {
         self.source
             .get(self.position)
@@ -363,6 +559,7 @@ impl Tokenizer {
                 self.column += 1;
             }
             self.position += 1;
+            self.offset += ch.len_utf8();
         }
     }
 
@@ -406,6 +603,15 @@ mod tests {
         assert_eq!(decision_count, 4);
     }
 
+    #[test]
+    fn test_other_languages_keywords_are_identifiers() {
+        let source = "match := re.Match(s)\nfor loop := 0; loop < n; loop++ {}";
+        let tokens = Tokenizer::new(source, Language::Go).tokenize().unwrap();
+        let decision_count = tokens.iter().filter(|t| t.token_type.is_decision_point()).count();
+        assert_eq!(decision_count, 1);
+        assert_eq!(tokens[0].token_type, TokenType::Identifier("match".to_string()));
+    }
+
     #[test]
     fn test_comments() {
         let source = r#"
@@ -425,6 +631,18 @@ let x = 5;
         assert_eq!(comments.len(), 2);
     }
 
+    #[test]
+    fn test_raw_strings_hide_comment_markers() {
+        let source = "url := `http://example.com/*`\nx := 1 // trailing\n";
+        let tokens = Tokenizer::new(source, Language::Go).tokenize().unwrap();
+
+        let comments = tokens.iter().filter(|t| t.token_type == TokenType::Comment).count();
+        assert_eq!(comments, 1);
+        let raw = tokens.iter().find(|t| t.text.starts_with('`')).unwrap();
+        assert_eq!(raw.token_type, TokenType::Literal("`http://example.com/*`".to_string()));
+        assert!(tokens.iter().any(|t| t.text == "x" && t.line == 2));
+    }
+
     #[test]
     fn test_strings() {
         let source = r#"let s = "hello \"world\""; let c = 'x';"#;
@@ -438,4 +656,115 @@ let x = 5;
 
         assert!(literals.len() >= 2);
     }
+
+    #[test]
+    fn test_go_type_parameters() {
+        let source = "func Keys[K comparable, V int|~string](m map[K]V) []K {}";
+        let tokens = Tokenizer::new(source, Language::Go).tokenize().unwrap();
+        let texts: Vec<_> = tokens
+            .iter()
+            .filter(|t| t.token_type.is_significant())
+            .map(|t| t.text.as_str())
+            .collect();
+
+        assert_eq!(
+            texts[..12],
+            [
+                "func",
+                "Keys",
+                "[",
+                "K",
+                "comparable",
+                ",",
+                "V",
+                "int",
+                "|",
+                "~",
+                "string",
+                "]"
+            ]
+        );
+        assert!(!tokens.iter().any(|t| t.token_type.is_decision_point()));
+    }
+
+    #[test]
+    fn test_renamed_normalization() {
+        let source = r#"trimmed = strings.TrimSpace("x", 42)
+return trimmed"#;
+        let tokens = Tokenizer::new(source, Language::Go)
+            .with_normalization(NormalizeMode::Renamed)
+            .tokenize()
+            .unwrap();
+
+        let texts: Vec<_> = tokens
+            .iter()
+            .filter(|t| t.token_type.is_significant())
+            .map(|t| t.text.as_str())
+            .collect();
+
+        assert_eq!(
+            texts,
+            vec![
+                "IDENT", "=", "IDENT", ".", "IDENT", "(", "LIT", ",", "LIT", ")", "return", "IDENT"
+            ]
+        );
+
+        let literal = tokens.iter().find(|t| t.text == "LIT").unwrap();
+        assert_eq!(literal.token_type, TokenType::Literal("\"x\"".to_string()));
+        assert_eq!((literal.line, literal.column), (1, 29));
+    }
+
+    #[test]
+    fn test_strings_normalization() {
+        let source = "greet(\"Hello\", 'x', `raw`, 42, name)";
+        let tokens = Tokenizer::new(source, Language::Go)
+            .with_normalization(NormalizeMode::Strings)
+            .tokenize()
+            .unwrap();
+
+        let texts: Vec<_> = tokens
+            .iter()
+            .filter(|t| t.token_type.is_significant())
+            .map(|t| t.text.as_str())
+            .collect();
+
+        assert_eq!(
+            texts,
+            vec!["greet", "(", "STR", ",", "STR", ",", "STR", ",", "42", ",", "name", ")"]
+        );
+
+        let literal = tokens.iter().find(|t| t.text == "STR").unwrap();
+        assert_eq!(literal.token_type, TokenType::Literal("\"Hello\"".to_string()));
+        assert_eq!((literal.column, literal.end_column), (7, 14));
+    }
+
+    #[test]
+    fn test_exact_mode_keeps_text() {
+        let source = "let total = count + 1;";
+        let exact = Tokenizer::new(source, Language::Rust).tokenize().unwrap();
+        let explicit = Tokenizer::new(source, Language::Rust)
+            .with_normalization(NormalizeMode::Exact)
+            .tokenize()
+            .unwrap();
+
+        let texts = |tokens: &[Token]| tokens.iter().map(|t| t.text.clone()).collect::<Vec<_>>();
+        assert_eq!(texts(&exact), texts(&explicit));
+        assert!(exact.iter().any(|t| t.text == "total"));
+    }
+
+    #[test]
+    fn test_token_positions() {
+        let source = "s := \"héllo\"\nx := 1";
+        let tokens = Tokenizer::new(source, Language::Go).tokenize().unwrap();
+
+        let literal = tokens.iter().find(|t| t.text.starts_with('"')).unwrap();
+        assert_eq!((literal.line, literal.column), (1, 6));
+        assert_eq!((literal.end_line, literal.end_column), (1, 13));
+        assert_eq!((literal.offset, literal.end_offset), (5, 13));
+        assert_eq!(&source[literal.offset..literal.end_offset], "\"héllo\"");
+
+        let x = tokens.iter().find(|t| t.text == "x").unwrap();
+        assert_eq!((x.line, x.column, x.end_column), (2, 1, 2));
+        assert_eq!(x.offset, 14);
+    }
 }
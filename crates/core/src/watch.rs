@@ -0,0 +1,190 @@
+use crate::Result;
+use crate::loader::FileLoader;
+use std::collections::BTreeMap;
+use std::fs;
+use std::path::{Path, PathBuf};
+use std::thread;
+use std::time::{Duration, SystemTime};
+
+/// Default time between polls for changes
+pub const DEFAULT_POLL_INTERVAL: Duration = Duration::from_millis(250);
+
+/// Default quiet period after a change before it is reported
+pub const DEFAULT_DEBOUNCE: Duration = Duration::from_millis(200);
+
+/// Modification time and size of every watched file at one point in time
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct Snapshot {
+    stamps: BTreeMap<PathBuf, (Option<SystemTime>, u64)>,
+}
+
+impl Snapshot {
+    /// Stamp the given files; files that cannot be read are left out
+    pub fn capture(paths: &[PathBuf]) -> Self {
+        let stamps = paths
+            .iter()
+            .filter_map(|path| {
+                let meta = fs::metadata(path).ok()?;
+                Some((path.clone(), (meta.modified().ok(), meta.len())))
+            })
+            .collect();
+        Self { stamps }
+    }
+
+    /// Files added, modified, or removed in `newer`, sorted by path
+    pub fn changed(&self, newer: &Snapshot) -> Vec<PathBuf> {
+        let mut changed: Vec<PathBuf> = newer
+            .stamps
+            .iter()
+            .filter(|(path, stamp)| self.stamps.get(*path) != Some(stamp))
+            .map(|(path, _)| path.clone())
+            .collect();
+        changed.extend(
+            self.stamps
+                .keys()
+                .filter(|path| !newer.stamps.contains_key(*path))
+                .cloned(),
+        );
+        changed.sort();
+        changed
+    }
+}
+
+/// Polls a file or directory and reports when its source files change
+///
+/// The same loader filters apply as for analysis, so ignored and vendored files never trigger
+/// a run. Polling keeps the watcher dependency-free and works the same on every platform and
+/// on network mounts. Editors often write a file several times per save, so a change is only
+/// reported once the tree has been quiet for the debounce period.
+pub struct Watcher {
+    loader: FileLoader,
+    root: PathBuf,
+    poll_interval: Duration,
+    debounce: Duration,
+    snapshot: Snapshot,
+}
+
+impl Watcher {
+    /// Watch `root`, taking the current state as the starting point
+    pub fn new<P: AsRef<Path>>(loader: FileLoader, root: P) -> Result<Self> {
+        let root = root.as_ref().to_path_buf();
+        let snapshot = Snapshot::capture(&loader.paths(&root)?);
+        Ok(Self { loader, root, poll_interval: DEFAULT_POLL_INTERVAL, debounce: DEFAULT_DEBOUNCE, snapshot })
+    }
+
+    pub fn with_poll_interval(mut self, interval: Duration) -> Self {
+        self.poll_interval = interval;
+        self
+    }
+
+    pub fn with_debounce(mut self, debounce: Duration) -> Self {
+        self.debounce = debounce;
+        self
+    }
+
+    /// Check once for changes since the last reported state, without waiting
+    pub fn poll(&mut self) -> Result<Vec<PathBuf>> {
+        let current = self.capture()?;
+        let changed = self.snapshot.changed(&current);
+        self.snapshot = current;
+        Ok(changed)
+    }
+
+    /// Block until files change and then stay unchanged for the debounce period
+    ///
+    /// Returns every file that changed since the previous call, sorted by path.
+    pub fn wait(&mut self) -> Result<Vec<PathBuf>> {
+        loop {
+            thread::sleep(self.poll_interval);
+            let mut current = self.capture()?;
+            if self.snapshot.changed(&current).is_empty() {
+                continue;
+            }
+
+            loop {
+                thread::sleep(self.debounce);
+                let settled = self.capture()?;
+                if settled == current {
+                    break;
+                }
+                current = settled;
+            }
+
+            let changed = self.snapshot.changed(&current);
+            self.snapshot = current;
+            if !changed.is_empty() {
+                return Ok(changed);
+            }
+        }
+    }
+
+    fn capture(&self) -> Result<Snapshot> {
+        Ok(Snapshot::capture(&self.loader.paths(&self.root)?))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tempfile::TempDir;
+
+    #[test]
+    fn test_snapshot_changes() {
+        let dir = TempDir::new().unwrap();
+        let a = dir.path().join("a.go");
+        let b = dir.path().join("b.go");
+        fs::write(&a, "package a\n").unwrap();
+        fs::write(&b, "package b\n").unwrap();
+        let before = Snapshot::capture(&[a.clone(), b.clone()]);
+
+        fs::write(&a, "package a\n\nfunc A() {}\n").unwrap();
+        fs::remove_file(&b).unwrap();
+        let c = dir.path().join("c.go");
+        fs::write(&c, "package c\n").unwrap();
+        let after = Snapshot::capture(&[a.clone(), b.clone(), c.clone()]);
+
+        assert_eq!(before.changed(&after), vec![a, b, c]);
+        assert!(after.changed(&after).is_empty());
+    }
+
+    #[test]
+    fn test_poll_ignores_filtered_files() {
+        let dir = TempDir::new().unwrap();
+        let main = dir.path().join("main.go");
+        fs::write(&main, "package main\n").unwrap();
+        let mut watcher = Watcher::new(FileLoader::new(), dir.path()).unwrap();
+
+        fs::write(dir.path().join("notes.txt"), "todo").unwrap();
+        fs::create_dir_all(dir.path().join("vendor")).unwrap();
+        fs::write(dir.path().join("vendor").join("dep.go"), "package dep\n").unwrap();
+        assert!(watcher.poll().unwrap().is_empty());
+
+        fs::write(&main, "package main\n\nfunc main() {}\n").unwrap();
+        assert_eq!(watcher.poll().unwrap(), vec![main]);
+        assert!(watcher.poll().unwrap().is_empty());
+    }
+
+    #[test]
+    fn test_wait_returns_settled_changes() {
+        let dir = TempDir::new().unwrap();
+        let main = dir.path().join("main.go");
+        fs::write(&main, "package main\n").unwrap();
+        let mut watcher = Watcher::new(FileLoader::new(), dir.path())
+            .unwrap()
+            .with_poll_interval(Duration::from_millis(10))
+            .with_debounce(Duration::from_millis(50));
+
+        let writer = {
+            let main = main.clone();
+            thread::spawn(move || {
+                for body in ["func a() {}\n", "func a() {}\nfunc b() {}\n"] {
+                    thread::sleep(Duration::from_millis(20));
+                    fs::write(&main, format!("package main\n\n{body}")).unwrap();
+                }
+            })
+        };
+
+        assert_eq!(watcher.wait().unwrap(), vec![main]);
+        writer.join().unwrap();
+    }
+}
@@ -0,0 +1,21 @@
+package tally
+
+import "fmt"
+
+// Print writes each word with how often it appears, skipping blanks.
+func Print(words []string) {
+	counts := make(map[string]int)
+	order := make([]string, 0, len(words))
+	for _, w := range words {
+		if w == "" || w == " " {
+			continue
+		}
+		if counts[w] == 0 {
+			order = append(order, w)
+		}
+		counts[w]++
+	}
+	for _, w := range order {
+		fmt.Printf("%s: %d\n", w, counts[w])
+	}
+}
@@ -0,0 +1,25 @@
+package tally_test
+
+import "fmt"
+
+// ExamplePrint spells out what Print does, so it is measured and copied like any function.
+func ExamplePrint() {
+	words := []string{"go", "", "vet", "go"}
+	counts := make(map[string]int)
+	order := make([]string, 0, len(words))
+	for _, w := range words {
+		if w == "" || w == " " {
+			continue
+		}
+		if counts[w] == 0 {
+			order = append(order, w)
+		}
+		counts[w]++
+	}
+	for _, w := range order {
+		fmt.Printf("%s: %d\n", w, counts[w])
+	}
+	// Output:
+	// go: 2
+	// vet: 1
+}
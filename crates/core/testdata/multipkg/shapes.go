@@ -0,0 +1,28 @@
+// Package shapes measures simple figures.
+package shapes
+
+import "errors"
+
+type Rect struct {
+	Width, Height float64
+}
+
+// Area returns the area of r, or an error when a side is negative.
+func Area(r Rect) (float64, error) {
+	if r.Width < 0 || r.Height < 0 {
+		return 0, errors.New("negative side")
+	}
+	return r.Width * r.Height, nil
+}
+
+// Scale multiplies both sides of every figure by factor, skipping empty ones.
+func Scale(rects []Rect, factor float64) []Rect {
+	out := make([]Rect, 0, len(rects))
+	for _, r := range rects {
+		if r.Width == 0 || r.Height == 0 {
+			continue
+		}
+		out = append(out, Rect{Width: r.Width * factor, Height: r.Height * factor})
+	}
+	return out
+}
@@ -0,0 +1,9 @@
+package shapes
+
+import "testing"
+
+func TestAreaRejectsNegative(t *testing.T) {
+	if _, err := Area(Rect{Width: -1, Height: 2}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,33 @@
+package shapes_test
+
+import (
+	"testing"
+
+	"example.com/shapes"
+)
+
+// scaled is a copy of shapes.Scale used to check it, duplication included.
+func scaled(rects []shapes.Rect, factor float64) []shapes.Rect {
+	out := make([]shapes.Rect, 0, len(rects))
+	for _, r := range rects {
+		if r.Width == 0 || r.Height == 0 {
+			continue
+		}
+		out = append(out, shapes.Rect{Width: r.Width * factor, Height: r.Height * factor})
+	}
+	return out
+}
+
+func TestScale(t *testing.T) {
+	rects := []shapes.Rect{{Width: 1, Height: 2}, {Width: 0, Height: 3}}
+	got := shapes.Scale(rects, 2)
+	want := scaled(rects, 2)
+	if len(got) != len(want) {
+		t.Fatalf("got %d figures, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("figure %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}